@@ -3,13 +3,13 @@ package agent
 //go:generate sh generate_sha.sh
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
-	"net"
 	"net/http"
+	"strings"
 	"sync"
-	"time"
 
 	"github.com/bearer/go-agent/config"
 	"github.com/bearer/go-agent/events"
@@ -43,15 +43,37 @@ type Agent struct {
 	transports    transportMap
 	error         error
 	sender        *proxy.Sender
+	aggregator    *interception.AggregationProvider
+	latency       *interception.LatencyProvider
+	monitor       *selfMonitor
+	closeHooks    []func() error
+}
+
+// CloseError is the error returned by Agent.Close when some of the hooks
+// registered with OnClose failed.
+type CloseError struct {
+	Errors []error
+}
+
+// Error implements the error interface.
+func (e CloseError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return `closing agent: ` + strings.Join(messages, `; `)
 }
 
 // New constructs a new Agent and returns it.
 //
 // In most usage scenarios, you will only use a single Agent in a given application,
 // and pass a config.WithLogger(some *io.Writer) config.Option.
+//
+// Besides the http.DefaultClient, New decorates the clients passed with the
+// WithAutoDecorateClients Option.
 func New(secretKey string, opts ...Option) *Agent {
 	a := &Agent{
-		baseTransport: unwrapTransport(http.DefaultClient.Transport),
+		baseTransport: interception.UnwrapTransport(http.DefaultClient.Transport),
 		dispatcher:    events.NewDispatcher(),
 		SecretKey:     secretKey,
 		transports:    make(transportMap),
@@ -77,24 +99,126 @@ func New(secretKey string, opts ...Option) *Agent {
 	a.sender = proxy.NewSender(c.ReportOutstanding, c.ReportEndpoint, Version,
 		c.SecretKey(), c.Environment(),
 		a.DefaultTransport(), a.Logger())
+	a.sender.Authorization = c.Authorization()
+	a.sender.RateLimit = c.ReportRateLimit()
+	a.sender.StopGracePeriod = c.StopGracePeriod()
+	a.sender.Format = c.ReportFormat()
+	a.sender.MirrorEndpoint = c.MirrorEndpoint()
+	a.sender.MeasureSendDelay = c.SendDelayMeasurement()
 	go a.sender.Start()
 
-	dcrp := interception.DCRProvider{DCRs: a.config.DataCollectionRules()}
-	a.dispatcher.AddProviders(interception.TopicConnect, events.ListenerProviderFunc(a.Provider), dcrp)
-	a.dispatcher.AddProviders(interception.TopicRequest, dcrp)
-	a.dispatcher.AddProviders(interception.TopicResponse, dcrp)
-	a.dispatcher.AddProviders(interception.TopicBodies, interception.BodyParsingProvider{}, dcrp)
-	a.dispatcher.AddProviders(interception.TopicReport,
-		dcrp,
-		interception.SanitizationProvider{
-			SensitiveKeys:    a.config.SensitiveKeys(),
-			SensitiveRegexps: a.config.SensitiveRegexps(),
+	dcrp := interception.DCRProvider{
+		DCRs:                 a.config.DataCollectionRules(),
+		Precedence:           c.RulePrecedence(),
+		MaxLogLevel:          c.MaxLogLevel(),
+		BodyCaptureDenyHosts: c.BodyCaptureDenyHosts(),
+		BodyCaptureStatuses:  c.BodyCaptureStatusCodes(),
+		BodyPreview:          c.BodyPreview(),
+		MaxReportedRules:     c.MaxReportedRules(),
+	}
+	if c.MatchExplanations() {
+		dcrp.Explain = func(rule *interception.DataCollectionRule, explanation string) {
+			a.LogTrace(`data collection rule triggered`, map[string]interface{}{
+				`filterHash`:  rule.FilterHash,
+				`explanation`: explanation,
+			})
+		}
+	}
+	pipeline := interception.Pipeline{
+		DCR:     dcrp,
+		Connect: []events.ListenerProvider{events.ListenerProviderFunc(a.Provider)},
+		BodyParser: interception.BodyParsingProvider{
+			Digests:         c.BodyDigests(),
+			Lazy:            c.LazyBodyParsing(),
+			ContentSniffing: c.ContentSniffing(),
+			QueryAsBody:     c.QueryAsBody(),
+			ShapeWarner:     interception.NewShapeWarner(a.LogWarn),
 		},
-		interception.ProxyProvider{Sender: a.sender},
-	)
+		Tracer: c.SpanTracer(),
+		Sender: a.sender,
+	}
+	if c.DetectAnomalies() {
+		pipeline.Request = append(pipeline.Request, interception.AnomalyProvider{})
+	}
+	if hosts := c.HostStatusSuppression(); len(hosts) > 0 {
+		pipeline.Response = append(pipeline.Response, interception.StatusSuppressionProvider{Hosts: hosts})
+	}
+	if limit, skip := c.MaxConcurrentHashes(); limit > 0 {
+		pipeline.BodyParser.HashLimiter = interception.NewHashLimiter(limit, skip)
+	}
+	hosts := interception.NewHostRegistry(c.MaxHosts())
+	a.latency = interception.NewLatencyProvider(interception.DefaultLatencyHosts)
+	a.latency.TrackHosts(hosts)
+	reportProviders := []events.ListenerProvider{a.latency}
+	if success, errorRate := c.SampleRates(); success < 1 || errorRate < 1 {
+		reportProviders = append(reportProviders, interception.SamplingProvider{
+			SuccessRate: success,
+			ErrorRate:   errorRate,
+			Key:         c.SamplingKey(),
+		})
+	}
+	if n := c.ShapeDiscoveryMode(); n > 0 {
+		shapes := interception.NewShapeDiscoveryProvider(n)
+		shapes.TrackHosts(hosts)
+		reportProviders = append(reportProviders, shapes)
+	}
+	if header := c.RetryCountHeader(); header != `` {
+		reportProviders = append(reportProviders, interception.RetryCountProvider{Header: header})
+	}
+	reportProviders = append(reportProviders, interception.CacheProvider{Header: c.CacheIndicatorHeader()})
+	if headers := c.EnrichmentHeaders(); len(headers) > 0 {
+		reportProviders = append(reportProviders, interception.EnrichmentProvider{Headers: headers})
+	}
+	if claims := c.JWTClaims(); len(claims) > 0 {
+		reportProviders = append(reportProviders, interception.JWTClaimsProvider{Claims: claims})
+	}
+	if classifier := c.ErrorClassifier(); classifier != nil {
+		reportProviders = append(reportProviders, interception.ErrorClassificationProvider{Classifier: classifier})
+	}
+	if c.RedactionDisabled() {
+		a.LogWarn(`sensitive data redaction disabled: reports will include all values`, nil)
+	}
+	sensitiveKeys, sensitiveRegexps := c.SensitiveKeys(), c.SensitiveRegexps()
+	strategy, hashKey := c.RedactionStrategy()
+	if c.CoalescedSanitization() {
+		sensitiveKeys = interception.CoalesceRegexps(sensitiveKeys)
+		sensitiveRegexps = interception.CoalesceRegexps(sensitiveRegexps)
+	}
+	pipeline.Report = reportProviders
+	pipeline.Sanitizer = interception.SanitizationProvider{
+		SensitiveKeys:      sensitiveKeys,
+		SensitiveRegexps:   sensitiveRegexps,
+		MaxBodyDepth:       c.MaxBodyDepth(),
+		MaxQueryParams:     c.MaxQueryParams(),
+		ExcludedBodyFields: c.ExcludedBodyFields(),
+		Strict:             c.StrictSanitization(),
+		Strategy:           strategy,
+		HashKey:            hashKey,
+	}
+	if w := c.RedactionAudit(); w != nil {
+		pipeline.Sanitizer.Audit = interception.NewRedactionAuditor(w)
+	}
+	if window := c.AggregationWindow(); window > 0 {
+		a.aggregator = interception.NewAggregationProvider(a.sender, window)
+		pipeline.Aggregator = a.aggregator
+	}
+	pipeline.Wire(a.dispatcher)
+	if td, ok := a.dispatcher.(events.TimeoutDispatcher); ok {
+		for topic, timeout := range c.ListenerTimeouts() {
+			td.SetTimeout(topic, timeout)
+		}
+	}
+
+	if c.GlobalInstrumentation() {
+		http.DefaultTransport = a.Decorate(http.DefaultTransport)
+		a.DecorateClientTransports(http.DefaultClient)
+	}
+	a.DecorateClientTransports(c.AutoDecorateClients()...)
 
-	http.DefaultTransport = a.Decorate(http.DefaultTransport)
-	a.DecorateClientTransports(http.DefaultClient)
+	if interval := c.SelfMonitoring(); interval > 0 {
+		a.monitor = newSelfMonitor(interval, func() uint { return a.sender.Stats().Handled })
+		go a.monitor.Start()
+	}
 
 	return a
 }
@@ -106,6 +230,10 @@ func (a *Agent) DefaultTransport() http.RoundTripper {
 }
 
 // Decorate wraps a http.RoundTripper with Bearer instrumentation.
+//
+// Without global instrumentation, a nil http.RoundTripper stands for the
+// http.DefaultTransport without the instrumentation any other Agent may have
+// added to it.
 func (a *Agent) Decorate(rt http.RoundTripper) http.RoundTripper {
 	if a.error != nil {
 		return rt
@@ -113,6 +241,9 @@ func (a *Agent) Decorate(rt http.RoundTripper) http.RoundTripper {
 
 	if rt == nil {
 		rt = http.DefaultTransport
+		if !a.config.GlobalInstrumentation() {
+			rt = interception.UnwrapTransport(rt)
+		}
 	}
 
 	if a.transports == nil {
@@ -130,6 +261,16 @@ func (a *Agent) Decorate(rt http.RoundTripper) http.RoundTripper {
 	var wrapped = &interception.RoundTripper{
 		Dispatcher: a.dispatcher,
 		Underlying: rt,
+		Paused:     a.IsPaused,
+
+		IgnoredHosts: a.config.IgnoredHosts(),
+
+		MaxInstrumentationLatency: a.config.MaxInstrumentationLatency(),
+		Warn:                      a.LogWarn,
+		CaptureCallerStack:        a.config.CaptureCallerStack(),
+		MaxRequestBodySize:        a.config.MaxRequestBodySize(),
+		MaxResponseBodySize:       a.config.MaxResponseBodySize(),
+		BufferStreams:             a.config.StreamBuffering(),
 	}
 
 	a.transports[rt] = wrapped
@@ -148,6 +289,56 @@ func (a *Agent) DecorateClientTransports(clients ...*http.Client) {
 	}
 }
 
+// LatencyStats returns the latency percentiles of the API calls performed
+// since the agent started, per host. Beyond the hosts set by WithMaxHosts, the
+// latencies of the least recently called hosts are dropped.
+func (a *Agent) LatencyStats() map[string]interception.LatencySummary {
+	if a.latency == nil {
+		return map[string]interception.LatencySummary{}
+	}
+	return a.latency.Stats()
+}
+
+// Pause temporarily stops reporting, without tearing down the agent: while
+// paused, API calls pass through decorated transports without instrumentation,
+// and new reports are dropped.
+func (a *Agent) Pause() {
+	if a.sender != nil {
+		a.sender.Pause()
+	}
+}
+
+// Resume restarts reporting after Pause.
+func (a *Agent) Resume() {
+	if a.sender != nil {
+		a.sender.Resume()
+	}
+}
+
+// IsPaused checks whether reporting is paused.
+func (a *Agent) IsPaused() bool {
+	return a.sender != nil && a.sender.IsPaused()
+}
+
+// Verify checks the secret key against the Bearer platform, with a lightweight
+// authenticated request, returning config.ErrSecretKeyRejected, possibly
+// wrapped, if the platform rejects it.
+//
+// Unlike the format check performed by New, it allows applications to validate
+// their credentials at startup, as a well-formed key may still be invalid.
+func (a *Agent) Verify(ctx context.Context) error {
+	if a.config == nil {
+		if a.error != nil {
+			return a.error
+		}
+		return errors.New(`agent not configured`)
+	}
+	if a.config.fetcher == nil {
+		return errors.New(`remote configuration disabled`)
+	}
+	return a.config.fetcher.Verify(ctx)
+}
+
 // Error returns any error that has cause the agent to shutdown. If there has
 // been no error then it returns nil
 func (a *Agent) Error() error {
@@ -159,22 +350,55 @@ func (a *Agent) setError(err error) {
 	log.Println(err)
 }
 
-// Close shuts down the agent
+// OnClose registers a hook run by Close, after the agent has stopped, e.g. to
+// flush custom sinks. Hooks run in the reverse order of their registration.
+func (a *Agent) OnClose(hook func() error) {
+	a.m.Lock()
+	defer a.m.Unlock()
+	a.closeHooks = append(a.closeHooks, hook)
+}
+
+// runCloseHooks runs the OnClose hooks once, collecting their errors.
+func (a *Agent) runCloseHooks() error {
+	a.m.Lock()
+	hooks := a.closeHooks
+	a.closeHooks = nil
+	a.m.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i](); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return CloseError{Errors: errs}
+	}
+	return nil
+}
+
+// Close shuts down the agent, then runs the hooks registered with OnClose.
 func (a *Agent) Close() error {
 	if a.config.IsDisabled() {
-		return nil
+		return a.runCloseHooks()
 	}
 
 	a.LogTrace("Bearer agent stopping", nil)
 
 	count := uint(0)
+	if a.monitor != nil {
+		a.monitor.Stop()
+	}
+	if a.aggregator != nil {
+		a.aggregator.Close()
+	}
 	if a.sender != nil {
 		a.sender.Stop()
-		count = a.sender.Counter
+		count = a.sender.Stats().Handled
 	}
 
 	a.LogTrace(fmt.Sprintf(`End of Bearer agent operation with %d API calls logged`, count), nil)
-	return nil
+	return a.runCloseHooks()
 }
 
 // Provider provides the default agent listeners:
@@ -191,31 +415,3 @@ func (*Agent) Provider(e events.Event) []events.Listener {
 
 	return l
 }
-
-func unwrapTransport(rt http.RoundTripper) http.RoundTripper {
-	for {
-		if base, ok := rt.(*interception.RoundTripper); ok {
-			rt = base.Underlying
-			continue
-		}
-		break
-	}
-	// If the underlying transport, some other package may modify it, so we cannot
-	// rely on it being correct afterwards, so provide a default Transport matching
-	// the standard values of the http.DefaultTransport.
-	if rt == nil {
-		rt = &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			DialContext: (&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
-			ForceAttemptHTTP2:     true,
-			MaxIdleConns:          100,
-			IdleConnTimeout:       90 * time.Second,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-		}
-	}
-	return rt
-}
@@ -1,17 +1,24 @@
 package agent
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"regexp"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog"
 
+	"github.com/bearer/go-agent/config"
 	"github.com/bearer/go-agent/events"
 	"github.com/bearer/go-agent/interception"
 	"github.com/bearer/go-agent/proxy"
@@ -148,3 +155,330 @@ func TestAgent_Decorate(t *testing.T) {
 		t.Error(`expected round tripper not to be wrapped due to agent error`)
 	}
 }
+
+func TestAgent_PauseResume(t *testing.T) {
+	a := Agent{sender: &proxy.Sender{}, dispatcher: events.NewDispatcher()}
+	calls := 0
+	a.dispatcher.AddProviders(interception.TopicConnect, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+		calls++
+		return nil
+	}))
+	c := &http.Client{Transport: testRoundTripper{}}
+	a.DecorateClientTransports(c)
+
+	get := func() {
+		res, err := c.Get(`http://example.com`)
+		if err != nil || res == nil {
+			t.Fatalf(`unexpected call failure: %v`, err)
+		}
+	}
+
+	a.Pause()
+	if !a.IsPaused() {
+		t.Fatal(`agent not paused after Pause()`)
+	}
+	get()
+	if calls != 0 {
+		t.Errorf(`%d events dispatched while paused, expected none`, calls)
+	}
+
+	a.Resume()
+	if a.IsPaused() {
+		t.Fatal(`agent still paused after Resume()`)
+	}
+	get()
+	if calls != 1 {
+		t.Errorf(`%d events dispatched after resuming, expected 1`, calls)
+	}
+}
+
+func TestAgent_OnClose(t *testing.T) {
+	errFirst, errSecond := errors.New(`first`), errors.New(`second`)
+	var order []string
+	a := Agent{}
+	a.OnClose(func() error {
+		order = append(order, `first`)
+		return errFirst
+	})
+	a.OnClose(func() error {
+		order = append(order, `second`)
+		return errSecond
+	})
+
+	err := a.Close()
+	if expected := []string{`second`, `first`}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("hooks ran in order %v, expected %v", order, expected)
+	}
+	ce, ok := err.(CloseError)
+	if !ok {
+		t.Fatalf("Close() error = %v, expected a CloseError", err)
+	}
+	if expected := []error{errSecond, errFirst}; !reflect.DeepEqual(ce.Errors, expected) {
+		t.Errorf("Close() errors = %v, expected %v", ce.Errors, expected)
+	}
+	if expected := `closing agent: second; first`; err.Error() != expected {
+		t.Errorf("Close() error message = %q, expected %q", err.Error(), expected)
+	}
+
+	// Hooks only run once.
+	order = nil
+	if err := a.Close(); err != nil || len(order) != 0 {
+		t.Errorf("second Close() ran hooks %v, error %v", order, err)
+	}
+}
+
+func TestAgent_LatencyStats(t *testing.T) {
+	a := Agent{}
+	if stats := a.LatencyStats(); stats == nil || len(stats) != 0 {
+		t.Errorf("LatencyStats() on an unstarted agent = %v, expected an empty map", stats)
+	}
+
+	a.latency = interception.NewLatencyProvider(interception.DefaultLatencyHosts)
+	a.latency.Record(`example.com`, time.Millisecond)
+	if stats := a.LatencyStats(); stats[`example.com`].Count != 1 {
+		t.Errorf("LatencyStats() = %v, expected one call to example.com", stats)
+	}
+}
+
+func TestNew_Providers(t *testing.T) {
+	// A local configuration server keeps the agent enabled.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected map[events.Topic]int
+	}{
+		{`default`, nil, map[events.Topic]int{
+			interception.TopicConnect:  2,
+			interception.TopicRequest:  1,
+			interception.TopicResponse: 1,
+			interception.TopicBodies:   2,
+			interception.TopicReport:   5,
+		}},
+		{`all options`, []Option{
+			WithAnomalyDetection(true),
+			WithSampleRates(0.5, 1),
+			WithRetryCountHeader(`X-Retry-Count`),
+			WithAggregationWindow(time.Second),
+		}, map[events.Topic]int{
+			interception.TopicConnect:  2,
+			interception.TopicRequest:  2,
+			interception.TopicResponse: 1,
+			interception.TopicBodies:   2,
+			interception.TopicReport:   8,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]Option{WithEndpoints(ts.URL, ts.URL)}, tt.opts...)
+			a := New(ExampleWellFormedInvalidKey, opts...)
+			defer a.Close()
+			if a.Error() != nil {
+				t.Fatalf("New() error = %v", a.Error())
+			}
+
+			inspector, ok := a.dispatcher.(events.DispatcherInspector)
+			if !ok {
+				t.Fatalf("dispatcher %T does not implement DispatcherInspector", a.dispatcher)
+			}
+			if topics := inspector.Topics(); len(topics) != len(tt.expected) {
+				t.Errorf("providers set for topics %v, expected %d topics", topics, len(tt.expected))
+			}
+			for topic, expected := range tt.expected {
+				if actual := inspector.ProviderCount(topic); actual != expected {
+					t.Errorf("ProviderCount(%s) = %d, expected %d", topic, actual, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestAgent_Verify(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		wantErr      bool
+		wantRejected bool
+	}{
+		{`accepted`, http.StatusOK, false, false},
+		{`rejected`, http.StatusUnauthorized, true, true},
+		{`unavailable`, http.StatusServiceUnavailable, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var authorization string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				authorization = r.Header.Get(`Authorization`)
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(`{}`))
+			}))
+			defer ts.Close()
+
+			a := New(ExampleWellFormedInvalidKey, WithEndpoints(ts.URL, ts.URL))
+			defer a.Close()
+			err := a.Verify(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if errors.Is(err, config.ErrSecretKeyRejected) != tt.wantRejected {
+				t.Errorf("Verify() error = %v, wantRejected %t", err, tt.wantRejected)
+			}
+			if authorization != ExampleWellFormedInvalidKey {
+				t.Errorf("Verify() sent Authorization %q, expected the secret key", authorization)
+			}
+		})
+	}
+
+	a := New(`not a key`)
+	if err := a.Verify(context.Background()); err == nil {
+		t.Error("Verify() on an ill-formed key did not return an error")
+	}
+}
+
+// tenantServer is a local Bearer platform for a single agent, collecting the
+// hostnames of the API calls reported to it.
+type tenantServer struct {
+	*httptest.Server
+	m         sync.Mutex
+	hostnames []string
+}
+
+func newTenantServer() *tenantServer {
+	s := &tenantServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == `/logs` {
+			body, _ := ioutil.ReadAll(r.Body)
+			lr := proxy.LogReport{}
+			_ = json.Unmarshal(body, &lr)
+			s.m.Lock()
+			for _, l := range lr.Logs {
+				s.hostnames = append(s.hostnames, l.Hostname)
+			}
+			s.m.Unlock()
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	return s
+}
+
+func (s *tenantServer) reported() []string {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]string(nil), s.hostnames...)
+}
+
+func TestNew_AutoDecorateClients(t *testing.T) {
+	s := newTenantServer()
+	defer s.Close()
+
+	listed, unlisted := &http.Client{}, &http.Client{}
+	a := New(ExampleWellFormedInvalidKey,
+		WithEndpoints(s.URL+`/config`, s.URL+`/logs`),
+		WithGlobalInstrumentation(false),
+		WithAutoDecorateClients(listed),
+	)
+	if err := a.Error(); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer a.Close()
+
+	rt, ok := listed.Transport.(*interception.RoundTripper)
+	if !ok {
+		t.Fatalf("listed client transport is %T, expected it to be decorated", listed.Transport)
+	}
+	if rt.Dispatcher != a.dispatcher {
+		t.Error("listed client transport is not decorated by the agent")
+	}
+	if unlisted.Transport != nil {
+		t.Errorf("unlisted client transport is %T, expected it to be untouched", unlisted.Transport)
+	}
+}
+
+func TestNew_RedactionDisabledWarning(t *testing.T) {
+	s := newTenantServer()
+	defer s.Close()
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected bool
+	}{
+		{`defaults`, nil, false},
+		{`no keys`, []Option{WithNoSensitiveKeys()}, false},
+		{`no data`, []Option{WithNoSensitiveData()}, false},
+		{`disabled`, []Option{WithNoSensitiveKeys(), WithNoSensitiveData()}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := &bytes.Buffer{}
+			opts := append([]Option{
+				WithEndpoints(s.URL+`/config`, s.URL+`/logs`),
+				WithGlobalInstrumentation(false),
+				WithLogger(logs),
+			}, tt.opts...)
+			a := New(ExampleWellFormedInvalidKey, opts...)
+			if err := a.Error(); err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			defer a.Close()
+			if warned := strings.Contains(logs.String(), `redaction disabled`); warned != tt.expected {
+				t.Errorf("warned = %t, expected %t, logs: %s", warned, tt.expected, logs)
+			}
+		})
+	}
+}
+
+func TestNew_MultipleAgents(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`ok`))
+	}))
+	defer api.Close()
+	apiURL, _ := url.Parse(api.URL)
+
+	defaultTransport := http.DefaultTransport
+	servers := [2]*tenantServer{newTenantServer(), newTenantServer()}
+	agents := [2]*Agent{}
+	clients := [2]*http.Client{}
+	for i, s := range servers {
+		defer s.Close()
+		agents[i] = New(ExampleWellFormedInvalidKey,
+			WithEndpoints(s.URL+`/config`, s.URL+`/logs`),
+			WithGlobalInstrumentation(false),
+		)
+		if err := agents[i].Error(); err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		clients[i] = &http.Client{}
+		agents[i].DecorateClientTransports(clients[i])
+	}
+	if http.DefaultTransport != defaultTransport {
+		t.Error("expected http.DefaultTransport not to be instrumented")
+	}
+	if clients[0].Transport == clients[1].Transport {
+		t.Error("expected each agent to decorate its client independently")
+	}
+
+	// Only the first agent client performs calls.
+	for i := 0; i < 2; i++ {
+		res, err := clients[0].Get(api.URL)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		_, _ = ioutil.ReadAll(res.Body)
+		_ = res.Body.Close()
+	}
+	for _, a := range agents {
+		_ = a.Close()
+	}
+
+	expected := [2][]string{{apiURL.Hostname(), apiURL.Hostname()}, nil}
+	for i, s := range servers {
+		if actual := s.reported(); !reflect.DeepEqual(actual, expected[i]) {
+			t.Errorf("agent %d reported calls to %v, expected %v", i, actual, expected[i])
+		}
+	}
+}
@@ -5,16 +5,20 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"regexp"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/rs/zerolog"
 
 	"github.com/bearer/go-agent/config"
+	"github.com/bearer/go-agent/events"
 	"github.com/bearer/go-agent/filters"
 	"github.com/bearer/go-agent/interception"
+	"github.com/bearer/go-agent/proxy"
 )
 
 // Config represents the Agent configuration.
@@ -27,17 +31,81 @@ type Config struct {
 	// Sanitization options.
 	sensitiveRegexes []*regexp.Regexp // Named per Agent spec, although Go uses "regexp".
 	sensitiveKeys    []*regexp.Regexp
+	excludedFields   []string
+	strictSanitize   bool
+	coalescePatterns bool
+	redaction        interception.RedactionStrategy
+	redactionHashKey []byte
+	redactionAudit   io.Writer
+
+	// Sampling options.
+	sampleRateSuccess float64
+	sampleRateError   float64
+	samplingKey       func(*http.Request) string
 
 	// Rules.
 	dataCollectionRules []*interception.DataCollectionRule
 	Rules               []interface{} // XXX Agent spec defines the field but no use for it.
 	filters             filters.FilterMap
+	maxFilterDepth      int
+	rulePrecedence      interception.RulePrecedence
+
+	// Reporting options.
+	maxLogLevel       *interception.LogLevel
+	retryCountHeader  string
+	cacheHeader       string
+	enrichmentHeaders map[string]string
+	errorClassifier   interception.ErrorClassifier
+	jwtClaims         []string
+	statusSuppression map[string]filters.RangeMatcher
+	maxHosts          int
+	aggregationWindow time.Duration
+	selfMonitoring    time.Duration
+	detectAnomalies   bool
+	maxBodyDepth      int
+	maxQueryParams    int
+	maxHashes         int
+	bodyDigests       []string
+	skipHashOverflow  bool
+	spanTracer        interception.SpanTracer
+	bodyDenyHosts     []*regexp.Regexp
+	bodyStatuses      []filters.RangeMatcher
+	bodyPreview       int
+	maxRequestBody    int
+	maxResponseBody   int
+	bufferStreams     bool
+	lazyBodyParsing   bool
+	contentSniffing   bool
+	queryAsBody       bool
+	maxReportedRules  int
+	shapeDiscovery    int
+
+	// Interception options.
+	ignoredHosts            []*regexp.Regexp
+	noGlobalInstrumentation bool
+	autoDecorateClients     []*http.Client
+	listenerTimeouts        map[events.Topic]time.Duration
+	maxInstrumentLatency    time.Duration
+	explainMatches          bool
+	captureCallerStack      bool
+
+	// Transmission options.
+	authorization proxy.Authorization
+	reportFormat  proxy.ReportFormat
+
+	// Startup options.
+	requireRemoteConfig bool
 
 	// Internal dev. options.
 	fetchEndpoint     string
+	envEndpoints      map[string]Endpoints
 	fetchInterval     time.Duration
 	ReportEndpoint    string
+	mirrorEndpoint    string
 	ReportOutstanding uint
+	reportRateLimit   float64
+	measureSendDelay  bool
+	stopGracePeriod   time.Duration
 
 	// Internal runtime properties.
 	fetcher *config.Fetcher
@@ -52,6 +120,11 @@ var optionDefaults Option = func(c *Config) error {
 	c.ReportEndpoint = config.DefaultReportEndpoint
 	c.ReportOutstanding = config.DefaultReportOutstanding
 	c.fetchInterval = config.DefaultFetchInterval
+	c.maxFilterDepth = config.DefaultMaxFilterDepth
+	c.maxHosts = interception.DefaultMaxHosts
+	c.stopGracePeriod = proxy.DefaultStopGracePeriod
+	c.sampleRateSuccess = 1
+	c.sampleRateError = 1
 	c.sensitiveKeys = []*regexp.Regexp{interception.DefaultSensitiveKeys}
 	c.sensitiveRegexes = []*regexp.Regexp{interception.DefaultSensitiveData}
 	return nil
@@ -80,6 +153,16 @@ func WithDisabled(value bool) Option {
 	}
 }
 
+// WithRequireRemoteConfig is a functional Option making the Bearer platform
+// configuration mandatory: when true, failing to fetch it at startup makes
+// NewConfig, and thus New, fail, instead of silently disabling the agent.
+func WithRequireRemoteConfig(value bool) Option {
+	return func(c *Config) error {
+		c.requireRemoteConfig = value
+		return nil
+	}
+}
+
 // withError is a functional Option for errors.
 func withError(err error) Option {
 	return func(*Config) error {
@@ -106,9 +189,13 @@ func WithLogger(logger io.Writer) Option {
 // withRemote is an always-on functional Option loading values from Bearer platform configuration.
 func withRemote(transport http.RoundTripper, version string) Option {
 	return func(c *Config) error {
-		c.fetcher = config.NewFetcher(transport, c.Logger, version, c.fetchEndpoint, c.fetchInterval, c.runtimeEnvironmentType, c.secretKey)
+		c.fetcher = config.NewFetcher(transport, c.Logger, version, c.fetchEndpoint, c.fetchInterval, c.runtimeEnvironmentType, c.secretKey).
+			SetAuthorization(c.authorization)
 		d, err := c.fetcher.Fetch()
 		if err != nil {
+			if c.requireRemoteConfig {
+				return fmt.Errorf("fetching remote configuration: %w", err)
+			}
 			c.isDisabled = true
 			return nil
 		}
@@ -146,11 +233,19 @@ func withSecretKey(secretKey string) Option {
 	}
 }
 
-// WithSensitiveKeys is a functional Option configuring the sensitive regexps.
+// WithSensitiveKeys is a functional Option configuring the sensitive keys
+// regexps, replacing the default interception.DefaultSensitiveKeys:
+//   - a nil slice keeps the current keys, the defaults unless already replaced
+//   - a non-nil empty slice disables the redaction of values by key, which
+//     WithNoSensitiveKeys expresses more explicitly
+//   - any other slice replaces the current keys.
 //
 // It will return an error if any key is empty. Duplicate regexps will be reduced
 // to unique values to limit filtering costs.
 func WithSensitiveKeys(keys []string) Option {
+	if keys == nil {
+		return func(*Config) error { return nil }
+	}
 	dups := make(map[string]int, len(keys))
 	var reduced []*regexp.Regexp
 	for _, key := range keys {
@@ -168,7 +263,7 @@ func WithSensitiveKeys(keys []string) Option {
 		reduced = append(reduced, reKey)
 	}
 	// For non-nil empty slice, return a non-nil empty slice too.
-	if keys != nil && reduced == nil {
+	if reduced == nil {
 		reduced = make([]*regexp.Regexp, 0)
 	}
 	return func(c *Config) error {
@@ -177,10 +272,18 @@ func WithSensitiveKeys(keys []string) Option {
 	}
 }
 
-// WithSensitiveRegexps is a functional Option configuring the sensitive regular expressions.
+// WithSensitiveRegexps is a functional Option configuring the sensitive regular
+// expressions, replacing the default interception.DefaultSensitiveData:
+//   - a nil slice keeps the current expressions, the defaults unless already replaced
+//   - a non-nil empty slice disables the redaction of values by content, which
+//     WithNoSensitiveData expresses more explicitly
+//   - any other slice replaces the current expressions.
 //
 // It will cause an error if any of the regular expressions is invalid.
 func WithSensitiveRegexps(res []string) Option {
+	if res == nil {
+		return func(*Config) error { return nil }
+	}
 	dups := make(map[string]int, len(res))
 	var reduced []*regexp.Regexp
 	for _, re := range res {
@@ -199,7 +302,7 @@ func WithSensitiveRegexps(res []string) Option {
 	}
 
 	// For non-nil empty slice, return a non-nil empty slice too.
-	if res != nil && reduced == nil {
+	if reduced == nil {
 		reduced = []*regexp.Regexp{}
 	}
 	return func(c *Config) error {
@@ -208,6 +311,785 @@ func WithSensitiveRegexps(res []string) Option {
 	}
 }
 
+// WithNoSensitiveKeys is a functional Option disabling the redaction of values
+// by key, like passwords in JSON bodies. Values are still redacted by content if
+// sensitive regexps remain. The agent warns on startup if neither remains.
+func WithNoSensitiveKeys() Option {
+	return WithSensitiveKeys([]string{})
+}
+
+// WithNoSensitiveData is a functional Option disabling the redaction of values
+// by content, like card numbers. Values are still redacted by key if sensitive
+// keys remain. The agent warns on startup if neither remains.
+func WithNoSensitiveData() Option {
+	return WithSensitiveRegexps([]string{})
+}
+
+// WithStrictSanitization is a functional Option making sanitization fail safe:
+// when a URL or body cannot be sanitized, it is replaced entirely with
+// interception.Filtered instead of the report failing.
+func WithStrictSanitization(enabled bool) Option {
+	return func(c *Config) error {
+		c.strictSanitize = enabled
+		return nil
+	}
+}
+
+// WithRedactionStrategy is a functional Option defining how sensitive values
+// are replaced in headers, URLs and bodies:
+//   - interception.RedactionMask, the default, replaces them with interception.Filtered
+//   - interception.RedactionHash replaces them with their HMAC with hashKey,
+//     allowing to correlate equal values across reports
+//   - interception.RedactionRemove removes them entirely.
+//
+// The hashKey is only used by interception.RedactionHash, for which it is
+// required. It should be kept secret, lest low-entropy values be guessed.
+func WithRedactionStrategy(strategy interception.RedactionStrategy, hashKey []byte) Option {
+	return func(c *Config) error {
+		if !strategy.IsValid() {
+			return fmt.Errorf("invalid redaction strategy: %d", strategy)
+		}
+		if strategy == interception.RedactionHash && len(hashKey) == 0 {
+			return errors.New(`the hash redaction strategy requires a hash key`)
+		}
+		c.redaction = strategy
+		c.redactionHashKey = append([]byte(nil), hashKey...)
+		return nil
+	}
+}
+
+// WithRedactionAudit is a functional Option writing an audit record to w for
+// each redaction, as a JSON line holding the location of the redacted value,
+// like "request.headers.Authorization", and the kind and index of the sensitive
+// key or regexp causing it, as an interception.RedactionAuditRecord. Redacted
+// values are never written. A nil w, the default, disables the audit.
+//
+// With WithCoalescedSanitization, the patterns are combined, so their index is
+// always 0.
+func WithRedactionAudit(w io.Writer) Option {
+	return func(c *Config) error {
+		c.redactionAudit = w
+		return nil
+	}
+}
+
+// WithCoalescedSanitization is a functional Option combining the sensitive keys
+// and the sensitive regexps into a single regular expression each, with
+// interception.CoalesceRegexps, so that each value is matched once instead of
+// once per pattern. This mostly benefits the regexps, matched against every
+// value: anchored key patterns are usually rejected faster one by one, as
+// shown by the interception.CoalesceRegexps benchmark.
+//
+// Keys matches still replace whole values, and regexps matches the matched
+// parts. However, when matches of different regexps overlap in a value, only
+// the leftmost one is replaced.
+func WithCoalescedSanitization(enabled bool) Option {
+	return func(c *Config) error {
+		c.coalescePatterns = enabled
+		return nil
+	}
+}
+
+// WithExcludedBodyFields is a functional Option configuring fields removed
+// entirely from the reported bodies, like large embedded blobs, unlike
+// sensitive fields which are only redacted.
+//
+// Fields are designated by their dotted path from the body root, like
+// "data.image". Paths traverse arrays, applying to each of their elements.
+//
+// It will cause an error if any of the paths is empty or has an empty segment.
+func WithExcludedBodyFields(paths ...string) Option {
+	return func(c *Config) error {
+		for _, path := range paths {
+			for _, segment := range strings.Split(path, `.`) {
+				if segment == `` {
+					return fmt.Errorf("invalid excluded body field path: %q", path)
+				}
+			}
+		}
+		c.excludedFields = paths
+		return nil
+	}
+}
+
+// WithBodyPreview is a functional Option enabling a preview of the sanitized
+// bodies, truncated to maxBytes, in reports at the Restricted log level, which
+// otherwise includes no bodies. A value of 0 disables previews, the default.
+//
+// It will cause an error if maxBytes is negative or exceeds
+// interception.MaxBodyPreview.
+func WithBodyPreview(maxBytes int) Option {
+	return func(c *Config) error {
+		if maxBytes < 0 || maxBytes > interception.MaxBodyPreview {
+			return fmt.Errorf("body preview size must be between 0 and %d, got %d",
+				interception.MaxBodyPreview, maxBytes)
+		}
+		c.bodyPreview = maxBytes
+		return nil
+	}
+}
+
+// WithMaxRequestBodySize is a functional Option bounding the size of the
+// request bodies captured for parsing and reporting, below the default
+// interception.MaximumBodySize. Longer bodies are reported as
+// interception.BodyTooLong. A value of 0 means the default.
+//
+// It will cause an error if size is negative or exceeds
+// interception.MaximumBodySize.
+func WithMaxRequestBodySize(size int) Option {
+	return func(c *Config) error {
+		if size < 0 || size > interception.MaximumBodySize {
+			return fmt.Errorf("maximum request body size must be between 0 and %d, got %d",
+				interception.MaximumBodySize, size)
+		}
+		c.maxRequestBody = size
+		return nil
+	}
+}
+
+// WithMaxResponseBodySize is a functional Option bounding the size of the
+// response bodies captured for parsing and reporting, like
+// WithMaxRequestBodySize does for request bodies.
+//
+// It will cause an error if size is negative or exceeds
+// interception.MaximumBodySize.
+func WithMaxResponseBodySize(size int) Option {
+	return func(c *Config) error {
+		if size < 0 || size > interception.MaximumBodySize {
+			return fmt.Errorf("maximum response body size must be between 0 and %d, got %d",
+				interception.MaximumBodySize, size)
+		}
+		c.maxResponseBody = size
+		return nil
+	}
+}
+
+// WithStreamBuffering is a functional Option enabling the capture of the
+// bodies of streaming responses, like Server-Sent Events with the
+// text/event-stream content type. By default, these bodies are passed through
+// without buffering, since peeking them blocks until enough events are
+// received, and they are reported as interception.BodyIsStream, along with
+// their headers and status.
+func WithStreamBuffering(enabled bool) Option {
+	return func(c *Config) error {
+		c.bufferStreams = enabled
+		return nil
+	}
+}
+
+// WithMaxReportedRules is a functional Option bounding the number of triggered
+// data collection rules listed in each report, to limit the report size when
+// many rules match. The rule which determined the log level is always listed,
+// and the reports include the number of omitted rules. A value of 0 means no
+// limit, the default.
+//
+// It will cause an error if max is negative.
+func WithMaxReportedRules(max int) Option {
+	return func(c *Config) error {
+		if max < 0 {
+			return fmt.Errorf("maximum number of reported rules must not be negative, got %d", max)
+		}
+		c.maxReportedRules = max
+		return nil
+	}
+}
+
+// WithShapeDiscoveryMode is a functional Option restricting reports to the
+// first n calls for each combination of host, path, and body shapes, to
+// discover the API shapes without an ongoing reporting overhead. Later calls
+// with an already reported combination are marked inactive and not reported.
+//
+// It will cause an error if n is not strictly positive.
+func WithShapeDiscoveryMode(n int) Option {
+	return func(c *Config) error {
+		if n <= 0 {
+			return fmt.Errorf("shape discovery reports must be strictly positive, got %d", n)
+		}
+		c.shapeDiscovery = n
+		return nil
+	}
+}
+
+// WithLazyBodyParsing is a functional Option skipping the parsing and shape
+// hashing of the bodies of the calls which do not report them, because of
+// their log level or of the body capture settings, to save CPU. Bodies are
+// still parsed for all calls when data collection rules filter on their
+// contents.
+func WithLazyBodyParsing(enabled bool) Option {
+	return func(c *Config) error {
+		c.lazyBodyParsing = enabled
+		return nil
+	}
+}
+
+// WithContentSniffing is a functional Option guessing the content type of the
+// bodies sent without a Content-Type header, so that those which look like JSON
+// or text are parsed and sanitized instead of being reported as binary data.
+func WithContentSniffing(enabled bool) Option {
+	return func(c *Config) error {
+		c.contentSniffing = enabled
+		return nil
+	}
+}
+
+// WithQueryAsBody is a functional Option using the query parameters of GET
+// requests without a body as their request body, so that APIs passing
+// structured data in the query string get the same shape hashing and
+// sanitization as those using request bodies.
+func WithQueryAsBody(enabled bool) Option {
+	return func(c *Config) error {
+		c.queryAsBody = enabled
+		return nil
+	}
+}
+
+// WithMaxBodyDepth is a functional Option bounding the nesting depth of the
+// request and response bodies walked during sanitization, to limit the cost of
+// sanitizing deeply nested bodies. Content nested deeper is replaced with the
+// interception.DepthLimitExceeded marker.
+//
+// A zero depth, the default, means no limit.
+func WithMaxBodyDepth(depth int) Option {
+	return func(c *Config) error {
+		if depth < 0 {
+			return fmt.Errorf("maximum body depth may not be negative: %d", depth)
+		}
+		c.maxBodyDepth = depth
+		return nil
+	}
+}
+
+// WithMaxQueryParams is a functional Option bounding the number of query
+// parameters included in reported URLs, to avoid bloated reports. Extra
+// parameters are dropped, and an interception.TruncatedQueryMarker parameter
+// is appended with the number of parameters dropped.
+//
+// A zero maximum, the default, means no limit.
+func WithMaxQueryParams(max int) Option {
+	return func(c *Config) error {
+		if max < 0 {
+			return fmt.Errorf("maximum query parameters may not be negative: %d", max)
+		}
+		c.maxQueryParams = max
+		return nil
+	}
+}
+
+// WithMaxFilterDepth is a functional Option bounding the nesting depth of the
+// filters received from the configuration server. Configurations with deeper
+// filters are rejected with a warning, keeping the previous configuration.
+//
+// It defaults to config.DefaultMaxFilterDepth, and must be strictly positive.
+func WithMaxFilterDepth(depth int) Option {
+	return func(c *Config) error {
+		if depth <= 0 {
+			return fmt.Errorf("maximum filter depth must be strictly positive: %d", depth)
+		}
+		c.maxFilterDepth = depth
+		return nil
+	}
+}
+
+// WithMaxHosts is a functional Option bounding the number of distinct hosts
+// for which the agent maintains per-host state, like latency histograms and
+// shape discovery counts. Beyond it, the state of the least recently called
+// hosts is dropped.
+//
+// It defaults to interception.DefaultMaxHosts, and must be strictly positive.
+func WithMaxHosts(n int) Option {
+	return func(c *Config) error {
+		if n <= 0 {
+			return fmt.Errorf("maximum hosts must be strictly positive: %d", n)
+		}
+		c.maxHosts = n
+		return nil
+	}
+}
+
+// WithBodyDigests is a functional Option enabling the report of digests of the
+// raw request and response bodies, computed with the named algorithms among
+// those in interception.BodyDigestAlgorithms, like "md5" or "sha1", for the
+// deduplication of calls against other systems.
+//
+// Digests are reported along with bodies, at the All log level, and only for
+// bodies shorter than interception.MaximumBodySize.
+func WithBodyDigests(algorithms ...string) Option {
+	return func(c *Config) error {
+		for _, algorithm := range algorithms {
+			if _, ok := interception.BodyDigestAlgorithms[algorithm]; !ok {
+				return fmt.Errorf("unknown body digest algorithm: %q", algorithm)
+			}
+		}
+		c.bodyDigests = algorithms
+		return nil
+	}
+}
+
+// WithMaxConcurrentHashes is a functional Option bounding the number of body
+// shape hashes computed concurrently, as these computations are CPU-heavy.
+// Beyond the limit, computations wait for a running one to complete or, if
+// skipOnOverflow is true, are skipped, reporting an empty SHA.
+//
+// A zero limit, the default, means no limit.
+func WithMaxConcurrentHashes(limit int, skipOnOverflow bool) Option {
+	return func(c *Config) error {
+		if limit < 0 {
+			return fmt.Errorf("maximum concurrent hashes may not be negative: %d", limit)
+		}
+		c.maxHashes = limit
+		c.skipHashOverflow = skipOnOverflow
+		return nil
+	}
+}
+
+// WithIgnoredHosts is a functional Option configuring regular expressions
+// matched against the host name, without port, of outgoing API calls. Calls to
+// matching hosts bypass instrumentation entirely: no events are dispatched for
+// them, which is cheaper than disabling them with a DomainFilter-based rule.
+//
+// It will cause an error if any of the regular expressions is empty or invalid.
+func WithIgnoredHosts(patterns ...string) Option {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		if pattern == "" {
+			return withError(errors.New("empty string may not be used as an ignored host pattern"))
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return withError(fmt.Errorf("invalid ignored host regexp %s: %w", pattern, err))
+		}
+		res = append(res, re)
+	}
+	return func(c *Config) error {
+		c.ignoredHosts = res
+		return nil
+	}
+}
+
+// WithListenerTimeout is a functional Option bounding the duration of the
+// listeners for a topic, like interception.TopicBodies, independently of the
+// cancellation of the API call: they are only bound by its deadline when it is
+// sooner, and their dispatch is aborted once the timeout expires, without
+// failing the call. If it
+// expires before the call, on the connect or request topics, the call is
+// performed without instrumentation.
+//
+// It will cause an error if the timeout is not positive.
+func WithListenerTimeout(topic events.Topic, timeout time.Duration) Option {
+	return func(c *Config) error {
+		if timeout <= 0 {
+			return fmt.Errorf("listener timeout for topic %s must be positive, got %v", topic, timeout)
+		}
+		if c.listenerTimeouts == nil {
+			c.listenerTimeouts = make(map[events.Topic]time.Duration)
+		}
+		c.listenerTimeouts[topic] = timeout
+		return nil
+	}
+}
+
+// WithMaxInstrumentationLatency is a functional Option bounding the total
+// latency the Agent adds before each API call, in the connect and request
+// stages together, unlike the per-topic WithListenerTimeout. Once it is
+// exceeded, the call proceeds without instrumentation, and a warning is logged.
+//
+// It will cause an error if the latency is not positive.
+func WithMaxInstrumentationLatency(latency time.Duration) Option {
+	return func(c *Config) error {
+		if latency <= 0 {
+			return fmt.Errorf("max instrumentation latency must be positive, got %v", latency)
+		}
+		c.maxInstrumentLatency = latency
+		return nil
+	}
+}
+
+// WithGlobalInstrumentation is a functional Option defining whether the Agent
+// instruments the http.DefaultTransport and http.DefaultClient, which it does
+// by default.
+//
+// Disabling it allows multiple independent agents in the same process, e.g.
+// one per tenant, each only instrumenting the clients passed to its
+// DecorateClientTransports method.
+func WithGlobalInstrumentation(enabled bool) Option {
+	return func(c *Config) error {
+		c.noGlobalInstrumentation = !enabled
+		return nil
+	}
+}
+
+// WithAutoDecorateClients is a functional Option declaring HTTP clients whose
+// transports New decorates with Bearer instrumentation, as if they were passed
+// to Agent.DecorateClientTransports. It may be used multiple times, the clients
+// accumulating.
+//
+// It will cause an error if any of the clients is nil.
+func WithAutoDecorateClients(clients ...*http.Client) Option {
+	return func(c *Config) error {
+		for i, client := range clients {
+			if client == nil {
+				return fmt.Errorf("auto-decorated client %d is nil", i)
+			}
+		}
+		c.autoDecorateClients = append(c.autoDecorateClients, clients...)
+		return nil
+	}
+}
+
+// WithBodyCaptureDenyHosts is a functional Option configuring regular
+// expressions matched against the host of API calls, for which bodies are never
+// reported, even when a data collection rule applies the All log level. Other
+// information, like headers and status, is still reported.
+//
+// It will cause an error if any of the regular expressions is empty or invalid.
+func WithBodyCaptureDenyHosts(patterns ...string) Option {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		if pattern == "" {
+			return withError(errors.New("empty string may not be used as a body capture deny host pattern"))
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return withError(fmt.Errorf("invalid body capture deny host regexp %s: %w", pattern, err))
+		}
+		res = append(res, re)
+	}
+	return func(c *Config) error {
+		c.bodyDenyHosts = res
+		return nil
+	}
+}
+
+// WithBodyCaptureStatusCodes is a functional Option restricting the report of
+// bodies at the All log level to the API calls whose response status is in any
+// of the given ranges: the log level of other calls is lowered to Restricted.
+// For instance, using filters.NewRangeMatcher().From(400) only reports the
+// bodies of failed calls. Calls without a response are not restricted.
+//
+// It will cause an error if any of the ranges is nil.
+func WithBodyCaptureStatusCodes(ranges ...filters.RangeMatcher) Option {
+	for _, statuses := range ranges {
+		if statuses == nil {
+			return withError(errors.New("nil range may not be used as body capture status codes"))
+		}
+	}
+	return func(c *Config) error {
+		c.bodyStatuses = ranges
+		return nil
+	}
+}
+
+// WithSampleRates is a functional Option configuring the ratio of API calls
+// reported, separately for successful and failed calls.
+//
+// Both rates must be in the [0, 1] range. As the sampling decision is taken
+// once the call outcome is known, a common setting is to report all errors,
+// but only a small sample of successful calls, as in WithSampleRates(0.1, 1).
+func WithSampleRates(success float64, errorRate float64) Option {
+	return func(c *Config) error {
+		if success < 0 || success > 1 || errorRate < 0 || errorRate > 1 {
+			return fmt.Errorf("sample rates must be in the [0, 1] range: got %f and %f", success, errorRate)
+		}
+		c.sampleRateSuccess = success
+		c.sampleRateError = errorRate
+		return nil
+	}
+}
+
+// WithSamplingKey is a functional Option making the sampling decisions of
+// WithSampleRates deterministic, based on a key returned for each request,
+// like a user or correlation ID, so that the calls with the same key are
+// consistently reported or not. Calls for which the key is empty are sampled
+// randomly.
+func WithSamplingKey(key func(*http.Request) string) Option {
+	return func(c *Config) error {
+		c.samplingKey = key
+		return nil
+	}
+}
+
+// WithRulePrecedence is a functional Option selecting which of the data
+// collection rules matching a call applies:
+//   - interception.LastWins, the default, applies them all in order, so the
+//     last one setting the log level wins
+//   - interception.FirstWins only applies the first one, skipping the
+//     evaluation of the following rules.
+func WithRulePrecedence(precedence interception.RulePrecedence) Option {
+	return func(c *Config) error {
+		if !precedence.IsValid() {
+			return fmt.Errorf("invalid rule precedence: %d", precedence)
+		}
+		c.rulePrecedence = precedence
+		return nil
+	}
+}
+
+// WithMaxLogLevel is a functional Option capping the log level applied to API
+// calls, regardless of the data collection rules received from Bearer.
+//
+// For instance, WithMaxLogLevel(interception.Restricted) ensures that request
+// and response headers and bodies are never reported, even by rules
+// requesting the All level.
+func WithMaxLogLevel(level interception.LogLevel) Option {
+	return func(c *Config) error {
+		capped := interception.LogLevelFromInt(int(level))
+		c.maxLogLevel = &capped
+		return nil
+	}
+}
+
+// WithAnomalyDetection is a functional Option enabling the detection of
+// suspicious requests, like those with conflicting Content-Length and
+// Transfer-Encoding headers, or CRLF sequences in headers. Anomalies are
+// only reported, the requests are not blocked.
+func WithAnomalyDetection(enabled bool) Option {
+	return func(c *Config) error {
+		c.detectAnomalies = enabled
+		return nil
+	}
+}
+
+// WithMatchExplanations is a functional Option logging, at the trace level, why
+// the filter of each triggered data collection rule matched the API calls, like
+// which pattern matched which header, to debug unexpected matches.
+//
+// Since it explains every match, it is only meant for debugging sessions.
+func WithMatchExplanations(enabled bool) Option {
+	return func(c *Config) error {
+		c.explainMatches = enabled
+		return nil
+	}
+}
+
+// WithCaptureCallerStack is a functional Option including in the reports of
+// failed calls the stack of the application code issuing them, to locate their
+// origin. The stack excludes the agent frames, and is capped to
+// interception.MaxCallerStackFrames frames and interception.MaxCallerStackSize
+// bytes. It is only captured on errors, so successful calls incur no overhead.
+func WithCaptureCallerStack(enabled bool) Option {
+	return func(c *Config) error {
+		c.captureCallerStack = enabled
+		return nil
+	}
+}
+
+// WithRetryCountHeader is a functional Option naming a response header from
+// which to read the number of retries performed by the underlying transport,
+// for transports retrying requests internally and exposing that count.
+//
+// The count is included in reports at the Restricted level and above.
+func WithRetryCountHeader(name string) Option {
+	return func(c *Config) error {
+		re := regexp.MustCompile(filters.RFC7230_3_2_6Token)
+		if name != `` && !re.MatchString(name) {
+			return fmt.Errorf("invalid retry count header name: %q", name)
+		}
+		c.retryCountHeader = name
+		return nil
+	}
+}
+
+// WithCacheIndicatorHeader is a functional Option naming a response header,
+// like X-Cache, whose values contain "HIT" on responses served from a cache.
+// Responses carrying an Age header, or whose Request context was marked with
+// interception.WithCacheMarker, are always considered served from a cache.
+//
+// The cache flag is included in reports at the Restricted level and above.
+func WithCacheIndicatorHeader(name string) Option {
+	return func(c *Config) error {
+		re := regexp.MustCompile(filters.RFC7230_3_2_6Token)
+		if name != `` && !re.MatchString(name) {
+			return fmt.Errorf("invalid cache indicator header name: %q", name)
+		}
+		c.cacheHeader = name
+		return nil
+	}
+}
+
+// WithEnrichmentHeaders is a functional Option copying response headers, like
+// the edge or region headers added by CDNs, to report custom fields, keyed by
+// header name. Unlike headers, the fields are included in reports at the
+// Restricted level and above, without sanitization, so the headers should not
+// carry sensitive data.
+func WithEnrichmentHeaders(headers map[string]string) Option {
+	return func(c *Config) error {
+		re := regexp.MustCompile(filters.RFC7230_3_2_6Token)
+		copied := make(map[string]string, len(headers))
+		for header, field := range headers {
+			if !re.MatchString(header) {
+				return fmt.Errorf("invalid enrichment header name: %q", header)
+			}
+			if field == `` {
+				return fmt.Errorf("empty report field name for enrichment header %q", header)
+			}
+			copied[header] = field
+		}
+		c.enrichmentHeaders = copied
+		return nil
+	}
+}
+
+// WithJWTClaims is a functional Option copying claims of the JWT bearer tokens
+// sent in Authorization headers, like "iss", "aud", or "sub", to report custom
+// fields named after them with an interception.JWTClaimFieldPrefix. The tokens
+// are decoded without verification, and still redacted from the headers. As
+// custom fields are not sanitized, only non-sensitive claims should be listed.
+//
+// It will cause an error if any claim name is empty.
+func WithJWTClaims(claims ...string) Option {
+	for _, claim := range claims {
+		if claim == `` {
+			return withError(errors.New("empty string may not be used as a JWT claim name"))
+		}
+	}
+	return func(c *Config) error {
+		c.jwtClaims = claims
+		return nil
+	}
+}
+
+// WithErrorClassifier is a functional Option registering a function mapping
+// the errors of failed calls to report error codes, e.g. for the error types of
+// custom transports. Errors for which it returns false are reported with their
+// message as error code.
+func WithErrorClassifier(classifier func(error) (code string, ok bool)) Option {
+	return func(c *Config) error {
+		c.errorClassifier = classifier
+		return nil
+	}
+}
+
+// WithHostStatusSuppression is a functional Option preventing the report of
+// API calls to some hosts when their response status is within a range, keyed
+// by host name without port. For instance, suppressing the 2xx range for a
+// health endpoint host stops reporting its successful calls, but still reports
+// its errors, and the calls to other hosts.
+//
+// It will cause an error if any host name is empty or any range is nil.
+func WithHostStatusSuppression(hosts map[string]filters.RangeMatcher) Option {
+	return func(c *Config) error {
+		copied := make(map[string]filters.RangeMatcher, len(hosts))
+		for host, statuses := range hosts {
+			if host == `` {
+				return errors.New("empty string may not be used as a status suppression host")
+			}
+			if statuses == nil {
+				return fmt.Errorf("nil status range for status suppression host %q", host)
+			}
+			copied[strings.ToLower(host)] = statuses
+		}
+		c.statusSuppression = copied
+		return nil
+	}
+}
+
+// WithReportRateLimit is a functional Option capping the number of reports
+// transmitted to Bearer per second.
+//
+// Reports beyond that rate are queued until they can be sent, within the limit
+// of outstanding reports, beyond which they are dropped. A zero value, which is
+// the default, means no limit.
+func WithReportRateLimit(perSecond float64) Option {
+	return func(c *Config) error {
+		if perSecond < 0 {
+			return fmt.Errorf("report rate limit may not be negative: %f", perSecond)
+		}
+		c.reportRateLimit = perSecond
+		return nil
+	}
+}
+
+// WithStopGracePeriod is a functional Option configuring how long closing the
+// agent waits for reports being sent concurrently to be accepted, before it
+// stops accepting them. It defaults to proxy.DefaultStopGracePeriod.
+func WithStopGracePeriod(period time.Duration) Option {
+	return func(c *Config) error {
+		if period < 0 {
+			return fmt.Errorf("stop grace period may not be negative: %v", period)
+		}
+		c.stopGracePeriod = period
+		return nil
+	}
+}
+
+// WithAuthorization is a functional Option configuring how the secret key is
+// passed to the Bearer platform, for setups where a proxy or gateway requires
+// a specific header name or authentication scheme.
+//
+// An empty header name means the default Authorization header, and an empty
+// scheme means the bare secret key is sent, which is the default behaviour.
+func WithAuthorization(header string, scheme string) Option {
+	return func(c *Config) error {
+		re := regexp.MustCompile(filters.RFC7230_3_2_6Token)
+		if header != `` && !re.MatchString(header) {
+			return fmt.Errorf("invalid authorization header name: %q", header)
+		}
+		if scheme != `` && !re.MatchString(scheme) {
+			return fmt.Errorf("invalid authorization scheme: %q", scheme)
+		}
+		c.authorization = proxy.Authorization{Header: header, Scheme: scheme}
+		return nil
+	}
+}
+
+// WithAggregationWindow is a functional Option enabling the aggregation of
+// identical reports: calls with the same host, path, method, status, and body
+// shapes within the window are reported once, with their count.
+//
+// A zero window, the default, disables aggregation.
+func WithAggregationWindow(window time.Duration) Option {
+	return func(c *Config) error {
+		if window < 0 {
+			return fmt.Errorf("aggregation window may not be negative: %v", window)
+		}
+		c.aggregationWindow = window
+		return nil
+	}
+}
+
+// WithSelfMonitoring is a functional Option enabling the periodic sampling of
+// the resources used by the agent process, like its goroutines, heap size,
+// and report rate, available from Agent.SelfStats, for overhead monitoring.
+//
+// A zero interval, the default, disables self-monitoring.
+func WithSelfMonitoring(interval time.Duration) Option {
+	return func(c *Config) error {
+		if interval < 0 {
+			return fmt.Errorf("self-monitoring interval may not be negative: %v", interval)
+		}
+		c.selfMonitoring = interval
+		return nil
+	}
+}
+
+// WithReportFormat is a functional Option selecting the encoding of reports
+// sent to the Bearer platform: proxy.FormatJSON, the default, or
+// proxy.FormatProtobuf for protobuf-based ingestion pipelines.
+func WithReportFormat(format proxy.ReportFormat) Option {
+	return func(c *Config) error {
+		switch format {
+		case proxy.FormatJSON, proxy.FormatProtobuf:
+			c.reportFormat = format
+			return nil
+		default:
+			return fmt.Errorf("unknown report format: %d", format)
+		}
+	}
+}
+
+// WithSpanTracer is a functional Option exporting each sanitized report as an
+// OpenTelemetry span through the tracer, in addition to its transmission to
+// Bearer. A nil tracer, the default, disables the export.
+//
+// The github.com/bearer/go-agent/otel module provides a tracer emitting the
+// spans with an OpenTelemetry trace.Tracer.
+func WithSpanTracer(tracer interception.SpanTracer) Option {
+	return func(c *Config) error {
+		c.spanTracer = tracer
+		return nil
+	}
+}
+
 // WithEndpoints is an undocumented functional Option used for development
 // purposes.
 func WithEndpoints(fetchEndpoint string, reportEndpoint string) Option {
@@ -218,6 +1100,74 @@ func WithEndpoints(fetchEndpoint string, reportEndpoint string) Option {
 	}
 }
 
+// WithMirrorEndpoint is a functional Option mirroring the sanitized reports to
+// a secondary collector, like an internal one, at the given http or https URL.
+// The mirror does not receive the secret key, and its failures do not affect
+// the transmissions to the Bearer platform. An empty URL disables mirroring.
+func WithMirrorEndpoint(rawURL string) Option {
+	return func(c *Config) error {
+		if rawURL != `` {
+			u, err := url.Parse(rawURL)
+			if err != nil {
+				return fmt.Errorf("invalid mirror endpoint: %w", err)
+			}
+			if (u.Scheme != `http` && u.Scheme != `https`) || u.Host == `` {
+				return fmt.Errorf("invalid mirror endpoint %q: expected an absolute http or https URL", rawURL)
+			}
+		}
+		c.mirrorEndpoint = rawURL
+		return nil
+	}
+}
+
+// WithSendDelayMeasurement is a functional Option measuring the time reports
+// wait before entering the queue of the report sender, exposed in the sender
+// statistics of the DebugHandler, to detect when the reporting of calls is
+// delayed by a full queue or by goroutine starvation under load.
+func WithSendDelayMeasurement(enabled bool) Option {
+	return func(c *Config) error {
+		c.measureSendDelay = enabled
+		return nil
+	}
+}
+
+// Endpoints are the Bearer platform endpoints used by an agent. Empty values
+// keep the endpoints otherwise configured.
+type Endpoints struct {
+	Fetch  string
+	Report string
+}
+
+// WithEndpointsForEnvironment is a functional Option selecting the endpoints by
+// runtime environment type, as configured by WithEnvironment, allowing the same
+// binary to report to different endpoints in each environment. The endpoints
+// mapped to the environment type override those set by WithEndpoints, while
+// unmapped environment types keep them, or the defaults.
+func WithEndpointsForEnvironment(endpoints map[string]Endpoints) Option {
+	return func(c *Config) error {
+		c.envEndpoints = endpoints
+		return nil
+	}
+}
+
+// withEnvironmentEndpoints is an always-on Option applying the endpoints
+// configured for the runtime environment type, once all options are applied,
+// so that it does not depend on the order of WithEnvironment and
+// WithEndpointsForEnvironment.
+func withEnvironmentEndpoints(c *Config) error {
+	endpoints, ok := c.envEndpoints[c.runtimeEnvironmentType]
+	if !ok {
+		return nil
+	}
+	if endpoints.Fetch != `` {
+		c.fetchEndpoint = endpoints.Fetch
+	}
+	if endpoints.Report != `` {
+		c.ReportEndpoint = endpoints.Report
+	}
+	return nil
+}
+
 // DisableRemote stops the goroutine updating the Agent configuration periodically.
 func (c *Config) DisableRemote() {
 	if c.fetcher == nil {
@@ -237,6 +1187,21 @@ func (c *Config) IsDisabled() bool {
 	return c == nil || c.isDisabled || !config.IsSecretKeyWellFormed(c.secretKey)
 }
 
+// SendDelayMeasurement is a getter for measureSendDelay.
+func (c *Config) SendDelayMeasurement() bool {
+	return c.measureSendDelay
+}
+
+// MirrorEndpoint is a getter for mirrorEndpoint.
+func (c *Config) MirrorEndpoint() string {
+	return c.mirrorEndpoint
+}
+
+// FetchEndpoint is a getter for fetchEndpoint.
+func (c *Config) FetchEndpoint() string {
+	return c.fetchEndpoint
+}
+
 // Environment is a getter for runtimeEnvironmentType.
 func (c *Config) Environment() string {
 	return c.runtimeEnvironmentType
@@ -252,6 +1217,268 @@ func (c *Config) SensitiveRegexps() []*regexp.Regexp {
 	return c.sensitiveRegexes
 }
 
+// RedactionDisabled checks whether both the sensitive keys and regexps were
+// disabled, in which case the reports include all values unredacted.
+func (c *Config) RedactionDisabled() bool {
+	return len(c.sensitiveKeys) == 0 && len(c.sensitiveRegexes) == 0
+}
+
+// MatchExplanations is a getter for explainMatches.
+func (c *Config) MatchExplanations() bool {
+	return c.explainMatches
+}
+
+// CoalescedSanitization is a getter for coalescePatterns.
+func (c *Config) CoalescedSanitization() bool {
+	return c.coalescePatterns
+}
+
+// RedactionStrategy is a getter for the redaction strategy and its hash key.
+func (c *Config) RedactionStrategy() (interception.RedactionStrategy, []byte) {
+	return c.redaction, c.redactionHashKey
+}
+
+// RedactionAudit is a getter for redactionAudit.
+func (c *Config) RedactionAudit() io.Writer {
+	return c.redactionAudit
+}
+
+// StrictSanitization is a getter for strictSanitize.
+func (c *Config) StrictSanitization() bool {
+	return c.strictSanitize
+}
+
+// ExcludedBodyFields is a getter for excludedFields.
+func (c *Config) ExcludedBodyFields() []string {
+	return c.excludedFields
+}
+
+// BodyPreview is a getter for bodyPreview.
+func (c *Config) BodyPreview() int {
+	return c.bodyPreview
+}
+
+// MaxRequestBodySize is a getter for maxRequestBody.
+func (c *Config) MaxRequestBodySize() int {
+	if c == nil {
+		return 0
+	}
+	return c.maxRequestBody
+}
+
+// MaxResponseBodySize is a getter for maxResponseBody.
+func (c *Config) MaxResponseBodySize() int {
+	if c == nil {
+		return 0
+	}
+	return c.maxResponseBody
+}
+
+// StreamBuffering is a getter for bufferStreams.
+func (c *Config) StreamBuffering() bool {
+	if c == nil {
+		return false
+	}
+	return c.bufferStreams
+}
+
+// MaxReportedRules is a getter for maxReportedRules.
+func (c *Config) MaxReportedRules() int {
+	return c.maxReportedRules
+}
+
+// ShapeDiscoveryMode is a getter for shapeDiscovery. 0 means the mode is disabled.
+func (c *Config) ShapeDiscoveryMode() int {
+	return c.shapeDiscovery
+}
+
+// LazyBodyParsing is a getter for lazyBodyParsing.
+func (c *Config) LazyBodyParsing() bool {
+	return c.lazyBodyParsing
+}
+
+// ContentSniffing is a getter for contentSniffing.
+func (c *Config) ContentSniffing() bool {
+	return c.contentSniffing
+}
+
+// QueryAsBody is a getter for queryAsBody.
+func (c *Config) QueryAsBody() bool {
+	return c.queryAsBody
+}
+
+// MaxBodyDepth is a getter for maxBodyDepth.
+func (c *Config) MaxBodyDepth() int {
+	return c.maxBodyDepth
+}
+
+// RulePrecedence is a getter for rulePrecedence.
+func (c *Config) RulePrecedence() interception.RulePrecedence {
+	return c.rulePrecedence
+}
+
+// MaxFilterDepth is a getter for maxFilterDepth.
+func (c *Config) MaxFilterDepth() int {
+	return c.maxFilterDepth
+}
+
+// MaxHosts is a getter for maxHosts.
+func (c *Config) MaxHosts() int {
+	return c.maxHosts
+}
+
+// BodyDigests is a getter for bodyDigests.
+func (c *Config) BodyDigests() []string {
+	return c.bodyDigests
+}
+
+// MaxQueryParams is a getter for maxQueryParams.
+func (c *Config) MaxQueryParams() int {
+	return c.maxQueryParams
+}
+
+// MaxConcurrentHashes is a getter for the concurrent hashes limit and overflow
+// behaviour.
+func (c *Config) MaxConcurrentHashes() (limit int, skipOnOverflow bool) {
+	return c.maxHashes, c.skipHashOverflow
+}
+
+// IgnoredHosts is a getter for ignoredHosts.
+func (c *Config) IgnoredHosts() []*regexp.Regexp {
+	if c == nil {
+		return nil
+	}
+	return c.ignoredHosts
+}
+
+// ListenerTimeouts is a getter for listenerTimeouts.
+func (c *Config) ListenerTimeouts() map[events.Topic]time.Duration {
+	return c.listenerTimeouts
+}
+
+// MaxInstrumentationLatency is a getter for maxInstrumentLatency. It returns 0
+// if the latency is not bounded.
+func (c *Config) MaxInstrumentationLatency() time.Duration {
+	if c == nil {
+		return 0
+	}
+	return c.maxInstrumentLatency
+}
+
+// CaptureCallerStack is a getter for captureCallerStack.
+func (c *Config) CaptureCallerStack() bool {
+	if c == nil {
+		return false
+	}
+	return c.captureCallerStack
+}
+
+// GlobalInstrumentation is a getter for the negation of noGlobalInstrumentation.
+func (c *Config) GlobalInstrumentation() bool {
+	return c == nil || !c.noGlobalInstrumentation
+}
+
+// AutoDecorateClients is a getter for autoDecorateClients.
+func (c *Config) AutoDecorateClients() []*http.Client {
+	return c.autoDecorateClients
+}
+
+// BodyCaptureDenyHosts is a getter for bodyDenyHosts.
+func (c *Config) BodyCaptureDenyHosts() []*regexp.Regexp {
+	return c.bodyDenyHosts
+}
+
+// BodyCaptureStatusCodes is a getter for bodyStatuses.
+func (c *Config) BodyCaptureStatusCodes() []filters.RangeMatcher {
+	return c.bodyStatuses
+}
+
+// SampleRates is a getter for the success and error sample rates.
+func (c *Config) SampleRates() (success float64, errorRate float64) {
+	return c.sampleRateSuccess, c.sampleRateError
+}
+
+// SamplingKey is a getter for samplingKey.
+func (c *Config) SamplingKey() func(*http.Request) string {
+	return c.samplingKey
+}
+
+// MaxLogLevel is a getter for maxLogLevel. It returns nil if the log level
+// is not capped.
+func (c *Config) MaxLogLevel() *interception.LogLevel {
+	return c.maxLogLevel
+}
+
+// DetectAnomalies is a getter for detectAnomalies.
+func (c *Config) DetectAnomalies() bool {
+	return c.detectAnomalies
+}
+
+// EnrichmentHeaders is a getter for enrichmentHeaders.
+func (c *Config) EnrichmentHeaders() map[string]string {
+	return c.enrichmentHeaders
+}
+
+// JWTClaims is a getter for jwtClaims.
+func (c *Config) JWTClaims() []string {
+	return c.jwtClaims
+}
+
+// ErrorClassifier is a getter for errorClassifier.
+func (c *Config) ErrorClassifier() interception.ErrorClassifier {
+	return c.errorClassifier
+}
+
+// HostStatusSuppression is a getter for statusSuppression.
+func (c *Config) HostStatusSuppression() map[string]filters.RangeMatcher {
+	return c.statusSuppression
+}
+
+// CacheIndicatorHeader is a getter for cacheHeader.
+func (c *Config) CacheIndicatorHeader() string {
+	return c.cacheHeader
+}
+
+// RetryCountHeader is a getter for retryCountHeader.
+func (c *Config) RetryCountHeader() string {
+	return c.retryCountHeader
+}
+
+// SpanTracer is a getter for spanTracer.
+func (c *Config) SpanTracer() interception.SpanTracer {
+	return c.spanTracer
+}
+
+// SelfMonitoring is a getter for selfMonitoring.
+func (c *Config) SelfMonitoring() time.Duration {
+	return c.selfMonitoring
+}
+
+// AggregationWindow is a getter for aggregationWindow.
+func (c *Config) AggregationWindow() time.Duration {
+	return c.aggregationWindow
+}
+
+// Authorization is a getter for authorization.
+func (c *Config) Authorization() proxy.Authorization {
+	return c.authorization
+}
+
+// ReportFormat is a getter for reportFormat.
+func (c *Config) ReportFormat() proxy.ReportFormat {
+	return c.reportFormat
+}
+
+// StopGracePeriod is a getter for stopGracePeriod.
+func (c *Config) StopGracePeriod() time.Duration {
+	return c.stopGracePeriod
+}
+
+// ReportRateLimit is a getter for reportRateLimit.
+func (c *Config) ReportRateLimit() float64 {
+	return c.reportRateLimit
+}
+
 // DataCollectionRules returns the active DataCollectionRule instances.
 func (c *Config) DataCollectionRules() []*interception.DataCollectionRule {
 	return c.dataCollectionRules
@@ -271,6 +1498,7 @@ func NewConfig(secretKey string, transport http.RoundTripper, version string, op
 	}
 
 	alwaysOnAfter := []Option{
+		withEnvironmentEndpoints,
 		withRemote(transport, version), // Sets Fetcher.
 	}
 
@@ -303,6 +1531,7 @@ func (c *Config) UpdateFromDescription(description *config.Description) {
 		c.Warn().Msgf(`invalid configuration received from config server: %v`, err)
 		return
 	}
+	description.MaxFilterDepth = c.maxFilterDepth
 	resolved, err := description.ResolveHashes(filterDescriptions)
 	if err != nil {
 		c.Warn().Msgf(`incorrect filter resolution in configuration received from config server: %v`, err)
@@ -30,6 +30,11 @@ const (
 	// exceeded, records are no longer sent to Bearer to avoid saturating the
 	// client.
 	DefaultReportOutstanding = 1000
+
+	// DefaultMaxFilterDepth is the default maximum nesting depth of the filter
+	// trees built from the configuration, bounding the recursion when matching
+	// API calls against them.
+	DefaultMaxFilterDepth = 32
 )
 
 // TraceLogging is set in init() and enabled the default logger for Trace level.
@@ -2,12 +2,16 @@ package config
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -29,6 +33,10 @@ type Description struct {
 		RuleType     string
 	}
 	Error map[string]string
+
+	// MaxFilterDepth is the maximum nesting depth of the filters resolved by
+	// ResolveHashes. 0 means DefaultMaxFilterDepth.
+	MaxFilterDepth int `json:"-"`
 }
 
 func (d Description) String() string {
@@ -82,10 +90,11 @@ func (d Description) FilterDescriptions() (map[string]*filters.FilterDescription
 	for hash, description := range hashes {
 		if description == nil {
 			undefined = append(undefined, hash)
-			break
 		}
 	}
 	if len(undefined) > 0 {
+		// Sort for a stable error message, as map order is random.
+		sort.Strings(undefined)
 		return nil, fmt.Errorf("undefined hashes referenced: %v", undefined)
 	}
 
@@ -94,7 +103,8 @@ func (d Description) FilterDescriptions() (map[string]*filters.FilterDescription
 
 // ResolveHashes builds a filters.FilterMap from the filter descriptions it
 // receives, resolving dependencies to allow instantiation.
-// The function detects cyclic dependencies, and returns errors accordingly.
+// The function detects cyclic dependencies, and filters nested deeper than
+// MaxFilterDepth, and returns errors accordingly.
 //
 // Algorithm inspired by:
 // https://www.electricmonk.nl/docs/dependency_resolving_algorithm/dependency_resolving_algorithm.html
@@ -107,6 +117,12 @@ func (d Description) ResolveHashes(descriptions map[string]*filters.FilterDescri
 
 	resolved := make(filterSlice, 0, len(descriptions))
 	unresolved := make(map[string]*filters.FilterDescription)
+	// depths holds the nesting depth of resolved filters, leaves being at 1.
+	depths := make(map[string]int, len(descriptions))
+	maxDepth := d.MaxFilterDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxFilterDepth
+	}
 
 	resolvedIndexOf := func(sl filterSlice, hash string) int {
 		pos := -1
@@ -119,8 +135,13 @@ func (d Description) ResolveHashes(descriptions map[string]*filters.FilterDescri
 		return pos
 	}
 
-	var resolve func(string, *filters.FilterDescription) error
-	resolve = func(hash string, desc *filters.FilterDescription) error {
+	// resolve tracks the recursion level, to fail before the recursion itself
+	// gets too deep.
+	var resolve func(string, *filters.FilterDescription, int) error
+	resolve = func(hash string, desc *filters.FilterDescription, level int) error {
+		if level > maxDepth {
+			return fmt.Errorf("filter %s nested deeper than the maximum depth %d", hash, maxDepth)
+		}
 		var dependencyHashes []string
 		unresolved[hash] = desc
 		switch desc.TypeName {
@@ -129,17 +150,25 @@ func (d Description) ResolveHashes(descriptions map[string]*filters.FilterDescri
 		case filters.FilterSetFilterType.Name():
 			dependencyHashes = desc.ChildHashes
 		}
+		depth := 1
 		for _, dependencyHash := range dependencyHashes {
 			if resolvedIndexOf(resolved, dependencyHash) == -1 {
 				if _, ok := unresolved[dependencyHash]; ok {
 					return fmt.Errorf("circular hash dependency: %s <-> %s", hash, dependencyHash)
 				}
-				err := resolve(dependencyHash, descriptions[dependencyHash])
+				err := resolve(dependencyHash, descriptions[dependencyHash], level+1)
 				if err != nil {
-					return nil
+					return err
 				}
 			}
+			if depths[dependencyHash]+1 > depth {
+				depth = depths[dependencyHash] + 1
+			}
+		}
+		if depth > maxDepth {
+			return fmt.Errorf("filter %s nested deeper than the maximum depth %d", hash, maxDepth)
 		}
+		depths[hash] = depth
 		if resolvedIndexOf(resolved, hash) == -1 {
 			resolved = append(resolved, struct {
 				hash string
@@ -151,7 +180,7 @@ func (d Description) ResolveHashes(descriptions map[string]*filters.FilterDescri
 	}
 
 	for hash, desc := range descriptions {
-		err := resolve(hash, desc)
+		err := resolve(hash, desc, 1)
 		if err != nil {
 			return nil, err
 		}
@@ -177,8 +206,17 @@ func (d *Description) ResolveDCRs(filterMap filters.FilterMap) ([]*interception.
 	return dcrs, nil
 }
 
+// FetchResult describes the outcome of a configuration fetch.
+type FetchResult struct {
+	At  time.Time
+	Err error
+}
+
 // Fetcher describes the data used to perform the background configuration refresh.
 type Fetcher struct {
+	m               sync.Mutex
+	last            FetchResult
+	authorization   proxy.Authorization
 	done            chan bool
 	endpoint        string
 	environmentType string
@@ -203,22 +241,84 @@ func NewFetcher(transport http.RoundTripper, logger *zerolog.Logger, version str
 	}
 }
 
-// Fetch fetches a fresh configuration from the Bearer platform and assigns it
-// to the current config. As per Agent spec, all config fetch errors are logged
-// and ignored.
-func (f *Fetcher) Fetch() (*Description, error) {
+// SetAuthorization configures how the secret key is passed to the Bearer
+// platform, returning the Fetcher.
+func (f *Fetcher) SetAuthorization(authorization proxy.Authorization) *Fetcher {
+	f.authorization = authorization
+	return f
+}
+
+// ErrSecretKeyRejected is returned by Fetcher.Verify when the Bearer platform
+// rejects the secret key.
+var ErrSecretKeyRejected = errors.New("the Bearer platform rejected the secret key")
+
+// newRequest builds an authenticated configuration request.
+func (f *Fetcher) newRequest() (*http.Request, error) {
 	report := &bytes.Buffer{}
 	// Cannot fail, the only possible error coming from os.Hostname() is handled.
 	_ = json.NewEncoder(report).Encode(proxy.MakeConfigReport(f.version, f.environmentType, ``))
 
 	req, err := http.NewRequest(http.MethodPost, f.endpoint, report)
 	if err != nil {
-		f.logger.Warn().Msgf("building Bearer remote config request: %v", err)
 		return nil, err
 	}
 	req.Header.Add(proxy.AcceptHeader, "application/json")
-	req.Header.Add(proxy.AuthorizationHeader, f.secretKey)
+	f.authorization.Set(req.Header, f.secretKey)
 	req.Header.Set(proxy.ContentTypeHeader, proxy.FullContentTypeJSON)
+	return req, nil
+}
+
+// Verify performs an authenticated configuration request to the Bearer
+// platform, returning ErrSecretKeyRejected if it rejects the secret key, or
+// another error if the request fails for other reasons.
+func (f *Fetcher) Verify(ctx context.Context) error {
+	req, err := f.newRequest()
+	if err != nil {
+		return fmt.Errorf("building Bearer remote config request: %w", err)
+	}
+	client := http.Client{Transport: f.transport}
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("verifying secret key: %w", err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+
+	switch {
+	case res.StatusCode == http.StatusUnauthorized, res.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: status %d", ErrSecretKeyRejected, res.StatusCode)
+	case res.StatusCode != http.StatusOK:
+		return fmt.Errorf("verifying secret key: unexpected status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// LastFetch returns the result of the last configuration fetch, which has a
+// zero At time if none was performed.
+func (f *Fetcher) LastFetch() FetchResult {
+	f.m.Lock()
+	defer f.m.Unlock()
+	return f.last
+}
+
+// Fetch fetches a fresh configuration from the Bearer platform and assigns it
+// to the current config. As per Agent spec, all config fetch errors are logged
+// and ignored.
+func (f *Fetcher) Fetch() (*Description, error) {
+	d, err := f.fetch()
+	f.m.Lock()
+	f.last = FetchResult{At: time.Now(), Err: err}
+	f.m.Unlock()
+	return d, err
+}
+
+// fetch implements Fetch, without recording its result.
+func (f *Fetcher) fetch() (*Description, error) {
+	req, err := f.newRequest()
+	if err != nil {
+		f.logger.Warn().Msgf("building Bearer remote config request: %v", err)
+		return nil, err
+	}
 
 	client := http.Client{Transport: f.transport}
 	res, err := client.Do(req)
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/rs/zerolog"
 
+	"github.com/bearer/go-agent/events"
 	"github.com/bearer/go-agent/filters"
 	"github.com/bearer/go-agent/interception"
 	"github.com/bearer/go-agent/proxy"
@@ -125,6 +127,76 @@ func TestDescription_filterDescriptions(t *testing.T) {
 	}
 }
 
+func TestDescription_filterDescriptions_undefined(t *testing.T) {
+	d := Description{Filters: map[string]filters.FilterDescription{
+		`one`: {TypeName: filters.NotFilterType.Name(), ChildHash: `two`},
+		`set`: {
+			FilterSetDescription: filters.FilterSetDescription{
+				ChildHashes: []string{`one`, `three`, `four`},
+			},
+			TypeName: filters.FilterSetFilterType.Name(),
+		},
+	}}
+	_, err := d.FilterDescriptions()
+	if err == nil {
+		t.Fatal("filterDescriptions() expected error on undefined hashes")
+	}
+	const expected = `undefined hashes referenced: [four three two]`
+	if err.Error() != expected {
+		t.Errorf("filterDescriptions() error = %q, expected %q", err, expected)
+	}
+}
+
+// nestedFilterDescriptions builds a chain of FilterSet descriptions nested
+// depth levels deep, returning the descriptions and the hash of the root.
+func nestedFilterDescriptions(depth int) (map[string]*filters.FilterDescription, string) {
+	descriptions := map[string]*filters.FilterDescription{
+		`f1`: {TypeName: filters.YesInternalFilter.Name()},
+	}
+	for i := 2; i <= depth; i++ {
+		descriptions[fmt.Sprintf("f%d", i)] = &filters.FilterDescription{
+			TypeName: filters.FilterSetFilterType.Name(),
+			FilterSetDescription: filters.FilterSetDescription{
+				ChildHashes: []string{fmt.Sprintf("f%d", i-1)},
+			},
+		}
+	}
+	return descriptions, fmt.Sprintf("f%d", depth)
+}
+
+func TestDescription_ResolveHashesMaxDepth(t *testing.T) {
+	tests := []struct {
+		name    string
+		max     int
+		depth   int
+		wantErr bool
+	}{
+		{`happy at limit`, 5, 5, false},
+		{`sad beyond limit`, 5, 6, true},
+		{`happy default`, 0, DefaultMaxFilterDepth, false},
+		{`sad default`, 0, 10000, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			descriptions, root := nestedFilterDescriptions(tt.depth)
+			d := Description{MaxFilterDepth: tt.max}
+			fm, err := d.ResolveHashes(descriptions)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveHashes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if fm[root] == nil {
+				t.Fatalf("ResolveHashes() did not build the root filter %s", root)
+			}
+			if !fm[root].MatchesCall(events.NewEvent(`test`)) {
+				t.Errorf("nested filter %s did not match", root)
+			}
+		})
+	}
+}
+
 func TestFetcher_Start(t *testing.T) {
 	sb := &strings.Builder{}
 	z := zerolog.New(sb)
@@ -222,3 +294,35 @@ func TestFetcher_Fetch(t *testing.T) {
 		})
 	}
 }
+
+func TestFetcher_FetchAuthorization(t *testing.T) {
+	const key = `app_12345678901234567890123456789012345678901234567890`
+	var actual http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		actual = request.Header
+		_, _ = writer.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	z := zerolog.New(&strings.Builder{})
+
+	tests := []struct {
+		name          string
+		authorization proxy.Authorization
+		wantHeader    string
+		wantValue     string
+	}{
+		{`default`, proxy.Authorization{}, proxy.AuthorizationHeader, key},
+		{`custom`, proxy.Authorization{Header: `X-Api-Key`, Scheme: `Bearer`}, `X-Api-Key`, `Bearer ` + key},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := (&Fetcher{endpoint: ts.URL, logger: &z, secretKey: key}).SetAuthorization(tt.authorization)
+			if _, err := f.Fetch(); err != nil {
+				t.Fatalf(`unexpected Fetch() error: %v`, err)
+			}
+			if got := actual.Get(tt.wantHeader); got != tt.wantValue {
+				t.Errorf(`header %s = %q, want %q`, tt.wantHeader, got, tt.wantValue)
+			}
+		})
+	}
+}
@@ -1,12 +1,23 @@
 package agent_test
 
 import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/bearer/go-agent"
+	"github.com/bearer/go-agent/config"
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/filters"
+	"github.com/bearer/go-agent/interception"
+	"github.com/bearer/go-agent/proxy"
 )
 
 // TODO improve tests to avoid calling the config server.
@@ -81,7 +92,7 @@ func TestConfig_WithSensitiveKeys(t *testing.T) {
 		expected []string
 	}
 	tests := []testType{
-		{"nil", nil, false, nil},
+		{"nil", nil, false, []string{interception.DefaultSensitiveKeys.String()}},
 		{"empty", []string{}, false, []string{}},
 		{"normal", []string{"one", "two"}, false, []string{"one", "two"}},
 		{"duplicated", []string{"one", "two", "one"}, false, []string{"one", "two"}},
@@ -126,7 +137,7 @@ func TestConfig_WithSensitiveRegexes(t *testing.T) {
 	reOne := regexp.MustCompile("one")
 	reTwo := regexp.MustCompile("two")
 	tests := []testType{
-		{"nil", nil, false, nil},
+		{"nil", nil, false, []*regexp.Regexp{interception.DefaultSensitiveData}},
 		{"empty", []string{}, false, []*regexp.Regexp{}},
 		{"normal", []string{"one", "two"}, false, []*regexp.Regexp{reOne, reTwo}},
 		{"duplicated", []string{"one", "two", "one"}, false, []*regexp.Regexp{reOne, reTwo}},
@@ -155,6 +166,39 @@ func TestConfig_WithSensitiveRegexes(t *testing.T) {
 	}
 }
 
+func TestConfig_SensitiveDataStates(t *testing.T) {
+	tests := []struct {
+		name             string
+		opts             []agent.Option
+		keys, regexps    int
+		expectedDisabled bool
+	}{
+		{`default`, nil, 1, 1, false},
+		{`added`, []agent.Option{agent.WithSensitiveKeys([]string{`a`, `b`}), agent.WithSensitiveRegexps([]string{`c`})}, 2, 1, false},
+		{`defaults kept on nil`, []agent.Option{agent.WithSensitiveKeys(nil), agent.WithSensitiveRegexps(nil)}, 1, 1, false},
+		{`keys disabled`, []agent.Option{agent.WithNoSensitiveKeys()}, 0, 1, false},
+		{`data disabled`, []agent.Option{agent.WithNoSensitiveData()}, 1, 0, false},
+		{`disabled`, []agent.Option{agent.WithNoSensitiveKeys(), agent.WithNoSensitiveData()}, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewConfig error = %v", err)
+			}
+			if actual := len(c.SensitiveKeys()); actual != tt.keys {
+				t.Errorf("got %d sensitive keys, expected %d", actual, tt.keys)
+			}
+			if actual := len(c.SensitiveRegexps()); actual != tt.regexps {
+				t.Errorf("got %d sensitive regexps, expected %d", actual, tt.regexps)
+			}
+			if actual := c.RedactionDisabled(); actual != tt.expectedDisabled {
+				t.Errorf("RedactionDisabled() = %t, expected %t", actual, tt.expectedDisabled)
+			}
+		})
+	}
+}
+
 func TestConfig_WithEndpoints(t *testing.T) {
 	const expected = "http://report.example.com/data"
 	c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
@@ -168,3 +212,1311 @@ func TestConfig_WithEndpoints(t *testing.T) {
 		t.Errorf("incorrect report endpoint: expected %s, got %s", expected, actual)
 	}
 }
+
+func TestConfig_WithEndpointsForEnvironment(t *testing.T) {
+	endpoints := map[string]agent.Endpoints{
+		`staging`: {Fetch: `http://fetch.staging.example.com/cfg`, Report: `http://report.staging.example.com/logs`},
+		`dev`:     {Report: `http://report.dev.example.com/logs`},
+	}
+	tests := []struct {
+		name           string
+		opts           []agent.Option
+		expectedFetch  string
+		expectedReport string
+	}{
+		{`mapped`, []agent.Option{agent.WithEnvironment(`staging`)},
+			`http://fetch.staging.example.com/cfg`, `http://report.staging.example.com/logs`},
+		{`partially mapped`, []agent.Option{agent.WithEnvironment(`dev`)},
+			config.DefaultConfigEndpoint, `http://report.dev.example.com/logs`},
+		{`unmapped`, []agent.Option{agent.WithEnvironment(`production`)},
+			config.DefaultConfigEndpoint, config.DefaultReportEndpoint},
+		{`no environment`, nil,
+			config.DefaultConfigEndpoint, config.DefaultReportEndpoint},
+		{`unmapped with endpoints`, []agent.Option{
+			agent.WithEnvironment(`production`),
+			agent.WithEndpoints(`http://fetch.example.com/cfg`, `http://report.example.com/logs`),
+		}, `http://fetch.example.com/cfg`, `http://report.example.com/logs`},
+		{`mapped with endpoints`, []agent.Option{
+			agent.WithEndpoints(`http://fetch.example.com/cfg`, `http://report.example.com/logs`),
+			agent.WithEnvironment(`staging`),
+		}, `http://fetch.staging.example.com/cfg`, `http://report.staging.example.com/logs`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]agent.Option{agent.WithEndpointsForEnvironment(endpoints)}, tt.opts...)
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version, opts...)
+			if err != nil {
+				t.Fatalf("NewConfig error = %v", err)
+			}
+			if actual := c.FetchEndpoint(); actual != tt.expectedFetch {
+				t.Errorf("incorrect fetch endpoint: expected %s, got %s", tt.expectedFetch, actual)
+			}
+			if actual := c.ReportEndpoint; actual != tt.expectedReport {
+				t.Errorf("incorrect report endpoint: expected %s, got %s", tt.expectedReport, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithMirrorEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		wantFail bool
+	}{
+		{`none`, ``, false},
+		{`happy`, `https://collector.internal/logs`, false},
+		{`sad relative`, `/logs`, true},
+		{`sad scheme`, `ftp://collector.internal/logs`, true},
+		{`sad invalid`, `http://[::1`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithMirrorEndpoint(tt.endpoint),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.MirrorEndpoint(); actual != tt.endpoint {
+				t.Errorf("incorrect mirror endpoint: expected %s, got %s", tt.endpoint, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithSendDelayMeasurement(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+			agent.WithSendDelayMeasurement(enabled),
+		)
+		if err != nil {
+			t.Fatalf("NewConfig error = %v", err)
+		}
+		if actual := c.SendDelayMeasurement(); actual != enabled {
+			t.Errorf("incorrect send delay measurement: expected %t, got %t", enabled, actual)
+		}
+	}
+}
+
+func TestConfig_WithReportRateLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     float64
+		wantFail bool
+	}{
+		{`unlimited`, 0, false},
+		{`limited`, 12.5, false},
+		{`negative`, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithReportRateLimit(tt.rate),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.ReportRateLimit(); actual != tt.rate {
+				t.Errorf("incorrect report rate limit: expected %f, got %f", tt.rate, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithStopGracePeriod(t *testing.T) {
+	tests := []struct {
+		name     string
+		period   time.Duration
+		wantFail bool
+	}{
+		{`no wait`, 0, false},
+		{`waiting`, time.Second, false},
+		{`negative`, -time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithStopGracePeriod(tt.period),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.StopGracePeriod(); actual != tt.period {
+				t.Errorf("incorrect stop grace period: expected %v, got %v", tt.period, actual)
+			}
+		})
+	}
+
+	c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version)
+	if err != nil {
+		t.Fatalf("NewConfig error = %v", err)
+	}
+	if actual := c.StopGracePeriod(); actual != proxy.DefaultStopGracePeriod {
+		t.Errorf("incorrect default stop grace period: expected %v, got %v", proxy.DefaultStopGracePeriod, actual)
+	}
+}
+
+func TestConfig_WithSampleRates(t *testing.T) {
+	tests := []struct {
+		name               string
+		success, errorRate float64
+		wantFail           bool
+	}{
+		{`happy`, 0.1, 1, false},
+		{`sad success too high`, 1.5, 1, true},
+		{`sad negative error rate`, 1, -0.5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithSampleRates(tt.success, tt.errorRate),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if success, errorRate := c.SampleRates(); success != tt.success || errorRate != tt.errorRate {
+				t.Errorf("incorrect sample rates: expected %f/%f, got %f/%f",
+					tt.success, tt.errorRate, success, errorRate)
+			}
+		})
+	}
+}
+
+func TestConfig_WithSamplingKey(t *testing.T) {
+	c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version)
+	if err != nil {
+		t.Fatalf("failed building default config: %v", err)
+	}
+	if c.SamplingKey() != nil {
+		t.Error("expected no default sampling key")
+	}
+
+	c, err = agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+		agent.WithSamplingKey(func(r *http.Request) string {
+			return r.Header.Get(`X-User-ID`)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("failed building config with sampling key: %v", err)
+	}
+	key := c.SamplingKey()
+	if key == nil {
+		t.Fatal("expected a sampling key")
+	}
+	req, _ := http.NewRequest(http.MethodGet, `https://example.com`, nil)
+	req.Header.Set(`X-User-ID`, `42`)
+	if actual := key(req); actual != `42` {
+		t.Errorf("incorrect sampling key: expected 42, got %s", actual)
+	}
+}
+
+func TestConfig_WithAuthorization(t *testing.T) {
+	tests := []struct {
+		name           string
+		header, scheme string
+		wantFail       bool
+	}{
+		{`default`, ``, ``, false},
+		{`custom`, `X-Api-Key`, `Bearer`, false},
+		{`sad header`, `X Api Key`, ``, true},
+		{`sad scheme`, ``, `Bear:er`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithAuthorization(tt.header, tt.scheme),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if a := c.Authorization(); a.Header != tt.header || a.Scheme != tt.scheme {
+				t.Errorf("incorrect authorization: expected %s/%s, got %s/%s",
+					tt.header, tt.scheme, a.Header, a.Scheme)
+			}
+		})
+	}
+}
+
+func TestConfig_WithRetryCountHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		wantFail bool
+	}{
+		{`none`, ``, false},
+		{`happy`, `X-Retry-Count`, false},
+		{`sad`, `X Retry Count`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithRetryCountHeader(tt.header),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.RetryCountHeader(); actual != tt.header {
+				t.Errorf("incorrect retry count header: expected %s, got %s", tt.header, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithCacheIndicatorHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		wantFail bool
+	}{
+		{`none`, ``, false},
+		{`happy`, `X-Cache`, false},
+		{`sad`, `X Cache`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithCacheIndicatorHeader(tt.header),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.CacheIndicatorHeader(); actual != tt.header {
+				t.Errorf("incorrect cache indicator header: expected %s, got %s", tt.header, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithEnrichmentHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		wantFail bool
+	}{
+		{`none`, nil, false},
+		{`happy`, map[string]string{`CF-Ray`: `edge`, `X-Served-By`: `region`}, false},
+		{`sad header`, map[string]string{`CF Ray`: `edge`}, true},
+		{`sad field`, map[string]string{`CF-Ray`: ``}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithEnrichmentHeaders(tt.headers),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.EnrichmentHeaders(); len(actual) != len(tt.headers) || (len(actual) > 0 && !reflect.DeepEqual(actual, tt.headers)) {
+				t.Errorf("incorrect enrichment headers: expected %v, got %v", tt.headers, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithJWTClaims(t *testing.T) {
+	tests := []struct {
+		name     string
+		claims   []string
+		wantFail bool
+	}{
+		{`none`, nil, false},
+		{`happy`, []string{`iss`, `aud`, `sub`}, false},
+		{`sad empty`, []string{`iss`, ``}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithJWTClaims(tt.claims...),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.JWTClaims(); !reflect.DeepEqual(actual, tt.claims) {
+				t.Errorf("incorrect JWT claims: expected %v, got %v", tt.claims, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithErrorClassifier(t *testing.T) {
+	c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version)
+	if err != nil {
+		t.Fatalf("failed building default config: %v", err)
+	}
+	if c.ErrorClassifier() != nil {
+		t.Error("expected no default error classifier")
+	}
+
+	c, err = agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+		agent.WithErrorClassifier(func(err error) (string, bool) {
+			return `CUSTOM`, err == io.ErrUnexpectedEOF
+		}),
+	)
+	if err != nil {
+		t.Fatalf("failed building config with error classifier: %v", err)
+	}
+	classifier := c.ErrorClassifier()
+	if classifier == nil {
+		t.Fatal("expected an error classifier")
+	}
+	if code, ok := classifier(io.ErrUnexpectedEOF); !ok || code != `CUSTOM` {
+		t.Errorf("incorrect classification: expected CUSTOM, got %s, %t", code, ok)
+	}
+}
+
+func TestConfig_WithHostStatusSuppression(t *testing.T) {
+	successes := filters.NewRangeMatcher().From(200).To(300).ExcludeTo()
+	tests := []struct {
+		name     string
+		hosts    map[string]filters.RangeMatcher
+		expected map[string]filters.RangeMatcher
+		wantFail bool
+	}{
+		{`none`, nil, nil, false},
+		{`happy`, map[string]filters.RangeMatcher{`Health.example.com`: successes},
+			map[string]filters.RangeMatcher{`health.example.com`: successes}, false},
+		{`sad host`, map[string]filters.RangeMatcher{``: successes}, nil, true},
+		{`sad range`, map[string]filters.RangeMatcher{`health.example.com`: nil}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithHostStatusSuppression(tt.hosts),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.HostStatusSuppression(); len(actual) != len(tt.expected) || (len(actual) > 0 && !reflect.DeepEqual(actual, tt.expected)) {
+				t.Errorf("incorrect status suppression: expected %v, got %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithMaxLogLevel(t *testing.T) {
+	c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version)
+	if err != nil {
+		t.Fatalf("failed building default config: %v", err)
+	}
+	if c.MaxLogLevel() != nil {
+		t.Errorf("expected no default log level cap, got %v", *c.MaxLogLevel())
+	}
+
+	c, err = agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+		agent.WithMaxLogLevel(interception.Restricted),
+	)
+	if err != nil {
+		t.Fatalf("failed building config with log level cap: %v", err)
+	}
+	if actual := c.MaxLogLevel(); actual == nil || *actual != interception.Restricted {
+		t.Errorf("incorrect log level cap: expected %v, got %v", interception.Restricted, actual)
+	}
+}
+
+func TestConfig_WithSelfMonitoring(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		wantFail bool
+	}{
+		{`disabled`, 0, false},
+		{`happy`, time.Minute, false},
+		{`sad negative`, -time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithSelfMonitoring(tt.interval),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.SelfMonitoring(); actual != tt.interval {
+				t.Errorf("incorrect self-monitoring interval: expected %v, got %v", tt.interval, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithReportFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   proxy.ReportFormat
+		wantFail bool
+	}{
+		{`json`, proxy.FormatJSON, false},
+		{`protobuf`, proxy.FormatProtobuf, false},
+		{`sad unknown`, proxy.ReportFormat(42), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithReportFormat(tt.format),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.ReportFormat(); actual != tt.format {
+				t.Errorf("incorrect report format: expected %d, got %d", tt.format, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithAggregationWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		window   time.Duration
+		wantFail bool
+	}{
+		{`disabled`, 0, false},
+		{`enabled`, 5 * time.Second, false},
+		{`sad negative`, -time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithAggregationWindow(tt.window),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.AggregationWindow(); actual != tt.window {
+				t.Errorf("incorrect aggregation window: expected %v, got %v", tt.window, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithAnomalyDetection(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+			agent.WithAnomalyDetection(enabled),
+		)
+		if err != nil {
+			t.Fatalf("NewConfig error = %v", err)
+		}
+		if actual := c.DetectAnomalies(); actual != enabled {
+			t.Errorf("incorrect anomaly detection: expected %t, got %t", enabled, actual)
+		}
+	}
+}
+
+func TestConfig_WithListenerTimeout(t *testing.T) {
+	c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+		agent.WithListenerTimeout(interception.TopicBodies, 50*time.Millisecond),
+		agent.WithListenerTimeout(interception.TopicReport, time.Second),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig error = %v", err)
+	}
+	expected := map[events.Topic]time.Duration{
+		interception.TopicBodies: 50 * time.Millisecond,
+		interception.TopicReport: time.Second,
+	}
+	if actual := c.ListenerTimeouts(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("incorrect listener timeouts: expected %v, got %v", expected, actual)
+	}
+
+	_, err = agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+		agent.WithListenerTimeout(interception.TopicBodies, 0),
+	)
+	if err == nil {
+		t.Error("expected an error for a non-positive listener timeout")
+	}
+}
+
+func TestConfig_WithMaxInstrumentationLatency(t *testing.T) {
+	tests := []struct {
+		name     string
+		latency  time.Duration
+		wantFail bool
+	}{
+		{`happy`, 50 * time.Millisecond, false},
+		{`sad zero`, 0, true},
+		{`sad negative`, -time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithMaxInstrumentationLatency(tt.latency),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if err == nil && c.MaxInstrumentationLatency() != tt.latency {
+				t.Errorf("incorrect max instrumentation latency: expected %v, got %v", tt.latency, c.MaxInstrumentationLatency())
+			}
+		})
+	}
+}
+
+func TestConfig_WithGlobalInstrumentation(t *testing.T) {
+	c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version)
+	if err != nil {
+		t.Fatalf("NewConfig error = %v", err)
+	}
+	if !c.GlobalInstrumentation() {
+		t.Error("expected global instrumentation by default")
+	}
+
+	for _, enabled := range []bool{false, true} {
+		c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+			agent.WithGlobalInstrumentation(enabled),
+		)
+		if err != nil {
+			t.Fatalf("NewConfig error = %v", err)
+		}
+		if actual := c.GlobalInstrumentation(); actual != enabled {
+			t.Errorf("incorrect global instrumentation: expected %t, got %t", enabled, actual)
+		}
+	}
+}
+
+func TestConfig_WithAutoDecorateClients(t *testing.T) {
+	first, second := &http.Client{}, &http.Client{}
+	c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+		agent.WithAutoDecorateClients(first),
+		agent.WithAutoDecorateClients(second),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig error = %v", err)
+	}
+	actual := c.AutoDecorateClients()
+	if len(actual) != 2 || actual[0] != first || actual[1] != second {
+		t.Errorf("incorrect auto-decorated clients: %v", actual)
+	}
+
+	_, err = agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+		agent.WithAutoDecorateClients(first, nil),
+	)
+	if err == nil {
+		t.Error("expected an error on nil client")
+	}
+}
+
+func TestConfig_WithCoalescedSanitization(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+			agent.WithCoalescedSanitization(enabled),
+		)
+		if err != nil {
+			t.Fatalf("NewConfig error = %v", err)
+		}
+		if actual := c.CoalescedSanitization(); actual != enabled {
+			t.Errorf("incorrect coalesced sanitization: expected %t, got %t", enabled, actual)
+		}
+	}
+}
+
+func TestConfig_WithMatchExplanations(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+			agent.WithMatchExplanations(enabled),
+		)
+		if err != nil {
+			t.Fatalf("NewConfig error = %v", err)
+		}
+		if actual := c.MatchExplanations(); actual != enabled {
+			t.Errorf("incorrect match explanations: expected %t, got %t", enabled, actual)
+		}
+	}
+}
+
+func TestConfig_WithCaptureCallerStack(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+			agent.WithCaptureCallerStack(enabled),
+		)
+		if err != nil {
+			t.Fatalf("NewConfig error = %v", err)
+		}
+		if actual := c.CaptureCallerStack(); actual != enabled {
+			t.Errorf("incorrect caller stack capture: expected %t, got %t", enabled, actual)
+		}
+	}
+}
+
+func TestConfig_WithStreamBuffering(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+			agent.WithStreamBuffering(enabled),
+		)
+		if err != nil {
+			t.Fatalf("NewConfig error = %v", err)
+		}
+		if actual := c.StreamBuffering(); actual != enabled {
+			t.Errorf("incorrect stream buffering: expected %t, got %t", enabled, actual)
+		}
+	}
+}
+
+func TestConfig_WithStrictSanitization(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+			agent.WithStrictSanitization(enabled),
+		)
+		if err != nil {
+			t.Fatalf("NewConfig error = %v", err)
+		}
+		if actual := c.StrictSanitization(); actual != enabled {
+			t.Errorf("incorrect strict sanitization: expected %t, got %t", enabled, actual)
+		}
+	}
+}
+
+func TestConfig_WithRedactionStrategy(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy interception.RedactionStrategy
+		key      []byte
+		wantFail bool
+	}{
+		{`mask`, interception.RedactionMask, nil, false},
+		{`hash`, interception.RedactionHash, []byte(`key`), false},
+		{`remove`, interception.RedactionRemove, nil, false},
+		{`sad hash without key`, interception.RedactionHash, nil, true},
+		{`sad unknown`, interception.RedactionStrategy(42), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithRedactionStrategy(tt.strategy, tt.key),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			strategy, key := c.RedactionStrategy()
+			if strategy != tt.strategy || string(key) != string(tt.key) {
+				t.Errorf("incorrect redaction strategy: expected %d/%q, got %d/%q", tt.strategy, tt.key, strategy, key)
+			}
+		})
+	}
+}
+
+func TestConfig_WithRulePrecedence(t *testing.T) {
+	tests := []struct {
+		name       string
+		precedence interception.RulePrecedence
+		wantFail   bool
+	}{
+		{`last wins`, interception.LastWins, false},
+		{`first wins`, interception.FirstWins, false},
+		{`sad unknown`, interception.RulePrecedence(42), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithRulePrecedence(tt.precedence),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.RulePrecedence(); actual != tt.precedence {
+				t.Errorf("incorrect rule precedence: expected %d, got %d", tt.precedence, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithRedactionAudit(t *testing.T) {
+	for _, w := range []io.Writer{nil, &bytes.Buffer{}} {
+		c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+			agent.WithRedactionAudit(w),
+		)
+		if err != nil {
+			t.Fatalf("NewConfig error = %v", err)
+		}
+		if actual := c.RedactionAudit(); actual != w {
+			t.Errorf("incorrect redaction audit writer: expected %v, got %v", w, actual)
+		}
+	}
+}
+
+func TestConfig_WithExcludedBodyFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		paths    []string
+		wantFail bool
+	}{
+		{`none`, nil, false},
+		{`happy`, []string{`image`, `data.image`}, false},
+		{`sad empty`, []string{``}, true},
+		{`sad empty segment`, []string{`data..image`}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithExcludedBodyFields(tt.paths...),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.ExcludedBodyFields(); !reflect.DeepEqual(actual, tt.paths) {
+				t.Errorf("incorrect excluded body fields: expected %v, got %v", tt.paths, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithBodyPreview(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxBytes int
+		wantFail bool
+	}{
+		{`disabled`, 0, false},
+		{`happy`, 256, false},
+		{`maximum`, interception.MaxBodyPreview, false},
+		{`sad negative`, -1, true},
+		{`sad too large`, interception.MaxBodyPreview + 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithBodyPreview(tt.maxBytes),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.BodyPreview(); actual != tt.maxBytes {
+				t.Errorf("incorrect body preview: expected %d, got %d", tt.maxBytes, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithMaxBodySizes(t *testing.T) {
+	tests := []struct {
+		name              string
+		request, response int
+		wantFail          bool
+	}{
+		{`default`, 0, 0, false},
+		{`happy`, 1024, 64, false},
+		{`maximum`, interception.MaximumBodySize, interception.MaximumBodySize, false},
+		{`sad negative request`, -1, 0, true},
+		{`sad too large response`, 0, interception.MaximumBodySize + 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithMaxRequestBodySize(tt.request),
+				agent.WithMaxResponseBodySize(tt.response),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.MaxRequestBodySize(); actual != tt.request {
+				t.Errorf("incorrect maximum request body size: expected %d, got %d", tt.request, actual)
+			}
+			if actual := c.MaxResponseBodySize(); actual != tt.response {
+				t.Errorf("incorrect maximum response body size: expected %d, got %d", tt.response, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithMaxReportedRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		max      int
+		wantFail bool
+	}{
+		{`unlimited`, 0, false},
+		{`happy`, 10, false},
+		{`sad negative`, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithMaxReportedRules(tt.max),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.MaxReportedRules(); actual != tt.max {
+				t.Errorf("incorrect maximum reported rules: expected %d, got %d", tt.max, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithShapeDiscoveryMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		n        int
+		wantFail bool
+	}{
+		{`happy`, 5, false},
+		{`sad zero`, 0, true},
+		{`sad negative`, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithShapeDiscoveryMode(tt.n),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.ShapeDiscoveryMode(); actual != tt.n {
+				t.Errorf("incorrect shape discovery reports: expected %d, got %d", tt.n, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithLazyBodyParsing(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+			agent.WithLazyBodyParsing(enabled),
+		)
+		if err != nil {
+			t.Fatalf("NewConfig error = %v", err)
+		}
+		if actual := c.LazyBodyParsing(); actual != enabled {
+			t.Errorf("incorrect lazy body parsing: expected %t, got %t", enabled, actual)
+		}
+	}
+}
+
+func TestConfig_WithContentSniffing(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+			agent.WithContentSniffing(enabled),
+		)
+		if err != nil {
+			t.Fatalf("NewConfig error = %v", err)
+		}
+		if actual := c.ContentSniffing(); actual != enabled {
+			t.Errorf("incorrect content sniffing: expected %t, got %t", enabled, actual)
+		}
+	}
+}
+
+func TestConfig_WithQueryAsBody(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+			agent.WithQueryAsBody(enabled),
+		)
+		if err != nil {
+			t.Fatalf("NewConfig error = %v", err)
+		}
+		if actual := c.QueryAsBody(); actual != enabled {
+			t.Errorf("incorrect query as body: expected %t, got %t", enabled, actual)
+		}
+	}
+}
+
+func TestConfig_WithMaxBodyDepth(t *testing.T) {
+	tests := []struct {
+		name     string
+		depth    int
+		wantFail bool
+	}{
+		{`unlimited`, 0, false},
+		{`limited`, 32, false},
+		{`sad negative`, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithMaxBodyDepth(tt.depth),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.MaxBodyDepth(); actual != tt.depth {
+				t.Errorf("incorrect maximum body depth: expected %d, got %d", tt.depth, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithMaxQueryParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		max      int
+		wantFail bool
+	}{
+		{`unlimited`, 0, false},
+		{`limited`, 50, false},
+		{`sad negative`, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithMaxQueryParams(tt.max),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.MaxQueryParams(); actual != tt.max {
+				t.Errorf("incorrect maximum query parameters: expected %d, got %d", tt.max, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithMaxFilterDepth(t *testing.T) {
+	tests := []struct {
+		name     string
+		depth    int
+		wantFail bool
+	}{
+		{`happy`, 8, false},
+		{`sad zero`, 0, true},
+		{`sad negative`, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithMaxFilterDepth(tt.depth),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.MaxFilterDepth(); actual != tt.depth {
+				t.Errorf("incorrect maximum filter depth: expected %d, got %d", tt.depth, actual)
+			}
+		})
+	}
+
+	c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version)
+	if err != nil {
+		t.Fatalf("NewConfig error = %v", err)
+	}
+	if actual := c.MaxFilterDepth(); actual != config.DefaultMaxFilterDepth {
+		t.Errorf("incorrect default maximum filter depth: expected %d, got %d", config.DefaultMaxFilterDepth, actual)
+	}
+}
+
+func TestConfig_WithMaxHosts(t *testing.T) {
+	tests := []struct {
+		name     string
+		max      int
+		wantFail bool
+	}{
+		{`happy`, 10, false},
+		{`sad zero`, 0, true},
+		{`sad negative`, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithMaxHosts(tt.max),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.MaxHosts(); actual != tt.max {
+				t.Errorf("incorrect maximum hosts: expected %d, got %d", tt.max, actual)
+			}
+		})
+	}
+
+	c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version)
+	if err != nil {
+		t.Fatalf("NewConfig error = %v", err)
+	}
+	if actual := c.MaxHosts(); actual != interception.DefaultMaxHosts {
+		t.Errorf("incorrect default maximum hosts: expected %d, got %d", interception.DefaultMaxHosts, actual)
+	}
+}
+
+func TestConfig_WithBodyDigests(t *testing.T) {
+	tests := []struct {
+		name       string
+		algorithms []string
+		wantFail   bool
+	}{
+		{`none`, nil, false},
+		{`happy`, []string{`md5`, `sha1`}, false},
+		{`sad unknown`, []string{`md5`, `crc32`}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithBodyDigests(tt.algorithms...),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.BodyDigests(); !reflect.DeepEqual(actual, tt.algorithms) {
+				t.Errorf("incorrect body digests: expected %v, got %v", tt.algorithms, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithMaxConcurrentHashes(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    int
+		skip     bool
+		wantFail bool
+	}{
+		{`unlimited`, 0, false, false},
+		{`waiting`, 4, false, false},
+		{`skipping`, 4, true, false},
+		{`sad negative`, -1, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithMaxConcurrentHashes(tt.limit, tt.skip),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if limit, skip := c.MaxConcurrentHashes(); limit != tt.limit || skip != tt.skip {
+				t.Errorf("incorrect hashes limit: expected %d/%t, got %d/%t", tt.limit, tt.skip, limit, skip)
+			}
+		})
+	}
+}
+
+func TestConfig_WithIgnoredHosts(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		wantFail bool
+	}{
+		{`none`, nil, false},
+		{`happy`, []string{`^localhost$`, `\.internal$`}, false},
+		{`sad empty`, []string{``}, true},
+		{`sad invalid`, []string{`[`}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithIgnoredHosts(tt.patterns...),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			actual := c.IgnoredHosts()
+			if len(actual) != len(tt.patterns) {
+				t.Fatalf("incorrect ignored hosts: expected %v, got %v", tt.patterns, actual)
+			}
+			for i, re := range actual {
+				if re.String() != tt.patterns[i] {
+					t.Errorf("incorrect ignored host %d: expected %s, got %s", i, tt.patterns[i], re)
+				}
+			}
+		})
+	}
+}
+
+func TestConfig_WithBodyCaptureStatusCodes(t *testing.T) {
+	failures := filters.NewRangeMatcher().From(400)
+	tests := []struct {
+		name     string
+		ranges   []filters.RangeMatcher
+		wantFail bool
+	}{
+		{`none`, nil, false},
+		{`happy`, []filters.RangeMatcher{failures}, false},
+		{`sad nil`, []filters.RangeMatcher{failures, nil}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithBodyCaptureStatusCodes(tt.ranges...),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			if actual := c.BodyCaptureStatusCodes(); !reflect.DeepEqual(actual, tt.ranges) {
+				t.Errorf("incorrect body capture status codes: expected %v, got %v", tt.ranges, actual)
+			}
+		})
+	}
+}
+
+func TestConfig_WithBodyCaptureDenyHosts(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		wantFail bool
+	}{
+		{`none`, nil, false},
+		{`happy`, []string{`^payments\.`, `\.bank$`}, false},
+		{`sad empty`, []string{``}, true},
+		{`sad invalid`, []string{`[`}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithBodyCaptureDenyHosts(tt.patterns...),
+			)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewConfig error = %v, wantFail %t", err, tt.wantFail)
+			}
+			if tt.wantFail {
+				return
+			}
+			actual := c.BodyCaptureDenyHosts()
+			if len(actual) != len(tt.patterns) {
+				t.Fatalf("incorrect body capture deny hosts: expected %v, got %v", tt.patterns, actual)
+			}
+			for i, re := range actual {
+				if re.String() != tt.patterns[i] {
+					t.Errorf("incorrect body capture deny host %d: expected %s, got %s", i, tt.patterns[i], re)
+				}
+			}
+		})
+	}
+}
+
+func TestConfig_WithRequireRemoteConfig(t *testing.T) {
+	// A closed server provides an unreachable configuration endpoint.
+	ts := httptest.NewServer(http.NotFoundHandler())
+	unreachable := ts.URL
+	ts.Close()
+
+	tests := []struct {
+		name         string
+		require      bool
+		wantErr      bool
+		wantDisabled bool
+	}{
+		{`lenient`, false, false, true},
+		{`strict`, true, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+				agent.WithEndpoints(unreachable, unreachable),
+				agent.WithRequireRemoteConfig(tt.require),
+			)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewConfig error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				a := agent.New(agent.ExampleWellFormedInvalidKey,
+					agent.WithEndpoints(unreachable, unreachable),
+					agent.WithRequireRemoteConfig(tt.require),
+				)
+				if a.Error() == nil {
+					t.Error("New() did not report the configuration error")
+				}
+				return
+			}
+			if c.IsDisabled() != tt.wantDisabled {
+				t.Errorf("IsDisabled() = %t, want %t", c.IsDisabled(), tt.wantDisabled)
+			}
+		})
+	}
+}
+
+type testSpanTracer struct{}
+
+func (testSpanTracer) EmitSpan(context.Context, interception.Span) {}
+
+func TestConfig_WithSpanTracer(t *testing.T) {
+	c, err := agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version)
+	if err != nil {
+		t.Fatalf("NewConfig error = %v", err)
+	}
+	if actual := c.SpanTracer(); actual != nil {
+		t.Errorf("incorrect default span tracer: expected nil, got %v", actual)
+	}
+
+	tracer := testSpanTracer{}
+	c, err = agent.NewConfig(agent.ExampleWellFormedInvalidKey, nil, agent.Version,
+		agent.WithSpanTracer(tracer),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig error = %v", err)
+	}
+	if actual := c.SpanTracer(); actual != tracer {
+		t.Errorf("incorrect span tracer: expected %v, got %v", tracer, actual)
+	}
+}
@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bearer/go-agent/proxy"
+)
+
+// debugHealth is the health section of the DebugHandler output.
+type debugHealth struct {
+	Status string `json:"status"` // "ok", "paused", or "error".
+	Error  string `json:"error,omitempty"`
+}
+
+// debugConfig is the configuration section of the DebugHandler output.
+type debugConfig struct {
+	SecretKey      string `json:"secretKey"` // Masked.
+	Environment    string `json:"environment"`
+	FetchEndpoint  string `json:"fetchEndpoint"`
+	ReportEndpoint string `json:"reportEndpoint"`
+	Version        string `json:"version"`
+}
+
+// debugRule is an element of the rules section of the DebugHandler output.
+type debugRule struct {
+	FilterHash string `json:"filterHash,omitempty"`
+	Signature  string `json:"signature,omitempty"`
+	LogLevel   string `json:"logLevel,omitempty"`
+	IsActive   *bool  `json:"isActive,omitempty"`
+}
+
+// debugFetch is the last config fetch section of the DebugHandler output.
+type debugFetch struct {
+	At    time.Time `json:"at"`
+	Error string    `json:"error,omitempty"`
+}
+
+// debugInfo is the DebugHandler output.
+type debugInfo struct {
+	Health    debugHealth        `json:"health"`
+	Config    *debugConfig       `json:"config,omitempty"`
+	Sender    *proxy.SenderStats `json:"sender,omitempty"`
+	Rules     []debugRule        `json:"rules"`
+	LastFetch *debugFetch        `json:"lastFetch,omitempty"`
+}
+
+// maskSecretKey masks all of a secret key but its prefix and last 4 characters.
+func maskSecretKey(key string) string {
+	const visible = 4
+	if len(key) <= 2*visible {
+		return strings.Repeat(`*`, len(key))
+	}
+	return key[:visible] + strings.Repeat(`*`, len(key)-2*visible) + key[len(key)-visible:]
+}
+
+// debugInfo collects the agent internals exposed by the DebugHandler.
+func (a *Agent) debugInfo() debugInfo {
+	info := debugInfo{
+		Health: debugHealth{Status: `ok`},
+		Rules:  []debugRule{},
+	}
+	if err := a.Error(); err != nil {
+		info.Health = debugHealth{Status: `error`, Error: err.Error()}
+	} else if a.IsPaused() {
+		info.Health.Status = `paused`
+	}
+	if a.sender != nil {
+		stats := a.sender.Stats()
+		info.Sender = &stats
+	}
+
+	c := a.config
+	if c == nil {
+		return info
+	}
+	info.Config = &debugConfig{
+		SecretKey:      maskSecretKey(c.SecretKey()),
+		Environment:    c.Environment(),
+		FetchEndpoint:  c.fetchEndpoint,
+		ReportEndpoint: c.ReportEndpoint,
+		Version:        Version,
+	}
+
+	c.Lock()
+	dcrs := c.DataCollectionRules()
+	c.Unlock()
+	for _, dcr := range dcrs {
+		rule := debugRule{
+			FilterHash: dcr.FilterHash,
+			Signature:  dcr.Signature,
+			IsActive:   dcr.IsActive,
+		}
+		if dcr.LogLevel != nil {
+			rule.LogLevel = strings.ToUpper(dcr.LogLevel.String())
+		}
+		info.Rules = append(info.Rules, rule)
+	}
+
+	if c.fetcher != nil {
+		if last := c.fetcher.LastFetch(); !last.At.IsZero() {
+			info.LastFetch = &debugFetch{At: last.At}
+			if last.Err != nil {
+				info.LastFetch.Error = last.Err.Error()
+			}
+		}
+	}
+	return info
+}
+
+// DebugHandler returns a http.Handler serving the agent internals as JSON, for
+// mounting on an admin server, e.g. at /debug/bearer:
+//   - health: whether the agent is operating, paused, or failed
+//   - config: the main configuration values, with the secret key masked
+//   - sender: the report sender statistics
+//   - rules: the active data collection rules
+//   - lastFetch: the time and error of the last configuration fetch.
+//
+// Since it exposes the agent configuration, it should not be publicly reachable.
+func (a *Agent) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(proxy.ContentTypeHeader, proxy.FullContentTypeJSON)
+		enc := json.NewEncoder(w)
+		enc.SetIndent(``, `  `)
+		_ = enc.Encode(a.debugInfo())
+	})
+}
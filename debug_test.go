@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAgent_DebugHandler(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"dataCollectionRules":[{"config":{"logLevel":"ALL"},"signature":"sig"}]}`))
+	}))
+	defer ts.Close()
+
+	a := New(ExampleWellFormedInvalidKey, WithEndpoints(ts.URL, ts.URL))
+	defer a.Close()
+	if err := a.Error(); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	a.DebugHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, `/debug/bearer`, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, expected %d", rec.Code, http.StatusOK)
+	}
+	body := rec.Body.String()
+	if strings.Contains(body, ExampleWellFormedInvalidKey) {
+		t.Errorf("secret key not masked: %s", body)
+	}
+
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &sections); err != nil {
+		t.Fatalf("invalid JSON %s: %v", body, err)
+	}
+	for _, name := range []string{`health`, `config`, `sender`, `rules`, `lastFetch`} {
+		if _, ok := sections[name]; !ok {
+			t.Errorf("missing section %s in %s", name, body)
+		}
+	}
+
+	var info debugInfo
+	_ = json.Unmarshal(rec.Body.Bytes(), &info)
+	if info.Health.Status != `ok` {
+		t.Errorf("health status = %s, expected ok", info.Health.Status)
+	}
+	if len(info.Rules) != 1 || info.Rules[0].Signature != `sig` || info.Rules[0].LogLevel != `ALL` {
+		t.Errorf("rules = %+v, expected the ALL rule", info.Rules)
+	}
+	if info.LastFetch == nil || info.LastFetch.Error != `` {
+		t.Errorf("lastFetch = %+v, expected a successful fetch", info.LastFetch)
+	}
+}
+
+func TestAgent_DebugHandlerError(t *testing.T) {
+	a := New(`not a key`)
+	rec := httptest.NewRecorder()
+	a.DebugHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, `/debug/bearer`, nil))
+
+	var info debugInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("invalid JSON %s: %v", rec.Body.String(), err)
+	}
+	if info.Health.Status != `error` || info.Health.Error == `` {
+		t.Errorf("health = %+v, expected an error", info.Health)
+	}
+	if info.Config != nil {
+		t.Errorf("config = %+v, expected none without configuration", info.Config)
+	}
+}
+
+func Test_maskSecretKey(t *testing.T) {
+	tests := []struct {
+		key, expected string
+	}{
+		{``, ``},
+		{`short`, `*****`},
+		{`app_1234567890`, `app_******7890`},
+	}
+	for _, tt := range tests {
+		if actual := maskSecretKey(tt.key); actual != tt.expected {
+			t.Errorf("maskSecretKey(%q) = %q, expected %q", tt.key, actual, tt.expected)
+		}
+	}
+}
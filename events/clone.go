@@ -0,0 +1,110 @@
+package events
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Cloner is implemented by Event types able to provide a copy of themselves,
+// preserving their concrete type. Implementations will usually rely on CloneBase
+// to copy their embedded EventBase.
+type Cloner interface {
+	Clone() Event
+}
+
+// DataCloner is implemented by Event data types able to provide a deep copy
+// of themselves, for use by Clone and CloneBase.
+type DataCloner interface {
+	CloneData() interface{}
+}
+
+// Clone returns a copy of an Event which concurrent consumers may modify
+// without affecting the original Event.
+//
+// Events implementing Cloner are copied by their Clone method. Other events are
+// copied to a new *EventBase with the same topic, data, request, response and
+// error, losing their concrete type.
+//
+// See CloneBase for the limits of the copy.
+func Clone(e Event) Event {
+	if e == nil {
+		return nil
+	}
+	if c, ok := e.(Cloner); ok {
+		return c.Clone()
+	}
+	eb := CloneBase(e)
+	return &eb
+}
+
+// CloneBase builds an EventBase from the data in an Event, copying the request
+// and response it carries.
+//
+// The copy is not fully deep:
+//   - request and response bodies are shared, as they cannot be read twice,
+//   - response TLS state is shared, as it is read-only by convention,
+//   - data is only copied if it is a DataCloner, a *url.URL, an http.Header,
+//     a []byte, or a map[string]interface{}, the latter only being copied one
+//     level deep. Other data values are shared with the original event.
+func CloneBase(e Event) EventBase {
+	eb := EventBase{
+		data:  cloneData(e.Data()),
+		topic: e.Topic(),
+		Error: e.Err(),
+	}
+
+	request := e.Request()
+	if request != nil {
+		eb.request = request.Clone(request.Context())
+	}
+
+	response := e.Response()
+	if response != nil {
+		res := *response
+		res.Header = response.Header.Clone()
+		res.Trailer = response.Trailer.Clone()
+		if response.TransferEncoding != nil {
+			res.TransferEncoding = append([]string(nil), response.TransferEncoding...)
+		}
+		// Preserve the request identity if the response shared it.
+		if response.Request == request {
+			res.Request = eb.request
+		} else if response.Request != nil {
+			res.Request = response.Request.Clone(response.Request.Context())
+		}
+		eb.response = &res
+	}
+
+	return eb
+}
+
+func cloneData(data interface{}) interface{} {
+	switch d := data.(type) {
+	case DataCloner:
+		return d.CloneData()
+	case *url.URL:
+		if d == nil {
+			return d
+		}
+		u := *d
+		return &u
+	case http.Header:
+		return d.Clone()
+	case []byte:
+		if d == nil {
+			return d
+		}
+		return append([]byte(nil), d...)
+	case map[string]interface{}:
+		if d == nil {
+			return d
+		}
+		m := make(map[string]interface{}, len(d))
+		for k, v := range d {
+			m[k] = v
+		}
+		return m
+	default:
+		return data
+	}
+}
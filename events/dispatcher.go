@@ -7,7 +7,10 @@ package events
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Error provides the ability to define constant errors, preventing global modification.
@@ -56,6 +59,47 @@ type Dispatcher interface {
 	Reset(topics ...Topic) Dispatcher
 }
 
+// OnceDispatcher is an optional interface for Dispatchers supporting one-shot
+// Listeners, like the one returned by NewDispatcher. Client code needing it
+// should type-assert their Dispatcher.
+type OnceDispatcher interface {
+	Dispatcher
+
+	// Once adds a Listener invoked for the next Event with a given Topic only,
+	// after the listeners already registered for that Topic. It is removed
+	// once invoked, even if multiple dispatches for the Topic run concurrently.
+	// It returns the dispatcher, making the call chainable.
+	Once(Topic, Listener) Dispatcher
+}
+
+// DispatcherInspector is an optional interface for Dispatchers describing
+// their ListenerProviders, like the one returned by NewDispatcher, for
+// diagnostics and tests. Client code needing it should type-assert their
+// Dispatcher.
+type DispatcherInspector interface {
+	// ProviderCount returns the number of ListenerProviders set for a Topic.
+	ProviderCount(Topic) int
+
+	// Topics returns the Topic values having ListenerProviders set, in
+	// lexicographic order.
+	Topics() []Topic
+}
+
+// TimeoutDispatcher is an optional interface for Dispatchers supporting
+// per-Topic dispatch timeouts, like the one returned by NewDispatcher. Client
+// code needing it should type-assert their Dispatcher.
+type TimeoutDispatcher interface {
+	Dispatcher
+
+	// SetTimeout bounds the duration of the dispatch of Events with a given
+	// Topic, regardless of the cancellation of the context passed to Dispatch:
+	// their Listeners receive a context carrying the values of the Dispatch
+	// context, only canceled when the timeout or the Dispatch context deadline,
+	// whichever is sooner, expires. A non-positive timeout removes it.
+	// It returns the dispatcher, making the call chainable.
+	SetTimeout(Topic, time.Duration) Dispatcher
+}
+
 // Listener is the type passed to Dispatchers as callbacks acting on events.
 //
 // Unlike PSR-14 listeners, they return an error which, if non-nil, stops
@@ -88,11 +132,30 @@ type providersMap map[Topic][]ListenerProvider
 type dispatcher struct {
 	m         sync.Mutex
 	providers providersMap
+	timeouts  map[Topic]time.Duration
+}
+
+// detachedContext is a context carrying the values of its parent, but neither
+// its deadline nor its cancellation. Dispatch restores the parent deadline
+// when it is sooner than the topic timeout.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
 }
 
 func (d *dispatcher) Dispatch(ctx context.Context, e Event) (Event, error) {
 	topic := e.Topic()
+	d.m.Lock()
 	providers, ok := d.providers[topic]
+	timeout := d.timeouts[topic]
+	d.m.Unlock()
 	// Shortcut: no provider means no listeners, so nothing to call.
 	if !ok {
 		return e, nil
@@ -110,7 +173,17 @@ func (d *dispatcher) Dispatch(ctx context.Context, e Event) (Event, error) {
 
 	// Ensure any context-aware async code run by a listener is able to be canceled
 	// when the dispatch loop ends.
-	dispatcherCtx, cancel := context.WithCancel(ctx)
+	var dispatcherCtx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		deadline := time.Now().Add(timeout)
+		if parentDeadline, ok := ctx.Deadline(); ok && parentDeadline.Before(deadline) {
+			deadline = parentDeadline
+		}
+		dispatcherCtx, cancel = context.WithDeadline(detachedContext{parent: ctx}, deadline)
+	} else {
+		dispatcherCtx, cancel = context.WithCancel(ctx)
+	}
 	defer cancel()
 
 	for _, provider := range providers {
@@ -161,6 +234,54 @@ func (d *dispatcher) AddProviders(topic Topic, providers ...ListenerProvider) Di
 	return d
 }
 
+// onceProvider is the ListenerProvider used by OnceDispatcher.Once.
+type onceProvider struct {
+	fired    int32
+	listener Listener
+	remove   func()
+}
+
+// Listeners implements the ListenerProvider interface.
+func (p *onceProvider) Listeners(Event) []Listener {
+	if !atomic.CompareAndSwapInt32(&p.fired, 0, 1) {
+		return nil
+	}
+	p.remove()
+	return []Listener{p.listener}
+}
+
+// Once is part of the OnceDispatcher interface.
+func (d *dispatcher) Once(topic Topic, listener Listener) Dispatcher {
+	p := &onceProvider{listener: listener}
+	p.remove = func() {
+		d.removeProvider(topic, p)
+	}
+	return d.AddProviders(topic, p)
+}
+
+// removeProvider removes a onceProvider for a topic. It builds a new slice
+// instead of modifying the existing one, which may be in use by Dispatch.
+//
+// Providers are not compared directly, as some types like ListenerProviderFunc
+// are not comparable.
+func (d *dispatcher) removeProvider(topic Topic, provider *onceProvider) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	existing := d.providers[topic]
+	providers := make([]ListenerProvider, 0, len(existing))
+	for _, p := range existing {
+		if op, ok := p.(*onceProvider); ok && op == provider {
+			continue
+		}
+		providers = append(providers, p)
+	}
+	if len(providers) == 0 {
+		delete(d.providers, topic)
+		return
+	}
+	d.providers[topic] = providers
+}
+
 // Reset is part of the Dispatcher interface.
 func (d *dispatcher) Reset(topics ...Topic) Dispatcher {
 	d.m.Lock()
@@ -177,6 +298,42 @@ func (d *dispatcher) Reset(topics ...Topic) Dispatcher {
 	return d
 }
 
+// ProviderCount is part of the DispatcherInspector interface.
+func (d *dispatcher) ProviderCount(topic Topic) int {
+	d.m.Lock()
+	defer d.m.Unlock()
+	return len(d.providers[topic])
+}
+
+// Topics is part of the DispatcherInspector interface.
+func (d *dispatcher) Topics() []Topic {
+	d.m.Lock()
+	defer d.m.Unlock()
+	topics := make([]Topic, 0, len(d.providers))
+	for topic := range d.providers {
+		topics = append(topics, topic)
+	}
+	sort.Slice(topics, func(i, j int) bool {
+		return topics[i] < topics[j]
+	})
+	return topics
+}
+
+// SetTimeout is part of the TimeoutDispatcher interface.
+func (d *dispatcher) SetTimeout(topic Topic, timeout time.Duration) Dispatcher {
+	d.m.Lock()
+	defer d.m.Unlock()
+	if timeout <= 0 {
+		delete(d.timeouts, topic)
+		return d
+	}
+	if d.timeouts == nil {
+		d.timeouts = make(map[Topic]time.Duration)
+	}
+	d.timeouts[topic] = timeout
+	return d
+}
+
 // NewDispatcher returns a basic Dispatcher implementation.
 //
 // Client code may use this constructor or create their own Dispatcher implementations.
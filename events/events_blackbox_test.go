@@ -3,8 +3,11 @@ package events_test
 import (
 	"context"
 	"errors"
+	"net/http"
 	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -293,6 +296,77 @@ func Test_dispatcher_DispatchTimeout(t *testing.T) {
 	}
 }
 
+func Test_dispatcher_SetTimeout(t *testing.T) {
+	const slow, fast = "slow", "fast"
+	const timeout = 20 * time.Millisecond
+	type key struct{}
+
+	var value interface{}
+	waiting := events.ListenerProviderFunc(func(events.Event) []events.Listener {
+		return []events.Listener{
+			func(ctx context.Context, _ events.Event) error {
+				value = ctx.Value(key{})
+				select {
+				case <-ctx.Done():
+				case <-time.After(10 * timeout):
+				}
+				return nil
+			},
+		}
+	})
+	d, ok := events.NewDispatcher().
+		AddProviders(slow, waiting).
+		AddProviders(fast, waiting).(events.TimeoutDispatcher)
+	if !ok {
+		t.Fatal("NewDispatcher() does not implement TimeoutDispatcher")
+	}
+	d.SetTimeout(slow, timeout)
+	d.SetTimeout(fast, 20*timeout)
+
+	// The topic timeout aborts the dispatch, without canceling the caller context.
+	ctx := context.WithValue(context.Background(), key{}, `value`)
+	t0 := time.Now()
+	_, err := d.Dispatch(ctx, events.NewEvent(slow))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("returned a non-DeadlineExceeded context error: %v", err)
+	}
+	if elapsed := time.Since(t0); elapsed >= 10*timeout {
+		t.Errorf("dispatch aborted after %v, expected about %v", elapsed, timeout)
+	}
+	if ctx.Err() != nil {
+		t.Errorf("caller context error = %v, expected none", ctx.Err())
+	}
+	if value != `value` {
+		t.Errorf("listener context value = %v, expected the caller context value", value)
+	}
+
+	// The caller cancellation does not abort topics with a timeout.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = d.Dispatch(ctx, events.NewEvent(fast)); err != nil {
+		t.Errorf("Dispatch() error = %v, expected none past the caller cancellation", err)
+	}
+
+	// The caller deadline bounds topics with a longer timeout.
+	ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	t0 = time.Now()
+	if _, err = d.Dispatch(ctx, events.NewEvent(fast)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("returned a non-DeadlineExceeded context error: %v", err)
+	}
+	if elapsed := time.Since(t0); elapsed >= 10*timeout {
+		t.Errorf("dispatch aborted after %v, expected about %v", elapsed, timeout)
+	}
+
+	// Removing the timeout restores the caller deadline.
+	d.SetTimeout(fast, 0)
+	ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if _, err = d.Dispatch(ctx, events.NewEvent(fast)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("returned a non-DeadlineExceeded context error: %v", err)
+	}
+}
+
 func Test_dispatcher_DispatchError(t *testing.T) {
 	const topic = "topic"
 	const expected = events.Error("random error")
@@ -429,3 +503,161 @@ func Test_dispatcher_Reset(t *testing.T) {
 		})
 	}
 }
+
+func Test_dispatcher_ProviderCount(t *testing.T) {
+	var noopProvider events.ListenerProviderFunc = func(events.Event) []events.Listener {
+		return nil
+	}
+	d := events.NewDispatcher()
+	inspector, ok := d.(events.DispatcherInspector)
+	if !ok {
+		t.Fatal("NewDispatcher() does not implement DispatcherInspector")
+	}
+	if topics := inspector.Topics(); len(topics) != 0 {
+		t.Errorf("Topics() on a new dispatcher = %v, expected none", topics)
+	}
+
+	d.AddProviders(`b`, noopProvider, noopProvider).
+		AddProviders(`a`, noopProvider).
+		AddProviders(`b`, noopProvider)
+	tests := []struct {
+		topic events.Topic
+		want  int
+	}{
+		{`a`, 1},
+		{`b`, 3},
+		{`c`, 0},
+	}
+	for _, tt := range tests {
+		if got := inspector.ProviderCount(tt.topic); got != tt.want {
+			t.Errorf("ProviderCount(%s) = %d, want %d", tt.topic, got, tt.want)
+		}
+	}
+	if topics := inspector.Topics(); !reflect.DeepEqual(topics, []events.Topic{`a`, `b`}) {
+		t.Errorf("Topics() = %v, want [a b]", topics)
+	}
+
+	d.Reset(`a`)
+	if topics := inspector.Topics(); !reflect.DeepEqual(topics, []events.Topic{`b`}) {
+		t.Errorf("Topics() after Reset = %v, want [b]", topics)
+	}
+}
+
+type clonerEvent struct {
+	events.EventBase
+	cloned bool
+}
+
+func (e *clonerEvent) Clone() events.Event {
+	return &clonerEvent{EventBase: events.CloneBase(e), cloned: true}
+}
+
+func Test_dispatcher_Once(t *testing.T) {
+	const topic = "topic"
+	d, ok := events.NewDispatcher().(events.OnceDispatcher)
+	if !ok {
+		t.Fatal("NewDispatcher() does not implement OnceDispatcher")
+	}
+	var regular, once int32
+	d.AddProviders(topic, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+		return []events.Listener{func(context.Context, events.Event) error {
+			atomic.AddInt32(&regular, 1)
+			return nil
+		}}
+	}))
+	d.Once(topic, func(context.Context, events.Event) error {
+		atomic.AddInt32(&once, 1)
+		return nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := d.Dispatch(context.Background(), events.NewEvent(topic)); err != nil {
+			t.Fatalf("dispatch %d returned an error: %v", i, err)
+		}
+	}
+	if regular != 2 || once != 1 {
+		t.Errorf("regular listener fired %d times, once listener %d times: expected 2 and 1", regular, once)
+	}
+
+	// Concurrent dispatches.
+	once = 0
+	d.Once(topic, func(context.Context, events.Event) error {
+		atomic.AddInt32(&once, 1)
+		return nil
+	})
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = d.Dispatch(context.Background(), events.NewEvent(topic))
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&once); n != 1 {
+		t.Errorf("once listener fired %d times on concurrent dispatches, expected 1", n)
+	}
+}
+
+func TestClone(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, `https://example.com/path?q=1`, nil)
+	req.Header.Set(`X-Test`, `original`)
+	res := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{`X-Test`: []string{`original`}},
+		Request:    req,
+	}
+	err := errors.New(`oops`)
+	data := map[string]interface{}{`key`: `original`}
+
+	original := events.NewEvent(`clone`).
+		SetData(data).
+		SetRequest(req).
+		SetResponse(res).
+		SetError(err)
+
+	clone := events.Clone(original)
+	if clone == original {
+		t.Fatal(`Clone returned the original event`)
+	}
+	if clone.Topic() != original.Topic() || clone.Err() != err {
+		t.Fatalf(`Clone did not preserve topic and error`)
+	}
+	if clone.Response().Request != clone.Request() {
+		t.Error(`Clone did not preserve the request shared by the response`)
+	}
+
+	// Mutate the clone in every copied part.
+	clone.Request().Header.Set(`X-Test`, `modified`)
+	clone.Request().URL.Path = `/modified`
+	clone.Response().Header.Set(`X-Test`, `modified`)
+	clone.Response().StatusCode = http.StatusTeapot
+	clone.Data().(map[string]interface{})[`key`] = `modified`
+
+	if actual := req.Header.Get(`X-Test`); actual != `original` {
+		t.Errorf(`original request header modified: %s`, actual)
+	}
+	if actual := req.URL.Path; actual != `/path` {
+		t.Errorf(`original request URL modified: %s`, actual)
+	}
+	if actual := res.Header.Get(`X-Test`); actual != `original` {
+		t.Errorf(`original response header modified: %s`, actual)
+	}
+	if actual := res.StatusCode; actual != http.StatusOK {
+		t.Errorf(`original response status modified: %d`, actual)
+	}
+	if actual := data[`key`]; actual != `original` {
+		t.Errorf(`original data modified: %v`, actual)
+	}
+
+	if events.Clone(nil) != nil {
+		t.Error(`Clone(nil) should be nil`)
+	}
+
+	ce := &clonerEvent{}
+	ce.SetTopic(`cloner`)
+	cc, ok := events.Clone(ce).(*clonerEvent)
+	if !ok || !cc.cloned || cc.Topic() != ce.Topic() {
+		t.Errorf(`Clone did not use the Cloner implementation: %#v`, cc)
+	}
+}
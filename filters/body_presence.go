@@ -0,0 +1,71 @@
+package filters
+
+import (
+	"fmt"
+
+	"github.com/bearer/go-agent/events"
+)
+
+// BodyPresenceDescription carries the fields set on filters.BodyPresenceFilter.
+type BodyPresenceDescription struct {
+	// Present is true to match non-empty bodies, and false to match empty ones.
+	Present bool
+	// Response selects the response body instead of the request body.
+	Response bool
+}
+
+func (d BodyPresenceDescription) String() string {
+	side := `request`
+	if d.Response {
+		side = `response`
+	}
+	return fmt.Sprintf("Body: %s present=%t\n", side, d.Present)
+}
+
+// BodyPresenceFilter matches on whether the request or response body is
+// non-empty, e.g. to report POST requests without a body.
+//
+// It only applies once the bodies are peeked, so calls before the bodies stage
+// never match.
+type BodyPresenceFilter struct {
+	BodyPresenceDescription
+}
+
+// Type is part of the Filter interface.
+func (*BodyPresenceFilter) Type() FilterType {
+	return BodyPresenceFilterType
+}
+
+// MatchesCall is part of the Filter interface.
+func (f *BodyPresenceFilter) MatchesCall(e events.Event) bool {
+	be, ok := e.(BodyLengthsEvent)
+	if !ok {
+		return false
+	}
+	length, responseLength := be.BodyLengths()
+	if f.Response {
+		length = responseLength
+	}
+	return (length > 0) == f.Present
+}
+
+// SetMatcher is part of the Filter interface. In BodyPresenceFilter, is only
+// accepts a nil matcher, as no underlying matcher is actually used.
+func (*BodyPresenceFilter) SetMatcher(matcher Matcher) error {
+	if matcher != nil {
+		return fmt.Errorf("instances of BodyPresenceFilter only accept a nil Matcher, got %T", matcher)
+	}
+	return nil
+}
+
+// Describe is part of the Filter interface.
+func (f *BodyPresenceFilter) Describe() FilterDescription {
+	return FilterDescription{
+		TypeName:     f.Type().Name(),
+		BodyPresence: f.BodyPresenceDescription,
+	}
+}
+
+func bodyPresenceFilterFromDescription(_ FilterMap, fd *FilterDescription) Filter {
+	return &BodyPresenceFilter{BodyPresenceDescription: fd.BodyPresence}
+}
@@ -0,0 +1,81 @@
+package filters
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+)
+
+type bodyLengthsEvent struct {
+	events.EventBase
+	request, response int
+}
+
+func (e *bodyLengthsEvent) BodyLengths() (request, response int) {
+	return e.request, e.response
+}
+
+func TestBodyPresenceFilter_Type(t *testing.T) {
+	expected := BodyPresenceFilterType.String()
+	var f BodyPresenceFilter
+	actual := f.Type().String()
+	if actual != expected {
+		t.Errorf("Type() = %v, want %v", actual, expected)
+	}
+}
+
+func Test_bodyPresenceFilterFromDescription(t *testing.T) {
+	fd := FilterDescription{BodyPresence: BodyPresenceDescription{Present: true, Response: true}}
+	actual := bodyPresenceFilterFromDescription(nil, &fd)
+	expected := &BodyPresenceFilter{BodyPresenceDescription{Present: true, Response: true}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("bodyPresenceFilterFromDescription() = %v, want %v", actual, expected)
+	}
+}
+
+func TestBodyPresenceFilter_SetMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher Matcher
+		wantErr bool
+	}{
+		{`happy`, nil, false},
+		{`sad`, &yesMatcher{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &BodyPresenceFilter{}
+			if err := f.SetMatcher(tt.matcher); (err != nil) != tt.wantErr {
+				t.Errorf("SetMatcher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBodyPresenceFilter_MatchesCall(t *testing.T) {
+	tests := []struct {
+		name              string
+		present, response bool
+		event             events.Event
+		want              bool
+	}{
+		{`no lengths`, false, false, &events.EventBase{}, false},
+		{`request empty, want present`, true, false, &bodyLengthsEvent{}, false},
+		{`request empty, want absent`, false, false, &bodyLengthsEvent{}, true},
+		{`request non-empty, want present`, true, false, &bodyLengthsEvent{request: 3}, true},
+		{`request non-empty, want absent`, false, false, &bodyLengthsEvent{request: 3}, false},
+		{`response empty, want present`, true, true, &bodyLengthsEvent{request: 3}, false},
+		{`response empty, want absent`, false, true, &bodyLengthsEvent{request: 3}, true},
+		{`response non-empty, want present`, true, true, &bodyLengthsEvent{response: 2}, true},
+		{`response non-empty, want absent`, false, true, &bodyLengthsEvent{response: 2}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &BodyPresenceFilter{BodyPresenceDescription{Present: tt.present, Response: tt.response}}
+			if got := f.MatchesCall(tt.event); got != tt.want {
+				t.Errorf("MatchesCall() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
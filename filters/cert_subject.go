@@ -0,0 +1,83 @@
+package filters
+
+import (
+	"fmt"
+
+	"github.com/bearer/go-agent/events"
+)
+
+// CertSubjectFilter provides a filter for the certificate presented by the
+// server in API calls over TLS. It matches if its regexp matches the subject
+// common name or any of the DNS names in the leaf certificate.
+//
+// Calls without a TLS connection, like plain HTTP calls, never match.
+type CertSubjectFilter struct {
+	RegexpMatcher
+}
+
+// Type is part of the Filter interface.
+func (*CertSubjectFilter) Type() FilterType {
+	return CertSubjectFilterType
+}
+
+func (f *CertSubjectFilter) ensureMatcher() {
+	if f.RegexpMatcher != nil {
+		return
+	}
+	_ = f.SetMatcher(NewEmptyRegexpMatcher())
+}
+
+// MatchesCall is part of the Filter interface.
+func (f *CertSubjectFilter) MatchesCall(e events.Event) bool {
+	response := e.Response()
+	if response == nil || response.TLS == nil || len(response.TLS.PeerCertificates) == 0 {
+		return false
+	}
+	f.ensureMatcher()
+	leaf := response.TLS.PeerCertificates[0]
+	if leaf.Subject.CommonName != `` && f.RegexpMatcher.Matches(leaf.Subject.CommonName) {
+		return true
+	}
+	for _, name := range leaf.DNSNames {
+		if f.RegexpMatcher.Matches(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMatcher sets the filter RegexpMatcher.
+//
+// If the returned error is not nil, the filter Regex will accept any value.
+//
+// Like host names, certificate names are case-insensitive, so prepend (?i) to
+// the regex to apply a case-insensitive match, as in: (?i)\.bearer\.sh$
+func (f *CertSubjectFilter) SetMatcher(matcher Matcher) error {
+	if matcher == nil {
+		matcher = NewEmptyRegexpMatcher()
+	}
+	rm, ok := matcher.(RegexpMatcher)
+	if !ok {
+		f.ensureMatcher()
+		return fmt.Errorf("the CertSubjectFilter only accepts RegexMatchers: got %T", matcher)
+	}
+	f.RegexpMatcher = rm
+	return nil
+}
+
+// Describe is part of the Filter interface.
+func (f *CertSubjectFilter) Describe() FilterDescription {
+	f.ensureMatcher()
+	return FilterDescription{
+		TypeName: f.Type().Name(),
+		Pattern:  regexpToDescription(f.Regexp()),
+	}
+}
+
+func certSubjectFilterFromDescription(_ FilterMap, fd *FilterDescription) Filter {
+	f := &CertSubjectFilter{}
+	// If the pattern is invalid, the matcher will be nil, and SetMatcher will
+	// apply the EmptyRegexpMatcher and not fail.
+	_ = f.SetMatcher(NewRegexpMatcher(fd.PatternRegexp()))
+	return f
+}
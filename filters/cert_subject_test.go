@@ -0,0 +1,91 @@
+package filters
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+)
+
+func TestCertSubjectFilter_MatchesCall(t *testing.T) {
+	handler := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	// The httptest certificate is issued for example.com, without a common name.
+	tlsServer := httptest.NewTLSServer(handler)
+	defer tlsServer.Close()
+	plainServer := httptest.NewServer(handler)
+	defer plainServer.Close()
+
+	get := func(ts *httptest.Server) *http.Response {
+		res, err := ts.Client().Get(ts.URL)
+		if err != nil {
+			t.Fatalf("unexpected error performing test call: %v", err)
+		}
+		_ = res.Body.Close()
+		return res
+	}
+	tlsResponse, plainResponse := get(tlsServer), get(plainServer)
+	cnResponse := &http.Response{TLS: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{
+		{Subject: pkix.Name{CommonName: `api.bearer.sh`}},
+	}}}
+
+	tests := []struct {
+		name     string
+		matcher  RegexpMatcher
+		response *http.Response
+		want     bool
+	}{
+		{"empty", NewEmptyRegexpMatcher(), tlsResponse, true},
+		{"happy SAN", NewRegexpMatcher(regexp.MustCompile(`^example\.com$`)), tlsResponse, true},
+		{"happy case-insensitive", NewRegexpMatcher(regexp.MustCompile(`(?i)^EXAMPLE\.COM$`)), tlsResponse, true},
+		{"happy CN", NewRegexpMatcher(regexp.MustCompile(`\.bearer\.sh$`)), cnResponse, true},
+		{"sad subject", NewRegexpMatcher(regexp.MustCompile(`\.bearer\.sh$`)), tlsResponse, false},
+		{"sad plain HTTP", NewEmptyRegexpMatcher(), plainResponse, false},
+		{"sad no response", NewEmptyRegexpMatcher(), nil, false},
+		{"sad no certificate", NewEmptyRegexpMatcher(), &http.Response{TLS: &tls.ConnectionState{}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &CertSubjectFilter{
+				RegexpMatcher: tt.matcher,
+			}
+			e := (&events.EventBase{}).SetResponse(tt.response)
+			if got := f.MatchesCall(e); got != tt.want {
+				t.Errorf("MatchesCall() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCertSubjectFilter_SetMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher Matcher
+		wantErr bool
+	}{
+		{"happy", NewEmptyRegexpMatcher(), false},
+		{"nil", nil, false},
+		{"sad matcher", &yesMatcher{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &CertSubjectFilter{}
+			if err := f.SetMatcher(tt.matcher); (err != nil) != tt.wantErr {
+				t.Errorf("SetMatcher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCertSubjectFilter_Type(t *testing.T) {
+	expected := CertSubjectFilterType.String()
+	var f CertSubjectFilter
+	actual := f.Type().String()
+	if actual != expected {
+		t.Errorf("Type() = %v, want %v", actual, expected)
+	}
+}
@@ -28,6 +28,11 @@ func (*ConnectionErrorFilter) SetMatcher(matcher Matcher) error {
 	return nil
 }
 
+// Describe is part of the Filter interface.
+func (f *ConnectionErrorFilter) Describe() FilterDescription {
+	return FilterDescription{TypeName: f.Type().Name()}
+}
+
 func connectionErrorFilterFromDescription(FilterMap, *FilterDescription) Filter {
 	return &ConnectionErrorFilter{}
 }
@@ -30,27 +30,44 @@ func (f *DomainFilter) MatchesCall(e events.Event) bool {
 	return f.RegexpMatcher.Matches(criterium)
 }
 
-// SetMatcher sets the filter RegexpMatcher.
+// ExplainCall is part of the CallExplainer interface.
+func (f *DomainFilter) ExplainCall(e events.Event) string {
+	f.ensureMatcher()
+	return Explain(f.RegexpMatcher, e.Request().URL.Hostname())
+}
+
+// SetMatcher sets the filter RegexpMatcher, or GlobMatcher, as returned by
+// NewGlobMatcher for simple patterns like *.internal.example.com.
 //
 // If the returned error is not nil, the filter Matcher cannot be used.
 //
 // If the returned error is not nil, the filter Regex will accept any value.
 //
 // To apply a case-insensitive match, prepend (?i) to the regex, as in: (?i)\.bearer\.sh$
-// DomainFilter should always use a case-insensitive match.
+// DomainFilter should always use a case-insensitive match, which globs always do.
 func (f *DomainFilter) SetMatcher(matcher Matcher) error {
 	if matcher == nil {
 		matcher = NewEmptyRegexpMatcher()
 	}
+	// A *GlobMatcher is also a RegexpMatcher.
 	rm, ok := matcher.(RegexpMatcher)
 	if !ok {
 		f.ensureMatcher()
-		return fmt.Errorf("the DomainFilter only accepts RegexMatchers: got %T", matcher)
+		return fmt.Errorf("the DomainFilter only accepts RegexMatchers and GlobMatchers: got %T", matcher)
 	}
 	f.RegexpMatcher = rm
 	return nil
 }
 
+// Describe is part of the Filter interface.
+func (f *DomainFilter) Describe() FilterDescription {
+	f.ensureMatcher()
+	return FilterDescription{
+		TypeName: f.Type().Name(),
+		Pattern:  regexpToDescription(f.Regexp()),
+	}
+}
+
 func domainFilterFromDescription(_ FilterMap, fd *FilterDescription) Filter {
 	f := &DomainFilter{}
 	// If the pattern is invalid, the matcher will be nil, and SetMatcher will
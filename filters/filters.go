@@ -12,6 +12,38 @@ import (
 // FilterMap binds Filter hashes in a config.Description to the actual Filter instances.
 type FilterMap map[string]Filter
 
+// Describe serializes the filters in the map back to descriptions, including
+// the children hashes of compound filters. Children missing from the map are
+// omitted.
+//
+// The StageType of the filters is not retained by them, so it is not described.
+func (fm FilterMap) Describe() map[string]FilterDescription {
+	hashes := make(map[Filter]string, len(fm))
+	for hash, f := range fm {
+		hashes[f] = hash
+	}
+
+	descriptions := make(map[string]FilterDescription, len(fm))
+	for hash, f := range fm {
+		d := f.Describe()
+		if fs, ok := f.(FilterSet); ok {
+			for _, child := range fs.Children() {
+				childHash, ok := hashes[child]
+				if !ok {
+					continue
+				}
+				if d.TypeName == NotFilterType.Name() {
+					d.ChildHash = childHash
+					break
+				}
+				d.ChildHashes = append(d.ChildHashes, childHash)
+			}
+		}
+		descriptions[hash] = d
+	}
+	return descriptions
+}
+
 // FilterType allows Filter types to have "static" properties.
 type FilterType interface {
 	// Create creates an instance of the described type. The actual type of the
@@ -65,6 +97,91 @@ type Filter interface {
 	// SetMatcher assigns a specific Matcher instance to the filter.
 	// Passing a nil matcher will assign a filter-specific default Matcher.
 	SetMatcher(Matcher) error
+	// Describe returns a FilterDescription from which NewFilterFromDescription
+	// builds an equivalent Filter. Compound filters do not know the hashes of
+	// their children, which FilterMap.Describe provides.
+	Describe() FilterDescription
+}
+
+// CallExplainer is implemented by the filters able to explain their result on
+// an API call, for debugging purposes.
+type CallExplainer interface {
+	ExplainCall(event events.Event) string
+}
+
+// ExplainCall returns the explanation of the filter result on an API call, if
+// it is a CallExplainer, or just the result otherwise.
+func ExplainCall(f Filter, e events.Event) string {
+	if ce, ok := f.(CallExplainer); ok {
+		return ce.ExplainCall(e)
+	}
+	if f.MatchesCall(e) {
+		return f.Type().Name() + ` matched`
+	}
+	return f.Type().Name() + ` did not match`
+}
+
+// ParsedBodiesEvent is implemented by the events carrying the parsed request
+// and response bodies, like interception.BodiesEvent, for filters matching on
+// body contents.
+type ParsedBodiesEvent interface {
+	events.Event
+	ParsedBodies() (request, response interface{})
+}
+
+// The placeholders set instead of the parsed bodies which could not be, or were
+// not, parsed. Filters matching on body contents do not apply to them.
+const (
+	// BodyTooLong is the replacement string for bodies beyond the maximum body
+	// size.
+	BodyTooLong = `(omitted due to size)`
+
+	// BodyIsBinary is the replacement string for unparseable bodies.
+	BodyIsBinary = `(not showing binary data)`
+
+	// BodyIsStream is the replacement string for the bodies of streaming
+	// responses, like Server-Sent Events, which are not buffered.
+	BodyIsStream = `(streaming response not captured)`
+
+	// BodyUndecodable is the replacement string for bodies which were expected to be parsable but failed decoding.
+	BodyUndecodable = `(could not decode data)`
+)
+
+// isUnparsedBody checks whether a parsed body is missing or a placeholder.
+func isUnparsedBody(body interface{}) bool {
+	switch body {
+	case nil, BodyTooLong, BodyIsBinary, BodyIsStream, BodyUndecodable:
+		return true
+	}
+	return false
+}
+
+// UsesParsedBodies checks whether a Filter, or any of its children, matches on
+// the parsed bodies, which must then be available at the bodies stage.
+func UsesParsedBodies(f Filter) bool {
+	if isNilInterface(f) {
+		return false
+	}
+	switch f.Type().Name() {
+	case JSONSchemaFilterType.Name(), RequestBodiesFilterType.Name():
+		return true
+	}
+	if fs, ok := f.(FilterSet); ok {
+		for _, child := range fs.Children() {
+			if UsesParsedBodies(child) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// BodyLengthsEvent is implemented by the events carrying the lengths of the
+// raw request and response bodies, like interception.BodiesEvent, for filters
+// matching on body presence.
+type BodyLengthsEvent interface {
+	events.Event
+	BodyLengths() (request, response int)
 }
 
 var (
@@ -75,6 +192,8 @@ var (
 
 	// DomainFilterType describes DomainFilter.
 	DomainFilterType FilterType = filterType{"DomainFilter", domainFilterFromDescription, true, false}
+	// IPRangeFilterType describes IPRangeFilter.
+	IPRangeFilterType FilterType = filterType{"IPRangeFilter", ipRangeFilterFromDescription, true, false}
 
 	// HTTPMethodFilterType describes HTTPMethodFilter.
 	HTTPMethodFilterType FilterType = filterType{"HttpMethodFilter", methodFilterFromDescription, true, false}
@@ -82,20 +201,37 @@ var (
 	ParamFilterType FilterType = filterType{"ParamFilter", paramFilterFromDescription, true, false}
 	// PathFilterType describes PathFilter.
 	PathFilterType FilterType = filterType{"PathFilter", pathFilterFromDescription, true, false}
+	// URLFilterType describes URLFilter.
+	URLFilterType FilterType = filterType{"URLFilter", urlFilterFromDescription, true, false}
 	// RequestHeadersFilterType describes RequestHeadersFilter.
 	RequestHeadersFilterType FilterType = filterType{"RequestHeadersFilter", requestFilterHeadersFromDescription, true, false}
 	// ResponseHeadersFilterType describes ResponseHeadersFilter.
 	ResponseHeadersFilterType FilterType = filterType{"ResponseHeadersFilter", responseHeadersFilterFromDescription, false, true}
 	// StatusCodeFilterType describes StatusCodeFilter.
 	StatusCodeFilterType FilterType = filterType{"StatusCodeFilter", statusCodeFilterFromDescription, false, true}
+	// HeaderCountFilterType describes HeaderCountFilter.
+	HeaderCountFilterType FilterType = filterType{"HeaderCountFilter", headerCountFilterFromDescription, true, false}
+	// RequestContentTypeFilterType describes RequestContentTypeFilter.
+	RequestContentTypeFilterType FilterType = filterType{"RequestContentTypeFilter", requestContentTypeFilterFromDescription, true, false}
+	// RequestBodiesFilterType describes RequestBodiesFilter.
+	RequestBodiesFilterType FilterType = filterType{"RequestBodiesFilter", requestBodiesFilterFromDescription, true, false}
 
-	//RequestBodiesFilterType  FilterType = filterType{"RequestBodiesFilter", requestBodiesFilterFromDescription, true, false}
 	//ResponseBodiesFilterType FilterType = filterType{"ResponseBodiesFilter", responseBodiesFilterFromDescription, false, true}
 
+	// JSONSchemaFilterType describes JSONSchemaFilter.
+	JSONSchemaFilterType FilterType = filterType{"JSONSchemaFilter", jsonSchemaFilterFromDescription, false, true}
+	// BodyPresenceFilterType describes BodyPresenceFilter.
+	BodyPresenceFilterType FilterType = filterType{"BodyPresenceFilter", bodyPresenceFilterFromDescription, true, true}
+	// CertSubjectFilterType describes CertSubjectFilter.
+	CertSubjectFilterType FilterType = filterType{"CertSubjectFilter", certSubjectFilterFromDescription, false, true}
 	// ConnectionErrorFilterType describes ConnectionErrorFilter.
 	ConnectionErrorFilterType FilterType = filterType{"ConnectionErrorFilter", connectionErrorFilterFromDescription, false, false}
+	// ScheduleFilterType describes ScheduleFilter.
+	ScheduleFilterType FilterType = filterType{"ScheduleFilter", scheduleFilterFromDescription, false, false}
 	// YesInternalFilter described YesFilter, an internal use filter.
 	YesInternalFilter FilterType = filterType{"YesFilter", yesFilterFromDescription, false, false}
+	// NoInternalFilter describes NoFilter, an internal use filter.
+	NoInternalFilter FilterType = filterType{"NoFilter", noFilterFromDescription, false, false}
 )
 
 // FilterTypeByName returns a FilterType instance for the passed name, or nil if
@@ -108,22 +244,42 @@ func FilterTypeByName(name string) FilterType {
 		return FilterSetFilterType
 	case DomainFilterType.Name():
 		return DomainFilterType
+	case IPRangeFilterType.Name():
+		return IPRangeFilterType
 	case HTTPMethodFilterType.Name():
 		return HTTPMethodFilterType
 	case ParamFilterType.Name():
 		return ParamFilterType
 	case PathFilterType.Name():
 		return PathFilterType
+	case URLFilterType.Name():
+		return URLFilterType
 	case RequestHeadersFilterType.Name():
 		return RequestHeadersFilterType
 	case ResponseHeadersFilterType.Name():
 		return ResponseHeadersFilterType
 	case StatusCodeFilterType.Name():
 		return StatusCodeFilterType
+	case HeaderCountFilterType.Name():
+		return HeaderCountFilterType
+	case RequestContentTypeFilterType.Name():
+		return RequestContentTypeFilterType
+	case RequestBodiesFilterType.Name():
+		return RequestBodiesFilterType
+	case JSONSchemaFilterType.Name():
+		return JSONSchemaFilterType
+	case BodyPresenceFilterType.Name():
+		return BodyPresenceFilterType
+	case CertSubjectFilterType.Name():
+		return CertSubjectFilterType
 	case ConnectionErrorFilterType.Name():
 		return ConnectionErrorFilterType
+	case ScheduleFilterType.Name():
+		return ScheduleFilterType
 	case YesInternalFilter.Name():
 		return YesInternalFilter
+	case NoInternalFilter.Name():
+		return NoInternalFilter
 	default:
 		return nil
 	}
@@ -159,9 +315,22 @@ type FilterDescription struct {
 	// XXX Its fields are not portable across regexp implementations.
 	KeyValueDescription
 
-	// Range is set on filters using filters.RangeMatcher like filters.StatusCodeFilter.
+	// Range is set on filters using filters.RangeMatcher like filters.StatusCodeFilter
+	// and filters.HeaderCountFilter.
 	Range RangeMatcherDescription
 
+	// Schema is set on filters using filters.SchemaMatcher, like filters.JSONSchemaFilter.
+	Schema map[string]interface{}
+
+	// BodyPresence is set on filters.BodyPresenceFilter.
+	BodyPresence BodyPresenceDescription
+
+	// Schedule is set on filters.ScheduleFilter.
+	Schedule ScheduleDescription
+
+	// IPRanges are the CIDR blocks set on filters.IPRangeFilter, like "10.0.0.0/8".
+	IPRanges []string
+
 	// StageType is one of the 4 API call stages.
 	StageType string
 
@@ -186,6 +355,16 @@ func (d FilterDescription) String() string {
 	b.WriteString(d.FilterSetDescription.String())
 	b.WriteString(d.KeyValueDescription.String())
 	b.WriteString(d.Range.String())
+	if d.Schema != nil {
+		b.WriteString(fmt.Sprintf("Schema: %v\n", d.Schema))
+	}
+	if d.TypeName == BodyPresenceFilterType.Name() {
+		b.WriteString(d.BodyPresence.String())
+	}
+	b.WriteString(d.Schedule.String())
+	if len(d.IPRanges) > 0 {
+		b.WriteString(fmt.Sprintf("IPRanges: %v\n", d.IPRanges))
+	}
 	s := b.String()
 	if len(s) == l1 {
 		s += "\n"
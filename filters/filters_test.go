@@ -3,6 +3,7 @@ package filters
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/bearer/go-agent/proxy"
 )
@@ -41,14 +42,24 @@ func TestNewFilterFromDescription(t *testing.T) {
 		{`not`, NotFilterType, nil},
 		{`set`, FilterSetFilterType, &filterSet{}},
 		{`domain`, DomainFilterType, &DomainFilter{NewRegexpMatcher(nil)}},
+		{`ip range`, IPRangeFilterType, &IPRangeFilter{}},
 		{`method`, HTTPMethodFilterType, &HTTPMethodFilter{NewStringMatcher(``, true)}},
 		{`param`, ParamFilterType, &ParamFilter{NewKeyValueMatcher(nil, nil)}},
 		{`path`, PathFilterType, &PathFilter{NewRegexpMatcher(nil)}},
+		{`url`, URLFilterType, &URLFilter{NewRegexpMatcher(nil)}},
+		{`cert subject`, CertSubjectFilterType, &CertSubjectFilter{NewRegexpMatcher(nil)}},
 		{`request headers`, RequestHeadersFilterType, &RequestHeadersFilter{NewKeyValueMatcher(nil, nil)}},
 		{`response headers`, ResponseHeadersFilterType, &ResponseHeadersFilter{NewKeyValueMatcher(nil, nil)}},
 		{`status`, StatusCodeFilterType, &StatusCodeFilter{NewRangeMatcher()}},
+		{`header count`, HeaderCountFilterType, &HeaderCountFilter{NewRangeMatcher()}},
+		{`request content type`, RequestContentTypeFilterType, &RequestContentTypeFilter{NewRegexpMatcher(nil)}},
+		{`request bodies`, RequestBodiesFilterType, &RequestBodiesFilter{NewKeyValueMatcher(nil, nil)}},
+		{`json schema`, JSONSchemaFilterType, &JSONSchemaFilter{NewSchemaMatcher(nil)}},
 		{`error`, ConnectionErrorFilterType, &ConnectionErrorFilter{}},
+		{`body presence`, BodyPresenceFilterType, &BodyPresenceFilter{}},
+		{`schedule`, ScheduleFilterType, &ScheduleFilter{Location: time.UTC}},
 		{`yes`, YesInternalFilter, &YesFilter{}},
+		{`no`, NoInternalFilter, &NoFilter{}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -113,3 +124,96 @@ func TestFilterDescription_String(t *testing.T) {
 		})
 	}
 }
+
+func TestFilterMap_Describe(t *testing.T) {
+	// Descriptions are listed in dependency order, to build children first.
+	hashes := []string{`domain`, `method`, `param`, `path`, `url`, `reqHeaders`,
+		`resHeaders`, `status`, `headerCount`, `reqContentType`, `reqBodies`, `cert`, `schema`, `connError`, `ipRange`, `body`, `schedule`, `yes`, `no`, `set`, `not`}
+	descriptions := map[string]FilterDescription{
+		`domain`: {TypeName: DomainFilterType.Name(), Pattern: &RegexpMatcherDescription{Value: `\.example\.com$`, Flags: `i`}},
+		`method`: {TypeName: HTTPMethodFilterType.Name(), Value: `POST`},
+		`param`: {TypeName: ParamFilterType.Name(), KeyValueDescription: KeyValueDescription{
+			KeyPattern: &RegexpMatcherDescription{Value: `^id$`},
+		}},
+		`path`: {TypeName: PathFilterType.Name(), Pattern: &RegexpMatcherDescription{Value: `^/v1/`}},
+		`url`:  {TypeName: URLFilterType.Name()},
+		`reqHeaders`: {TypeName: RequestHeadersFilterType.Name(), KeyValueDescription: KeyValueDescription{
+			KeyPattern:   &RegexpMatcherDescription{Value: `^Accept$`},
+			ValuePattern: &RegexpMatcherDescription{Value: `json`},
+		}},
+		`resHeaders`: {TypeName: ResponseHeadersFilterType.Name(), KeyValueDescription: KeyValueDescription{
+			ValuePattern: &RegexpMatcherDescription{Value: `xml`, Flags: `is`},
+		}},
+		`status`:      {TypeName: StatusCodeFilterType.Name(), Range: RangeMatcherDescription{From: 200, To: 300, ExcludeTo: true}},
+		`headerCount`: {TypeName: HeaderCountFilterType.Name(), Range: RangeMatcherDescription{From: 50}},
+		`reqContentType`: {TypeName: RequestContentTypeFilterType.Name(), Pattern: &RegexpMatcherDescription{
+			Value: `^application/json\b`, Flags: `i`,
+		}},
+		`reqBodies`: {TypeName: RequestBodiesFilterType.Name(), KeyValueDescription: KeyValueDescription{
+			KeyPattern:   &RegexpMatcherDescription{Value: `^event$`},
+			ValuePattern: &RegexpMatcherDescription{Value: `^checkout$`},
+		}},
+		`cert`: {TypeName: CertSubjectFilterType.Name(), Pattern: &RegexpMatcherDescription{Value: `example`}},
+		`schema`: {TypeName: JSONSchemaFilterType.Name(), Schema: map[string]interface{}{
+			`type`:     `object`,
+			`required`: []interface{}{`id`},
+		}},
+		`connError`: {TypeName: ConnectionErrorFilterType.Name()},
+		`ipRange`:   {TypeName: IPRangeFilterType.Name(), IPRanges: []string{`10.0.0.0/8`, `fd00::/8`}},
+		`body`:      {TypeName: BodyPresenceFilterType.Name(), BodyPresence: BodyPresenceDescription{Present: true, Response: true}},
+		`schedule`: {TypeName: ScheduleFilterType.Name(), Schedule: ScheduleDescription{
+			Timezone: `Europe/Paris`,
+			Windows:  []ScheduleWindowDescription{{Days: []string{`Monday`, `Friday`}, From: `09:00`, To: `17:30`}},
+		}},
+		`yes`: {TypeName: YesInternalFilter.Name()},
+		`no`:  {TypeName: NoInternalFilter.Name()},
+		`set`: {TypeName: FilterSetFilterType.Name(), FilterSetDescription: FilterSetDescription{
+			ChildHashes: []string{`domain`, `status`},
+			Operator:    `ALL`,
+		}},
+		`not`: {TypeName: NotFilterType.Name(), ChildHash: `set`},
+	}
+
+	fm := make(FilterMap, len(hashes))
+	for _, hash := range hashes {
+		d := descriptions[hash]
+		f := NewFilterFromDescription(fm, &d)
+		if f == nil {
+			t.Fatalf("failed building filter %s", hash)
+		}
+		fm[hash] = f
+	}
+
+	actual := fm.Describe()
+	if len(actual) != len(descriptions) {
+		t.Fatalf("Describe() returned %d descriptions, expected %d", len(actual), len(descriptions))
+	}
+	for hash, expected := range descriptions {
+		if !reflect.DeepEqual(actual[hash], expected) {
+			t.Errorf("Describe()[%s] = %#v, expected %#v", hash, actual[hash], expected)
+		}
+	}
+}
+
+func TestUsesParsedBodies(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{`nil`, nil, false},
+		{`domain`, &DomainFilter{}, false},
+		{`json schema`, &JSONSchemaFilter{}, true},
+		{`request bodies`, &RequestBodiesFilter{}, true},
+		{`set without body filter`, (&filterSet{}).AddChildren(&DomainFilter{}, &YesFilter{}), false},
+		{`set with body filter`, (&filterSet{}).AddChildren(&DomainFilter{}, &RequestBodiesFilter{}), true},
+		{`negated body filter`, Not(&JSONSchemaFilter{}), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UsesParsedBodies(tt.filter); got != tt.want {
+				t.Errorf("UsesParsedBodies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package filters
+
+import (
+	"fmt"
+
+	"github.com/bearer/go-agent/events"
+)
+
+// HeaderCountFilter provides a filter for the number of request headers in API
+// requests, e.g. to flag requests with an unusually large number of headers.
+type HeaderCountFilter struct {
+	RangeMatcher
+}
+
+// Type is part of the Filter interface.
+func (*HeaderCountFilter) Type() FilterType {
+	return HeaderCountFilterType
+}
+
+func (f *HeaderCountFilter) ensureMatcher() {
+	if f.RangeMatcher != nil {
+		return
+	}
+	_ = f.SetMatcher(NewRangeMatcher())
+}
+
+// MatchesCall is part of the Filter interface.
+func (f *HeaderCountFilter) MatchesCall(e events.Event) bool {
+	if e.Request() == nil {
+		return false
+	}
+	f.ensureMatcher()
+	return f.Matches(len(e.Request().Header))
+}
+
+// SetMatcher sets the filter RangeMatcher. A nil RangeMatcher means any number
+// of headers.
+//
+// If the returned error is not nil, the RangeMatcher is rejected.
+func (f *HeaderCountFilter) SetMatcher(matcher Matcher) error {
+	if matcher == nil {
+		matcher = NewRangeMatcher()
+	}
+	rm, ok := matcher.(RangeMatcher)
+	if !ok {
+		f.ensureMatcher()
+		return fmt.Errorf("the HeaderCountFilter only accepts RangeMatchers: got %T", matcher)
+	}
+	f.RangeMatcher = rm
+	return nil
+}
+
+// Describe is part of the Filter interface.
+func (f *HeaderCountFilter) Describe() FilterDescription {
+	f.ensureMatcher()
+	return FilterDescription{
+		TypeName: f.Type().Name(),
+		Range:    rangeToDescription(f.RangeMatcher),
+	}
+}
+
+func headerCountFilterFromDescription(_ FilterMap, fd *FilterDescription) Filter {
+	f := &HeaderCountFilter{}
+	if err := f.SetMatcher(rangeFromDescription(fd.Range)); err != nil {
+		return nil
+	}
+	return f
+}
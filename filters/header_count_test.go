@@ -0,0 +1,69 @@
+package filters
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+)
+
+func makeHeaders(n int) http.Header {
+	h := make(http.Header, n)
+	for i := 0; i < n; i++ {
+		h.Set(fmt.Sprintf(`X-Header-%d`, i), `value`)
+	}
+	return h
+}
+
+func TestHeaderCountFilter_MatchesCall(t *testing.T) {
+	threshold := func() RangeMatcher { return NewRangeMatcher().From(20) }
+	tests := []struct {
+		name        string
+		matcher     RangeMatcher
+		withRequest bool
+		headers     int
+		want        bool
+	}{
+		{"default", nil, true, 3, true},
+		{"small", threshold(), true, 3, false},
+		{"just below", threshold(), true, 19, false},
+		{"threshold", threshold(), true, 20, true},
+		{"large", threshold(), true, 100, true},
+		{"excluded bound", threshold().ExcludeFrom(), true, 20, false},
+		{"bounded", NewRangeMatcher().From(5).To(10), true, 12, false},
+		{"no request", threshold(), false, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &HeaderCountFilter{RangeMatcher: tt.matcher}
+			e := &events.EventBase{}
+			if tt.withRequest {
+				e.SetRequest(&http.Request{Header: makeHeaders(tt.headers)})
+			}
+			if got := f.MatchesCall(e); got != tt.want {
+				t.Errorf("MatchesCall() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaderCountFilter_SetMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher Matcher
+		wantErr bool
+	}{
+		{"happy", NewRangeMatcher().From(10), false},
+		{"nil", nil, false},
+		{"sad matcher", &yesMatcher{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &HeaderCountFilter{}
+			if err := f.SetMatcher(tt.matcher); (err != nil) != tt.wantErr {
+				t.Errorf("SetMatcher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
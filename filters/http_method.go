@@ -65,6 +65,15 @@ func (f *HTTPMethodFilter) SetMatcher(matcher Matcher) error {
 	return nil
 }
 
+// Describe is part of the Filter interface.
+func (f *HTTPMethodFilter) Describe() FilterDescription {
+	d := FilterDescription{TypeName: f.Type().Name()}
+	if f.StringMatcher != nil {
+		d.Value = f.StringMatcher.String()
+	}
+	return d
+}
+
 func methodFilterFromDescription(_ FilterMap, fd *FilterDescription) Filter {
 	f := &HTTPMethodFilter{}
 	err := f.SetMatcher(NewStringMatcher(fd.Value, true))
@@ -0,0 +1,68 @@
+package filters
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/bearer/go-agent/events"
+)
+
+// IPRangeFilter matches API calls to hosts given as literal IP addresses
+// within CIDR blocks, e.g. to target internal services by network rather than
+// by name. Hosts given by name never match, as no DNS resolution is performed.
+type IPRangeFilter struct {
+	Ranges []*net.IPNet
+}
+
+// Type is part of the Filter interface.
+func (*IPRangeFilter) Type() FilterType {
+	return IPRangeFilterType
+}
+
+// MatchesCall is part of the Filter interface.
+func (f *IPRangeFilter) MatchesCall(e events.Event) bool {
+	request := e.Request()
+	if request == nil || request.URL == nil {
+		return false
+	}
+	// Hostname removes the brackets around IPv6 addresses.
+	ip := net.ParseIP(request.URL.Hostname())
+	if ip == nil {
+		return false
+	}
+	for _, r := range f.Ranges {
+		if r.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMatcher is part of the Filter interface. In IPRangeFilter, is only
+// accepts a nil matcher, as no underlying matcher is actually used.
+func (*IPRangeFilter) SetMatcher(matcher Matcher) error {
+	if matcher != nil {
+		return fmt.Errorf("instances of IPRangeFilter only accept a nil Matcher, got %T", matcher)
+	}
+	return nil
+}
+
+// Describe is part of the Filter interface.
+func (f *IPRangeFilter) Describe() FilterDescription {
+	d := FilterDescription{TypeName: f.Type().Name()}
+	for _, r := range f.Ranges {
+		d.IPRanges = append(d.IPRanges, r.String())
+	}
+	return d
+}
+
+func ipRangeFilterFromDescription(_ FilterMap, fd *FilterDescription) Filter {
+	f := &IPRangeFilter{}
+	// Invalid blocks are ignored, so a filter without valid blocks never matches.
+	for _, cidr := range fd.IPRanges {
+		if _, r, err := net.ParseCIDR(cidr); err == nil {
+			f.Ranges = append(f.Ranges, r)
+		}
+	}
+	return f
+}
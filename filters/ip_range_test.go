@@ -0,0 +1,106 @@
+package filters
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+)
+
+func mustParseCIDRs(t *testing.T, cidrs ...string) []*net.IPNet {
+	var ranges []*net.IPNet
+	for _, cidr := range cidrs {
+		_, r, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatalf("ParseCIDR(%s) error = %v", cidr, err)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+func TestIPRangeFilter_Type(t *testing.T) {
+	expected := IPRangeFilterType.String()
+	var f IPRangeFilter
+	actual := f.Type().String()
+	if actual != expected {
+		t.Errorf("Type() = %v, want %v", actual, expected)
+	}
+}
+
+func TestIPRangeFilter_MatchesCall(t *testing.T) {
+	ranges := mustParseCIDRs(t, `10.0.0.0/8`, `192.168.1.0/24`, `fd00::/8`)
+	tests := []struct {
+		name   string
+		ranges []*net.IPNet
+		url    string
+		want   bool
+	}{
+		{`ipv4 in range`, ranges, `http://10.1.2.3/path`, true},
+		{`ipv4 with port`, ranges, `http://192.168.1.20:8080/`, true},
+		{`ipv4 out of range`, ranges, `http://192.168.2.20/`, false},
+		{`ipv6 in range`, ranges, `http://[fd12::1]:8443/`, true},
+		{`ipv6 out of range`, ranges, `http://[2001:db8::1]/`, false},
+		{`ipv4-mapped ipv6`, ranges, `http://[::ffff:10.0.0.1]/`, true},
+		{`host name`, ranges, `https://internal.example.com/`, false},
+		{`no ranges`, nil, `http://10.1.2.3/`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &IPRangeFilter{Ranges: tt.ranges}
+			u, _ := url.Parse(tt.url)
+			e := &events.EventBase{}
+			e.SetRequest(&http.Request{URL: u})
+			if got := f.MatchesCall(e); got != tt.want {
+				t.Errorf("MatchesCall() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	f := &IPRangeFilter{Ranges: ranges}
+	if f.MatchesCall(&events.EventBase{}) {
+		t.Error("MatchesCall() = true without a request")
+	}
+}
+
+func TestIPRangeFilter_SetMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher Matcher
+		wantErr bool
+	}{
+		{`happy`, nil, false},
+		{`sad`, &yesMatcher{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &IPRangeFilter{}
+			if err := f.SetMatcher(tt.matcher); (err != nil) != tt.wantErr {
+				t.Errorf("SetMatcher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_ipRangeFilterFromDescription(t *testing.T) {
+	tests := []struct {
+		name     string
+		ranges   []string
+		expected []*net.IPNet
+	}{
+		{`none`, nil, nil},
+		{`valid`, []string{`10.0.0.0/8`, `fd00::/8`}, mustParseCIDRs(t, `10.0.0.0/8`, `fd00::/8`)},
+		{`invalid ignored`, []string{`10.0.0.0/33`, `not a block`, `172.16.0.0/12`}, mustParseCIDRs(t, `172.16.0.0/12`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := ipRangeFilterFromDescription(nil, &FilterDescription{IPRanges: tt.ranges}).(*IPRangeFilter)
+			if !reflect.DeepEqual(f.Ranges, tt.expected) {
+				t.Errorf("Ranges = %v, want %v", f.Ranges, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package filters
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/bearer/go-agent/events"
+)
+
+// jsonContentType matches the content types of JSON bodies.
+var jsonContentType = regexp.MustCompile(`(?i)json`)
+
+// JSONSchemaFilter provides a filter validating the parsed JSON response body
+// in API calls against a JSON Schema. It matches when validation fails, for
+// rules to report schema violations.
+//
+// It only applies once the bodies are parsed, so calls before the bodies
+// stage, calls without a JSON response body, and calls with a response body
+// replaced by a placeholder like BodyTooLong, never match.
+type JSONSchemaFilter struct {
+	SchemaMatcher
+}
+
+// Type is part of the Filter interface.
+func (*JSONSchemaFilter) Type() FilterType {
+	return JSONSchemaFilterType
+}
+
+func (f *JSONSchemaFilter) ensureMatcher() {
+	if f.SchemaMatcher != nil {
+		return
+	}
+	_ = f.SetMatcher(NewSchemaMatcher(nil))
+}
+
+// MatchesCall is part of the Filter interface.
+func (f *JSONSchemaFilter) MatchesCall(e events.Event) bool {
+	be, ok := e.(ParsedBodiesEvent)
+	if !ok {
+		return false
+	}
+	response := e.Response()
+	if response == nil || !jsonContentType.MatchString(response.Header.Get(`Content-Type`)) {
+		return false
+	}
+	_, body := be.ParsedBodies()
+	// Bodies not parsed cannot be validated, and are not schema violations.
+	if isUnparsedBody(body) {
+		return false
+	}
+	f.ensureMatcher()
+	return !f.SchemaMatcher.Matches(body)
+}
+
+// SetMatcher sets the filter SchemaMatcher. A nil matcher accepts any body, so
+// the filter never matches.
+//
+// If the returned error is not nil, the SchemaMatcher is rejected.
+func (f *JSONSchemaFilter) SetMatcher(matcher Matcher) error {
+	if matcher == nil {
+		matcher = NewSchemaMatcher(nil)
+	}
+	sm, ok := matcher.(SchemaMatcher)
+	if !ok {
+		f.ensureMatcher()
+		return fmt.Errorf("the JSONSchemaFilter only accepts SchemaMatchers: got %T", matcher)
+	}
+	f.SchemaMatcher = sm
+	return nil
+}
+
+// Describe is part of the Filter interface.
+func (f *JSONSchemaFilter) Describe() FilterDescription {
+	f.ensureMatcher()
+	return FilterDescription{
+		TypeName: f.Type().Name(),
+		Schema:   f.Schema(),
+	}
+}
+
+func jsonSchemaFilterFromDescription(_ FilterMap, fd *FilterDescription) Filter {
+	f := &JSONSchemaFilter{}
+	_ = f.SetMatcher(NewSchemaMatcher(fd.Schema))
+	return f
+}
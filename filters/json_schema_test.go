@@ -0,0 +1,135 @@
+package filters
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+)
+
+type parsedBodiesEvent struct {
+	events.EventBase
+	request, response interface{}
+}
+
+func (e *parsedBodiesEvent) ParsedBodies() (request, response interface{}) {
+	return e.request, e.response
+}
+
+func TestJSONSchemaFilter_MatchesCall(t *testing.T) {
+	var schema map[string]interface{}
+	err := json.Unmarshal([]byte(`{
+		"type": "object",
+		"required": ["id", "name"],
+		"properties": {
+			"id": { "type": "integer", "minimum": 1 },
+			"name": { "type": "string", "minLength": 1 },
+			"tags": { "type": "array", "items": { "type": "string" } }
+		}
+	}`), &schema)
+	if err != nil {
+		t.Fatalf("failed decoding schema: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		parsed      bool
+		want        bool
+	}{
+		{"conforming", `application/json`, `{"id": 1, "name": "foo", "tags": ["bar"]}`, true, false},
+		{"conforming extra property", `application/json`, `{"id": 1, "name": "foo", "extra": true}`, true, false},
+		{"missing required", `application/json`, `{"id": 1}`, true, true},
+		{"wrong type", `application/json`, `{"id": "1", "name": "foo"}`, true, true},
+		{"below minimum", `application/json`, `{"id": 0, "name": "foo"}`, true, true},
+		{"bad item", `application/json`, `{"id": 1, "name": "foo", "tags": [1]}`, true, true},
+		{"not an object", `application/problem+json`, `[]`, true, true},
+		{"not json", `text/plain`, `{"id": 1}`, true, false},
+		{"not parsed", `application/json`, `{"id": 1}`, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &JSONSchemaFilter{NewSchemaMatcher(schema)}
+			response := &http.Response{Header: http.Header{`Content-Type`: {tt.contentType}}}
+			var e events.Event
+			if tt.parsed {
+				var body interface{}
+				if err := json.Unmarshal([]byte(tt.body), &body); err != nil {
+					t.Fatalf("failed decoding body: %v", err)
+				}
+				pe := &parsedBodiesEvent{response: body}
+				pe.SetResponse(response)
+				e = pe
+			} else {
+				e = (&events.EventBase{}).SetResponse(response)
+			}
+			if got := f.MatchesCall(e); got != tt.want {
+				t.Errorf("MatchesCall() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONSchemaFilter_MatchesCallUnparsedBody(t *testing.T) {
+	f := &JSONSchemaFilter{NewSchemaMatcher(map[string]interface{}{`type`: `object`})}
+	tests := []struct {
+		name string
+		body interface{}
+	}{
+		{"nil", nil},
+		{"too long", BodyTooLong},
+		{"undecodable", BodyUndecodable},
+		{"binary", BodyIsBinary},
+		{"stream", BodyIsStream},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pe := &parsedBodiesEvent{response: tt.body}
+			pe.SetResponse(&http.Response{Header: http.Header{`Content-Type`: {`application/json`}}})
+			if f.MatchesCall(pe) {
+				t.Errorf("MatchesCall() = true on unparsed body %v", tt.body)
+			}
+		})
+	}
+}
+
+func TestJSONSchemaFilter_MatchesCallNoResponse(t *testing.T) {
+	f := &JSONSchemaFilter{}
+	if f.MatchesCall(&parsedBodiesEvent{}) {
+		t.Error("MatchesCall() = true without a response")
+	}
+}
+
+func TestJSONSchemaFilter_SetMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher Matcher
+		wantErr bool
+	}{
+		{"happy", NewSchemaMatcher(nil), false},
+		{"nil", nil, false},
+		{"sad matcher", &yesMatcher{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &JSONSchemaFilter{}
+			if err := f.SetMatcher(tt.matcher); (err != nil) != tt.wantErr {
+				t.Errorf("SetMatcher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if f.SchemaMatcher == nil {
+				t.Error("SetMatcher() left a nil matcher")
+			}
+		})
+	}
+}
+
+func TestJSONSchemaFilter_Type(t *testing.T) {
+	expected := JSONSchemaFilterType.String()
+	var f JSONSchemaFilter
+	actual := f.Type().String()
+	if actual != expected {
+		t.Errorf("Type() = %v, want %v", actual, expected)
+	}
+}
@@ -0,0 +1,121 @@
+package filters
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// NeverRegexp is a compiled regexp matching no string, used by the GlobMatcher
+// for invalid patterns.
+var NeverRegexp = regexp.MustCompile(`[^\x00-\x{10FFFF}]`)
+
+// GlobMatcher provides the ability to match strings against a shell-style
+// glob, like *.internal.example.com, without escaping regexp metacharacters:
+//   - * matches any sequence of characters, including dots
+//   - ? matches any single character
+//   - [abc], [a-z] match a character class, negated by a leading ! or ^
+//   - \ matches the next character literally.
+//
+// Matching is case-insensitive, as for host names, and applies to the whole
+// value. The empty pattern matches anything, while invalid patterns, like an
+// unterminated class, match nothing.
+//
+// Since it is also a RegexpMatcher, it is accepted by the filters using one,
+// like DomainFilter, and described as the equivalent regexp.
+type GlobMatcher struct {
+	Glob string
+	regexpMatcher
+}
+
+// NewGlobMatcher creates a GlobMatcher.
+func NewGlobMatcher(pattern string) Matcher {
+	re, err := globToRegexp(pattern)
+	if err != nil {
+		re = NeverRegexp
+	}
+	return &GlobMatcher{
+		Glob:          pattern,
+		regexpMatcher: regexpMatcher{Pattern: re},
+	}
+}
+
+// String implements fmt.Stringer.
+func (m *GlobMatcher) String() string {
+	return m.Glob
+}
+
+// Explain is part of the ExplainMatcher interface.
+func (m *GlobMatcher) Explain(x interface{}) string {
+	s, ok := stringify(x).(string)
+	if !ok {
+		return fmt.Sprintf(`%T value cannot match glob %q`, x, m.Glob)
+	}
+	if m.Matches(s) {
+		return fmt.Sprintf(`glob %q matched value %q`, m.Glob, s)
+	}
+	return fmt.Sprintf(`glob %q did not match value %q`, m.Glob, s)
+}
+
+// globToRegexp translates a glob to an anchored case-insensitive regexp, or
+// the EmptyRegexp for the empty glob.
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	if glob == `` {
+		return EmptyRegexp, nil
+	}
+	b := strings.Builder{}
+	b.WriteString(`(?i)^`)
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			b.WriteString(`.*`)
+		case '?':
+			b.WriteString(`.`)
+		case '\\':
+			i++
+			if i == len(glob) {
+				return nil, fmt.Errorf("trailing escape in glob %q", glob)
+			}
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case '[':
+			end, class, err := globClass(glob, i)
+			if err != nil {
+				return nil, err
+			}
+			b.WriteString(class)
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	b.WriteString(`$`)
+	return regexp.Compile(b.String())
+}
+
+// globClass translates the character class starting at glob[start], returning
+// the index of its closing bracket and the equivalent regexp class.
+func globClass(glob string, start int) (int, string, error) {
+	b := strings.Builder{}
+	b.WriteByte('[')
+	i := start + 1
+	if i < len(glob) && (glob[i] == '!' || glob[i] == '^') {
+		b.WriteByte('^')
+		i++
+	}
+	// A leading ] is part of the class.
+	for first := true; i < len(glob); i, first = i+1, false {
+		c := glob[i]
+		if c == ']' && !first {
+			b.WriteByte(']')
+			return i, b.String(), nil
+		}
+		switch c {
+		case '\\', '[', ']', '^':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return 0, ``, fmt.Errorf("unterminated character class in glob %q", glob)
+}
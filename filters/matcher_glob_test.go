@@ -0,0 +1,94 @@
+package filters
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+)
+
+func TestGlobMatcher_Matches(t *testing.T) {
+	tests := []struct {
+		name     string
+		glob     string
+		check    interface{}
+		expected bool
+	}{
+		{`empty vs empty`, ``, ``, true},
+		{`empty vs any`, ``, `api.example.com`, true},
+		{`literal`, `api.example.com`, `api.example.com`, true},
+		{`literal dot`, `api.example.com`, `apixexample.com`, false},
+		{`whole value`, `example.com`, `api.example.com`, false},
+		{`case-insensitive`, `*.Internal.Example.com`, `API.internal.example.COM`, true},
+		{`star`, `*.internal.example.com`, `billing.internal.example.com`, true},
+		{`star across dots`, `*.internal.example.com`, `a.b.internal.example.com`, true},
+		{`star needs the dot`, `*.internal.example.com`, `internal.example.com`, false},
+		{`question mark`, `api-?.example.com`, `api-2.example.com`, true},
+		{`question mark single`, `api-?.example.com`, `api-12.example.com`, false},
+		{`class`, `api-[0-9].example.com`, `api-7.example.com`, true},
+		{`class miss`, `api-[0-9].example.com`, `api-x.example.com`, false},
+		{`negated class`, `api-[!0-9].example.com`, `api-x.example.com`, true},
+		{`negated class miss`, `api-[^0-9].example.com`, `api-7.example.com`, false},
+		{`leading bracket in class`, `[]a]`, `]`, true},
+		{`regexp metacharacters`, `a+b(c).example.com`, `a+b(c).example.com`, true},
+		{`escape`, `\*.example.com`, `*.example.com`, true},
+		{`escape literal`, `\*.example.com`, `api.example.com`, false},
+		{`stringer`, `*.example.com`, testString(`api.example.com`), true},
+		{`non-string`, `*`, 42, false},
+		{`invalid class`, `[a-`, `a`, false},
+		{`invalid class vs empty`, `[a-`, ``, false},
+		{`invalid range`, `[z-a]`, `b`, false},
+		{`trailing escape`, `api\`, `api\`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := NewGlobMatcher(tt.glob).Matches(tt.check); actual != tt.expected {
+				t.Errorf("NewGlobMatcher(%q).Matches(%v) = %t, expected %t", tt.glob, tt.check, actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGlobMatcher_Explain(t *testing.T) {
+	m := NewGlobMatcher(`*.example.com`)
+	if actual, expected := Explain(m, `api.example.com`), `glob "*.example.com" matched value "api.example.com"`; actual != expected {
+		t.Errorf("Explain() = %s, expected %s", actual, expected)
+	}
+	if actual, expected := Explain(m, `example.org`), `glob "*.example.com" did not match value "example.org"`; actual != expected {
+		t.Errorf("Explain() = %s, expected %s", actual, expected)
+	}
+}
+
+func TestDomainFilter_GlobMatcher(t *testing.T) {
+	f := &DomainFilter{}
+	if err := f.SetMatcher(NewGlobMatcher(`*.internal.example.com`)); err != nil {
+		t.Fatalf("SetMatcher() error = %v", err)
+	}
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{`billing.internal.example.com`, true},
+		{`Billing.Internal.Example.com`, true},
+		{`internal.example.com.evil.org`, false},
+		{`example.com`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			u, _ := url.Parse(`https://` + tt.host + `:8443/path`)
+			e := (&events.EventBase{}).SetRequest(&http.Request{URL: u})
+			if got := f.MatchesCall(e); got != tt.want {
+				t.Errorf("MatchesCall() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The glob is described as its regexp, which builds an equivalent filter.
+	d := f.Describe()
+	rebuilt := domainFilterFromDescription(nil, &d)
+	u, _ := url.Parse(`https://billing.internal.example.com`)
+	if !rebuilt.MatchesCall((&events.EventBase{}).SetRequest(&http.Request{URL: u})) {
+		t.Errorf("filter rebuilt from %v does not match", d.Pattern)
+	}
+}
@@ -68,6 +68,8 @@ type keyValueMatcher struct {
 	mutex                  sync.RWMutex
 	seen                   pMap
 	keyRegexp, valueRegexp *regexp.Regexp
+	// reason explains the last successful match, for Explain.
+	reason string
 }
 
 func (m *keyValueMatcher) KeyRegexp() *regexp.Regexp {
@@ -86,6 +88,28 @@ func (m *keyValueMatcher) Matches(x interface{}) (found bool) {
 	return m.doMatch(x, false)
 }
 
+// Explain is part of the ExplainMatcher interface.
+func (m *keyValueMatcher) Explain(x interface{}) string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.seen = pMap{}
+	m.reason = ``
+
+	if m.doMatch(x, false) {
+		return m.reason
+	}
+	return fmt.Sprintf(`no key matching %s with a value matching %s`,
+		regexpToString(m.keyRegexp), regexpToString(m.valueRegexp))
+}
+
+// regexpToString formats a regexp for explanations, nil meaning any value.
+func regexpToString(re *regexp.Regexp) string {
+	if re == nil {
+		return `(any)`
+	}
+	return `/` + re.String() + `/`
+}
+
 func (m *keyValueMatcher) doMatch(x interface{}, ignoreKeyRegexp bool) bool {
 	// Obtain a reflect.Value if we don't already have one.
 	v, isValue := x.(reflect.Value)
@@ -117,7 +141,11 @@ func (m *keyValueMatcher) matchesString(s string, ignoreKeyRegexp bool) bool {
 	if m.keyRegexp != nil && !ignoreKeyRegexp {
 		return false
 	}
-	return m.valueRegexp == nil || m.valueRegexp.MatchString(s)
+	if m.valueRegexp != nil && !m.valueRegexp.MatchString(s) {
+		return false
+	}
+	m.reason = fmt.Sprintf(`value %q matched %s`, s, regexpToString(m.valueRegexp))
+	return true
 }
 
 // matchesSlice matches against each element in a slice. The parameter must be
@@ -134,6 +162,7 @@ func (m *keyValueMatcher) matchesSlice(x interface{}, ignoreKeyRegexp bool) bool
 		v := value.Index(i).Interface()
 		// First, attempt a normal match.
 		if m.doMatch(v, ignoreKeyRegexp) {
+			m.reason = fmt.Sprintf(`element %d: %s`, i, m.reason)
 			return true
 		}
 	}
@@ -166,6 +195,7 @@ func (m *keyValueMatcher) matchesMap(x interface{}) bool {
 		return false
 	}
 	if m.keyRegexp == nil && m.valueRegexp == nil {
+		m.reason = `no patterns: any map matches`
 		return true
 	}
 	if value.Len() == 0 || !isElementMatchableKind(value) {
@@ -181,9 +211,9 @@ func (m *keyValueMatcher) matchesMap(x interface{}) bool {
 
 	mapIter := value.MapRange()
 	for mapIter.Next() {
+		key := mapIter.Key().Interface()
 		if m.keyRegexp != nil {
 			// If key doesn't match, no need to check x.
-			key := mapIter.Key().Interface()
 			// For stringable keys, use a plain regexp match: cycle detection does
 			// not apply.
 			switch key.(type) {
@@ -191,27 +221,50 @@ func (m *keyValueMatcher) matchesMap(x interface{}) bool {
 				// For these three types, s will always be a string.
 				s := stringify(key)
 				if !m.keyRegexp.MatchString(s.(string)) {
+					if m.matchesNested(key, mapIter.Value().Interface()) {
+						return true
+					}
 					continue
 				}
 			default:
 				if !m.doMatch(key, false) {
+					if m.matchesNested(key, mapIter.Value().Interface()) {
+						return true
+					}
 					continue
 				}
 			}
 		}
 
+		keyReason := fmt.Sprintf(`key %#v matched %s`, stringify(key), regexpToString(m.keyRegexp))
 		if m.valueRegexp == nil {
+			m.reason = keyReason
 			return true
 		}
 
 		i := mapIter.Value().Interface()
 		if m.matchElement(i) {
+			m.reason = keyReason + `, ` + m.reason
 			return true
 		}
 	}
 	return false
 }
 
+// matchesNested matches the entries of a map value below a non-matching key,
+// like the fields of a nested JSON object. String-like values have no entries.
+func (m *keyValueMatcher) matchesNested(key, x interface{}) bool {
+	switch x.(type) {
+	case string, fmt.Stringer, error:
+		return false
+	}
+	if !m.doMatch(x, false) {
+		return false
+	}
+	m.reason = fmt.Sprintf(`in key %#v: %s`, stringify(key), m.reason)
+	return true
+}
+
 // NewKeyValueMatcher creates a KeyValueMatcher accepting values matching the
 // regular expressions built from the passed strings.
 // Passing an empty string for either expression builds a nil regex accepting
@@ -248,3 +301,14 @@ func regexpDescriptionToString(pattern *RegexpMatcherDescription) string {
 
 	return fmt.Sprintf("/%s/%s", pattern.Value, pattern.Flags)
 }
+
+// keyValueToDescription builds the KeyValueDescription of a KeyValueMatcher.
+func keyValueToDescription(m KeyValueMatcher) KeyValueDescription {
+	if isNilInterface(m) {
+		return KeyValueDescription{}
+	}
+	return KeyValueDescription{
+		KeyPattern:   regexpToDescription(m.KeyRegexp()),
+		ValuePattern: regexpToDescription(m.ValueRegexp()),
+	}
+}
@@ -29,7 +29,7 @@ func isMatchableKind(typ reflect.Type) bool {
 	// Non-matchable kinds like a plain "int" can be matchable if they
 	// belong to defined types implementing a matchable interface like error or
 	// fmt.Stringer.
-	if (typ.Implements(errorType) || typ.Implements(stringerType)) {
+	if typ.Implements(errorType) || typ.Implements(stringerType) {
 		return true
 	}
 
@@ -38,6 +38,9 @@ func isMatchableKind(typ reflect.Type) bool {
 		reflect.Slice:  true,
 		reflect.Array:  true,
 		reflect.Map:    true,
+		// Interface elements, as in decoded JSON, are matched on their dynamic
+		// type by doMatch.
+		reflect.Interface: true,
 	}
 	if _, isMatchable := matchable[kind]; isMatchable {
 		return true
@@ -119,6 +119,12 @@ func Test_keyValueMatcher_matchesMap(t *testing.T) {
 		{"happy stringer key", &fields{reFoo, reBar}, map[fmt.Stringer]string{kvStringer(foo): bar}, true},
 		{"happy error key", &fields{reFoo, reBar}, map[error]string{errors.New(foo): bar}, true},
 		{"happy no key", &fields{nil, reBar}, map[int]fmt.Stringer{42: kvStringer(bar)}, true},
+		{"happy interface value", &fields{reFoo, reBar}, map[string]interface{}{foo: bar}, true},
+		{"happy nested interface value", &fields{reFoo, reBar}, map[string]interface{}{bar: []interface{}{42, map[string]interface{}{foo: bar}}}, true},
+		{"happy nested map", &fields{reFoo, reBar}, map[string]map[string]string{bar: {foo: bar}}, true},
+		{"sad nested map", &fields{reFoo, reBar}, map[string]map[string]string{bar: {bar: foo}}, false},
+		{"sad non-string interface value", &fields{reFoo, reBar}, map[string]interface{}{foo: 42}, false},
+		{"sad nil interface value", &fields{reFoo, reBar}, map[string]interface{}{foo: nil}, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -314,3 +320,30 @@ func Test_isMatchableKind(t *testing.T) {
 		})
 	}
 }
+
+func Test_keyValueMatcher_Explain(t *testing.T) {
+	tests := []struct {
+		name                   string
+		keyRegexp, valueRegexp *regexp.Regexp
+		x                      interface{}
+		expected               string
+	}{
+		{`key and value`, reFoo, reBar, http.Header{foo: {foo, bar}},
+			`key "foo" matched /foo/, element 1: value "bar" matched /bar/`},
+		{`key only`, reFoo, nil, map[string]string{foo: `baz`}, `key "foo" matched /foo/`},
+		{`value only`, nil, reBar, map[string]string{`qux`: bar},
+			`key "qux" matched (any), value "bar" matched /bar/`},
+		{`string`, nil, reBar, bar, `value "bar" matched /bar/`},
+		{`slice`, nil, reBar, []string{foo, bar}, `element 1: value "bar" matched /bar/`},
+		{`no patterns`, nil, nil, map[string]string{}, `no patterns: any map matches`},
+		{`no match`, reFoo, reBar, http.Header{foo: {foo}}, `no key matching /foo/ with a value matching /bar/`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewKeyValueMatcher(tt.keyRegexp, tt.valueRegexp)
+			if actual := Explain(m, tt.x); actual != tt.expected {
+				t.Errorf("Explain() = %q, expected %q", actual, tt.expected)
+			}
+		})
+	}
+}
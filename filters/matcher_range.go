@@ -154,18 +154,43 @@ func (d RangeMatcherDescription) String() string {
 		return ``
 	}
 
-	rm := NewRangeMatcher()
+	return `Range: ` + rangeFromDescription(d).String() + "\n"
+}
+
+// rangeFromDescription builds the RangeMatcher described by a RangeMatcherDescription.
+func rangeFromDescription(d RangeMatcherDescription) RangeMatcher {
+	m := NewRangeMatcher()
 	if d.From != nil {
-		rm.From(d.ToInt(d.From))
+		m.From(d.ToInt(d.From))
 	}
 	if d.To != nil {
-		rm.To(d.ToInt(d.To))
+		m.To(d.ToInt(d.To))
 	}
 	if d.ExcludeFrom {
-		rm.ExcludeFrom()
+		m.ExcludeFrom()
 	}
 	if d.ExcludeTo {
-		rm.ExcludeTo()
+		m.ExcludeTo()
+	}
+	return m
+}
+
+// rangeToDescription builds the RangeMatcherDescription of a RangeMatcher.
+// Unbounded limits are described as nil.
+func rangeToDescription(m RangeMatcher) RangeMatcherDescription {
+	r, ok := m.(*intRange)
+	if !ok {
+		return RangeMatcherDescription{}
+	}
+	d := RangeMatcherDescription{
+		ExcludeFrom: r.FromExclusive,
+		ExcludeTo:   r.ToExclusive,
+	}
+	if r.lo != minInt {
+		d.From = r.lo
+	}
+	if r.hi != maxInt {
+		d.To = r.hi
 	}
-	return `Range: ` + rm.String() + "\n"
+	return d
 }
@@ -49,6 +49,21 @@ func (m *regexpMatcher) Matches(x interface{}) bool {
 	return false
 }
 
+// Explain is part of the ExplainMatcher interface.
+func (m *regexpMatcher) Explain(x interface{}) string {
+	if m.Pattern == nil {
+		return `no pattern: any value matches`
+	}
+	s, ok := stringify(x).(string)
+	if !ok {
+		return fmt.Sprintf(`%T value cannot match /%s/`, x, m.Pattern)
+	}
+	if loc := m.Pattern.FindStringIndex(s); loc != nil {
+		return fmt.Sprintf(`/%s/ matched %q in value %q`, m.Pattern, s[loc[0]:loc[1]], s)
+	}
+	return fmt.Sprintf(`/%s/ did not match value %q`, m.Pattern, s)
+}
+
 func (m *regexpMatcher) Regexp() *regexp.Regexp {
 	return m.Pattern
 }
@@ -75,3 +90,20 @@ func (d RegexpMatcherDescription) String() string {
 	}
 	return fmt.Sprintf("Regexp: /%s/%s\n", d.Value, d.Flags)
 }
+
+// flagsRegexp matches the leading flags group prepended to regexps built from
+// a RegexpMatcherDescription.
+var flagsRegexp = regexp.MustCompile(`^\(\?([imsU]+)\)`)
+
+// regexpToDescription is the inverse of descriptionToRegexp.
+func regexpToDescription(re *regexp.Regexp) *RegexpMatcherDescription {
+	if re == nil {
+		return nil
+	}
+	d := &RegexpMatcherDescription{Value: re.String()}
+	if m := flagsRegexp.FindStringSubmatch(d.Value); m != nil {
+		d.Flags = m[1]
+		d.Value = d.Value[len(m[0]):]
+	}
+	return d
+}
@@ -3,6 +3,7 @@ package filters
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"testing"
 )
 
@@ -55,3 +56,36 @@ func TestRegexMatcher_Regexp(t *testing.T) {
 		t.Fatalf("incorrect regexp:\n  wanted %s\n  got %s", expected, actual)
 	}
 }
+
+func Test_regexpMatcher_Explain(t *testing.T) {
+	re := regexp.MustCompile(`b[aeiou]r`)
+	tests := []struct {
+		name     string
+		m        *regexpMatcher
+		x        interface{}
+		expected string
+	}{
+		{`match`, &regexpMatcher{re}, `foobarbaz`, `/b[aeiou]r/ matched "bar" in value "foobarbaz"`},
+		{`stringer match`, &regexpMatcher{re}, testString(`ber`), `/b[aeiou]r/ matched "ber" in value "ber"`},
+		{`no match`, &regexpMatcher{re}, `foo`, `/b[aeiou]r/ did not match value "foo"`},
+		{`non-string`, &regexpMatcher{re}, 42, `int value cannot match /b[aeiou]r/`},
+		{`no pattern`, &regexpMatcher{}, `foo`, `no pattern: any value matches`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := Explain(tt.m, tt.x); actual != tt.expected {
+				t.Errorf("Explain() = %q, expected %q", actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExplain_NonExplainMatcher(t *testing.T) {
+	m := NewStringMatcher(`GET`, false)
+	if actual := Explain(m, `GET`); actual != `matched` {
+		t.Errorf("Explain() on match = %q", actual)
+	}
+	if actual := Explain(m, `POST`); actual != `did not match` {
+		t.Errorf("Explain() on mismatch = %q", actual)
+	}
+}
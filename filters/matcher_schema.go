@@ -0,0 +1,237 @@
+package filters
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+// SchemaMatcher provides the ability to validate values unmarshalled from JSON
+// against a JSON Schema.
+//
+// It only supports a lightweight subset of JSON Schema, ignoring other keywords:
+//   - all types: type, enum
+//   - objects: properties, required, additionalProperties
+//   - arrays: items, minItems, maxItems
+//   - strings: minLength, maxLength, pattern
+//   - numbers: minimum, maximum
+//
+// By default, it matches anything.
+type SchemaMatcher interface {
+	Matcher
+	// Schema returns the JSON Schema, as unmarshalled from JSON.
+	Schema() map[string]interface{}
+	// Validate returns an error describing the first violation of the schema
+	// found in the value, if any.
+	Validate(x interface{}) error
+}
+
+type schemaMatcher struct {
+	schema map[string]interface{}
+
+	// patterns caches the compiled "pattern" keywords.
+	patterns sync.Map
+}
+
+// NewSchemaMatcher creates a SchemaMatcher from a JSON Schema unmarshalled from
+// JSON. A nil schema matches anything.
+func NewSchemaMatcher(schema map[string]interface{}) SchemaMatcher {
+	return &schemaMatcher{schema: schema}
+}
+
+func (m *schemaMatcher) Schema() map[string]interface{} {
+	return m.schema
+}
+
+func (m *schemaMatcher) Matches(x interface{}) bool {
+	return m.Validate(x) == nil
+}
+
+func (m *schemaMatcher) Validate(x interface{}) error {
+	return m.validate(`$`, m.schema, x)
+}
+
+func (m *schemaMatcher) validate(path string, schema map[string]interface{}, x interface{}) error {
+	if schema == nil {
+		return nil
+	}
+	if types, ok := schema[`type`]; ok && !matchesSchemaType(types, x) {
+		return fmt.Errorf("%s: %s does not match type %v", path, schemaTypeOf(x), types)
+	}
+	if enum, ok := schema[`enum`].([]interface{}); ok && !inSchemaEnum(enum, x) {
+		return fmt.Errorf("%s: value not in enum", path)
+	}
+
+	switch y := x.(type) {
+	case map[string]interface{}:
+		return m.validateObject(path, schema, y)
+	case []interface{}:
+		return m.validateArray(path, schema, y)
+	case string:
+		return m.validateString(path, schema, y)
+	case float64:
+		return validateNumber(path, schema, y)
+	}
+	return nil
+}
+
+func (m *schemaMatcher) validateObject(path string, schema map[string]interface{}, x map[string]interface{}) error {
+	if required, ok := schema[`required`].([]interface{}); ok {
+		for _, name := range required {
+			key, _ := name.(string)
+			if _, ok := x[key]; !ok {
+				return fmt.Errorf("%s: missing required property %s", path, key)
+			}
+		}
+	}
+
+	properties, _ := schema[`properties`].(map[string]interface{})
+	// Sort the keys for a stable choice of the reported violation.
+	keys := make([]string, 0, len(x))
+	for key := range x {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if property, ok := properties[key]; ok {
+			sub, _ := property.(map[string]interface{})
+			if err := m.validate(path+`.`+key, sub, x[key]); err != nil {
+				return err
+			}
+			continue
+		}
+		switch additional := schema[`additionalProperties`].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s: unexpected property %s", path, key)
+			}
+		case map[string]interface{}:
+			if err := m.validate(path+`.`+key, additional, x[key]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *schemaMatcher) validateArray(path string, schema map[string]interface{}, x []interface{}) error {
+	if min, ok := schemaInt(schema, `minItems`); ok && len(x) < min {
+		return fmt.Errorf("%s: %d items, expected at least %d", path, len(x), min)
+	}
+	if max, ok := schemaInt(schema, `maxItems`); ok && len(x) > max {
+		return fmt.Errorf("%s: %d items, expected at most %d", path, len(x), max)
+	}
+	items, ok := schema[`items`].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	for i, item := range x {
+		if err := m.validate(fmt.Sprintf("%s[%d]", path, i), items, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *schemaMatcher) validateString(path string, schema map[string]interface{}, x string) error {
+	length := utf8.RuneCountInString(x)
+	if min, ok := schemaInt(schema, `minLength`); ok && length < min {
+		return fmt.Errorf("%s: length %d, expected at least %d", path, length, min)
+	}
+	if max, ok := schemaInt(schema, `maxLength`); ok && length > max {
+		return fmt.Errorf("%s: length %d, expected at most %d", path, length, max)
+	}
+	pattern, ok := schema[`pattern`].(string)
+	if !ok {
+		return nil
+	}
+	re, err := m.compile(pattern)
+	if err != nil {
+		return fmt.Errorf("%s: invalid pattern %s: %w", path, pattern, err)
+	}
+	if !re.MatchString(x) {
+		return fmt.Errorf("%s: value does not match pattern %s", path, pattern)
+	}
+	return nil
+}
+
+func validateNumber(path string, schema map[string]interface{}, x float64) error {
+	if min, ok := schema[`minimum`].(float64); ok && x < min {
+		return fmt.Errorf("%s: %v is less than the minimum %v", path, x, min)
+	}
+	if max, ok := schema[`maximum`].(float64); ok && x > max {
+		return fmt.Errorf("%s: %v is greater than the maximum %v", path, x, max)
+	}
+	return nil
+}
+
+func (m *schemaMatcher) compile(pattern string) (*regexp.Regexp, error) {
+	if re, ok := m.patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	m.patterns.Store(pattern, re)
+	return re, nil
+}
+
+// schemaTypeOf returns the JSON Schema type name of a value unmarshalled from JSON.
+func schemaTypeOf(x interface{}) string {
+	switch y := x.(type) {
+	case nil:
+		return `null`
+	case bool:
+		return `boolean`
+	case string:
+		return `string`
+	case float64:
+		if y == math.Trunc(y) {
+			return `integer`
+		}
+		return `number`
+	case []interface{}:
+		return `array`
+	case map[string]interface{}:
+		return `object`
+	}
+	return `unknown`
+}
+
+// matchesSchemaType checks the value against a "type" keyword, which may be a
+// single type name or a list of them.
+func matchesSchemaType(types interface{}, x interface{}) bool {
+	actual := schemaTypeOf(x)
+	matches := func(typ interface{}) bool {
+		return typ == actual || (typ == `number` && actual == `integer`)
+	}
+	if list, ok := types.([]interface{}); ok {
+		for _, typ := range list {
+			if matches(typ) {
+				return true
+			}
+		}
+		return false
+	}
+	return matches(types)
+}
+
+func inSchemaEnum(enum []interface{}, x interface{}) bool {
+	for _, value := range enum {
+		if reflect.DeepEqual(value, x) {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaInt returns the value of an integer keyword.
+func schemaInt(schema map[string]interface{}, keyword string) (int, bool) {
+	n, ok := schema[keyword].(float64)
+	return int(n), ok
+}
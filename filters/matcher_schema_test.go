@@ -0,0 +1,56 @@
+package filters
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSchemaMatcher_Matches(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		value  string
+		want   bool
+	}{
+		{"nil schema", `null`, `{"any": "thing"}`, true},
+		{"type", `{"type": "string"}`, `"foo"`, true},
+		{"type mismatch", `{"type": "string"}`, `1`, false},
+		{"type list", `{"type": ["string", "null"]}`, `null`, true},
+		{"integer as number", `{"type": "number"}`, `1`, true},
+		{"number as integer", `{"type": "integer"}`, `1.5`, false},
+		{"enum", `{"enum": ["a", "b"]}`, `"b"`, true},
+		{"enum mismatch", `{"enum": ["a", "b"]}`, `"c"`, false},
+		{"required", `{"required": ["a"]}`, `{"a": 1}`, true},
+		{"required missing", `{"required": ["a"]}`, `{"b": 1}`, false},
+		{"no additional properties", `{"properties": {"a": {}}, "additionalProperties": false}`, `{"a": 1, "b": 2}`, false},
+		{"additional properties schema", `{"additionalProperties": {"type": "integer"}}`, `{"a": 1, "b": "2"}`, false},
+		{"items", `{"items": {"type": "integer"}}`, `[1, 2]`, true},
+		{"items mismatch", `{"items": {"type": "integer"}}`, `[1, "2"]`, false},
+		{"min items", `{"minItems": 2}`, `[1]`, false},
+		{"max items", `{"maxItems": 1}`, `[1, 2]`, false},
+		{"min length", `{"minLength": 2}`, `"é"`, false},
+		{"max length", `{"maxLength": 2}`, `"éé"`, true},
+		{"pattern", `{"pattern": "^[a-z]+$"}`, `"foo"`, true},
+		{"pattern mismatch", `{"pattern": "^[a-z]+$"}`, `"Foo"`, false},
+		{"invalid pattern", `{"pattern": "("}`, `"foo"`, false},
+		{"minimum", `{"minimum": 1}`, `0.5`, false},
+		{"maximum", `{"maximum": 1}`, `1`, true},
+		{"nested", `{"properties": {"a": {"properties": {"b": {"type": "boolean"}}}}}`, `{"a": {"b": "true"}}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var schema map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.schema), &schema); err != nil {
+				t.Fatalf("failed decoding schema: %v", err)
+			}
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.value), &value); err != nil {
+				t.Fatalf("failed decoding value: %v", err)
+			}
+			m := NewSchemaMatcher(schema)
+			if got := m.Matches(value); got != tt.want {
+				t.Errorf("Matches() = %v, want %v, validation error: %v", got, tt.want, m.Validate(value))
+			}
+		})
+	}
+}
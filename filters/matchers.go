@@ -10,6 +10,27 @@ type Matcher interface {
 	Matches(x interface{}) bool
 }
 
+// ExplainMatcher is implemented by the matchers able to explain their result,
+// for debugging purposes.
+type ExplainMatcher interface {
+	Matcher
+	// Explain returns a human-readable reason for the match result on x, like
+	// the pattern and the value which matched.
+	Explain(x interface{}) string
+}
+
+// Explain returns the explanation of the matcher result on x, if it is an
+// ExplainMatcher, or just the result otherwise.
+func Explain(m Matcher, x interface{}) string {
+	if em, ok := m.(ExplainMatcher); ok {
+		return em.Explain(x)
+	}
+	if m.Matches(x) {
+		return `matched`
+	}
+	return `did not match`
+}
+
 // Like reflect.Value.IsNil, but return false instead of panicking on non-nil-able
 // values.
 func isNilValue(value reflect.Value) bool {
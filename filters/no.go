@@ -0,0 +1,40 @@
+package filters
+
+import (
+	"fmt"
+
+	"github.com/bearer/go-agent/events"
+)
+
+// NoFilter provides a filter rejecting any input, even nil. It is the
+// counterpart of YesFilter, e.g. to build explicit exclusion rules without
+// wrapping a YesFilter in a NotFilter.
+type NoFilter struct{}
+
+// Type is part of the Filter interface.
+func (*NoFilter) Type() FilterType {
+	return NoInternalFilter
+}
+
+// MatchesCall is part of the Filter interface.
+func (*NoFilter) MatchesCall(events.Event) bool {
+	return false
+}
+
+// SetMatcher is part of the Filter interface. In NoFilter, is only accepts
+// a nil matcher, as no underlying matcher is actually used.
+func (*NoFilter) SetMatcher(matcher Matcher) error {
+	if matcher != nil {
+		return fmt.Errorf("instances of NoFilter only accept a nil Matcher, got %T", matcher)
+	}
+	return nil
+}
+
+// Describe is part of the Filter interface.
+func (f *NoFilter) Describe() FilterDescription {
+	return FilterDescription{TypeName: f.Type().Name()}
+}
+
+func noFilterFromDescription(FilterMap, *FilterDescription) Filter {
+	return &NoFilter{}
+}
@@ -0,0 +1,86 @@
+package filters
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+)
+
+func TestNoFilter_MatchesCall(t *testing.T) {
+	type args struct {
+		in0 *http.Request
+		in1 *http.Response
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{"both nil", args{nil, nil}},
+		{"only request", args{&http.Request{}, nil}},
+		{"only response", args{nil, &http.Response{}}},
+		{"both", args{&http.Request{}, &http.Response{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nf := &NoFilter{}
+			e := (&events.EventBase{}).SetRequest(tt.args.in0).SetResponse(tt.args.in1)
+			if gotFalse := nf.MatchesCall(e); gotFalse {
+				t.Errorf("MatchesCall() = %v, want false", gotFalse)
+			}
+		})
+	}
+}
+
+func TestNoFilter_SetMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher Matcher
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"non nil", &yesMatcher{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &NoFilter{}
+			if err := f.SetMatcher(tt.matcher); (err != nil) != tt.wantErr {
+				t.Errorf("SetMatcher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNoFilter_Type(t *testing.T) {
+	expected := NoInternalFilter.String()
+	var f NoFilter
+	actual := f.Type().String()
+	if actual != expected {
+		t.Errorf("Type() = %v, want %v", actual, expected)
+	}
+}
+
+func TestNoFilter_FilterSet(t *testing.T) {
+	domain := &DomainFilter{RegexpMatcher: NewRegexpMatcher(BearerRE)}
+	tests := []struct {
+		name string
+		set  Filter
+		want func(e events.Event) bool
+	}{
+		{"any no and domain is domain", NewFilterSet(Any, &NoFilter{}, domain), domain.MatchesCall},
+		{"all no and domain never matches", NewFilterSet(All, &NoFilter{}, domain), func(events.Event) bool { return false }},
+		{"not no always matches", (&NotFilter{}).AddChildren(&NoFilter{}), func(events.Event) bool { return true }},
+	}
+	for _, tt := range tests {
+		for _, domainName := range []string{BearerDomain, `example.com`} {
+			t.Run(tt.name+" "+domainName, func(t *testing.T) {
+				u, _ := url.Parse(`https://` + domainName)
+				e := (&events.EventBase{}).SetRequest(&http.Request{URL: u})
+				if got, want := tt.set.MatchesCall(e), tt.want(e); got != want {
+					t.Errorf("MatchesCall() = %v, want %v", got, want)
+				}
+			})
+		}
+	}
+}
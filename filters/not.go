@@ -49,6 +49,13 @@ func (f *NotFilter) SetMatcher(matcher Matcher) error {
 	return nil
 }
 
+// Describe is part of the Filter interface. As the child hash is not known to
+// the filter, it is not included: use FilterMap.Describe for it.
+func (f *NotFilter) Describe() FilterDescription {
+	f.ensureFilter()
+	return f.filterSet.Describe()
+}
+
 // AddChildren overrides the embedded filterSet method to have one child at most.
 func (f *NotFilter) AddChildren(filters ...Filter) FilterSet {
 	f.ensureFilter()
@@ -66,6 +73,20 @@ func (f *NotFilter) AddChildren(filters ...Filter) FilterSet {
 	return f
 }
 
+// Not builds a NotFilter inverting the passed Filter.
+//
+// Since negating nothing should exclude nothing, Not(nil) returns a NotFilter
+// inverting a NoFilter, which matches everything. This differs from
+// NotFilter.SetFilter, which treats a nil filter as a Yes filter.
+func Not(filter Filter) Filter {
+	if isNilInterface(filter) {
+		filter = &NoFilter{}
+	}
+	f := &NotFilter{}
+	_ = f.SetFilter(filter)
+	return f
+}
+
 func notFilterFromDescription(filterMap FilterMap, fd *FilterDescription) Filter {
 	child, ok := filterMap[fd.ChildHash]
 	if !ok {
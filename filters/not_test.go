@@ -2,6 +2,7 @@ package filters
 
 import (
 	"net/http"
+	"regexp"
 	"testing"
 
 	"github.com/bearer/go-agent/events"
@@ -127,3 +128,35 @@ func TestNotFilter_Type(t *testing.T) {
 		t.Errorf("Type() = %v, want %v", actual, expected)
 	}
 }
+
+func TestNot(t *testing.T) {
+	exampleReq, _ := http.NewRequest(http.MethodGet, `https://api.example.com/path`, nil)
+	otherReq, _ := http.NewRequest(http.MethodGet, `https://other.example.org/path`, nil)
+	domain := &DomainFilter{}
+	_ = domain.SetMatcher(NewRegexpMatcher(regexp.MustCompile(`(?i)\.example\.com$`)))
+
+	tests := []struct {
+		name   string
+		filter Filter
+		req    *http.Request
+		want   bool
+	}{
+		{"inverted domain match", domain, exampleReq, false},
+		{"inverted domain mismatch", domain, otherReq, true},
+		{"inverted nil matches everything", nil, exampleReq, true},
+		{"inverted nil matches everything else", nil, otherReq, true},
+		{"inverted typed nil matches everything", (*DomainFilter)(nil), exampleReq, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Not(tt.filter)
+			if f.Type().Name() != NotFilterType.Name() {
+				t.Fatalf("Not() type = %v, want %v", f.Type(), NotFilterType)
+			}
+			e := (&events.EventBase{}).SetRequest(tt.req)
+			if got := f.MatchesCall(e); got != tt.want {
+				t.Errorf("MatchesCall() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
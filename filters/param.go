@@ -51,6 +51,14 @@ func (f *ParamFilter) SetMatcher(matcher Matcher) error {
 	return nil
 }
 
+// Describe is part of the Filter interface.
+func (f *ParamFilter) Describe() FilterDescription {
+	return FilterDescription{
+		TypeName:            f.Type().Name(),
+		KeyValueDescription: keyValueToDescription(f.KeyValueMatcher),
+	}
+}
+
 func paramFilterFromDescription(filterMap FilterMap, fd *FilterDescription) Filter {
 	m := NewKeyValueMatcher(fd.KeyPatternRegexp(), fd.ValuePatternRegexp())
 	if m == nil {
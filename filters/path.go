@@ -30,6 +30,12 @@ func (f *PathFilter) MatchesCall(e events.Event) bool {
 	return f.RegexpMatcher.Matches(criterium)
 }
 
+// ExplainCall is part of the CallExplainer interface.
+func (f *PathFilter) ExplainCall(e events.Event) string {
+	f.ensureMatcher()
+	return Explain(f.RegexpMatcher, e.Request().URL.Path)
+}
+
 // SetMatcher sets the filter RegexpMatcher.
 //
 // If the returned error is not nil, the filter Matcher cannot be used.
@@ -54,6 +60,15 @@ func (f *PathFilter) SetMatcher(matcher Matcher) error {
 	return nil
 }
 
+// Describe is part of the Filter interface.
+func (f *PathFilter) Describe() FilterDescription {
+	f.ensureMatcher()
+	return FilterDescription{
+		TypeName: f.Type().Name(),
+		Pattern:  regexpToDescription(f.Regexp()),
+	}
+}
+
 func pathFilterFromDescription(filterMap FilterMap, fd *FilterDescription) Filter {
 	// FIXME apply RegexpMatcherDescription.Flags
 	m := NewRegexpMatcher(fd.PatternRegexp())
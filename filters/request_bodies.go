@@ -0,0 +1,104 @@
+package filters
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bearer/go-agent/events"
+)
+
+// RequestBodiesFilter provides a filter for the parsed API request bodies, e.g.
+// to match the JSON requests containing "event":"checkout".
+//
+// It matches on the body parsed at the bodies stage, not on the raw stream, so
+// calls before the bodies stage, or with an unparsed body, never match.
+type RequestBodiesFilter struct {
+	KeyValueMatcher
+}
+
+// Type is part of the Filter interface.
+func (f *RequestBodiesFilter) Type() FilterType {
+	return RequestBodiesFilterType
+}
+
+func (f *RequestBodiesFilter) ensureMatcher() {
+	if !isNilInterface(f.KeyValueMatcher) {
+		return
+	}
+	_ = f.SetMatcher(NewKeyValueMatcher(nil, nil))
+}
+
+// body returns the parsed request body of the event, if it carries one.
+func (*RequestBodiesFilter) body(e events.Event) (interface{}, bool) {
+	be, ok := e.(ParsedBodiesEvent)
+	if !ok {
+		return nil, false
+	}
+	body, _ := be.ParsedBodies()
+	return body, body != nil
+}
+
+// MatchesCall is part of the Filter interface.
+func (f *RequestBodiesFilter) MatchesCall(e events.Event) bool {
+	body, ok := f.body(e)
+	if !ok {
+		return false
+	}
+	f.ensureMatcher()
+	return f.KeyValueMatcher.Matches(body)
+}
+
+// ExplainCall is part of the CallExplainer interface.
+func (f *RequestBodiesFilter) ExplainCall(e events.Event) string {
+	body, ok := f.body(e)
+	if !ok {
+		return `no parsed request body`
+	}
+	f.ensureMatcher()
+	return Explain(f.KeyValueMatcher, body)
+}
+
+// SetMatcher sets the filter KeyValueMatcher.
+//
+// If the returned error is not nil, the filter will accept any value except nil.
+//
+// To apply a case-insensitive match, prepend (?i) to the matcher regexps,
+// as in: (?i)^checkout$
+func (f *RequestBodiesFilter) SetMatcher(matcher Matcher) error {
+	defaultMatcher := NewKeyValueMatcher(nil, nil)
+
+	m, ok := matcher.(KeyValueMatcher)
+	if !ok {
+		f.KeyValueMatcher = defaultMatcher
+		return fmt.Errorf("key-value matcher expected, got a %T", matcher)
+	}
+
+	if isNilInterface(m) {
+		f.KeyValueMatcher = defaultMatcher
+		return errors.New("set nil Key-Value matcher on RequestBodies filter")
+	}
+
+	f.KeyValueMatcher = m
+	return nil
+}
+
+// Describe is part of the Filter interface.
+func (f *RequestBodiesFilter) Describe() FilterDescription {
+	return FilterDescription{
+		TypeName:            f.Type().Name(),
+		KeyValueDescription: keyValueToDescription(f.KeyValueMatcher),
+	}
+}
+
+func requestBodiesFilterFromDescription(_ FilterMap, fd *FilterDescription) Filter {
+	m := NewKeyValueMatcher(fd.KeyPatternRegexp(), fd.ValuePatternRegexp())
+	if m == nil {
+		return nil
+	}
+	f := &RequestBodiesFilter{}
+	err := f.SetMatcher(m)
+	if err != nil {
+		return nil
+	}
+	return f
+}
@@ -0,0 +1,87 @@
+package filters
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+)
+
+func TestRequestBodiesFilter_MatchesCall(t *testing.T) {
+	reEvent := regexp.MustCompile(`^event$`)
+	reCheckout := regexp.MustCompile(`^checkout$`)
+	jsonBody := func(s string) interface{} {
+		var body interface{}
+		if err := json.Unmarshal([]byte(s), &body); err != nil {
+			t.Fatalf("invalid JSON %s: %v", s, err)
+		}
+		return body
+	}
+	tests := []struct {
+		name                   string
+		keyRegexp, valueRegexp *regexp.Regexp
+		body                   interface{}
+		want                   bool
+	}{
+		{"happy flat", reEvent, reCheckout, jsonBody(`{"event":"checkout"}`), true},
+		{"happy nested", reEvent, reCheckout, jsonBody(`{"data":[{"event":"checkout"}]}`), true},
+		{"happy no key", nil, reCheckout, jsonBody(`{"type":"checkout"}`), true},
+		{"happy form", reEvent, reCheckout, map[string][]string{`event`: {`checkout`}}, true},
+		{"happy no filter", nil, nil, jsonBody(`{}`), true},
+		{"sad no matching value", reEvent, reCheckout, jsonBody(`{"event":"cart"}`), false},
+		{"sad no matching key", reEvent, reCheckout, jsonBody(`{"type":"checkout"}`), false},
+		{"sad no body", nil, nil, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &RequestBodiesFilter{}
+			_ = f.SetMatcher(NewKeyValueMatcher(tt.keyRegexp, tt.valueRegexp))
+			e := &parsedBodiesEvent{request: tt.body, response: jsonBody(`{"event":"checkout"}`)}
+			if got := f.MatchesCall(e); got != tt.want {
+				t.Errorf("MatchesCall() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestBodiesFilter_MatchesCallBeforeBodies(t *testing.T) {
+	f := &RequestBodiesFilter{}
+	if f.MatchesCall(&events.EventBase{}) {
+		t.Error("MatchesCall() = true on an event without parsed bodies, want false")
+	}
+	if actual, expected := f.ExplainCall(&events.EventBase{}), `no parsed request body`; actual != expected {
+		t.Errorf("ExplainCall() = %s, want %s", actual, expected)
+	}
+}
+
+func TestRequestBodiesFilter_SetMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher Matcher
+		wantErr bool
+	}{
+		{"happy", NewKeyValueMatcher(nil, nil), false},
+		{"sad nil", (*keyValueMatcher)(nil), true},
+		{"sad matcher", &yesMatcher{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &RequestBodiesFilter{}
+			if err := f.SetMatcher(tt.matcher); (err != nil) != tt.wantErr {
+				t.Errorf("SetMatcher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequestBodiesFilter_Type(t *testing.T) {
+	var f RequestBodiesFilter
+	ft := f.Type()
+	if actual, expected := ft.String(), RequestBodiesFilterType.String(); actual != expected {
+		t.Errorf("Type() = %v, want %v", actual, expected)
+	}
+	if !ft.WantsRequest() || ft.WantsResponse() {
+		t.Errorf("WantsRequest(), WantsResponse() = %t, %t, want true, false", ft.WantsRequest(), ft.WantsResponse())
+	}
+}
@@ -0,0 +1,86 @@
+package filters
+
+import (
+	"fmt"
+
+	"github.com/bearer/go-agent/events"
+)
+
+// contentTypeHeader is the canonical name of the Content-Type header.
+const contentTypeHeader = `Content-Type`
+
+// RequestContentTypeFilter provides a filter for the Content-Type header of
+// API requests, e.g. to match JSON requests with (?i)^application/json\b.
+// Requests without a Content-Type header are matched as an empty string.
+type RequestContentTypeFilter struct {
+	RegexpMatcher
+}
+
+// Type is part of the Filter interface.
+func (*RequestContentTypeFilter) Type() FilterType {
+	return RequestContentTypeFilterType
+}
+
+func (f *RequestContentTypeFilter) ensureMatcher() {
+	if f.RegexpMatcher != nil {
+		return
+	}
+	_ = f.SetMatcher(NewEmptyRegexpMatcher())
+}
+
+// contentType returns the Content-Type of the event request, if any.
+func (*RequestContentTypeFilter) contentType(e events.Event) string {
+	request := e.Request()
+	if request == nil {
+		return ``
+	}
+	return request.Header.Get(contentTypeHeader)
+}
+
+// MatchesCall is part of the Filter interface.
+func (f *RequestContentTypeFilter) MatchesCall(e events.Event) bool {
+	f.ensureMatcher()
+	return f.RegexpMatcher.Matches(f.contentType(e))
+}
+
+// ExplainCall is part of the CallExplainer interface.
+func (f *RequestContentTypeFilter) ExplainCall(e events.Event) string {
+	f.ensureMatcher()
+	return Explain(f.RegexpMatcher, f.contentType(e))
+}
+
+// SetMatcher sets the filter RegexpMatcher.
+//
+// If the returned error is not nil, the filter Regex will accept any value.
+//
+// Since media types are case-insensitive, the regex should usually be too, as
+// in: (?i)^application/json\b
+func (f *RequestContentTypeFilter) SetMatcher(matcher Matcher) error {
+	if matcher == nil {
+		matcher = NewEmptyRegexpMatcher()
+	}
+	rm, ok := matcher.(RegexpMatcher)
+	if !ok {
+		f.ensureMatcher()
+		return fmt.Errorf("the RequestContentTypeFilter only accepts RegexMatchers: got %T", matcher)
+	}
+	f.RegexpMatcher = rm
+	return nil
+}
+
+// Describe is part of the Filter interface.
+func (f *RequestContentTypeFilter) Describe() FilterDescription {
+	f.ensureMatcher()
+	return FilterDescription{
+		TypeName: f.Type().Name(),
+		Pattern:  regexpToDescription(f.Regexp()),
+	}
+}
+
+func requestContentTypeFilterFromDescription(_ FilterMap, fd *FilterDescription) Filter {
+	f := &RequestContentTypeFilter{}
+	// If the pattern is invalid, the matcher will be nil, and SetMatcher will
+	// apply the EmptyRegexpMatcher and not fail.
+	_ = f.SetMatcher(NewRegexpMatcher(fd.PatternRegexp()))
+	return f
+}
@@ -0,0 +1,74 @@
+package filters
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+)
+
+var jsonRE = regexp.MustCompile(`(?i)^application/json\b`)
+
+func TestRequestContentTypeFilter_MatchesCall(t *testing.T) {
+	tests := []struct {
+		name        string
+		matcher     RegexpMatcher
+		contentType string
+		want        bool
+	}{
+		{"empty", NewEmptyRegexpMatcher(), ``, true},
+		{"empty vs non-empty", NewEmptyRegexpMatcher(), `application/json`, true},
+		{"no header", NewRegexpMatcher(jsonRE), ``, false},
+		{"happy", NewRegexpMatcher(jsonRE), `application/json`, true},
+		{"happy with parameters", NewRegexpMatcher(jsonRE), `Application/JSON; charset=utf-8`, true},
+		{"sad", NewRegexpMatcher(jsonRE), `application/x-www-form-urlencoded`, false},
+		{"sad suffix", NewRegexpMatcher(jsonRE), `application/jsonp`, false},
+		// No regexp matches everything
+		{"no regexp", NewRegexpMatcher(nil), `text/plain`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &RequestContentTypeFilter{
+				RegexpMatcher: tt.matcher,
+			}
+			request := &http.Request{Header: http.Header{}}
+			if tt.contentType != `` {
+				request.Header.Set(contentTypeHeader, tt.contentType)
+			}
+			e := (&events.EventBase{}).SetRequest(request)
+			if got := f.MatchesCall(e); got != tt.want {
+				t.Errorf("MatchesCall() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestContentTypeFilter_SetMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher Matcher
+		wantErr bool
+	}{
+		{"happy", NewEmptyRegexpMatcher(), false},
+		{"nil", nil, false},
+		{"sad matcher", &yesMatcher{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &RequestContentTypeFilter{}
+			if err := f.SetMatcher(tt.matcher); (err != nil) != tt.wantErr {
+				t.Errorf("SetMatcher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequestContentTypeFilter_Type(t *testing.T) {
+	expected := RequestContentTypeFilterType.String()
+	var f RequestContentTypeFilter
+	actual := f.Type().String()
+	if actual != expected {
+		t.Errorf("Type() = %v, want %v", actual, expected)
+	}
+}
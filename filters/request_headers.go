@@ -30,6 +30,12 @@ func (f *RequestHeadersFilter) MatchesCall(e events.Event) bool {
 	return f.KeyValueMatcher.Matches(e.Request().Header)
 }
 
+// ExplainCall is part of the CallExplainer interface.
+func (f *RequestHeadersFilter) ExplainCall(e events.Event) string {
+	f.ensureMatcher()
+	return Explain(f.KeyValueMatcher, e.Request().Header)
+}
+
 // SetMatcher sets the filter KeyValueMatcher.
 //
 // If the returned error is not nil, the filter will accept any value except nil.
@@ -54,6 +60,14 @@ func (f *RequestHeadersFilter) SetMatcher(matcher Matcher) error {
 	return nil
 }
 
+// Describe is part of the Filter interface.
+func (f *RequestHeadersFilter) Describe() FilterDescription {
+	return FilterDescription{
+		TypeName:            f.Type().Name(),
+		KeyValueDescription: keyValueToDescription(f.KeyValueMatcher),
+	}
+}
+
 func requestFilterHeadersFromDescription(filterMap FilterMap, fd *FilterDescription) Filter {
 	// FIXME apply RegexpMatcherDescription.Flags
 	m := NewKeyValueMatcher(fd.KeyPatternRegexp(), fd.ValuePatternRegexp())
@@ -69,3 +69,16 @@ func TestRequestHeadersFilter_Type(t *testing.T) {
 		t.Errorf("Type() = %v, want %v", actual, expected)
 	}
 }
+
+func TestRequestHeadersFilter_ExplainCall(t *testing.T) {
+	f := &RequestHeadersFilter{}
+	_ = f.SetMatcher(NewKeyValueMatcher(reFoo, reBar))
+	e := (&events.EventBase{}).SetRequest(&http.Request{Header: http.Header{foo: {bar}}})
+	expected := `key "foo" matched /foo/, element 0: value "bar" matched /bar/`
+	if actual := ExplainCall(f, e); actual != expected {
+		t.Errorf("ExplainCall() = %q, expected %q", actual, expected)
+	}
+	if actual := ExplainCall(&YesFilter{}, e); actual != `YesFilter matched` {
+		t.Errorf("ExplainCall() on YesFilter = %q", actual)
+	}
+}
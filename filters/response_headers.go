@@ -33,6 +33,15 @@ func (f *ResponseHeadersFilter) MatchesCall(e events.Event) bool {
 	return f.KeyValueMatcher.Matches(e.Response().Header)
 }
 
+// ExplainCall is part of the CallExplainer interface.
+func (f *ResponseHeadersFilter) ExplainCall(e events.Event) string {
+	if e.Response() == nil {
+		return `no response`
+	}
+	f.ensureMatcher()
+	return Explain(f.KeyValueMatcher, e.Response().Header)
+}
+
 // SetMatcher sets the filter KeyValueMatcher.
 //
 // If the returned error is not nil, the filter will accept any value except nil.
@@ -57,6 +66,14 @@ func (f *ResponseHeadersFilter) SetMatcher(matcher Matcher) error {
 	return nil
 }
 
+// Describe is part of the Filter interface.
+func (f *ResponseHeadersFilter) Describe() FilterDescription {
+	return FilterDescription{
+		TypeName:            f.Type().Name(),
+		KeyValueDescription: keyValueToDescription(f.KeyValueMatcher),
+	}
+}
+
 func responseHeadersFilterFromDescription(filterMap FilterMap, fd *FilterDescription) Filter {
 	// FIXME apply RegexpMatcherDescription.Flags
 	m := NewKeyValueMatcher(fd.KeyPatternRegexp(), fd.ValuePatternRegexp())
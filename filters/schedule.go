@@ -0,0 +1,191 @@
+package filters
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bearer/go-agent/events"
+)
+
+// ScheduleWindowDescription describes a ScheduleWindow.
+type ScheduleWindowDescription struct {
+	// Days are English week day names, like "Monday". Empty means every day.
+	Days []string
+	// From and To are "15:04" times of day. The window includes From but not To.
+	From, To string
+}
+
+// ScheduleDescription carries the fields set on filters.ScheduleFilter.
+type ScheduleDescription struct {
+	// Timezone is an IANA time zone name, like "Europe/Paris". Empty means UTC.
+	Timezone string
+	Windows  []ScheduleWindowDescription
+}
+
+func (d ScheduleDescription) String() string {
+	b := strings.Builder{}
+	for _, w := range d.Windows {
+		b.WriteString(fmt.Sprintf("Schedule: %v %s-%s %s\n", w.Days, w.From, w.To, d.Timezone))
+	}
+	return b.String()
+}
+
+// ScheduleWindow is a time window recurring on some days of the week.
+type ScheduleWindow struct {
+	// Days are the days of the week on which the window starts. Empty means every day.
+	Days []time.Weekday
+	// From and To are offsets since midnight. The window includes From but not
+	// To. If To is before From, the window ends on the next day.
+	From, To time.Duration
+}
+
+// startsOn checks whether the window starts on a given day of the week.
+func (w ScheduleWindow) startsOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// contains checks whether a time, in the window time zone, is in the window.
+func (w ScheduleWindow) contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+	if w.From <= w.To {
+		return w.startsOn(t.Weekday()) && offset >= w.From && offset < w.To
+	}
+	// The window spans midnight.
+	yesterday := (t.Weekday() + 6) % 7
+	return (w.startsOn(t.Weekday()) && offset >= w.From) || (w.startsOn(yesterday) && offset < w.To)
+}
+
+// ScheduleFilter matches API calls made within time windows, e.g. to apply
+// rules during business hours only. It does not depend on the call, so it is
+// usually evaluated at the connect stage.
+type ScheduleFilter struct {
+	// Location is the time zone of the Windows. Nil means UTC.
+	Location *time.Location
+	Windows  []ScheduleWindow
+	// Now returns the current time. Nil means time.Now.
+	Now func() time.Time
+}
+
+// Type is part of the Filter interface.
+func (*ScheduleFilter) Type() FilterType {
+	return ScheduleFilterType
+}
+
+// MatchesCall is part of the Filter interface.
+func (f *ScheduleFilter) MatchesCall(events.Event) bool {
+	now := time.Now
+	if f.Now != nil {
+		now = f.Now
+	}
+	location := time.UTC
+	if f.Location != nil {
+		location = f.Location
+	}
+	t := now().In(location)
+	for _, w := range f.Windows {
+		if w.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMatcher is part of the Filter interface. In ScheduleFilter, is only
+// accepts a nil matcher, as no underlying matcher is actually used.
+func (*ScheduleFilter) SetMatcher(matcher Matcher) error {
+	if matcher != nil {
+		return fmt.Errorf("instances of ScheduleFilter only accept a nil Matcher, got %T", matcher)
+	}
+	return nil
+}
+
+// formatTimeOfDay formats an offset since midnight as a "15:04" time of day.
+func formatTimeOfDay(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+}
+
+// parseTimeOfDay parses a "15:04" time of day as an offset since midnight.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse(`15:04`, s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// parseWeekday parses an English week day name, case-insensitively.
+func parseWeekday(s string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(s, d.String()) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid week day %q", s)
+}
+
+// Describe is part of the Filter interface.
+func (f *ScheduleFilter) Describe() FilterDescription {
+	d := ScheduleDescription{}
+	if f.Location != nil && f.Location != time.UTC {
+		d.Timezone = f.Location.String()
+	}
+	for _, w := range f.Windows {
+		wd := ScheduleWindowDescription{From: formatTimeOfDay(w.From), To: formatTimeOfDay(w.To)}
+		for _, day := range w.Days {
+			wd.Days = append(wd.Days, day.String())
+		}
+		d.Windows = append(d.Windows, wd)
+	}
+	return FilterDescription{
+		TypeName: f.Type().Name(),
+		Schedule: d,
+	}
+}
+
+// scheduleWindowFromDescription builds a ScheduleWindow, failing on invalid
+// week days or times of day.
+func scheduleWindowFromDescription(wd ScheduleWindowDescription) (ScheduleWindow, error) {
+	var w ScheduleWindow
+	var err error
+	for _, day := range wd.Days {
+		d, err := parseWeekday(day)
+		if err != nil {
+			return w, err
+		}
+		w.Days = append(w.Days, d)
+	}
+	if w.From, err = parseTimeOfDay(wd.From); err != nil {
+		return w, err
+	}
+	if w.To, err = parseTimeOfDay(wd.To); err != nil {
+		return w, err
+	}
+	return w, nil
+}
+
+func scheduleFilterFromDescription(_ FilterMap, fd *FilterDescription) Filter {
+	f := &ScheduleFilter{Location: time.UTC}
+	// If the time zone is invalid, the filter never matches, rather than
+	// applying the windows at unexpected times. Invalid windows are ignored.
+	location, err := time.LoadLocation(fd.Schedule.Timezone)
+	if err != nil {
+		return f
+	}
+	f.Location = location
+	for _, wd := range fd.Schedule.Windows {
+		if w, err := scheduleWindowFromDescription(wd); err == nil {
+			f.Windows = append(f.Windows, w)
+		}
+	}
+	return f
+}
@@ -0,0 +1,127 @@
+package filters
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bearer/go-agent/events"
+)
+
+func TestScheduleFilter_Type(t *testing.T) {
+	expected := ScheduleFilterType.String()
+	var f ScheduleFilter
+	actual := f.Type().String()
+	if actual != expected {
+		t.Errorf("Type() = %v, want %v", actual, expected)
+	}
+}
+
+func TestScheduleFilter_SetMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher Matcher
+		wantErr bool
+	}{
+		{`happy`, nil, false},
+		{`sad`, &yesMatcher{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &ScheduleFilter{}
+			if err := f.SetMatcher(tt.matcher); (err != nil) != tt.wantErr {
+				t.Errorf("SetMatcher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_scheduleFilterFromDescription(t *testing.T) {
+	paris, err := time.LoadLocation(`Europe/Paris`)
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	tests := []struct {
+		name     string
+		schedule ScheduleDescription
+		expected *ScheduleFilter
+	}{
+		{`empty`, ScheduleDescription{}, &ScheduleFilter{Location: time.UTC}},
+		{`happy`, ScheduleDescription{
+			Timezone: `Europe/Paris`,
+			Windows: []ScheduleWindowDescription{
+				{Days: []string{`monday`, `Friday`}, From: `09:00`, To: `17:30`},
+				{From: `22:00`, To: `06:00`},
+			},
+		}, &ScheduleFilter{Location: paris, Windows: []ScheduleWindow{
+			{Days: []time.Weekday{time.Monday, time.Friday}, From: 9 * time.Hour, To: 17*time.Hour + 30*time.Minute},
+			{From: 22 * time.Hour, To: 6 * time.Hour},
+		}}},
+		{`invalid windows ignored`, ScheduleDescription{
+			Windows: []ScheduleWindowDescription{
+				{Days: []string{`Caturday`}, From: `09:00`, To: `17:00`},
+				{From: `9am`, To: `17:00`},
+				{From: `09:00`, To: `25:00`},
+				{From: `09:00`, To: `17:00`},
+			},
+		}, &ScheduleFilter{Location: time.UTC, Windows: []ScheduleWindow{{From: 9 * time.Hour, To: 17 * time.Hour}}}},
+		{`invalid time zone`, ScheduleDescription{
+			Timezone: `Mars/Olympus_Mons`,
+			Windows:  []ScheduleWindowDescription{{From: `09:00`, To: `17:00`}},
+		}, &ScheduleFilter{Location: time.UTC}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := scheduleFilterFromDescription(nil, &FilterDescription{Schedule: tt.schedule})
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("scheduleFilterFromDescription() = %#v, want %#v", actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestScheduleFilter_MatchesCall(t *testing.T) {
+	business := ScheduleWindow{
+		Days: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		From: 9 * time.Hour,
+		To:   17 * time.Hour,
+	}
+	night := ScheduleWindow{Days: []time.Weekday{time.Saturday}, From: 22 * time.Hour, To: 6 * time.Hour}
+	tokyo := time.FixedZone(`JST`, 9*60*60)
+
+	tests := []struct {
+		name     string
+		location *time.Location
+		windows  []ScheduleWindow
+		now      time.Time
+		want     bool
+	}{
+		{`no window`, nil, nil, time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC), false},
+		{`inside`, nil, []ScheduleWindow{business}, time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC), true},
+		{`at start`, nil, []ScheduleWindow{business}, time.Date(2020, 6, 1, 9, 0, 0, 0, time.UTC), true},
+		{`at end`, nil, []ScheduleWindow{business}, time.Date(2020, 6, 1, 17, 0, 0, 0, time.UTC), false},
+		{`before hours`, nil, []ScheduleWindow{business}, time.Date(2020, 6, 1, 8, 59, 59, 0, time.UTC), false},
+		{`wrong day`, nil, []ScheduleWindow{business}, time.Date(2020, 6, 6, 10, 0, 0, 0, time.UTC), false},
+		{`other time zone inside`, tokyo, []ScheduleWindow{business}, time.Date(2020, 6, 1, 1, 0, 0, 0, time.UTC), true},
+		{`other time zone outside`, tokyo, []ScheduleWindow{business}, time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC), false},
+		{`overnight start day`, nil, []ScheduleWindow{night}, time.Date(2020, 6, 6, 23, 0, 0, 0, time.UTC), true},
+		{`overnight next day`, nil, []ScheduleWindow{night}, time.Date(2020, 6, 7, 5, 0, 0, 0, time.UTC), true},
+		{`overnight next day after end`, nil, []ScheduleWindow{night}, time.Date(2020, 6, 7, 6, 0, 0, 0, time.UTC), false},
+		{`overnight other day`, nil, []ScheduleWindow{night}, time.Date(2020, 6, 6, 5, 0, 0, 0, time.UTC), false},
+		{`any window`, nil, []ScheduleWindow{business, night}, time.Date(2020, 6, 6, 23, 0, 0, 0, time.UTC), true},
+		{`every day`, nil, []ScheduleWindow{{From: 12 * time.Hour, To: 13 * time.Hour}}, time.Date(2020, 6, 7, 12, 30, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := tt.now
+			f := &ScheduleFilter{
+				Location: tt.location,
+				Windows:  tt.windows,
+				Now:      func() time.Time { return now },
+			}
+			if got := f.MatchesCall(&events.EventBase{}); got != tt.want {
+				t.Errorf("MatchesCall() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
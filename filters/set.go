@@ -92,6 +92,18 @@ func (f *filterSet) SetMatcher(matcher Matcher) error {
 	return nil
 }
 
+// Describe is part of the Filter interface. As the children hashes are not
+// known to the filter, they are not included: use FilterMap.Describe for them.
+func (f *filterSet) Describe() FilterDescription {
+	if f.operator == NotFirst {
+		return FilterDescription{TypeName: NotFilterType.Name()}
+	}
+	return FilterDescription{
+		TypeName:             f.Type().Name(),
+		FilterSetDescription: FilterSetDescription{Operator: strings.ToUpper(f.operator.String())},
+	}
+}
+
 func (f *filterSet) AddChildren(filters ...Filter) FilterSet {
 	for _, filter := range filters {
 		if !isNilInterface(filter) {
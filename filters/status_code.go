@@ -48,23 +48,18 @@ func (f *StatusCodeFilter) SetMatcher(matcher Matcher) error {
 	return nil
 }
 
-func statusCodeFilterFromDescription(filterMap FilterMap, fd *FilterDescription) Filter {
-	r := fd.Range
-	m := NewRangeMatcher()
-	if r.From != nil {
-		m.From(r.ToInt(r.From))
-	}
-	if r.To != nil {
-		m.To(r.ToInt(r.To))
-	}
-	if r.ExcludeFrom {
-		m.ExcludeFrom()
-	}
-	if r.ExcludeTo {
-		m.ExcludeTo()
+// Describe is part of the Filter interface.
+func (f *StatusCodeFilter) Describe() FilterDescription {
+	f.ensureMatcher()
+	return FilterDescription{
+		TypeName: f.Type().Name(),
+		Range:    rangeToDescription(f.RangeMatcher),
 	}
+}
+
+func statusCodeFilterFromDescription(filterMap FilterMap, fd *FilterDescription) Filter {
 	f := &StatusCodeFilter{}
-	err := f.SetMatcher(m)
+	err := f.SetMatcher(rangeFromDescription(fd.Range))
 	if err != nil {
 		return nil
 	}
@@ -0,0 +1,78 @@
+package filters
+
+import (
+	"fmt"
+
+	"github.com/bearer/go-agent/events"
+)
+
+// URLFilter provides a filter for the full URL in API requests, made of the
+// scheme, host, and path, excluding the query and fragment, as in:
+// https://api.example.com/v1/payments
+//
+// It allows targeting calls with a single filter instead of a FilterSet
+// combining a DomainFilter and a PathFilter.
+type URLFilter struct {
+	RegexpMatcher
+}
+
+// Type is part of the Filter interface.
+func (*URLFilter) Type() FilterType {
+	return URLFilterType
+}
+
+func (f *URLFilter) ensureMatcher() {
+	if f.RegexpMatcher != nil {
+		return
+	}
+	_ = f.SetMatcher(NewEmptyRegexpMatcher())
+}
+
+// MatchesCall is part of the Filter interface.
+func (f *URLFilter) MatchesCall(e events.Event) bool {
+	f.ensureMatcher()
+	u := e.Request().URL
+	if u == nil {
+		return false
+	}
+	criterium := u.Scheme + `://` + u.Host + u.Path
+	return f.RegexpMatcher.Matches(criterium)
+}
+
+// SetMatcher sets the filter RegexpMatcher.
+//
+// If the returned error is not nil, the filter Regex will accept any value.
+//
+// Scheme and host names are case-insensitive, so to apply a case-insensitive
+// match, prepend (?i) to the regex, as in: (?i)^https://api\.example\.com/v1/
+func (f *URLFilter) SetMatcher(matcher Matcher) error {
+	if matcher == nil {
+		matcher = NewEmptyRegexpMatcher()
+	}
+	rm, ok := matcher.(RegexpMatcher)
+	if !ok {
+		f.ensureMatcher()
+		return fmt.Errorf("the URLFilter only accepts RegexMatchers: got %T", matcher)
+	}
+	f.RegexpMatcher = rm
+	return nil
+}
+
+// Describe is part of the Filter interface.
+func (f *URLFilter) Describe() FilterDescription {
+	f.ensureMatcher()
+	return FilterDescription{
+		TypeName: f.Type().Name(),
+		Pattern:  regexpToDescription(f.Regexp()),
+	}
+}
+
+func urlFilterFromDescription(_ FilterMap, fd *FilterDescription) Filter {
+	m := NewRegexpMatcher(fd.PatternRegexp())
+	f := &URLFilter{}
+	err := f.SetMatcher(m)
+	if err != nil {
+		return nil
+	}
+	return f
+}
@@ -0,0 +1,90 @@
+package filters
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+)
+
+func TestURLFilter_MatchesCall(t *testing.T) {
+	const payments = `https://api.example.com/v1/payments`
+	tests := []struct {
+		name    string
+		matcher RegexpMatcher
+		url     string
+		want    bool
+	}{
+		{"empty", NewEmptyRegexpMatcher(), payments, true},
+		{"no regexp", NewRegexpMatcher(nil), payments, true},
+		{"happy anchored", NewRegexpMatcher(regexp.MustCompile(`^https://api\.example\.com/v1/payments$`)), payments, true},
+		{"happy prefix", NewRegexpMatcher(regexp.MustCompile(`^https://api\.example\.com/v1/`)), payments + `/123`, true},
+		{"query excluded", NewRegexpMatcher(regexp.MustCompile(`^https://api\.example\.com/v1/payments$`)), payments + `?id=123`, true},
+		{"query not matched", NewRegexpMatcher(regexp.MustCompile(`id=123`)), payments + `?id=123`, false},
+		{"port included", NewRegexpMatcher(regexp.MustCompile(`^https://api\.example\.com:8443/`)), `https://api.example.com:8443/v1`, true},
+		{"sad anchored", NewRegexpMatcher(regexp.MustCompile(`^https://example\.com/v1/payments$`)), payments, false},
+		{"sad scheme", NewRegexpMatcher(regexp.MustCompile(`^http://api\.example\.com/`)), payments, false},
+		{"case sensitive", NewRegexpMatcher(regexp.MustCompile(`^https://API\.example\.com/`)), payments, false},
+		{"case insensitive", NewRegexpMatcher(regexp.MustCompile(`(?i)^https://API\.EXAMPLE\.com/V1/`)), payments, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &URLFilter{
+				RegexpMatcher: tt.matcher,
+			}
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatalf("unexpected error building request: %v", err)
+			}
+			e := (&events.EventBase{}).SetRequest(req)
+			if got := f.MatchesCall(e); got != tt.want {
+				t.Errorf("MatchesCall() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestURLFilter_SetMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher Matcher
+		wantErr bool
+	}{
+		{"happy", NewEmptyRegexpMatcher(), false},
+		{"nil", nil, false},
+		{"sad matcher", &yesMatcher{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &URLFilter{}
+			if err := f.SetMatcher(tt.matcher); (err != nil) != tt.wantErr {
+				t.Errorf("SetMatcher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestURLFilter_Type(t *testing.T) {
+	expected := URLFilterType.String()
+	var f URLFilter
+	actual := f.Type().String()
+	if actual != expected {
+		t.Errorf("Type() = %v, want %v", actual, expected)
+	}
+}
+
+func Test_urlFilterFromDescription(t *testing.T) {
+	fd := &FilterDescription{
+		TypeName: URLFilterType.Name(),
+		Pattern:  &RegexpMatcherDescription{Value: `^https://api\.example\.com/v1/`, Flags: `i`},
+	}
+	f := NewFilterFromDescription(nil, fd)
+	if f == nil {
+		t.Fatal("unexpected nil filter")
+	}
+	req, _ := http.NewRequest(http.MethodGet, `HTTPS://API.Example.com/v1/payments`, nil)
+	if !f.MatchesCall((&events.EventBase{}).SetRequest(req)) {
+		t.Error("expected case-insensitive description pattern to match")
+	}
+}
@@ -28,6 +28,11 @@ func (*YesFilter) SetMatcher(matcher Matcher) error {
 	return nil
 }
 
+// Describe is part of the Filter interface.
+func (f *YesFilter) Describe() FilterDescription {
+	return FilterDescription{TypeName: f.Type().Name()}
+}
+
 // AddChildren is part of the FilterSet interface.
 func (f *YesFilter) AddChildren(...Filter) FilterSet { return f }
 
@@ -0,0 +1,150 @@
+package interception
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/proxy"
+)
+
+// aggregationKey identifies the calls coalesced in a single aggregated report.
+type aggregationKey struct {
+	method, host, path      string
+	statusCode              int
+	requestSha, responseSha string
+	errorMessage            string
+}
+
+func newAggregationKey(re *ReportEvent) aggregationKey {
+	var key aggregationKey
+	if request := re.Request(); request != nil {
+		key.method = request.Method
+		if request.URL != nil {
+			key.host = request.URL.Host
+			key.path = request.URL.Path
+		}
+	}
+	if response := re.Response(); response != nil {
+		key.statusCode = response.StatusCode
+	}
+	if re.BodiesEvent != nil {
+		key.requestSha, key.responseSha = re.RequestSha, re.ResponseSha
+	}
+	if re.Error != nil {
+		key.errorMessage = re.Error.Error()
+	}
+	return key
+}
+
+// AggregationProvider is an events.ListenerProvider returning a listener which
+// coalesces identical reports into a single report carrying their Count, to
+// avoid sending a full report for each call to chatty endpoints.
+//
+// Reports are identical if they share their host, path, method, status code,
+// error, and request and response body shapes. The first report for a given
+// combination is held for the aggregation Window, during which identical
+// reports only increment its Count, then it is sent.
+//
+// Its listener prevents the dispatch of all reports to the listeners after it,
+// so it must be placed right before the ProxyProvider, through which it sends
+// the aggregated reports.
+type AggregationProvider struct {
+	Proxy  ProxyProvider
+	Window time.Duration
+
+	mu      sync.Mutex
+	pending map[aggregationKey]*proxy.ReportLog
+	timers  map[aggregationKey]*time.Timer
+	closed  bool
+}
+
+// NewAggregationProvider builds an AggregationProvider sending its aggregated
+// reports to the Sender after each window.
+func NewAggregationProvider(sender *proxy.Sender, window time.Duration) *AggregationProvider {
+	return &AggregationProvider{
+		Proxy:   ProxyProvider{Sender: sender},
+		Window:  window,
+		pending: make(map[aggregationKey]*proxy.ReportLog),
+		timers:  make(map[aggregationKey]*time.Timer),
+	}
+}
+
+// Aggregate holds or counts the report in the event, stopping its dispatch.
+// Once the provider is closed, reports are sent without being held.
+func (p *AggregationProvider) Aggregate(_ context.Context, e events.Event) error {
+	re, ok := e.(*ReportEvent)
+	if !ok {
+		return fmt.Errorf("topic %s used with event type %T", e.Topic(), e)
+	}
+	key := newAggregationKey(re)
+
+	p.mu.Lock()
+	if rl, ok := p.pending[key]; ok {
+		rl.Count++
+		p.mu.Unlock()
+		return events.DispatchStopRequest
+	}
+
+	rl := re.reportLog()
+	rl.Count = 1
+	if p.closed {
+		p.mu.Unlock()
+		p.Proxy.Send(rl)
+		return events.DispatchStopRequest
+	}
+	p.pending[key] = &rl
+	p.timers[key] = time.AfterFunc(p.Window, func() {
+		p.flush(key)
+	})
+	p.mu.Unlock()
+	return events.DispatchStopRequest
+}
+
+// flush sends the aggregated report for a key, if it was not already sent.
+func (p *AggregationProvider) flush(key aggregationKey) {
+	p.mu.Lock()
+	rl, ok := p.pending[key]
+	delete(p.pending, key)
+	delete(p.timers, key)
+	p.mu.Unlock()
+	if ok {
+		p.Proxy.Send(*rl)
+	}
+}
+
+// Flush sends all the aggregated reports without waiting for their window to
+// end, stopping their timers.
+func (p *AggregationProvider) Flush() {
+	p.mu.Lock()
+	pending := p.pending
+	for _, timer := range p.timers {
+		timer.Stop()
+	}
+	p.pending = make(map[aggregationKey]*proxy.ReportLog)
+	p.timers = make(map[aggregationKey]*time.Timer)
+	p.mu.Unlock()
+	for _, rl := range pending {
+		p.Proxy.Send(*rl)
+	}
+}
+
+// Close flushes the aggregated reports and stops holding the later ones, so no
+// timer is left to send a report, e.g. before stopping the Sender.
+func (p *AggregationProvider) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.Flush()
+}
+
+// Listeners implements the events.ListenerProvider interface.
+func (p *AggregationProvider) Listeners(e events.Event) []events.Listener {
+	if e.Topic() != TopicReport {
+		return nil
+	}
+
+	return []events.Listener{p.Aggregate}
+}
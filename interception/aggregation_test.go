@@ -0,0 +1,140 @@
+package interception
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/proxy"
+)
+
+func makeAggregationTestEvent(method, url string, status int, responseSha string) *ReportEvent {
+	re := NewReportEvent(proxy.StageBodies, nil)
+	request, _ := http.NewRequest(method, url, nil)
+	re.SetRequest(request).SetResponse(&http.Response{StatusCode: status})
+	re.ResponseSha = responseSha
+	re.SetConfig(&APIEventConfig{IsActive: true, LogLevel: Restricted})
+	return re
+}
+
+func makeAggregationTestSender(backlog int) *proxy.Sender {
+	logger := zerolog.New(ioutil.Discard)
+	return &proxy.Sender{
+		FanIn:    make(chan proxy.ReportLog, backlog),
+		Draining: make(chan struct{}),
+		Logger:   &logger,
+	}
+}
+
+func TestAggregationProvider_Flush(t *testing.T) {
+	const calls = 25
+	sender := makeAggregationTestSender(calls)
+	p := NewAggregationProvider(sender, time.Hour)
+	ctx := context.Background()
+
+	for i := 0; i < calls; i++ {
+		err := p.Aggregate(ctx, makeAggregationTestEvent(http.MethodGet, `https://example.com/v1/items`, http.StatusOK, `sha`))
+		if err != events.DispatchStopRequest {
+			t.Fatalf("Aggregate() error = %v, want %v", err, events.DispatchStopRequest)
+		}
+	}
+	// Differing calls are not coalesced.
+	others := []*ReportEvent{
+		makeAggregationTestEvent(http.MethodPost, `https://example.com/v1/items`, http.StatusOK, `sha`),
+		makeAggregationTestEvent(http.MethodGet, `https://example.com/v1/other`, http.StatusOK, `sha`),
+		makeAggregationTestEvent(http.MethodGet, `https://example.com/v1/items`, http.StatusNotFound, `sha`),
+		makeAggregationTestEvent(http.MethodGet, `https://example.com/v1/items`, http.StatusOK, `other sha`),
+	}
+	for _, re := range others {
+		_ = p.Aggregate(ctx, re)
+	}
+	if len(sender.FanIn) != 0 {
+		t.Fatalf("%d reports sent before the end of the window", len(sender.FanIn))
+	}
+
+	p.Flush()
+	if n := len(sender.FanIn); n != 1+len(others) {
+		t.Fatalf("Flush() sent %d reports, expected %d", n, 1+len(others))
+	}
+	close(sender.FanIn)
+	counts := make(map[int]int)
+	for rl := range sender.FanIn {
+		counts[rl.Count]++
+	}
+	if counts[calls] != 1 || counts[1] != len(others) {
+		t.Errorf("unexpected report counts: %v", counts)
+	}
+}
+
+func TestAggregationProvider_Window(t *testing.T) {
+	const calls = 10
+	sender := makeAggregationTestSender(2)
+	p := NewAggregationProvider(sender, 20*time.Millisecond)
+	ctx := context.Background()
+
+	for window := 0; window < 2; window++ {
+		for i := 0; i < calls; i++ {
+			_ = p.Aggregate(ctx, makeAggregationTestEvent(http.MethodGet, `https://example.com/`, http.StatusOK, `sha`))
+		}
+		select {
+		case rl := <-sender.FanIn:
+			if rl.Count != calls {
+				t.Errorf("window %d: aggregated report Count = %d, want %d", window, rl.Count, calls)
+			}
+			if rl.Path != `/` || rl.Method != http.MethodGet {
+				t.Errorf("window %d: unexpected aggregated report %v", window, rl)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("window %d: no aggregated report sent", window)
+		}
+	}
+	if len(sender.FanIn) != 0 {
+		t.Errorf("unexpected extra reports: %d", len(sender.FanIn))
+	}
+}
+
+func TestAggregationProvider_Close(t *testing.T) {
+	sender := makeAggregationTestSender(3)
+	p := NewAggregationProvider(sender, 20*time.Millisecond)
+	ctx := context.Background()
+
+	_ = p.Aggregate(ctx, makeAggregationTestEvent(http.MethodGet, `https://example.com/`, http.StatusOK, `sha`))
+	p.Close()
+	if n := len(sender.FanIn); n != 1 {
+		t.Fatalf("Close() sent %d reports, expected 1", n)
+	}
+	// Reports after Close are sent without being held.
+	_ = p.Aggregate(ctx, makeAggregationTestEvent(http.MethodPost, `https://example.com/`, http.StatusOK, `sha`))
+	if n := len(sender.FanIn); n != 2 {
+		t.Fatalf("Aggregate() after Close() sent %d reports, expected 2", n)
+	}
+
+	// No timer is left to send the reports again after the window.
+	time.Sleep(3 * p.Window)
+	if n := len(sender.FanIn); n != 2 {
+		t.Errorf("%d reports sent after Close(), expected 2", n)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.timers) != 0 {
+		t.Errorf("%d timers left after Close()", len(p.timers))
+	}
+}
+
+func TestAggregationProvider_Listeners(t *testing.T) {
+	p := NewAggregationProvider(nil, time.Second)
+	if n := len(p.Listeners(NewReportEvent(proxy.StageBodies, nil))); n != 1 {
+		t.Errorf("Listeners() on report returned %d listeners, want 1", n)
+	}
+	if n := len(p.Listeners(NewConnectEvent(nil))); n != 0 {
+		t.Errorf("Listeners() on connect returned %d listeners, want 0", n)
+	}
+	if err := p.Aggregate(context.Background(), &events.EventBase{}); err == nil {
+		t.Error("Aggregate() accepted a non-report event")
+	}
+}
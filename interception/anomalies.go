@@ -0,0 +1,89 @@
+package interception
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bearer/go-agent/events"
+)
+
+const (
+	// AnomalyLengthAndEncoding flags requests with both Content-Length and
+	// Transfer-Encoding headers, which servers and proxies may interpret
+	// differently, enabling request smuggling.
+	AnomalyLengthAndEncoding = `conflicting-content-length-transfer-encoding`
+
+	// AnomalyMultipleLengths flags requests with differing Content-Length values.
+	AnomalyMultipleLengths = `multiple-content-length`
+
+	// AnomalyHeaderCRLF flags requests with CR or LF characters in header names
+	// or values, which may be used for header injection.
+	AnomalyHeaderCRLF = `crlf-in-header`
+)
+
+// RequestAnomalies lists the suspicious characteristics of a request headers.
+// It returns nil for a request without anomalies.
+func RequestAnomalies(request *http.Request) []string {
+	if request == nil {
+		return nil
+	}
+	var anomalies []string
+	h := request.Header
+
+	lengths := h[`Content-Length`]
+	if len(lengths) > 0 && len(h[`Transfer-Encoding`]) > 0 {
+		anomalies = append(anomalies, AnomalyLengthAndEncoding)
+	}
+	for _, length := range lengths {
+		if strings.TrimSpace(length) != strings.TrimSpace(lengths[0]) {
+			anomalies = append(anomalies, AnomalyMultipleLengths)
+			break
+		}
+	}
+
+	for name, values := range h {
+		if strings.ContainsAny(name, "\r\n") {
+			anomalies = append(anomalies, AnomalyHeaderCRLF)
+			return anomalies
+		}
+		for _, value := range values {
+			if strings.ContainsAny(value, "\r\n") {
+				anomalies = append(anomalies, AnomalyHeaderCRLF)
+				return anomalies
+			}
+		}
+	}
+	return anomalies
+}
+
+// AnomalyProvider is an events.ListenerProvider returning a listener which
+// annotates API calls with the anomalies found in their request, without
+// blocking them.
+type AnomalyProvider struct{}
+
+// DetectAnomalies sets the anomalies found in the event request on the event
+// configuration.
+func (AnomalyProvider) DetectAnomalies(_ context.Context, e events.Event) error {
+	ae, ok := e.(APIEvent)
+	if !ok {
+		return fmt.Errorf("topic %s used with non-APIEvent type %T", e.Topic(), e)
+	}
+	config := ae.Config()
+	if config == nil {
+		config = defaultAPIEventConfig()
+		ae.SetConfig(config)
+	}
+	config.Anomalies = RequestAnomalies(e.Request())
+	return nil
+}
+
+// Listeners implements the events.ListenerProvider interface.
+func (p AnomalyProvider) Listeners(e events.Event) []events.Listener {
+	if e.Topic() != TopicRequest {
+		return nil
+	}
+
+	return []events.Listener{p.DetectAnomalies}
+}
@@ -0,0 +1,87 @@
+package interception
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/proxy"
+)
+
+func TestRequestAnomalies(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   []string
+	}{
+		{`no headers`, nil, nil},
+		{`length only`, http.Header{`Content-Length`: {`12`}}, nil},
+		{`encoding only`, http.Header{`Transfer-Encoding`: {`chunked`}}, nil},
+		{`length and encoding`, http.Header{`Content-Length`: {`12`}, `Transfer-Encoding`: {`chunked`}},
+			[]string{AnomalyLengthAndEncoding}},
+		{`same lengths`, http.Header{`Content-Length`: {`12`, ` 12`}}, nil},
+		{`different lengths`, http.Header{`Content-Length`: {`12`, `24`}}, []string{AnomalyMultipleLengths}},
+		{`all length anomalies`, http.Header{`Content-Length`: {`12`, `24`}, `Transfer-Encoding`: {`chunked`}},
+			[]string{AnomalyLengthAndEncoding, AnomalyMultipleLengths}},
+		{`CRLF in value`, http.Header{`X-Foo`: {"bar\r\nX-Injected: baz"}}, []string{AnomalyHeaderCRLF}},
+		{`LF in name`, http.Header{"X-Foo\nX-Bar": {`baz`}}, []string{AnomalyHeaderCRLF}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, defaultTestURL, nil)
+			req.Header = tt.header
+			if got := RequestAnomalies(req); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RequestAnomalies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnomalyProvider_Report(t *testing.T) {
+	var rl proxy.ReportLog
+	d := events.NewDispatcher()
+	// Anomalies are reported from the Restricted level.
+	d.AddProviders(TopicConnect, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+		return []events.Listener{func(_ context.Context, e events.Event) error {
+			e.(APIEvent).Config().LogLevel = Restricted
+			return nil
+		}}
+	}))
+	d.AddProviders(TopicRequest, AnomalyProvider{})
+	d.AddProviders(TopicReport, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+		return []events.Listener{func(_ context.Context, e events.Event) error {
+			re := e.(*ReportEvent)
+			rl = re.Config().LogLevel.Prepare(re)
+			return nil
+		}}
+	}))
+	rt := &RoundTripper{
+		Dispatcher: d,
+		Underlying: testRoundTripper{},
+	}
+	req, _ := http.NewRequest(http.MethodPost, defaultTestURL, nil)
+	req.Header.Set(`Content-Length`, `12`)
+	req.Header.Set(`Transfer-Encoding`, `chunked`)
+
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip() error = %v, the request should not be blocked", err)
+	}
+	if want := []string{AnomalyLengthAndEncoding}; !reflect.DeepEqual(rl.Anomalies, want) {
+		t.Errorf("reported anomalies = %v, want %v", rl.Anomalies, want)
+	}
+}
+
+func TestAnomalyProvider_Listeners(t *testing.T) {
+	p := AnomalyProvider{}
+	if n := len(p.Listeners(&RequestEvent{})); n != 1 {
+		t.Errorf("Listeners() on request returned %d listeners, want 1", n)
+	}
+	if n := len(p.Listeners(NewReportEvent(proxy.StageBodies, nil))); n != 0 {
+		t.Errorf("Listeners() on report returned %d listeners, want 0", n)
+	}
+	if err := p.DetectAnomalies(context.Background(), &events.EventBase{}); err == nil {
+		t.Error("DetectAnomalies() accepted a non-API event")
+	}
+}
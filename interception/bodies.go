@@ -4,6 +4,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"sync/atomic"
 
 	"github.com/bearer/go-agent/events"
 	"github.com/bearer/go-agent/proxy"
@@ -17,6 +18,11 @@ type BodyReadCloser struct {
 	peekError  error
 	pos        int
 	readCloser io.ReadCloser
+
+	// bytesRead and eof are accessed atomically, as transports may still be
+	// reading request bodies when the API call is reported.
+	bytesRead int64
+	eof       int32
 }
 
 // NewBodyReadCloser constructs a BodyReadCloser wrapper
@@ -30,24 +36,56 @@ func NewBodyReadCloser(readCloser io.ReadCloser, peekSize int) *BodyReadCloser {
 
 // Read gives the usual io.Reader behaviour
 func (r *BodyReadCloser) Read(p []byte) (int, error) {
-	if r.pos < r.peekSize && (r.peekBuffer == nil || r.pos < len(r.peekBuffer)) {
-		r.ensurePeekBuffer()
-		peekN := copy(p, r.peekBuffer)
-		if r.peekError != nil {
-			// Use the normal read
-			r.pos = r.peekSize + 1
+	n, err := r.read(p)
+	atomic.AddInt64(&r.bytesRead, int64(n))
+	if err == io.EOF {
+		atomic.StoreInt32(&r.eof, 1)
+	}
+	return n, err
+}
 
-			return peekN, r.peekError
+func (r *BodyReadCloser) read(p []byte) (int, error) {
+	if r.pos < r.peekSize {
+		r.ensurePeekBuffer()
+		n := copy(p, r.peekBuffer[r.pos:])
+		r.pos += n
+		// The peek buffer is only shorter than peekSize on errors, which are
+		// returned once it is exhausted.
+		if r.pos == len(r.peekBuffer) && r.peekError != nil {
+			return n, r.peekError
 		}
-
-		n, err := r.readCloser.Read(p[peekN:])
-		r.pos += peekN + n
-		return peekN + n, err
+		return n, nil
 	}
 
 	return r.readCloser.Read(p)
 }
 
+// BytesRead returns the number of bytes read from the BodyReadCloser, not
+// including those only peeked.
+func (r *BodyReadCloser) BytesRead() int64 {
+	return atomic.LoadInt64(&r.bytesRead)
+}
+
+// FullyRead checks whether the BodyReadCloser was read until its end.
+func (r *BodyReadCloser) FullyRead() bool {
+	return atomic.LoadInt32(&r.eof) != 0
+}
+
+// fullyPeeked returns the length of the peek buffer, and whether it holds the
+// whole body, without peeking if it was not done yet.
+func (r *BodyReadCloser) fullyPeeked() (int, bool) {
+	if r.peekBuffer == nil {
+		return 0, false
+	}
+	return len(r.peekBuffer), r.peekError == io.EOF
+}
+
+// tooLong checks whether a peeked body reached the maximum body size for which
+// the BodyReadCloser was built, beyond which bodies are not parsed.
+func (r *BodyReadCloser) tooLong(peeked []byte) bool {
+	return len(peeked) >= r.peekSize-1
+}
+
 // Peek returns the result of reading the first peek bytes block
 func (r *BodyReadCloser) Peek() ([]byte, error) {
 	r.ensurePeekBuffer()
@@ -77,7 +115,51 @@ func (r *BodyReadCloser) Close() error {
 // BodyParsingProvider is an events.Listener provider returning listeners
 // performing data collection, hashing, and sanitization on request/reponse
 // bodies.
-type BodyParsingProvider struct{}
+type BodyParsingProvider struct {
+	// HashLimiter, if not nil, bounds the concurrent body shape hash computations.
+	HashLimiter *HashLimiter
+
+	// ShapeWarner, if not nil, reports the first failure to marshal a shape.
+	ShapeWarner *ShapeWarner
+
+	// Digests are the names of the BodyDigestAlgorithms used to compute the
+	// digests of the raw bodies.
+	Digests []string
+
+	// Lazy skips the parsing and shape hashing of the bodies of the calls
+	// which do not report them, because of NoBodies, or a LogLevel below All
+	// without a body preview. Only their lengths, line counts, digests, and
+	// form statistics are then available, so filters on body contents cannot
+	// match these calls.
+	Lazy bool
+
+	// ParseForFilters disables the Lazy skipping, for data collection rules
+	// whose filters match on body contents, as found by RulesUseParsedBodies.
+	ParseForFilters bool
+
+	// ContentSniffing enables guessing the content type of the bodies without
+	// a Content-Type header from their contents, so that they can be parsed,
+	// instead of being handled as binary data.
+	ContentSniffing bool
+
+	// QueryAsBody enables using the query parameters of GET requests without
+	// a body as their request body, for APIs passing structured data in the
+	// query string.
+	QueryAsBody bool
+}
+
+// skipsParsing checks whether the bodies of a call are not to be parsed, as
+// they will not be reported.
+func (p BodyParsingProvider) skipsParsing(e APIEvent) bool {
+	if !p.Lazy || p.ParseForFilters {
+		return false
+	}
+	config := e.Config()
+	if config == nil {
+		return false
+	}
+	return config.NoBodies || (config.LogLevel < All && config.BodyPreview <= 0)
+}
 
 // Listeners implements events.ListenerProvider.
 func (p BodyParsingProvider) Listeners(e events.Event) (l []events.Listener) {
@@ -99,3 +181,16 @@ func ParseFormData(reader io.Reader) (map[string][]string, error) {
 	err := request.ParseForm()
 	return request.Form, err
 }
+
+// FormStats returns the number of values in parsed form data, and the total
+// size of their decoded names and values, allowing to report forms without
+// their values.
+func FormStats(form map[string][]string) (fields, size int) {
+	for name, values := range form {
+		for _, value := range values {
+			fields++
+			size += len(name) + len(value)
+		}
+	}
+	return fields, size
+}
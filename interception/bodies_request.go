@@ -7,22 +7,24 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 
 	"github.com/bearer/go-agent/events"
-	"github.com/bearer/go-agent/proxy"
 )
 
 // RequestBodyParser is an events.Listener performing eager resBody loading on API
 // requests, to perform sanitization and bandwidth reduction.
-func (BodyParsingProvider) RequestBodyParser(_ context.Context, e events.Event) error {
+func (p BodyParsingProvider) RequestBodyParser(_ context.Context, e events.Event) error {
 	be, ok := e.(*BodiesEvent)
 	if !ok {
 		return fmt.Errorf(`topic BodiesEvent, got %T`, e)
 	}
 	request := e.Request()
 	body := request.Body
-	if body == nil {
+	if body == nil || body == http.NoBody {
 		be.RequestBody = ``
+		p.parseQueryAsBody(be, request)
 		return nil
 	}
 	bodyReader, ok := body.(*BodyReadCloser)
@@ -36,21 +38,33 @@ func (BodyParsingProvider) RequestBodyParser(_ context.Context, e events.Event)
 		be.RequestBody = BodyUndecodable
 		return fmt.Errorf("error peeking body: %w", err)
 	}
+	be.RequestBodyLength = len(bodyBytes)
 	reader := bytes.NewReader(bodyBytes)
 	if reader.Len() == 0 {
 		be.RequestBody = ``
+		p.parseQueryAsBody(be, request)
 		return nil
 	}
-	if reader.Len() >= MaximumBodySize {
+	ct, sniffed := p.contentType(request.Header, bodyBytes)
+	if sniffed {
+		be.RequestSniffedContentType = ct
+	}
+	be.RequestBodyLines = bodyLines(ct, bodyBytes, err)
+	be.RequestDigests = bodyDigests(p.Digests, bodyBytes, err)
+	if bodyReader.tooLong(bodyBytes) {
 		be.RequestBody = BodyTooLong
 		return nil
 	}
-	ct := request.Header.Get(proxy.ContentTypeHeader)
 	if !ParsableContentType.MatchString(ct) {
 		be.RequestBody = BodyIsBinary
 		return nil
 	}
+	// Lazy parsing only skips the decoding of bodies which are not reported,
+	// but not the form statistics reported at the Restricted level.
+	skipped := p.skipsParsing(be)
 	switch {
+	case skipped && !FormContentType.MatchString(ct):
+		return nil
 	case JSONContentType.MatchString(ct):
 		d := json.NewDecoder(reader)
 		err := d.Decode(&be.RequestBody)
@@ -58,13 +72,18 @@ func (BodyParsingProvider) RequestBodyParser(_ context.Context, e events.Event)
 			be.RequestBody = BodyUndecodable
 			return fmt.Errorf("decoding JSON request reqBody: %w", err)
 		}
-		be.RequestSha = ToSha(be.RequestBody)
+		be.RequestSha = p.HashLimiter.toSha(be.RequestBody, p.ShapeWarner.marshalFailed)
 	case FormContentType.MatchString(ct):
-		be.RequestBody, err = ParseFormData(reader)
+		form, err := ParseFormData(reader)
 		if err != nil {
 			be.RequestBody = BodyUndecodable
 			return fmt.Errorf("decoding HTML form request reqBody: %w", err)
 		}
+		be.RequestFormFields, be.RequestFormSize = FormStats(form)
+		if skipped {
+			return nil
+		}
+		be.RequestBody = form
 		be.RequestSha = `N/A`
 		return nil
 	default:
@@ -73,3 +92,36 @@ func (BodyParsingProvider) RequestBodyParser(_ context.Context, e events.Event)
 
 	return nil
 }
+
+// queryBody converts query parameters to a JSON-like body, with single values
+// as strings, and repeated ones as arrays of strings.
+func queryBody(query url.Values) map[string]interface{} {
+	body := make(map[string]interface{}, len(query))
+	for name, values := range query {
+		if len(values) == 1 {
+			body[name] = values[0]
+			continue
+		}
+		list := make([]interface{}, len(values))
+		for i, value := range values {
+			list[i] = value
+		}
+		body[name] = list
+	}
+	return body
+}
+
+// parseQueryAsBody uses the query parameters of GET requests without a body as
+// their request body if QueryAsBody is enabled, so that they are shape hashed
+// and sanitized like the bodies of other requests.
+func (p BodyParsingProvider) parseQueryAsBody(be *BodiesEvent, request *http.Request) {
+	if !p.QueryAsBody || request.Method != http.MethodGet || request.URL == nil || p.skipsParsing(be) {
+		return
+	}
+	query := request.URL.Query()
+	if len(query) == 0 {
+		return
+	}
+	be.RequestBody = queryBody(query)
+	be.RequestSha = p.HashLimiter.toSha(be.RequestBody, p.ShapeWarner.marshalFailed)
+}
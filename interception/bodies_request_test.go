@@ -5,6 +5,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -53,3 +54,75 @@ func TestBodyParsingProvider_RequestBodyParser(t *testing.T) {
 		})
 	}
 }
+
+func TestBodyParsingProvider_BodyLengths(t *testing.T) {
+	tests := []struct {
+		name                      string
+		reqBody, resBody          io.ReadCloser
+		wantRequest, wantResponse int
+	}{
+		{`nil bodies`, nil, nil, 0, 0},
+		{`empty bodies`, testReader(``), testReader(``), 0, 0},
+		{`request body`, testReader(`hello`), nil, 5, 0},
+		{`response body`, nil, testReader(`[1, 2]`), 0, 6},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &BodiesEvent{}
+			req, _ := http.NewRequest(http.MethodPost, defaultTestURL, tt.reqBody)
+			e.SetRequest(req)
+			e.SetResponse(&http.Response{Body: tt.resBody, Header: make(http.Header)})
+			bo := BodyParsingProvider{}
+			_ = bo.RequestBodyParser(ctx, e)
+			_ = bo.ResponseBodyParser(ctx, e)
+			request, response := e.BodyLengths()
+			if request != tt.wantRequest || response != tt.wantResponse {
+				t.Errorf("BodyLengths() = %d, %d, want %d, %d", request, response, tt.wantRequest, tt.wantResponse)
+			}
+		})
+	}
+}
+
+func TestBodyParsingProvider_QueryAsBody(t *testing.T) {
+	const query = `?filter%5Bname%5D=bearer&page=2&tag=a&tag=b`
+	tests := []struct {
+		name     string
+		method   string
+		query    string
+		body     io.ReadCloser
+		enabled  bool
+		expected interface{}
+	}{
+		{`GET with query`, http.MethodGet, query, nil, true, map[string]interface{}{
+			`filter[name]`: `bearer`,
+			`page`:         `2`,
+			`tag`:          []interface{}{`a`, `b`},
+		}},
+		{`disabled`, http.MethodGet, query, nil, false, ``},
+		{`GET without query`, http.MethodGet, ``, nil, true, ``},
+		{`POST with query`, http.MethodPost, query, nil, true, ``},
+		{`GET with body`, http.MethodGet, query, testReader(`hello`), true, BodyIsBinary},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			be := &BodiesEvent{}
+			req, _ := http.NewRequest(tt.method, defaultTestURL+tt.query, tt.body)
+			be.SetRequest(req)
+			p := BodyParsingProvider{QueryAsBody: tt.enabled}
+			if err := p.RequestBodyParser(context.Background(), be); err != nil {
+				t.Fatalf("RequestBodyParser() error = %v", err)
+			}
+			if !reflect.DeepEqual(be.RequestBody, tt.expected) {
+				t.Errorf("RequestBody = %#v, want %#v", be.RequestBody, tt.expected)
+			}
+			_, isQuery := tt.expected.(map[string]interface{})
+			if hasSha := be.RequestSha != ``; hasSha != isQuery {
+				t.Errorf("RequestSha = %q, want a shape hash: %t", be.RequestSha, isQuery)
+			}
+			if isQuery && be.RequestSha != ToSha(tt.expected) {
+				t.Errorf("RequestSha = %q, want %q", be.RequestSha, ToSha(tt.expected))
+			}
+		})
+	}
+}
@@ -28,6 +28,11 @@ func (p BodyParsingProvider) ResponseBodyParser(_ context.Context, e events.Even
 	}
 
 	bodyReader, ok := body.(*BodyReadCloser)
+	if !ok && EventStreamContentType.MatchString(response.Header.Get(proxy.ContentTypeHeader)) {
+		// Streams are passed through by the RoundTripper without buffering.
+		be.ResponseBody = BodyIsStream
+		return nil
+	}
 	if !ok {
 		be.RequestBody = BodyUndecodable
 		return errors.New(`expected Body to be a BodyReadCloser`)
@@ -38,21 +43,32 @@ func (p BodyParsingProvider) ResponseBodyParser(_ context.Context, e events.Even
 		be.RequestBody = BodyUndecodable
 		return fmt.Errorf("error peeking body: %w", err)
 	}
+	be.ResponseBodyLength = len(bodyBytes)
 	reader := bytes.NewReader(bodyBytes)
 	if reader.Len() == 0 {
 		be.ResponseBody = ``
 		return nil
 	}
-	if reader.Len() >= MaximumBodySize {
+	ct, sniffed := p.contentType(response.Header, bodyBytes)
+	if sniffed {
+		be.ResponseSniffedContentType = ct
+	}
+	be.ResponseBodyLines = bodyLines(ct, bodyBytes, err)
+	be.ResponseDigests = bodyDigests(p.Digests, bodyBytes, err)
+	if bodyReader.tooLong(bodyBytes) {
 		be.ResponseBody = BodyTooLong
 		return nil
 	}
-	ct := response.Header.Get(proxy.ContentTypeHeader)
 	if !ParsableContentType.MatchString(ct) {
 		be.ResponseBody = BodyIsBinary
 		return nil
 	}
+	// Lazy parsing only skips the decoding of bodies which are not reported,
+	// but not the form statistics reported at the Restricted level.
+	skipped := p.skipsParsing(be)
 	switch {
+	case skipped && !FormContentType.MatchString(ct):
+		return nil
 	case JSONContentType.MatchString(ct):
 		d := json.NewDecoder(reader)
 		err := d.Decode(&be.ResponseBody)
@@ -60,13 +76,18 @@ func (p BodyParsingProvider) ResponseBodyParser(_ context.Context, e events.Even
 			be.ResponseBody = BodyUndecodable
 			return fmt.Errorf("decoding JSON response resBody: %w", err)
 		}
-		be.ResponseSha = ToSha(be.ResponseBody)
+		be.ResponseSha = p.HashLimiter.toSha(be.ResponseBody, p.ShapeWarner.marshalFailed)
 	case FormContentType.MatchString(ct):
-		be.ResponseBody, err = ParseFormData(reader)
+		form, err := ParseFormData(reader)
 		if err != nil {
 			be.ResponseBody = BodyUndecodable
 			return fmt.Errorf("decoding HTML form response body: %w", err)
 		}
+		be.ResponseFormFields, be.ResponseFormSize = FormStats(form)
+		if skipped {
+			return nil
+		}
+		be.ResponseBody = form
 		be.ResponseSha = `N/A`
 		return nil
 	default:
@@ -1,11 +1,15 @@
 package interception
 
 import (
+	"context"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/bearer/go-agent/proxy"
 )
 
 func TestBodyReadCloser(t *testing.T) {
@@ -44,6 +48,50 @@ func TestBodyReadCloser(t *testing.T) {
 	}
 }
 
+func TestBodyReadCloser_BytesRead(t *testing.T) {
+	const data = `0123456789abcdefghij`
+	tests := []struct {
+		name          string
+		readSize      int
+		reads         int
+		expected      string
+		wantFullyRead bool
+	}{
+		{`full small reads`, 3, 10, data, true},
+		{`full large reads`, 32, 3, data, true},
+		{`partial within peek`, 3, 2, data[:6], false},
+		{`partial beyond peek`, 4, 4, data[:14], false},
+		{`none`, 4, 0, ``, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Peek less than the data, to cross the peek buffer boundary.
+			brc := NewBodyReadCloser(ioutil.NopCloser(strings.NewReader(data)), 10)
+			if _, err := brc.Peek(); err != nil {
+				t.Fatalf("Peek() error = %v", err)
+			}
+			var actual []byte
+			buffer := make([]byte, tt.readSize)
+			for i := 0; i < tt.reads; i++ {
+				n, err := brc.Read(buffer)
+				actual = append(actual, buffer[:n]...)
+				if err != nil {
+					break
+				}
+			}
+			if string(actual) != tt.expected {
+				t.Errorf("Read() expected: %s, actual: %s", tt.expected, actual)
+			}
+			if n := brc.BytesRead(); n != int64(len(tt.expected)) {
+				t.Errorf("BytesRead() = %d, expected %d", n, len(tt.expected))
+			}
+			if fullyRead := brc.FullyRead(); fullyRead != tt.wantFullyRead {
+				t.Errorf("FullyRead() = %t, expected %t", fullyRead, tt.wantFullyRead)
+			}
+		})
+	}
+}
+
 func TestParseFormData(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -71,3 +119,139 @@ func TestParseFormData(t *testing.T) {
 		})
 	}
 }
+
+func TestBodyParsingProvider_FormStats(t *testing.T) {
+	const form = `name=J%C3%A9r%C3%B4me&tag=a&tag=bc&empty=`
+	reader := func() *BodyReadCloser {
+		return NewBodyReadCloser(ioutil.NopCloser(strings.NewReader(form)), MaximumBodySize+1)
+	}
+	p := BodyParsingProvider{}
+	req, _ := http.NewRequest(http.MethodPost, defaultTestURL, nil)
+	req.Header.Set(proxy.ContentTypeHeader, proxy.ContentTypeSimpleForm)
+	req.Body = reader()
+	res := &http.Response{Header: make(http.Header), Body: reader()}
+	res.Header.Set(proxy.ContentTypeHeader, proxy.ContentTypeSimpleForm)
+	be := &BodiesEvent{}
+	be.SetRequest(req).SetResponse(res)
+
+	if err := p.RequestBodyParser(context.Background(), be); err != nil {
+		t.Fatalf("RequestBodyParser error = %v", err)
+	}
+	if err := p.ResponseBodyParser(context.Background(), be); err != nil {
+		t.Fatalf("ResponseBodyParser error = %v", err)
+	}
+
+	// Names and values are counted once decoded: "name" "Jérôme" "tag" "a"
+	// "tag" "bc" "empty" "".
+	const expectedFields, expectedSize = 4, 4 + 8 + 3 + 1 + 3 + 2 + 5
+	re := NewReportEvent(proxy.StageBodies, nil)
+	re.BodiesEvent = be
+	ll := Restricted
+	rl := ll.Prepare(re)
+	if rl.RequestFormFields != expectedFields || rl.ResponseFormFields != expectedFields {
+		t.Errorf("reported fields = %d, %d, expected %d", rl.RequestFormFields, rl.ResponseFormFields, expectedFields)
+	}
+	if rl.RequestFormSize != expectedSize || rl.ResponseFormSize != expectedSize {
+		t.Errorf("reported sizes = %d, %d, expected %d", rl.RequestFormSize, rl.ResponseFormSize, expectedSize)
+	}
+	if rl.RequestBody != `` || rl.ResponseBody != `` {
+		t.Errorf("form values reported at the Restricted level")
+	}
+}
+
+func TestBodyParsingProvider_Lazy(t *testing.T) {
+	tests := []struct {
+		name            string
+		lazy            bool
+		level           LogLevel
+		bodyPreview     int
+		noBodies        bool
+		parseForFilters bool
+		wantHashes      int
+	}{
+		{`lazy restricted`, true, Restricted, 0, false, false, 0},
+		{`lazy detected`, true, Detected, 0, false, false, 0},
+		{`lazy all`, true, All, 0, false, false, 2},
+		{`lazy restricted preview`, true, Restricted, 64, false, false, 2},
+		{`lazy all no bodies`, true, All, 0, true, false, 0},
+		{`lazy restricted preview no bodies`, true, Restricted, 64, true, false, 0},
+		{`lazy all no bodies for filters`, true, All, 0, true, true, 2},
+		{`lazy restricted for filters`, true, Restricted, 0, false, true, 2},
+		{`eager restricted`, false, Restricted, 0, false, false, 2},
+		{`eager all no bodies`, false, All, 0, true, false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hashes := 0
+			limiter := NewHashLimiter(1, false)
+			limiter.hash = func(j interface{}, _ func(error)) string {
+				hashes++
+				return ToSha(j)
+			}
+			p := BodyParsingProvider{HashLimiter: limiter, Lazy: tt.lazy, ParseForFilters: tt.parseForFilters}
+
+			req, _ := http.NewRequest(http.MethodPost, defaultTestURL, nil)
+			req.Header.Set(proxy.ContentTypeHeader, proxy.ContentTypeJSON)
+			req.Body = NewBodyReadCloser(ioutil.NopCloser(strings.NewReader(`{"a":1}`)), MaximumBodySize+1)
+			res := &http.Response{Header: make(http.Header)}
+			res.Header.Set(proxy.ContentTypeHeader, proxy.ContentTypeJSON)
+			res.Body = NewBodyReadCloser(ioutil.NopCloser(strings.NewReader(`{"b":2}`)), MaximumBodySize+1)
+			be := &BodiesEvent{}
+			be.SetRequest(req).SetResponse(res)
+			be.SetConfig(&APIEventConfig{IsActive: true, LogLevel: tt.level, BodyPreview: tt.bodyPreview, NoBodies: tt.noBodies})
+
+			if err := p.RequestBodyParser(context.Background(), be); err != nil {
+				t.Fatalf("RequestBodyParser() error = %v", err)
+			}
+			if err := p.ResponseBodyParser(context.Background(), be); err != nil {
+				t.Fatalf("ResponseBodyParser() error = %v", err)
+			}
+			if hashes != tt.wantHashes {
+				t.Errorf("computed %d shape hashes, expected %d", hashes, tt.wantHashes)
+			}
+			if parsed := be.RequestBody != nil; parsed != (tt.wantHashes > 0) {
+				t.Errorf("request body parsed = %t, expected %t", parsed, tt.wantHashes > 0)
+			}
+			if be.RequestBodyLength == 0 || be.ResponseBodyLength == 0 {
+				t.Errorf("body lengths not computed: %d, %d", be.RequestBodyLength, be.ResponseBodyLength)
+			}
+		})
+	}
+}
+
+func TestBodyParsingProvider_LazyRestrictedStats(t *testing.T) {
+	const form = `a=1&b=22&b=333`
+	p := BodyParsingProvider{Digests: []string{`sha256`}, Lazy: true}
+
+	req, _ := http.NewRequest(http.MethodPost, defaultTestURL, nil)
+	req.Header.Set(proxy.ContentTypeHeader, `application/x-www-form-urlencoded`)
+	req.Body = NewBodyReadCloser(ioutil.NopCloser(strings.NewReader(form)), MaximumBodySize+1)
+	res := &http.Response{Header: make(http.Header)}
+	res.Header.Set(proxy.ContentTypeHeader, `application/x-www-form-urlencoded`)
+	res.Body = NewBodyReadCloser(ioutil.NopCloser(strings.NewReader(form)), MaximumBodySize+1)
+	be := &BodiesEvent{}
+	be.SetRequest(req).SetResponse(res)
+	be.SetConfig(&APIEventConfig{IsActive: true, LogLevel: Restricted})
+
+	if err := p.RequestBodyParser(context.Background(), be); err != nil {
+		t.Fatalf("RequestBodyParser() error = %v", err)
+	}
+	if err := p.ResponseBodyParser(context.Background(), be); err != nil {
+		t.Fatalf("ResponseBodyParser() error = %v", err)
+	}
+
+	parsed, _ := ParseFormData(strings.NewReader(form))
+	fields, size := FormStats(parsed)
+	if be.RequestFormFields != fields || be.RequestFormSize != size {
+		t.Errorf("request form stats = %d, %d, expected %d, %d", be.RequestFormFields, be.RequestFormSize, fields, size)
+	}
+	if be.ResponseFormFields != fields || be.ResponseFormSize != size {
+		t.Errorf("response form stats = %d, %d, expected %d, %d", be.ResponseFormFields, be.ResponseFormSize, fields, size)
+	}
+	if be.RequestDigests[`sha256`] == `` || be.ResponseDigests[`sha256`] == `` {
+		t.Errorf("digests not computed: %v, %v", be.RequestDigests, be.ResponseDigests)
+	}
+	if be.RequestBody != nil || be.ResponseBody != nil {
+		t.Errorf("bodies parsed: %v, %v, expected none", be.RequestBody, be.ResponseBody)
+	}
+}
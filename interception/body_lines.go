@@ -0,0 +1,25 @@
+package interception
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+)
+
+// TextContentType matches the text/* content types, for which line counts are
+// reported.
+var TextContentType = regexp.MustCompile(`(?i)^\s*text/`)
+
+// bodyLines counts the lines of a text body peeked from a BodyReadCloser, the
+// last line not needing to end with a newline. Like digests, it is only
+// computed for bodies peeked in full, and is 0 for other content types.
+func bodyLines(contentType string, body []byte, peekErr error) int {
+	if len(body) == 0 || peekErr != io.EOF || !TextContentType.MatchString(contentType) {
+		return 0
+	}
+	lines := bytes.Count(body, []byte{'\n'})
+	if body[len(body)-1] != '\n' {
+		lines++
+	}
+	return lines
+}
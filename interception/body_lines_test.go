@@ -0,0 +1,59 @@
+package interception
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bearer/go-agent/proxy"
+)
+
+func TestBodyParsingProvider_BodyLines(t *testing.T) {
+	reader := func(s string) *BodyReadCloser {
+		return NewBodyReadCloser(ioutil.NopCloser(strings.NewReader(s)), MaximumBodySize+1)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    int
+	}{
+		{`single line`, `text/plain`, `hello`, 1},
+		{`multi-line`, `text/plain; charset=utf-8`, "one\ntwo\nthree", 3},
+		{`trailing newline`, `text/csv`, "a,b\n1,2\n", 2},
+		{`blank lines`, `text/plain`, "\n\n\n", 3},
+		{`empty body`, `text/plain`, ``, 0},
+		{`not text`, proxy.ContentTypeJSON, "{\n}", 0},
+		{`too long`, `text/plain`, strings.Repeat("a\n", MaximumBodySize), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := BodyParsingProvider{}
+			req, _ := http.NewRequest(http.MethodPost, defaultTestURL, nil)
+			req.Header.Set(proxy.ContentTypeHeader, tt.contentType)
+			req.Body = reader(tt.body)
+			res := &http.Response{Header: make(http.Header), Body: reader(tt.body)}
+			res.Header.Set(proxy.ContentTypeHeader, tt.contentType)
+			be := &BodiesEvent{}
+			be.SetRequest(req).SetResponse(res)
+
+			_ = p.RequestBodyParser(context.Background(), be)
+			_ = p.ResponseBodyParser(context.Background(), be)
+
+			// Line counts are reported without the bodies.
+			re := NewReportEvent(proxy.StageBodies, nil)
+			re.BodiesEvent = be
+			ll := Restricted
+			rl := ll.Prepare(re)
+			if rl.RequestBodyLines != tt.expected || rl.ResponseBodyLines != tt.expected {
+				t.Errorf("reported lines = %d, %d, expected %d", rl.RequestBodyLines, rl.ResponseBodyLines, tt.expected)
+			}
+			if rl.RequestBody != `` || rl.ResponseBody != `` {
+				t.Errorf("bodies reported at the Restricted level")
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package interception
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bearer/go-agent/events"
+)
+
+// AgeHeader is the canonical Age header name. Caches add it to the responses
+// they serve, per RFC7234 sec. 5.1.
+const AgeHeader = `Age`
+
+// cacheMarkerKey is the context key used by WithCacheMarker.
+type cacheMarkerKey struct{}
+
+// WithCacheMarker returns a context marking the responses to the requests
+// using it as served from a cache. Caching transports may use it on the
+// Request of the responses they serve from their cache, when they do not add
+// an Age header.
+func WithCacheMarker(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheMarkerKey{}, true)
+}
+
+// hasCacheMarker checks whether a request context was marked by WithCacheMarker.
+func hasCacheMarker(request *http.Request) bool {
+	if request == nil {
+		return false
+	}
+	marked, _ := request.Context().Value(cacheMarkerKey{}).(bool)
+	return marked
+}
+
+// CacheProvider is an events.ListenerProvider returning a listener which
+// detects responses served from a cache, for inclusion in the report.
+type CacheProvider struct {
+	// Header is the name of a response header, like X-Cache, whose values
+	// contain "HIT" on responses served from a cache. Empty means none.
+	Header string
+}
+
+// DetectCache sets the ReportEvent FromCache if the response carries an Age
+// header, a configured Header value containing "HIT", or a Request context
+// marked with WithCacheMarker.
+func (p CacheProvider) DetectCache(_ context.Context, e events.Event) error {
+	re, ok := e.(*ReportEvent)
+	if !ok {
+		return fmt.Errorf(`topic ReportEvent, got %T`, e)
+	}
+	response := re.Response()
+	if response == nil {
+		return nil
+	}
+	if response.Header.Get(AgeHeader) != `` || hasCacheMarker(response.Request) {
+		re.FromCache = true
+		return nil
+	}
+	if p.Header == `` {
+		return nil
+	}
+	for _, value := range response.Header.Values(p.Header) {
+		if strings.Contains(strings.ToUpper(value), `HIT`) {
+			re.FromCache = true
+			return nil
+		}
+	}
+	return nil
+}
+
+// Listeners implements the events.ListenerProvider interface.
+func (p CacheProvider) Listeners(e events.Event) []events.Listener {
+	if e.Topic() != TopicReport {
+		return nil
+	}
+
+	return []events.Listener{p.DetectCache}
+}
@@ -0,0 +1,69 @@
+package interception
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/proxy"
+)
+
+func TestCacheProvider_DetectCache(t *testing.T) {
+	const header = `X-Cache`
+	tests := []struct {
+		name     string
+		header   string
+		headers  http.Header
+		marked   bool
+		response bool
+		want     bool
+	}{
+		{`no signal`, header, http.Header{}, false, true, false},
+		{`age`, ``, http.Header{AgeHeader: {`42`}}, false, true, true},
+		{`indicator hit`, header, http.Header{header: {`HIT`}}, false, true, true},
+		{`indicator hit text`, header, http.Header{header: {`Hit from cloudfront`}}, false, true, true},
+		{`indicator miss`, header, http.Header{header: {`MISS`}}, false, true, false},
+		{`indicator not configured`, ``, http.Header{header: {`HIT`}}, false, true, false},
+		{`other indicator`, `X-Other`, http.Header{header: {`HIT`}}, false, true, false},
+		{`context marker`, ``, http.Header{}, true, true, true},
+		{`no response`, header, nil, true, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, `https://example.com`, nil)
+			re := NewReportEvent(proxy.StageBodies, nil)
+			re.SetRequest(req)
+			if tt.response {
+				resReq := req
+				if tt.marked {
+					resReq = req.WithContext(WithCacheMarker(req.Context()))
+				}
+				re.SetResponse(&http.Response{StatusCode: http.StatusOK, Header: tt.headers, Request: resReq})
+			}
+			p := CacheProvider{Header: tt.header}
+			if err := p.DetectCache(context.Background(), re); err != nil {
+				t.Fatalf(`unexpected error: %v`, err)
+			}
+
+			ll := Restricted
+			if got := ll.Prepare(re).FromCache; got != tt.want {
+				t.Errorf("reported FromCache = %t, want %t", got, tt.want)
+			}
+		})
+	}
+
+	if err := (CacheProvider{}).DetectCache(context.Background(), events.NewEvent(`bad`)); err == nil {
+		t.Error(`expected error on non-ReportEvent`)
+	}
+}
+
+func TestCacheProvider_Listeners(t *testing.T) {
+	p := CacheProvider{Header: `X-Cache`}
+	if got := p.Listeners(NewReportEvent(proxy.StageBodies, nil)); len(got) != 1 {
+		t.Errorf("Listeners() on report = %d, want 1", len(got))
+	}
+	if got := p.Listeners(&ResponseEvent{}); len(got) != 0 {
+		t.Errorf("Listeners() on response = %d, want 0", len(got))
+	}
+}
@@ -0,0 +1,67 @@
+package interception
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+const (
+	// MaxCallerStackFrames is the maximum number of frames in a caller stack.
+	MaxCallerStackFrames = 32
+
+	// MaxCallerStackSize is the maximum size, in bytes, of a caller stack.
+	MaxCallerStackSize = 4096
+
+	// agentPackage is the import path of the agent, whose frames are excluded
+	// from the caller stacks.
+	agentPackage = `github.com/bearer/go-agent`
+)
+
+// isAgentFunction checks whether a fully qualified function name belongs to
+// the agent packages, excluding those of other modules sharing its prefix.
+func isAgentFunction(function string) bool {
+	return strings.HasPrefix(function, agentPackage+`.`) ||
+		strings.HasPrefix(function, agentPackage+`/`)
+}
+
+// callerStack formats the stack of the current goroutine like a panic trace,
+// excluding the agent and runtime frames, capped to MaxCallerStackFrames and
+// MaxCallerStackSize.
+func callerStack() string {
+	pcs := make([]uintptr, 4*MaxCallerStackFrames)
+	n := runtime.Callers(1, pcs)
+	callers := runtime.CallersFrames(pcs[:n])
+	var frames []runtime.Frame
+	for {
+		frame, more := callers.Next()
+		frames = append(frames, frame)
+		if !more {
+			break
+		}
+	}
+	return formatStack(frames)
+}
+
+// formatStack formats the non-agent and non-runtime frames, capped to
+// MaxCallerStackFrames and MaxCallerStackSize.
+func formatStack(frames []runtime.Frame) string {
+	b := strings.Builder{}
+	count := 0
+	for _, frame := range frames {
+		if count == MaxCallerStackFrames {
+			break
+		}
+		if frame.Function == `` || isAgentFunction(frame.Function) ||
+			strings.HasPrefix(frame.Function, `runtime.`) {
+			continue
+		}
+		line := fmt.Sprintf("%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if b.Len()+len(line) > MaxCallerStackSize {
+			break
+		}
+		b.WriteString(line)
+		count++
+	}
+	return b.String()
+}
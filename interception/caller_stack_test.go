@@ -0,0 +1,119 @@
+package interception
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/proxy"
+)
+
+func TestRoundTripper_RoundTripCallerStack(t *testing.T) {
+	tests := []struct {
+		name       string
+		capture    bool
+		underlying http.RoundTripper
+		expected   bool
+	}{
+		{`error`, true, testErrorRoundTripper{}, true},
+		{`success`, true, testRoundTripper{}, false},
+		{`disabled`, false, testErrorRoundTripper{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var re *ReportEvent
+			var rl proxy.ReportLog
+			d := events.NewDispatcher()
+			d.AddProviders(TopicConnect, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+				return []events.Listener{func(_ context.Context, e events.Event) error {
+					e.(APIEvent).Config().LogLevel = Restricted
+					return nil
+				}}
+			}))
+			d.AddProviders(TopicReport, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+				return []events.Listener{func(_ context.Context, e events.Event) error {
+					re = e.(*ReportEvent)
+					rl = re.Config().LogLevel.Prepare(re)
+					return nil
+				}}
+			}))
+			client := http.Client{Transport: &RoundTripper{
+				Dispatcher:         d,
+				Underlying:         tt.underlying,
+				CaptureCallerStack: tt.capture,
+			}}
+
+			res, err := client.Get(defaultTestURL)
+			if err == nil {
+				_ = res.Body.Close()
+			}
+			if re == nil {
+				t.Fatal(`no report`)
+			}
+			if !tt.expected {
+				if re.CallerStack != `` || strings.Contains(rl.ErrorFullMessage, `called from`) {
+					t.Errorf("unexpected caller stack %q", re.CallerStack)
+				}
+				return
+			}
+
+			if !strings.Contains(rl.ErrorFullMessage, re.CallerStack) || !strings.HasPrefix(rl.ErrorFullMessage, `oops`) {
+				t.Errorf("ErrorFullMessage = %q, expected the error and stack", rl.ErrorFullMessage)
+			}
+			if !strings.Contains(re.CallerStack, `net/http.(*Client).Get`) {
+				t.Errorf("caller stack does not include the calling code:\n%s", re.CallerStack)
+			}
+			for _, line := range strings.Split(re.CallerStack, "\n") {
+				if !strings.HasPrefix(line, "\t") && (isAgentFunction(line) || strings.HasPrefix(line, `runtime.`)) {
+					t.Errorf("caller stack includes frame %s", line)
+				}
+			}
+		})
+	}
+}
+
+func Test_formatStack(t *testing.T) {
+	frame := func(function string, file string) runtime.Frame {
+		return runtime.Frame{Function: function, File: file, Line: 42}
+	}
+	tests := []struct {
+		name      string
+		frames    []runtime.Frame
+		expected  string
+		maxFrames int
+	}{
+		{`filtered`, []runtime.Frame{
+			frame(`github.com/bearer/go-agent/interception.callerStack`, `/agent/caller_stack.go`),
+			frame(`runtime.goexit`, `/go/runtime.go`),
+			frame(`github.com/bearer/go-agent-plugin.Call`, `/plugin/call.go`),
+			frame(`main.main`, `/app/main.go`),
+		}, "github.com/bearer/go-agent-plugin.Call\n\t/plugin/call.go:42\nmain.main\n\t/app/main.go:42\n", 2},
+		{`frames capped`, repeatFrames(frame(`main.f`, `/app/main.go`), 2*MaxCallerStackFrames), ``, MaxCallerStackFrames},
+		{`size capped`, repeatFrames(frame(`main.f`, `/app/`+strings.Repeat(`x`, 500)+`.go`), MaxCallerStackFrames), ``, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stack := formatStack(tt.frames)
+			if tt.expected != `` && stack != tt.expected {
+				t.Errorf("formatStack() = %q, expected %q", stack, tt.expected)
+			}
+			if len(stack) > MaxCallerStackSize {
+				t.Errorf("stack size = %d, expected at most %d", len(stack), MaxCallerStackSize)
+			}
+			if frames := strings.Count(stack, "\n\t"); frames != tt.maxFrames {
+				t.Errorf("stack frames = %d, expected %d", frames, tt.maxFrames)
+			}
+		})
+	}
+}
+
+func repeatFrames(frame runtime.Frame, n int) []runtime.Frame {
+	frames := make([]runtime.Frame, n)
+	for i := range frames {
+		frames[i] = frame
+	}
+	return frames
+}
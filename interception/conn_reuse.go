@@ -0,0 +1,56 @@
+package interception
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+)
+
+const (
+	// ConnectionNew is the ReportEvent ConnectionReuse value for calls sent
+	// over a newly established connection.
+	ConnectionNew = `new`
+
+	// ConnectionReused is the ReportEvent ConnectionReuse value for calls sent
+	// over a connection reused from the transport pool.
+	ConnectionReused = `reused`
+)
+
+// connRecorder captures whether the connection of an API call was reused, with
+// the httptrace.ClientTrace GotConn hook. The hook is only invoked by
+// transports supporting tracing, like http.Transport, leaving the reuse
+// unknown for the others.
+type connRecorder struct {
+	// state is 0 until GotConn is invoked, then 1 for new connections and 2
+	// for reused ones. It is accessed atomically.
+	state int32
+}
+
+// gotConn is the httptrace.ClientTrace GotConn hook.
+func (r *connRecorder) gotConn(info httptrace.GotConnInfo) {
+	state := int32(1)
+	if info.Reused {
+		state = 2
+	}
+	atomic.StoreInt32(&r.state, state)
+}
+
+// ConnectionReuse returns ConnectionNew or ConnectionReused once the
+// connection of the call is known, and an empty string otherwise.
+func (r *connRecorder) ConnectionReuse() string {
+	switch atomic.LoadInt32(&r.state) {
+	case 1:
+		return ConnectionNew
+	case 2:
+		return ConnectionReused
+	default:
+		return ``
+	}
+}
+
+// trace returns a shallow copy of the request, with a context reporting its
+// connection to the recorder, in addition to any existing client trace.
+func (r *connRecorder) trace(request *http.Request) *http.Request {
+	ctx := httptrace.WithClientTrace(request.Context(), &httptrace.ClientTrace{GotConn: r.gotConn})
+	return request.WithContext(ctx)
+}
@@ -0,0 +1,75 @@
+package interception
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+)
+
+// untracedTransport is a http.RoundTripper ignoring client traces.
+type untracedTransport struct{}
+
+func (untracedTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusNoContent,
+		Body:       http.NoBody,
+		Request:    request,
+	}, nil
+}
+
+func TestRoundTripper_RoundTripConnectionReuse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`hello`))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		underlying http.RoundTripper
+		want       []string
+	}{
+		{`traced`, ts.Client().Transport, []string{ConnectionNew, ConnectionReused}},
+		{`untraced`, untracedTransport{}, []string{``, ``}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var re *ReportEvent
+			d := events.NewDispatcher()
+			d.AddProviders(TopicReport, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+				return []events.Listener{func(_ context.Context, e events.Event) error {
+					re = e.(*ReportEvent)
+					return nil
+				}}
+			}))
+			rt := &RoundTripper{
+				Dispatcher: d,
+				Underlying: tt.underlying,
+			}
+			for i, want := range tt.want {
+				re = nil
+				req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+				res, err := rt.RoundTrip(req)
+				if err != nil {
+					t.Fatalf("RoundTrip() error = %v", err)
+				}
+				// The connection only returns to the pool once the body is read.
+				_, _ = io.Copy(ioutil.Discard, res.Body)
+				_ = res.Body.Close()
+				if re == nil {
+					t.Fatal("no report dispatched")
+				}
+
+				ll := Restricted
+				rl := ll.Prepare(re)
+				if rl.ConnectionReuse != want {
+					t.Errorf("call %d: ConnectionReuse = %q, want %q", i, rl.ConnectionReuse, want)
+				}
+			}
+		})
+	}
+}
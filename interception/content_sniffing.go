@@ -0,0 +1,31 @@
+package interception
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/bearer/go-agent/proxy"
+)
+
+// sniffContentType guesses the content type of a peeked body: JSON objects and
+// arrays are recognized as JSON, and other bodies are left to
+// http.DetectContentType, which recognizes text but not JSON.
+func sniffContentType(body []byte) string {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return proxy.ContentTypeJSON
+	}
+	return http.DetectContentType(body)
+}
+
+// contentType returns the content type declared in a Content-Type header or,
+// if it is missing and ContentSniffing is enabled, the one sniffed from the
+// peeked body, and whether it was sniffed.
+func (p BodyParsingProvider) contentType(header http.Header, body []byte) (string, bool) {
+	ct := header.Get(proxy.ContentTypeHeader)
+	if ct != `` || !p.ContentSniffing {
+		return ct, false
+	}
+	return sniffContentType(body), true
+}
@@ -0,0 +1,66 @@
+package interception
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bearer/go-agent/proxy"
+)
+
+func TestBodyParsingProvider_ContentSniffing(t *testing.T) {
+	tests := []struct {
+		name     string
+		sniffing bool
+		body     string
+		expected interface{}
+		sniffed  string
+	}{
+		{`disabled`, false, `{"a":1}`, BodyIsBinary, ``},
+		{`JSON object`, true, `{"a":1}`, map[string]interface{}{`a`: 1.0}, `application/json`},
+		{`JSON array`, true, ` [1, 2]`, []interface{}{1.0, 2.0}, `application/json`},
+		{`text`, true, `hello`, `hello`, `text/plain; charset=utf-8`},
+		{`invalid JSON`, true, `{"a":`, `{"a":`, `text/plain; charset=utf-8`},
+		{`binary`, true, "\x00\x01\x02", BodyIsBinary, `application/octet-stream`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := func() *BodyReadCloser {
+				return NewBodyReadCloser(ioutil.NopCloser(strings.NewReader(tt.body)), MaximumBodySize+1)
+			}
+			p := BodyParsingProvider{ContentSniffing: tt.sniffing}
+			req, _ := http.NewRequest(http.MethodPost, defaultTestURL, nil)
+			req.Body = reader()
+			res := &http.Response{Header: make(http.Header), Body: reader()}
+			be := &BodiesEvent{}
+			be.SetRequest(req).SetResponse(res)
+
+			if err := p.RequestBodyParser(context.Background(), be); err != nil {
+				t.Fatalf("RequestBodyParser error = %v", err)
+			}
+			if err := p.ResponseBodyParser(context.Background(), be); err != nil {
+				t.Fatalf("ResponseBodyParser error = %v", err)
+			}
+			if !reflect.DeepEqual(be.RequestBody, tt.expected) {
+				t.Errorf("request body = %#v, expected %#v", be.RequestBody, tt.expected)
+			}
+			if !reflect.DeepEqual(be.ResponseBody, tt.expected) {
+				t.Errorf("response body = %#v, expected %#v", be.ResponseBody, tt.expected)
+			}
+
+			re := NewReportEvent(proxy.StageBodies, nil)
+			re.BodiesEvent = be
+			ll := All
+			rl := ll.Prepare(re)
+			if rl.RequestBodySniffedContentType != tt.sniffed {
+				t.Errorf("request sniffed content type = %q, expected %q", rl.RequestBodySniffedContentType, tt.sniffed)
+			}
+			if rl.ResponseBodySniffedContentType != tt.sniffed {
+				t.Errorf("response sniffed content type = %q, expected %q", rl.ResponseBodySniffedContentType, tt.sniffed)
+			}
+		})
+	}
+}
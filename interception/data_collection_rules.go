@@ -82,3 +82,43 @@ func PrepareTriggeredRulesForReport(triggeredRules []*DataCollectionRule) []prox
 	}
 	return result
 }
+
+// capTriggeredRules bounds the number of triggered rules reported to max,
+// always keeping the rule which determined the LogLevel, if any, and otherwise
+// the first triggered rules in order. A max of 0 means no bound. It returns the
+// kept rules and the number of omitted ones.
+func capTriggeredRules(rules []*DataCollectionRule, max int, deciding *DataCollectionRule) ([]*DataCollectionRule, int) {
+	if max <= 0 || len(rules) <= max {
+		return rules, 0
+	}
+	keepDeciding := false
+	for _, rule := range rules[max:] {
+		if rule == deciding {
+			keepDeciding = true
+			break
+		}
+	}
+	kept := make([]*DataCollectionRule, 0, max)
+	for _, rule := range rules {
+		if len(kept) == max {
+			break
+		}
+		if keepDeciding && len(kept) == max-1 {
+			kept = append(kept, deciding)
+			break
+		}
+		kept = append(kept, rule)
+	}
+	return kept, len(rules) - len(kept)
+}
+
+// RulesUseParsedBodies checks whether any of the DataCollectionRule filters
+// matches on the parsed bodies, which BodyParsingProvider must then parse.
+func RulesUseParsedBodies(dcrs []*DataCollectionRule) bool {
+	for _, dcr := range dcrs {
+		if dcr != nil && filters.UsesParsedBodies(dcr.Filter) {
+			return true
+		}
+	}
+	return false
+}
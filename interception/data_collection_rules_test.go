@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/bearer/go-agent/filters"
 	"github.com/bearer/go-agent/proxy"
 )
 
@@ -136,3 +137,23 @@ func TestPrepareTriggeredRulesForReport(t *testing.T) {
 		t.Errorf("Expected:\n%#v\n\nActual:\n%#v\n", expected, reportRules)
 	}
 }
+
+func TestRulesUseParsedBodies(t *testing.T) {
+	tests := []struct {
+		name string
+		dcrs []*DataCollectionRule
+		want bool
+	}{
+		{`none`, nil, false},
+		{`without filters`, []*DataCollectionRule{nil, {}}, false},
+		{`without body filters`, []*DataCollectionRule{{Filter: &filters.DomainFilter{}}}, false},
+		{`with a body filter`, []*DataCollectionRule{{}, {Filter: &filters.RequestBodiesFilter{}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RulesUseParsedBodies(tt.dcrs); got != tt.want {
+				t.Errorf("RulesUseParsedBodies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
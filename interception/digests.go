@@ -0,0 +1,40 @@
+package interception
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+)
+
+// BodyDigestAlgorithms are the digest algorithms available to compute the
+// digests of raw bodies, by name.
+var BodyDigestAlgorithms = map[string]func() hash.Hash{
+	`md5`:    md5.New,
+	`sha1`:   sha1.New,
+	`sha256`: sha256.New,
+}
+
+// bodyDigests computes the hex-encoded digests of the raw body peeked from a
+// BodyReadCloser, for each of the algorithms.
+//
+// Digests are only computed for bodies peeked in full, as longer ones are still
+// to be read by the application when the API call is reported.
+func bodyDigests(algorithms []string, body []byte, peekErr error) map[string]string {
+	if len(algorithms) == 0 || len(body) == 0 || peekErr != io.EOF {
+		return nil
+	}
+	digests := make(map[string]string, len(algorithms))
+	for _, algorithm := range algorithms {
+		newHash, ok := BodyDigestAlgorithms[algorithm]
+		if !ok {
+			continue
+		}
+		h := newHash()
+		_, _ = h.Write(body)
+		digests[algorithm] = hex.EncodeToString(h.Sum(nil))
+	}
+	return digests
+}
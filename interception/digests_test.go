@@ -0,0 +1,72 @@
+package interception
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bearer/go-agent/proxy"
+)
+
+func TestBodyParsingProvider_Digests(t *testing.T) {
+	const body = `hello world`
+	// Reference digests of the body, as computed by md5sum, sha1sum, sha256sum.
+	reference := map[string]string{
+		`md5`:    `5eb63bbbe01eeed093cb22bb8f5acdc3`,
+		`sha1`:   `2aae6c35c94fcfb415dbe95f408b9ce91ee846ed`,
+		`sha256`: `b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9`,
+	}
+	reader := func(s string) *BodyReadCloser {
+		return NewBodyReadCloser(ioutil.NopCloser(strings.NewReader(s)), MaximumBodySize+1)
+	}
+
+	tests := []struct {
+		name     string
+		digests  []string
+		body     string
+		expected map[string]string
+	}{
+		{`none`, nil, body, nil},
+		{`md5`, []string{`md5`}, body, map[string]string{`md5`: reference[`md5`]}},
+		{`all`, []string{`md5`, `sha1`, `sha256`}, body, reference},
+		{`empty body`, []string{`md5`}, ``, nil},
+		{`too long`, []string{`md5`}, strings.Repeat(`a`, MaximumBodySize+1), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := BodyParsingProvider{Digests: tt.digests}
+			req, _ := http.NewRequest(http.MethodPost, defaultTestURL, nil)
+			req.Header.Set(proxy.ContentTypeHeader, `text/plain`)
+			req.Body = reader(tt.body)
+			res := &http.Response{Header: make(http.Header), Body: reader(tt.body)}
+			res.Header.Set(proxy.ContentTypeHeader, `text/plain`)
+			be := &BodiesEvent{}
+			be.SetRequest(req).SetResponse(res)
+
+			if err := p.RequestBodyParser(context.Background(), be); err != nil {
+				t.Fatalf("RequestBodyParser() error = %v", err)
+			}
+			if err := p.ResponseBodyParser(context.Background(), be); err != nil {
+				t.Fatalf("ResponseBodyParser() error = %v", err)
+			}
+			if !reflect.DeepEqual(be.RequestDigests, tt.expected) {
+				t.Errorf("RequestDigests = %v, expected %v", be.RequestDigests, tt.expected)
+			}
+			if !reflect.DeepEqual(be.ResponseDigests, tt.expected) {
+				t.Errorf("ResponseDigests = %v, expected %v", be.ResponseDigests, tt.expected)
+			}
+
+			// Digests are reported with the bodies.
+			re := NewReportEvent(proxy.StageBodies, nil)
+			re.BodiesEvent = be
+			ll := All
+			rl := ll.Prepare(re)
+			if !reflect.DeepEqual(rl.RequestBodyDigests, tt.expected) || !reflect.DeepEqual(rl.ResponseBodyDigests, tt.expected) {
+				t.Errorf("reported digests = %v, %v, expected %v", rl.RequestBodyDigests, rl.ResponseBodyDigests, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package interception
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+)
+
+// MaxResolvedAddresses is the maximum number of resolved addresses reported
+// for an API call.
+const MaxResolvedAddresses = 8
+
+// dnsRecorder captures the addresses resolved for an API call with the
+// httptrace.ClientTrace DNSDone hook. The hook is not invoked when no lookup is
+// needed, like on connection reuse or for IP hosts, leaving the list empty.
+type dnsRecorder struct {
+	m         sync.Mutex
+	addresses []string
+}
+
+// dnsDone is the httptrace.ClientTrace DNSDone hook. It may be invoked from
+// the transport dialing goroutines, even after the call completed.
+func (r *dnsRecorder) dnsDone(info httptrace.DNSDoneInfo) {
+	if info.Err != nil {
+		return
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	for _, addr := range info.Addrs {
+		if len(r.addresses) >= MaxResolvedAddresses {
+			return
+		}
+		r.addresses = append(r.addresses, addr.IP.String())
+	}
+}
+
+// Addresses returns a copy of the addresses resolved so far.
+func (r *dnsRecorder) Addresses() []string {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if len(r.addresses) == 0 {
+		return nil
+	}
+	return append([]string(nil), r.addresses...)
+}
+
+// trace returns a shallow copy of the request, with a context reporting its
+// DNS lookups to the recorder, in addition to any existing client trace.
+func (r *dnsRecorder) trace(request *http.Request) *http.Request {
+	ctx := httptrace.WithClientTrace(request.Context(), &httptrace.ClientTrace{DNSDone: r.dnsDone})
+	return request.WithContext(ctx)
+}
@@ -0,0 +1,99 @@
+package interception
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+)
+
+func TestDNSRecorder_dnsDone(t *testing.T) {
+	addrs := make([]net.IPAddr, MaxResolvedAddresses+2)
+	for i := range addrs {
+		addrs[i] = net.IPAddr{IP: net.IPv4(10, 0, 0, byte(i))}
+	}
+	tests := []struct {
+		name     string
+		info     httptrace.DNSDoneInfo
+		expected int
+	}{
+		{`no lookup`, httptrace.DNSDoneInfo{}, 0},
+		{`failed lookup`, httptrace.DNSDoneInfo{Addrs: addrs[:1], Err: context.Canceled}, 0},
+		{`happy`, httptrace.DNSDoneInfo{Addrs: addrs[:2]}, 2},
+		{`capped`, httptrace.DNSDoneInfo{Addrs: addrs}, MaxResolvedAddresses},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &dnsRecorder{}
+			r.dnsDone(tt.info)
+			actual := r.Addresses()
+			if len(actual) != tt.expected {
+				t.Fatalf("Addresses() = %v, expected %d addresses", actual, tt.expected)
+			}
+			for i, addr := range actual {
+				if addr != addrs[i].IP.String() {
+					t.Errorf("Addresses()[%d] = %s, expected %s", i, addr, addrs[i].IP)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundTripper_RoundTripResolvedAddresses(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`ok`))
+	}))
+	defer ts.Close()
+	// Use a host name, as IP hosts do not need a lookup.
+	u := strings.Replace(ts.URL, `127.0.0.1`, `localhost`, 1)
+
+	var re *ReportEvent
+	d := events.NewDispatcher()
+	d.AddProviders(TopicReport, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+		return []events.Listener{func(_ context.Context, e events.Event) error {
+			re = e.(*ReportEvent)
+			return nil
+		}}
+	}))
+	rt := &RoundTripper{
+		Dispatcher: d,
+		Underlying: &http.Transport{},
+	}
+
+	call := func() {
+		re = nil
+		req, _ := http.NewRequest(http.MethodGet, u, nil)
+		res, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip() error = %v", err)
+		}
+		_, _ = ioutil.ReadAll(res.Body)
+		_ = res.Body.Close()
+		if re == nil {
+			t.Fatal("no report dispatched")
+		}
+	}
+
+	// A fresh connection needs a lookup.
+	call()
+	if len(re.ResolvedAddresses) == 0 {
+		t.Fatal("no resolved addresses on a fresh connection")
+	}
+	for _, addr := range re.ResolvedAddresses {
+		if ip := net.ParseIP(addr); ip == nil || !ip.IsLoopback() {
+			t.Errorf("resolved address %s, expected a loopback address", addr)
+		}
+	}
+
+	// A reused connection does not.
+	call()
+	if len(re.ResolvedAddresses) != 0 {
+		t.Errorf("resolved addresses %v on a reused connection, expected none", re.ResolvedAddresses)
+	}
+}
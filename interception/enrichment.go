@@ -0,0 +1,69 @@
+package interception
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bearer/go-agent/events"
+)
+
+// EnrichmentProvider is an events.ListenerProvider returning a listener which
+// copies response headers, like the edge or region headers added by CDNs, to
+// report custom fields. Unlike the headers, which are only reported at the All
+// level, these fields are reported at the Restricted level and above.
+type EnrichmentProvider struct {
+	// Headers maps the names of the copied response headers to the names of
+	// the report fields receiving their values.
+	Headers map[string]string
+}
+
+// Enrich sets the ReportEvent EnrichedFields from the configured response
+// headers present in the response. Multiple values of a header are joined
+// with commas.
+func (p EnrichmentProvider) Enrich(_ context.Context, e events.Event) error {
+	re, ok := e.(*ReportEvent)
+	if !ok {
+		return fmt.Errorf(`topic ReportEvent, got %T`, e)
+	}
+	response := re.Response()
+	if response == nil {
+		return nil
+	}
+	for header, field := range p.Headers {
+		values := response.Header.Values(header)
+		if len(values) == 0 {
+			continue
+		}
+		if re.EnrichedFields == nil {
+			re.EnrichedFields = make(map[string]interface{}, len(p.Headers))
+		}
+		re.EnrichedFields[field] = strings.Join(values, `, `)
+	}
+	return nil
+}
+
+// Listeners implements the events.ListenerProvider interface.
+func (p EnrichmentProvider) Listeners(e events.Event) []events.Listener {
+	if e.Topic() != TopicReport || len(p.Headers) == 0 {
+		return nil
+	}
+
+	return []events.Listener{p.Enrich}
+}
+
+// customFields returns the report custom fields of a call: those attached to
+// the request context by WithReportFields, and the EnrichedFields they do not
+// override.
+func customFields(re *ReportEvent) map[string]interface{} {
+	fields := reportFields(re.Request())
+	for k, v := range re.EnrichedFields {
+		if fields == nil {
+			fields = make(map[string]interface{}, len(re.EnrichedFields))
+		}
+		if _, ok := fields[k]; !ok {
+			fields[k] = v
+		}
+	}
+	return fields
+}
@@ -0,0 +1,58 @@
+package interception
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/proxy"
+)
+
+func TestEnrichmentProvider_Enrich(t *testing.T) {
+	headers := map[string]string{`CF-Ray`: `edge`, `X-Served-By`: `region`}
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		headers  http.Header
+		response bool
+		expected map[string]interface{}
+	}{
+		{`enriched`, context.Background(), http.Header{
+			`Cf-Ray`:      {`7d1f-CDG`},
+			`X-Served-By`: {`cache-par1`, `cache-fra2`},
+			`X-Other`:     {`other`},
+		}, true, map[string]interface{}{`edge`: `7d1f-CDG`, `region`: `cache-par1, cache-fra2`}},
+		{`partial`, context.Background(), http.Header{`Cf-Ray`: {`7d1f-CDG`}}, true,
+			map[string]interface{}{`edge`: `7d1f-CDG`}},
+		{`no headers`, context.Background(), http.Header{}, true, nil},
+		{`no response`, context.Background(), nil, false, nil},
+		{`application fields win`, WithReportFields(context.Background(), map[string]interface{}{`edge`: `app`, `tier`: 2}),
+			http.Header{`Cf-Ray`: {`7d1f-CDG`}, `X-Served-By`: {`cache-par1`}}, true,
+			map[string]interface{}{`edge`: `app`, `region`: `cache-par1`, `tier`: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(tt.ctx, http.MethodGet, `https://example.com`, nil)
+			re := NewReportEvent(proxy.StageBodies, nil)
+			re.SetRequest(req)
+			if tt.response {
+				re.SetResponse(&http.Response{StatusCode: http.StatusOK, Header: tt.headers, Request: req})
+			}
+			p := EnrichmentProvider{Headers: headers}
+			if err := p.Enrich(context.Background(), re); err != nil {
+				t.Fatalf(`unexpected error: %v`, err)
+			}
+
+			ll := Restricted
+			if actual := ll.Prepare(re).CustomFields; !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("reported CustomFields = %#v, expected %#v", actual, tt.expected)
+			}
+		})
+	}
+
+	if err := (EnrichmentProvider{}).Enrich(context.Background(), events.NewEvent(`bad`)); err == nil {
+		t.Error(`expected error on non-ReportEvent`)
+	}
+}
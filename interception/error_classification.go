@@ -0,0 +1,45 @@
+package interception
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bearer/go-agent/events"
+)
+
+// ErrorClassifier maps an error to a report error code. It returns false for
+// the errors it does not classify.
+type ErrorClassifier func(err error) (code string, ok bool)
+
+// ErrorClassificationProvider is an events.ListenerProvider returning a
+// listener which sets the error code of failed calls using a custom
+// ErrorClassifier, e.g. for the error types of custom transports. Calls whose
+// errors it does not classify are reported with the error message as code.
+type ErrorClassificationProvider struct {
+	Classifier ErrorClassifier
+}
+
+// ClassifyError sets the ReportEvent ErrorCode if the Classifier classifies
+// the call error.
+func (p ErrorClassificationProvider) ClassifyError(_ context.Context, e events.Event) error {
+	re, ok := e.(*ReportEvent)
+	if !ok {
+		return fmt.Errorf(`topic ReportEvent, got %T`, e)
+	}
+	if re.Error == nil {
+		return nil
+	}
+	if code, ok := p.Classifier(re.Error); ok && code != `` {
+		re.ErrorCode = code
+	}
+	return nil
+}
+
+// Listeners implements the events.ListenerProvider interface.
+func (p ErrorClassificationProvider) Listeners(e events.Event) []events.Listener {
+	if e.Topic() != TopicReport || p.Classifier == nil {
+		return nil
+	}
+
+	return []events.Listener{p.ClassifyError}
+}
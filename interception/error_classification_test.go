@@ -0,0 +1,83 @@
+package interception
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+)
+
+// quotaError is a custom transport error type.
+type quotaError struct {
+	bucket string
+}
+
+func (e quotaError) Error() string {
+	return fmt.Sprintf("quota exceeded for bucket %s", e.bucket)
+}
+
+// failingTransport is a http.RoundTripper failing all calls with its err.
+type failingTransport struct {
+	err error
+}
+
+func (t failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
+}
+
+func TestErrorClassificationProvider_ClassifyError(t *testing.T) {
+	classifier := func(err error) (string, bool) {
+		var qe quotaError
+		if errors.As(err, &qe) {
+			return `QUOTA_EXCEEDED`, true
+		}
+		return ``, false
+	}
+	tests := []struct {
+		name        string
+		err         error
+		wantCode    string
+		wantMessage string
+	}{
+		{`custom error`, quotaError{bucket: `b1`}, `QUOTA_EXCEEDED`, `quota exceeded for bucket b1`},
+		{`wrapped custom error`, fmt.Errorf("sending: %w", quotaError{bucket: `b2`}), `QUOTA_EXCEEDED`,
+			`sending: quota exceeded for bucket b2`},
+		{`other error`, errors.New(`oops`), `oops`, `oops`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var re *ReportEvent
+			d := events.NewDispatcher()
+			d.AddProviders(TopicReport, ErrorClassificationProvider{Classifier: classifier},
+				events.ListenerProviderFunc(func(events.Event) []events.Listener {
+					return []events.Listener{func(_ context.Context, e events.Event) error {
+						re = e.(*ReportEvent)
+						return nil
+					}}
+				}))
+			rt := &RoundTripper{
+				Dispatcher: d,
+				Underlying: failingTransport{err: tt.err},
+			}
+			req, _ := http.NewRequest(http.MethodGet, defaultTestURL, nil)
+			if _, err := rt.RoundTrip(req); err == nil {
+				t.Fatal("RoundTrip() succeeded, expected an error")
+			}
+			if re == nil {
+				t.Fatal("no report dispatched")
+			}
+
+			ll := Restricted
+			rl := ll.Prepare(re)
+			if rl.ErrorCode != tt.wantCode {
+				t.Errorf("ErrorCode = %q, want %q", rl.ErrorCode, tt.wantCode)
+			}
+			if rl.ErrorFullMessage != tt.wantMessage {
+				t.Errorf("ErrorFullMessage = %q, want %q", rl.ErrorFullMessage, tt.wantMessage)
+			}
+		})
+	}
+}
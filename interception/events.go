@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/filters"
 	"github.com/bearer/go-agent/proxy"
 )
 
@@ -47,6 +50,26 @@ const (
 type APIEventConfig struct {
 	IsActive bool
 	LogLevel
+
+	// LogLevelRule is the last triggered DataCollectionRule setting the
+	// LogLevel, if any.
+	LogLevelRule *DataCollectionRule
+
+	// NoBodies prevents the report of the bodies, even at the All LogLevel.
+	NoBodies bool
+
+	// BodyPreview is the maximum size of the sanitized body previews reported at
+	// the Restricted LogLevel. 0 means no previews.
+	BodyPreview int
+
+	// MaxReportedRules is the maximum number of triggered DataCollectionRule
+	// objects listed in reports. 0 means no limit.
+	MaxReportedRules int
+
+	// Anomalies are the suspicious characteristics found in the call request
+	// by the AnomalyProvider. As the configuration is shared by the events of
+	// all stages, they are available until the report.
+	Anomalies []string
 }
 
 // APIEvent is the type common to all API call lifecycle events.
@@ -146,6 +169,39 @@ type BodiesEvent struct {
 	apiEvent
 	RequestBody, ResponseBody interface{}
 	RequestSha, ResponseSha   string
+
+	// RequestDigests and ResponseDigests are the digests of the raw bodies,
+	// by algorithm name.
+	RequestDigests, ResponseDigests map[string]string
+
+	// RequestBodyLength and ResponseBodyLength are the lengths of the peeked
+	// raw bodies, so they do not exceed the peek limit.
+	RequestBodyLength, ResponseBodyLength int
+
+	// RequestBodyLines and ResponseBodyLines are the line counts of the text/*
+	// bodies peeked in full.
+	RequestBodyLines, ResponseBodyLines int
+
+	// RequestFormFields and ResponseFormFields are the numbers of values in the
+	// parsed form bodies, and RequestFormSize and ResponseFormSize the total
+	// sizes of their decoded names and values.
+	RequestFormFields, RequestFormSize   int
+	ResponseFormFields, ResponseFormSize int
+
+	// RequestSniffedContentType and ResponseSniffedContentType are the content
+	// types sniffed from the bodies sent without a Content-Type header, with
+	// their charset if detected, when content sniffing is enabled.
+	RequestSniffedContentType, ResponseSniffedContentType string
+}
+
+// ParsedBodies implements the filters.ParsedBodiesEvent interface.
+func (be *BodiesEvent) ParsedBodies() (request, response interface{}) {
+	return be.RequestBody, be.ResponseBody
+}
+
+// BodyLengths implements the filters.BodyLengthsEvent interface.
+func (be *BodiesEvent) BodyLengths() (request, response int) {
+	return be.RequestBodyLength, be.ResponseBodyLength
 }
 
 // ReportEvent is emitted to publish a call proxy.ReportLog.
@@ -153,6 +209,173 @@ type ReportEvent struct {
 	*BodiesEvent
 	proxy.Stage
 	T0, T1 time.Time
+
+	// RetryCount is the number of retries reported by the underlying transport.
+	RetryCount int
+
+	// FromCache is true if the response was served from a cache.
+	FromCache bool
+
+	// StreamID is the HTTP/2 stream ID of the call, if the underlying
+	// transport reported it with RecordStreamID.
+	StreamID uint32
+
+	// CallerStack is the stack of the code issuing the call, only captured on
+	// errors when the RoundTripper CaptureCallerStack is set.
+	CallerStack string
+
+	// ErrorCode is the error code set by the ErrorClassificationProvider. When
+	// empty, the error message is used as code.
+	ErrorCode string
+
+	// EnrichedFields are the custom report fields set by the EnrichmentProvider
+	// and the JWTClaimsProvider.
+	EnrichedFields map[string]interface{}
+
+	// RequestContentType and ResponseContentType are the body content types
+	// as received, captured before sanitization may filter the headers.
+	RequestContentType, ResponseContentType string
+
+	// MethodOverride is the method requested by a MethodOverrideHeaders header,
+	// captured before sanitization may filter the headers.
+	MethodOverride string
+
+	// RequestBodyBytesRead is the number of request body bytes read by the
+	// underlying transport, and RequestBodyPartial is true if it did not read
+	// the body until its end, e.g. because the server replied early.
+	RequestBodyBytesRead int64
+	RequestBodyPartial   bool
+
+	// RequestChunked and ResponseChunked are true if the request or response
+	// used the chunked transfer encoding.
+	RequestChunked, ResponseChunked bool
+
+	// ResponseStreaming is true if the response was a stream, like Server-Sent
+	// Events, whose body was not buffered.
+	ResponseStreaming bool
+
+	// RequestLengthMismatch and ResponseLengthMismatch are true if the size of
+	// the request or response body differs from its declared Content-Length,
+	// which may reveal truncated bodies or request smuggling attempts.
+	RequestLengthMismatch, ResponseLengthMismatch bool
+
+	// RequestTrailers are the request trailers, captured once the request body
+	// was fully sent.
+	RequestTrailers http.Header
+
+	// ResolvedAddresses are the addresses, up to MaxResolvedAddresses, the
+	// request host resolved to, if the call needed a lookup.
+	ResolvedAddresses []string
+
+	// ConnectionReuse is ConnectionNew or ConnectionReused if the underlying
+	// transport traced the connection of the call, and empty otherwise.
+	ConnectionReuse string
+
+	prepared *proxy.ReportLog
+}
+
+// reportLog returns the ReportLog prepared at the event LogLevel. It is only
+// prepared once, by the first listener needing it, which therefore needs to be
+// placed after the listeners modifying the event, like the SanitizationProvider.
+func (re *ReportEvent) reportLog() proxy.ReportLog {
+	if re.prepared == nil {
+		ll := re.Config().LogLevel
+		rl := ll.Prepare(re)
+		re.prepared = &rl
+	}
+	return *re.prepared
+}
+
+// captureContentTypes sets the content types from the request and response
+// headers.
+func (re *ReportEvent) captureContentTypes() {
+	if request := re.Request(); request != nil {
+		re.RequestContentType = request.Header.Get(proxy.ContentTypeHeader)
+	}
+	if response := re.Response(); response != nil {
+		re.ResponseContentType = response.Header.Get(proxy.ContentTypeHeader)
+	}
+}
+
+// isChunked checks whether a transfer encoding list includes "chunked".
+func isChunked(transferEncoding []string) bool {
+	for _, encoding := range transferEncoding {
+		if strings.EqualFold(encoding, `chunked`) {
+			return true
+		}
+	}
+	return false
+}
+
+// captureTransferEncodings sets the chunked transfer flags from the request
+// and response.
+func (re *ReportEvent) captureTransferEncodings() {
+	if request := re.Request(); request != nil {
+		re.RequestChunked = isChunked(request.TransferEncoding)
+	}
+	if response := re.Response(); response != nil {
+		re.ResponseChunked = isChunked(response.TransferEncoding)
+	}
+}
+
+// captureRequestBodyTransmission sets the request body transmission details
+// from the request BodyReadCloser, if any.
+func (re *ReportEvent) captureRequestBodyTransmission() {
+	request := re.Request()
+	if request == nil {
+		return
+	}
+	brc, ok := request.Body.(*BodyReadCloser)
+	if !ok {
+		return
+	}
+	re.RequestBodyBytesRead = brc.BytesRead()
+	re.RequestBodyPartial = !brc.FullyRead()
+}
+
+// captureLengthMismatches compares the sizes of the bodies to their declared
+// Content-Length. Bodies without a declared length, like chunked ones, are not
+// compared, nor are those not read, or peeked, until their end.
+func (re *ReportEvent) captureLengthMismatches() {
+	request := re.Request()
+	if request == nil {
+		return
+	}
+	// For client requests, a zero ContentLength with a body means unknown.
+	if brc, ok := request.Body.(*BodyReadCloser); ok && request.ContentLength > 0 && brc.FullyRead() {
+		re.RequestLengthMismatch = brc.BytesRead() != request.ContentLength
+	}
+
+	response := re.Response()
+	if response == nil || response.ContentLength < 0 || !bodyAllowed(request.Method, response.StatusCode) {
+		return
+	}
+	if brc, ok := response.Body.(*BodyReadCloser); ok {
+		if n, complete := brc.fullyPeeked(); complete {
+			re.ResponseLengthMismatch = int64(n) != response.ContentLength
+		}
+	}
+}
+
+// bodyAllowed checks whether a response may have a body, per RFC7230 sec. 3.3.3:
+// responses to HEAD requests, and 1xx, 204, and 304 responses declare the
+// length of the body they would have, without having one.
+func bodyAllowed(method string, status int) bool {
+	return method != http.MethodHead && status >= http.StatusOK &&
+		status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// captureRequestTrailers copies the request trailers, if any, unless the
+// request body was not fully sent, as their values are only final afterwards.
+func (re *ReportEvent) captureRequestTrailers() {
+	request := re.Request()
+	if request == nil || len(request.Trailer) == 0 {
+		return
+	}
+	if brc, ok := request.Body.(*BodyReadCloser); ok && !brc.FullyRead() {
+		return
+	}
+	re.RequestTrailers = request.Trailer.Clone()
 }
 
 // Topic is part of the Event interface.
@@ -176,10 +399,96 @@ func NewReportEvent(stage proxy.Stage, err error) *ReportEvent {
 	}
 }
 
+// RulePrecedence defines which of the data collection rules matching a call
+// determines its configuration.
+type RulePrecedence int
+
+const (
+	// LastWins applies all the matching rules in order, so the last one setting
+	// the log level or the active state wins. It is the default.
+	LastWins RulePrecedence = iota
+
+	// FirstWins only applies the first matching rule, skipping the evaluation
+	// of the following ones.
+	FirstWins
+)
+
+// IsValid checks whether a RulePrecedence is one of the defined modes.
+func (p RulePrecedence) IsValid() bool {
+	return p >= LastWins && p <= FirstWins
+}
+
 // DCRProvider is an events.Listener provider returning listeners based on the
 // active data collection rules.
 type DCRProvider struct {
 	DCRs []*DataCollectionRule
+
+	// Precedence selects which of the matching DCRs applies, by default the
+	// last one.
+	Precedence RulePrecedence
+
+	// MaxLogLevel, if not nil, caps the LogLevel applied by the DCRs, e.g. to
+	// ensure no bodies or headers are reported regardless of the rules.
+	MaxLogLevel *LogLevel
+
+	// BodyCaptureDenyHosts, if not empty, lists the hosts for which bodies are
+	// never reported, regardless of the LogLevel applied by the DCRs.
+	BodyCaptureDenyHosts []*regexp.Regexp
+
+	// BodyCaptureStatuses, if not empty, are the ranges of response status
+	// codes for which the All LogLevel applies: it is lowered to Restricted for
+	// calls whose response status is in none of them, e.g. to only report the
+	// bodies of failed calls.
+	BodyCaptureStatuses []filters.RangeMatcher
+
+	// BodyPreview, if positive, is the maximum size of the sanitized body
+	// previews reported at the Restricted LogLevel, capped to MaxBodyPreview.
+	BodyPreview int
+
+	// MaxReportedRules, if positive, is the maximum number of triggered rules
+	// listed in reports. The rule which determined the LogLevel is always kept.
+	MaxReportedRules int
+
+	// Explain, if not nil, receives an explanation of the filter match of each
+	// triggered rule having a filter, for debugging purposes.
+	Explain func(rule *DataCollectionRule, explanation string)
+}
+
+// isBodyCaptureDenied checks whether the event host is denied body capture.
+func (p *DCRProvider) isBodyCaptureDenied(e events.Event) bool {
+	if len(p.BodyCaptureDenyHosts) == 0 {
+		return false
+	}
+	request := e.Request()
+	if request == nil || request.URL == nil {
+		return false
+	}
+	host := request.URL.Hostname()
+	for _, re := range p.BodyCaptureDenyHosts {
+		if re.MatchString(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBodyCaptureStatusDenied checks whether the event response status is in
+// none of the BodyCaptureStatuses. Before the response is received, or on
+// connection errors, the status is unknown and not denied.
+func (p *DCRProvider) isBodyCaptureStatusDenied(e events.Event) bool {
+	if len(p.BodyCaptureStatuses) == 0 {
+		return false
+	}
+	response := e.Response()
+	if response == nil {
+		return false
+	}
+	for _, statuses := range p.BodyCaptureStatuses {
+		if statuses.Contains(response.StatusCode) {
+			return false
+		}
+	}
+	return true
 }
 
 func (p *DCRProvider) onActiveTopics(_ context.Context, e events.Event) error {
@@ -197,17 +506,40 @@ func (p *DCRProvider) onActiveTopics(_ context.Context, e events.Event) error {
 	for _, dcr := range p.DCRs {
 		if dcr.Filter == nil || dcr.MatchesCall(e) {
 			triggeredDataCollectionRules = append(triggeredDataCollectionRules, dcr)
+			if p.Explain != nil && dcr.Filter != nil {
+				p.Explain(dcr, filters.ExplainCall(dcr.Filter, e))
+			}
 
 			if dcr.LogLevel != nil {
 				eventConfig.LogLevel = *dcr.LogLevel
+				eventConfig.LogLevelRule = dcr
 			}
 
 			if dcr.IsActive != nil {
 				eventConfig.IsActive = *dcr.IsActive
 			}
+
+			if p.Precedence == FirstWins {
+				break
+			}
 		}
 	}
 
+	if p.MaxLogLevel != nil && eventConfig.LogLevel > *p.MaxLogLevel {
+		eventConfig.LogLevel = *p.MaxLogLevel
+	}
+	if eventConfig.LogLevel > Restricted && p.isBodyCaptureStatusDenied(e) {
+		eventConfig.LogLevel = Restricted
+	}
+	if p.isBodyCaptureDenied(e) {
+		eventConfig.NoBodies = true
+	}
+	eventConfig.BodyPreview = p.BodyPreview
+	if eventConfig.BodyPreview > MaxBodyPreview {
+		eventConfig.BodyPreview = MaxBodyPreview
+	}
+	eventConfig.MaxReportedRules = p.MaxReportedRules
+
 	ae.SetTriggeredDataCollectionRules(triggeredDataCollectionRules)
 	ae.SetConfig(eventConfig)
 
@@ -234,9 +566,7 @@ func (p ProxyProvider) onReport(_ context.Context, e events.Event) error {
 	if !ok {
 		return fmt.Errorf("topic %s used with event type %T", e.Topic(), e)
 	}
-	ll := re.Config().LogLevel
-	rl := ll.Prepare(re)
-	p.Send(rl)
+	p.Send(re.reportLog())
 	return nil
 }
 
@@ -2,10 +2,12 @@ package interception
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"reflect"
+	"regexp"
 	"testing"
 
 	"github.com/bearer/go-agent/filters"
@@ -170,6 +172,408 @@ func TestDCRProvider_onActiveTopics(t *testing.T) {
 	}
 }
 
+func TestDCRProvider_LogLevelRule(t *testing.T) {
+	restricted, all, detected := Restricted, All, Detected
+	ruleAll := &DataCollectionRule{LogLevel: &all, FilterHash: `all`, Signature: `sig-all`}
+	ruleRestricted := &DataCollectionRule{LogLevel: &restricted, FilterHash: `restricted`, Signature: `sig-restricted`}
+	ruleDetected := &DataCollectionRule{
+		Filter:     &filters.HTTPMethodFilter{StringMatcher: filters.NewStringMatcher(`GET`, false)},
+		LogLevel:   &detected,
+		FilterHash: `detected`,
+		Signature:  `sig-detected`,
+	}
+	ruleNoLevel := &DataCollectionRule{FilterHash: `no level`, Signature: `sig-no-level`}
+
+	tests := []struct {
+		name             string
+		dcrs             []*DataCollectionRule
+		expectedLogLevel LogLevel
+		expectedRule     *proxy.ReportDataCollectionRule
+	}{
+		{`no rules`, nil, Detected, nil},
+		{`no level`, []*DataCollectionRule{ruleNoLevel}, Detected, nil},
+		{`last matching`, []*DataCollectionRule{ruleAll, ruleRestricted, ruleDetected, ruleNoLevel}, Restricted,
+			&proxy.ReportDataCollectionRule{FilterHash: `restricted`, Signature: `sig-restricted`}},
+		{`reverse order`, []*DataCollectionRule{ruleRestricted, ruleAll}, All,
+			&proxy.ReportDataCollectionRule{FilterHash: `all`, Signature: `sig-all`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, `https://example.com/path`, nil)
+			re := NewReportEvent(proxy.StageRequest, nil)
+			re.SetRequest(req)
+
+			p := DCRProvider{DCRs: tt.dcrs}
+			if err := p.onActiveTopics(context.Background(), re); err != nil {
+				t.Fatalf("onActiveTopics() error = %v", err)
+			}
+			ll := re.Config().LogLevel
+			if ll != tt.expectedLogLevel {
+				t.Errorf("LogLevel = %v, want %v", ll, tt.expectedLogLevel)
+			}
+			// Use the Restricted level to check the rule even for lower levels.
+			restricted := Restricted
+			rl := restricted.Prepare(re)
+			if !reflect.DeepEqual(rl.LogLevelRule, tt.expectedRule) {
+				t.Errorf("LogLevelRule = %+v, want %+v", rl.LogLevelRule, tt.expectedRule)
+			}
+		})
+	}
+}
+
+func TestDCRProvider_Precedence(t *testing.T) {
+	restricted, all := Restricted, All
+	inactive := false
+	// Both rules match POST requests, with different log levels.
+	rulePost := &DataCollectionRule{
+		Filter:     &filters.HTTPMethodFilter{StringMatcher: filters.NewStringMatcher(`POST`, false)},
+		LogLevel:   &restricted,
+		FilterHash: `post`,
+	}
+	ruleHost := &DataCollectionRule{
+		Filter:     &filters.DomainFilter{RegexpMatcher: filters.NewRegexpMatcher(regexp.MustCompile(`^example\.com$`))},
+		LogLevel:   &all,
+		FilterHash: `host`,
+	}
+	ruleInactive := &DataCollectionRule{IsActive: &inactive, FilterHash: `inactive`}
+
+	tests := []struct {
+		name             string
+		precedence       RulePrecedence
+		dcrs             []*DataCollectionRule
+		expectedLogLevel LogLevel
+		expectedActive   bool
+		expectedRules    int
+	}{
+		{`last wins`, LastWins, []*DataCollectionRule{rulePost, ruleHost, ruleInactive}, All, false, 3},
+		{`first wins`, FirstWins, []*DataCollectionRule{rulePost, ruleHost, ruleInactive}, Restricted, true, 1},
+		{`first wins reversed`, FirstWins, []*DataCollectionRule{ruleHost, rulePost}, All, true, 1},
+		{`first wins skips unmatched`, FirstWins, []*DataCollectionRule{
+			{Filter: &filters.HTTPMethodFilter{StringMatcher: filters.NewStringMatcher(`GET`, false)}, LogLevel: &all},
+			rulePost, ruleHost,
+		}, Restricted, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, `https://example.com/path`, nil)
+			re := NewReportEvent(proxy.StageRequest, nil)
+			re.SetRequest(req)
+
+			p := DCRProvider{DCRs: tt.dcrs, Precedence: tt.precedence}
+			if err := p.onActiveTopics(context.Background(), re); err != nil {
+				t.Fatalf("onActiveTopics() error = %v", err)
+			}
+			config := re.Config()
+			if config.LogLevel != tt.expectedLogLevel {
+				t.Errorf("LogLevel = %v, want %v", config.LogLevel, tt.expectedLogLevel)
+			}
+			if config.IsActive != tt.expectedActive {
+				t.Errorf("IsActive = %t, want %t", config.IsActive, tt.expectedActive)
+			}
+			if actual := len(re.TriggeredDataCollectionRules()); actual != tt.expectedRules {
+				t.Errorf("triggered %d rules, want %d", actual, tt.expectedRules)
+			}
+		})
+	}
+}
+
+func TestDCRProvider_MaxReportedRules(t *testing.T) {
+	all := All
+	dcrs := make([]*DataCollectionRule, 10)
+	for i := range dcrs {
+		dcrs[i] = &DataCollectionRule{FilterHash: fmt.Sprintf(`rule-%d`, i)}
+	}
+	deciding := &DataCollectionRule{LogLevel: &all, FilterHash: `deciding`}
+
+	tests := []struct {
+		name            string
+		dcrs            []*DataCollectionRule
+		max             int
+		expectedHashes  []string
+		expectedOmitted int
+	}{
+		{`no limit`, dcrs[:3], 0, []string{`rule-0`, `rule-1`, `rule-2`}, 0},
+		{`under limit`, dcrs[:3], 3, []string{`rule-0`, `rule-1`, `rule-2`}, 0},
+		{`over limit`, dcrs, 3, []string{`rule-0`, `rule-1`, `rule-2`}, 7},
+		{`deciding rule kept`, append(dcrs[:len(dcrs):len(dcrs)], deciding), 3,
+			[]string{`rule-0`, `rule-1`, `deciding`}, 8},
+		{`deciding rule within limit`, append([]*DataCollectionRule{deciding}, dcrs...), 3,
+			[]string{`deciding`, `rule-0`, `rule-1`}, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, `https://example.com/path`, nil)
+			re := NewReportEvent(proxy.StageRequest, nil)
+			re.SetRequest(req)
+
+			p := DCRProvider{DCRs: tt.dcrs, MaxReportedRules: tt.max}
+			if err := p.onActiveTopics(context.Background(), re); err != nil {
+				t.Fatalf("onActiveTopics() error = %v", err)
+			}
+			restricted := Restricted
+			rl := restricted.Prepare(re)
+			var hashes []string
+			for _, rule := range *rl.ActiveDataCollectionRules {
+				hashes = append(hashes, rule.FilterHash)
+			}
+			if !reflect.DeepEqual(hashes, tt.expectedHashes) {
+				t.Errorf("ActiveDataCollectionRules = %v, want %v", hashes, tt.expectedHashes)
+			}
+			if rl.OmittedDataCollectionRules != tt.expectedOmitted {
+				t.Errorf("OmittedDataCollectionRules = %d, want %d", rl.OmittedDataCollectionRules, tt.expectedOmitted)
+			}
+		})
+	}
+}
+
+func TestDCRProvider_Explain(t *testing.T) {
+	matching := &DataCollectionRule{
+		Filter:     &filters.DomainFilter{RegexpMatcher: filters.NewRegexpMatcher(regexp.MustCompile(`example`))},
+		FilterHash: `matching`,
+	}
+	other := &DataCollectionRule{
+		Filter:     &filters.DomainFilter{RegexpMatcher: filters.NewRegexpMatcher(regexp.MustCompile(`other`))},
+		FilterHash: `other`,
+	}
+	unfiltered := &DataCollectionRule{FilterHash: `unfiltered`}
+
+	explanations := map[string]string{}
+	p := DCRProvider{
+		DCRs: []*DataCollectionRule{matching, other, unfiltered},
+		Explain: func(rule *DataCollectionRule, explanation string) {
+			explanations[rule.FilterHash] = explanation
+		},
+	}
+	req, _ := http.NewRequest(http.MethodGet, `https://example.com/path`, nil)
+	re := NewReportEvent(proxy.StageRequest, nil)
+	re.SetRequest(req)
+	if err := p.onActiveTopics(context.Background(), re); err != nil {
+		t.Fatalf("onActiveTopics() error = %v", err)
+	}
+	expected := map[string]string{`matching`: `/example/ matched "example" in value "example.com"`}
+	if !reflect.DeepEqual(explanations, expected) {
+		t.Errorf("explanations = %v, expected %v", explanations, expected)
+	}
+}
+
+func TestDCRProvider_MaxLogLevel(t *testing.T) {
+	all, restricted, detected := All, Restricted, Detected
+	allRule := &DataCollectionRule{LogLevel: &all}
+
+	tests := []struct {
+		name          string
+		max           *LogLevel
+		expectedLevel LogLevel
+	}{
+		{`uncapped`, nil, All},
+		{`capped restricted`, &restricted, Restricted},
+		{`capped detected`, &detected, Detected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, `https://example.com/path`, nil)
+			req.Header.Set(`X-Request`, `value`)
+			res := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{`X-Response`: []string{`value`}},
+				Request:    req,
+			}
+			re := NewReportEvent(proxy.StageBodies, nil)
+			re.SetRequest(req).SetResponse(res)
+			re.RequestBody = map[string]interface{}{`request`: `body`}
+			re.ResponseBody = map[string]interface{}{`response`: `body`}
+
+			p := DCRProvider{DCRs: []*DataCollectionRule{allRule}, MaxLogLevel: tt.max}
+			if err := p.onActiveTopics(context.Background(), re); err != nil {
+				t.Fatalf("onActiveTopics() error = %v", err)
+			}
+			ll := re.Config().LogLevel
+			if ll != tt.expectedLevel {
+				t.Fatalf("LogLevel = %v, want %v", ll, tt.expectedLevel)
+			}
+
+			rl := ll.Prepare(re)
+			hasDetails := rl.RequestHeaders != nil || rl.ResponseHeaders != nil ||
+				rl.RequestBody != `` || rl.ResponseBody != ``
+			if hasDetails != (tt.expectedLevel == All) {
+				t.Errorf("headers and bodies reported: %t, expected %t", hasDetails, tt.expectedLevel == All)
+			}
+		})
+	}
+}
+
+func TestDCRProvider_BodyCaptureDenyHosts(t *testing.T) {
+	all := All
+	allRule := &DataCollectionRule{LogLevel: &all}
+	denyHosts := []*regexp.Regexp{regexp.MustCompile(`^payments\.`)}
+
+	tests := []struct {
+		name       string
+		url        string
+		wantBodies bool
+	}{
+		{`allowed host`, `https://example.com/path`, true},
+		{`denied host`, `https://payments.example.com/path`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, tt.url, nil)
+			req.Header.Set(`X-Request`, `value`)
+			res := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{`X-Response`: []string{`value`}},
+				Request:    req,
+			}
+			re := NewReportEvent(proxy.StageBodies, nil)
+			re.SetRequest(req).SetResponse(res)
+			re.RequestBody = map[string]interface{}{`request`: `body`}
+			re.ResponseBody = map[string]interface{}{`response`: `body`}
+			re.RequestSha, re.ResponseSha = `request sha`, `response sha`
+
+			p := DCRProvider{DCRs: []*DataCollectionRule{allRule}, BodyCaptureDenyHosts: denyHosts}
+			if err := p.onActiveTopics(context.Background(), re); err != nil {
+				t.Fatalf("onActiveTopics() error = %v", err)
+			}
+			ll := re.Config().LogLevel
+			rl := ll.Prepare(re)
+
+			if rl.RequestHeaders.Get(`X-Request`) == `` || rl.ResponseHeaders.Get(`X-Response`) == `` {
+				t.Errorf("headers not reported: %v, %v", rl.RequestHeaders, rl.ResponseHeaders)
+			}
+			if rl.StatusCode != http.StatusOK {
+				t.Errorf("StatusCode = %d, want %d", rl.StatusCode, http.StatusOK)
+			}
+			hasBodies := rl.RequestBody != `` || rl.ResponseBody != `` ||
+				rl.RequestBodyPayloadSHA != `` || rl.ResponseBodyPayloadSHA != ``
+			if hasBodies != tt.wantBodies {
+				t.Errorf("bodies reported: %t, expected %t", hasBodies, tt.wantBodies)
+			}
+		})
+	}
+}
+
+func TestDCRProvider_BodyCaptureStatuses(t *testing.T) {
+	all := All
+	allRule := &DataCollectionRule{LogLevel: &all}
+	errorStatuses := []filters.RangeMatcher{filters.NewRangeMatcher().From(400)}
+
+	tests := []struct {
+		name       string
+		statuses   []filters.RangeMatcher
+		status     int
+		wantLevel  LogLevel
+		wantBodies bool
+	}{
+		{`unrestricted success`, nil, http.StatusOK, All, true},
+		{`error captured`, errorStatuses, http.StatusInternalServerError, All, true},
+		{`success not captured`, errorStatuses, http.StatusOK, Restricted, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, `https://example.com/path`, nil)
+			res := &http.Response{StatusCode: tt.status, Header: http.Header{}, Request: req}
+			re := NewReportEvent(proxy.StageBodies, nil)
+			re.SetRequest(req).SetResponse(res)
+			re.RequestBody = map[string]interface{}{`request`: `body`}
+			re.ResponseBody = map[string]interface{}{`response`: `body`}
+			re.RequestSha, re.ResponseSha = `request sha`, `response sha`
+
+			p := DCRProvider{DCRs: []*DataCollectionRule{allRule}, BodyCaptureStatuses: tt.statuses}
+			if err := p.onActiveTopics(context.Background(), re); err != nil {
+				t.Fatalf("onActiveTopics() error = %v", err)
+			}
+			ll := re.Config().LogLevel
+			if ll != tt.wantLevel {
+				t.Errorf("LogLevel = %v, want %v", ll, tt.wantLevel)
+			}
+			rl := ll.Prepare(re)
+			if rl.StatusCode != tt.status {
+				t.Errorf("StatusCode = %d, want %d", rl.StatusCode, tt.status)
+			}
+			hasBodies := rl.RequestBody != `` || rl.ResponseBody != ``
+			if hasBodies != tt.wantBodies {
+				t.Errorf("bodies reported: %t, expected %t", hasBodies, tt.wantBodies)
+			}
+		})
+	}
+}
+
+func TestDCRProvider_BodyPreview(t *testing.T) {
+	restricted, all := Restricted, All
+	sanitizer := SanitizationProvider{
+		SensitiveKeys:    []*regexp.Regexp{DefaultSensitiveKeys},
+		SensitiveRegexps: []*regexp.Regexp{DefaultSensitiveData},
+	}
+
+	tests := []struct {
+		name            string
+		logLevel        *LogLevel
+		preview         int
+		expectedRequest string
+	}{
+		{`disabled`, &restricted, 0, ``},
+		{`restricted`, &restricted, 64, `{"email":"[FILTERED]","name":"Jane","password":"[FILTERED]"}`},
+		{`restricted capped`, &restricted, 16, `{"email":"[FILTE`},
+		{`all`, &all, 64, ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, `https://example.com/path`, nil)
+			res := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Request: req}
+			re := NewReportEvent(proxy.StageBodies, nil)
+			re.SetRequest(req).SetResponse(res)
+			re.RequestBody = map[string]interface{}{
+				`email`:    `jane@example.com`,
+				`name`:     `Jane`,
+				`password`: `hunter2`,
+			}
+			re.ResponseBody = `ok`
+
+			p := DCRProvider{DCRs: []*DataCollectionRule{{LogLevel: tt.logLevel}}, BodyPreview: tt.preview}
+			ctx := context.Background()
+			if err := p.onActiveTopics(ctx, re); err != nil {
+				t.Fatalf("onActiveTopics() error = %v", err)
+			}
+			if err := sanitizer.SanitizeRequestBody(ctx, re); err != nil {
+				t.Fatalf("SanitizeRequestBody() error = %v", err)
+			}
+			ll := re.Config().LogLevel
+			rl := ll.Prepare(re)
+
+			if rl.RequestBodyPreview != tt.expectedRequest {
+				t.Errorf("RequestBodyPreview = %q, want %q", rl.RequestBodyPreview, tt.expectedRequest)
+			}
+			if len(rl.RequestBodyPreview) > tt.preview {
+				t.Errorf("RequestBodyPreview length %d exceeds %d", len(rl.RequestBodyPreview), tt.preview)
+			}
+			if ll == Restricted && tt.preview > 0 && rl.ResponseBodyPreview != `ok` {
+				t.Errorf("ResponseBodyPreview = %q, want %q", rl.ResponseBodyPreview, `ok`)
+			}
+		})
+	}
+}
+
+func Test_bodyPreview(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     interface{}
+		max      int
+		expected string
+	}{
+		{`nil`, nil, 8, ``},
+		{`short`, `abc`, 8, `abc`},
+		{`truncated`, `abcdefghij`, 8, `abcdefgh`},
+		{`multi-byte boundary`, `aé`, 2, `a`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := bodyPreview(http.Header{}, tt.body, tt.max); actual != tt.expected {
+				t.Errorf("bodyPreview() = %q, want %q", actual, tt.expected)
+			}
+		})
+	}
+}
+
 func TestNewConnectEvent(t *testing.T) {
 	tests := []struct {
 		name string
@@ -0,0 +1,52 @@
+package interception
+
+// HashLimiter bounds the number of concurrent shape hash computations, which
+// are CPU-heavy, to avoid saturating the host application cores under a burst
+// of large bodies.
+//
+// A nil HashLimiter does not bound computations.
+type HashLimiter struct {
+	tokens chan struct{}
+
+	// SkipOnOverflow makes computations beyond the limit return an empty SHA
+	// instead of waiting for a running computation to complete.
+	SkipOnOverflow bool
+
+	// hash computes the hashes, and is only overridden in tests.
+	hash func(j interface{}, warn func(error)) string
+}
+
+// NewHashLimiter builds a HashLimiter allowing at most limit concurrent
+// computations, which must be strictly positive.
+func NewHashLimiter(limit int, skipOnOverflow bool) *HashLimiter {
+	return &HashLimiter{
+		tokens:         make(chan struct{}, limit),
+		SkipOnOverflow: skipOnOverflow,
+		hash:           toSha,
+	}
+}
+
+// ToSha is a bounded version of the ToSha function. If the limit is reached and
+// the HashLimiter skips on overflow, it returns an empty string.
+func (l *HashLimiter) ToSha(j interface{}) string {
+	return l.toSha(j, nil)
+}
+
+// toSha is ToSha, passing marshaling failures to the warn function if it is
+// not nil.
+func (l *HashLimiter) toSha(j interface{}, warn func(error)) string {
+	if l == nil {
+		return toSha(j, warn)
+	}
+	if l.SkipOnOverflow {
+		select {
+		case l.tokens <- struct{}{}:
+		default:
+			return ``
+		}
+	} else {
+		l.tokens <- struct{}{}
+	}
+	defer func() { <-l.tokens }()
+	return l.hash(j, warn)
+}
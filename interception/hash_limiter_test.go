@@ -0,0 +1,121 @@
+package interception
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHashLimiter_ToSha(t *testing.T) {
+	body := map[string]interface{}{`id`: 1.0, `name`: `item`}
+	expected := ToSha(body)
+	tests := []struct {
+		name string
+		l    *HashLimiter
+	}{
+		{`nil`, nil},
+		{`waiting`, NewHashLimiter(1, false)},
+		{`skipping`, NewHashLimiter(1, true)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := tt.l.ToSha(body); actual != expected {
+				t.Errorf("ToSha() = %s, expected %s", actual, expected)
+			}
+		})
+	}
+}
+
+func TestHashLimiter_Concurrency(t *testing.T) {
+	const limit, calls = 3, 50
+	var active, maxActive int32
+	l := NewHashLimiter(limit, false)
+	l.hash = func(interface{}, func(error)) string {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			prev := atomic.LoadInt32(&maxActive)
+			if n <= prev || atomic.CompareAndSwapInt32(&maxActive, prev, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&active, -1)
+		return `sha`
+	}
+
+	var wg sync.WaitGroup
+	var hashed int32
+	wg.Add(calls)
+	for i := 0; i < calls; i++ {
+		go func() {
+			defer wg.Done()
+			if l.ToSha(nil) == `sha` {
+				atomic.AddInt32(&hashed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxActive > limit {
+		t.Errorf("%d concurrent computations, limit is %d", maxActive, limit)
+	}
+	if hashed != calls {
+		t.Errorf("%d calls hashed, expected all %d to wait for their turn", hashed, calls)
+	}
+}
+
+func TestHashLimiter_SkipOnOverflow(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	l := NewHashLimiter(1, true)
+	l.hash = func(interface{}, func(error)) string {
+		close(started)
+		<-release
+		return `sha`
+	}
+
+	done := make(chan string)
+	go func() { done <- l.ToSha(nil) }()
+	<-started
+
+	if actual := l.ToSha(nil); actual != `` {
+		t.Errorf("ToSha() on overflow = %s, expected an empty SHA", actual)
+	}
+	close(release)
+	if actual := <-done; actual != `sha` {
+		t.Errorf("ToSha() within limit = %s, expected sha", actual)
+	}
+}
+
+func BenchmarkHashLimiter_Burst(b *testing.B) {
+	items := make([]interface{}, 1000)
+	for i := range items {
+		items[i] = map[string]interface{}{`id`: float64(i), `name`: `item`, `tags`: []interface{}{`a`, `b`}}
+	}
+	body := map[string]interface{}{`items`: items}
+
+	limit := runtime.NumCPU() / 2
+	if limit < 1 {
+		limit = 1
+	}
+	benchmarks := []struct {
+		name string
+		l    *HashLimiter
+	}{
+		{`unbounded`, nil},
+		{fmt.Sprintf(`waiting-%d`, limit), NewHashLimiter(limit, false)},
+		{fmt.Sprintf(`skipping-%d`, limit), NewHashLimiter(limit, true)},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.SetParallelism(4)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					bm.l.ToSha(body)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package interception
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultMaxHosts is the default maximum number of distinct hosts tracked by a
+// HostRegistry.
+const DefaultMaxHosts = 100
+
+// HostRegistry is a bounded set of hosts, shared by the features maintaining
+// per-host state, like the LatencyProvider and the ShapeDiscoveryProvider, to
+// bound their total memory use when the application calls many hosts.
+//
+// When a host beyond MaxHosts is tracked, the least recently used host is
+// evicted, and the features registered with OnEvict drop their state for it,
+// so frequently called hosts are retained.
+type HostRegistry struct {
+	MaxHosts int
+
+	mu       sync.Mutex
+	order    *list.List // Most recently used first.
+	elements map[string]*list.Element
+	onEvict  []func(host string)
+}
+
+// NewHostRegistry builds a HostRegistry tracking at most maxHosts hosts.
+func NewHostRegistry(maxHosts int) *HostRegistry {
+	return &HostRegistry{
+		MaxHosts: maxHosts,
+		order:    list.New(),
+		elements: make(map[string]*list.Element),
+	}
+}
+
+// OnEvict registers a function called with each host evicted from the registry.
+//
+// It is called without the registry lock held, so it may lock the state of the
+// feature, but features must not hold their own lock while calling Touch.
+func (r *HostRegistry) OnEvict(f func(host string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onEvict = append(r.onEvict, f)
+}
+
+// Touch marks a host as the most recently used one, tracking it if needed, and
+// evicts the least recently used hosts beyond MaxHosts.
+func (r *HostRegistry) Touch(host string) {
+	r.mu.Lock()
+	if element, ok := r.elements[host]; ok {
+		r.order.MoveToFront(element)
+		r.mu.Unlock()
+		return
+	}
+	r.elements[host] = r.order.PushFront(host)
+	var evicted []string
+	for r.order.Len() > r.MaxHosts && r.order.Len() > 1 {
+		host := r.order.Remove(r.order.Back()).(string)
+		delete(r.elements, host)
+		evicted = append(evicted, host)
+	}
+	onEvict := r.onEvict
+	r.mu.Unlock()
+
+	for _, host := range evicted {
+		for _, f := range onEvict {
+			f(host)
+		}
+	}
+}
+
+// Contains checks whether a host is currently tracked.
+func (r *HostRegistry) Contains(host string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.elements[host]
+	return ok
+}
+
+// Hosts returns the tracked hosts, from the most to the least recently used.
+func (r *HostRegistry) Hosts() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hosts := make([]string, 0, r.order.Len())
+	for element := r.order.Front(); element != nil; element = element.Next() {
+		hosts = append(hosts, element.Value.(string))
+	}
+	return hosts
+}
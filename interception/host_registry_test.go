@@ -0,0 +1,87 @@
+package interception
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/proxy"
+)
+
+func TestHostRegistry_Touch(t *testing.T) {
+	r := NewHostRegistry(3)
+	var evicted []string
+	r.OnEvict(func(host string) { evicted = append(evicted, host) })
+
+	// hot is called between each other host, so it is never the least recently used.
+	for i := 0; i < 10; i++ {
+		r.Touch(`hot.example.com`)
+		r.Touch(fmt.Sprintf("cold%d.example.com", i))
+	}
+
+	if actual := r.Hosts(); len(actual) != 3 {
+		t.Fatalf("Hosts() = %v, expected 3 hosts", actual)
+	}
+	if !r.Contains(`hot.example.com`) {
+		t.Errorf("hot host was evicted: %v", r.Hosts())
+	}
+	expected := []string{`cold9.example.com`, `hot.example.com`, `cold8.example.com`}
+	if actual := r.Hosts(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Hosts() = %v, expected %v", actual, expected)
+	}
+	if len(evicted) != 8 {
+		t.Fatalf("evicted %d hosts, expected 8: %v", len(evicted), evicted)
+	}
+	for i, host := range evicted {
+		if expected := fmt.Sprintf("cold%d.example.com", i); host != expected {
+			t.Errorf("evicted[%d] = %s, expected %s", i, host, expected)
+		}
+	}
+}
+
+func TestHostRegistry_Shared(t *testing.T) {
+	r := NewHostRegistry(2)
+	latency := NewLatencyProvider(DefaultLatencyHosts)
+	latency.TrackHosts(r)
+	shapes := NewShapeDiscoveryProvider(1)
+	shapes.TrackHosts(r)
+
+	discover := func(host string) error {
+		request, _ := http.NewRequest(http.MethodGet, `https://`+host+`/a`, nil)
+		re := NewReportEvent(proxy.StageBodies, nil)
+		re.SetRequest(request)
+		return shapes.DiscoverShape(context.Background(), re)
+	}
+
+	for _, host := range []string{`a.example.com`, `b.example.com`, `a.example.com`, `c.example.com`} {
+		latency.Record(host, time.Millisecond)
+	}
+	stats := latency.Stats()
+	if len(stats) != 2 || stats[`a.example.com`].Count != 2 || stats[`c.example.com`].Count != 1 {
+		t.Errorf("stats = %v, expected a.example.com twice and c.example.com once", stats)
+	}
+
+	// Reports for a.example.com are discovered, then evicted by the latency of
+	// other hosts, so the next one is reported again.
+	if err := discover(`a.example.com`); err != nil {
+		t.Fatalf("first report stopped: %v", err)
+	}
+	if err := discover(`a.example.com`); err != events.DispatchStopRequest {
+		t.Fatalf("second report not stopped: %v", err)
+	}
+	latency.Record(`d.example.com`, time.Millisecond)
+	latency.Record(`e.example.com`, time.Millisecond)
+	if err := discover(`a.example.com`); err != nil {
+		t.Errorf("report after eviction stopped: %v", err)
+	}
+	if _, ok := latency.Stats()[`a.example.com`]; ok {
+		t.Errorf("latency of the host kept after its eviction: %v", latency.Stats())
+	}
+	if actual := len(r.Hosts()); actual != 2 {
+		t.Errorf("registry tracks %d hosts, expected 2", actual)
+	}
+}
@@ -0,0 +1,85 @@
+package interception
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bearer/go-agent/events"
+)
+
+// JWTClaimFieldPrefix prefixes the names of the report custom fields set by
+// the JWTClaimsProvider, as in "jwt.iss".
+const JWTClaimFieldPrefix = `jwt.`
+
+// JWTClaimsProvider is an events.ListenerProvider returning a listener which
+// copies allowlisted claims of the JWT bearer tokens sent in Authorization
+// headers, like "iss", "aud", or "sub", to report custom fields. The tokens
+// are decoded, not verified, and the Authorization header is still sanitized.
+//
+// As custom fields are reported at the Restricted level and above without
+// sanitization, the Claims should not carry sensitive data.
+type JWTClaimsProvider struct {
+	Claims []string
+}
+
+// jwtPayload decodes the payload of a JWT bearer token in an Authorization
+// header, returning nil if the header does not carry a well-formed JWT.
+func jwtPayload(authorization string) map[string]interface{} {
+	const scheme = `bearer `
+	if len(authorization) <= len(scheme) || !strings.EqualFold(authorization[:len(scheme)], scheme) {
+		return nil
+	}
+	parts := strings.Split(strings.TrimSpace(authorization[len(scheme):]), `.`)
+	if len(parts) != 3 {
+		return nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], `=`))
+	if err != nil {
+		return nil
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil
+	}
+	return payload
+}
+
+// ExtractClaims sets ReportEvent EnrichedFields from the Claims present in the
+// JWT bearer token of the request, if any.
+func (p JWTClaimsProvider) ExtractClaims(_ context.Context, e events.Event) error {
+	re, ok := e.(*ReportEvent)
+	if !ok {
+		return fmt.Errorf(`topic ReportEvent, got %T`, e)
+	}
+	request := re.Request()
+	if request == nil {
+		return nil
+	}
+	payload := jwtPayload(request.Header.Get(`Authorization`))
+	if payload == nil {
+		return nil
+	}
+	for _, claim := range p.Claims {
+		value, ok := payload[claim]
+		if !ok {
+			continue
+		}
+		if re.EnrichedFields == nil {
+			re.EnrichedFields = make(map[string]interface{}, len(p.Claims))
+		}
+		re.EnrichedFields[JWTClaimFieldPrefix+claim] = value
+	}
+	return nil
+}
+
+// Listeners implements the events.ListenerProvider interface.
+func (p JWTClaimsProvider) Listeners(e events.Event) []events.Listener {
+	if e.Topic() != TopicReport || len(p.Claims) == 0 {
+		return nil
+	}
+
+	return []events.Listener{p.ExtractClaims}
+}
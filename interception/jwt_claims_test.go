@@ -0,0 +1,68 @@
+package interception
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/proxy"
+)
+
+func testJWT(payload string) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	return encode([]byte(`{"alg":"HS256","typ":"JWT"}`)) + `.` + encode([]byte(payload)) + `.signature`
+}
+
+func TestJWTClaimsProvider_ExtractClaims(t *testing.T) {
+	token := testJWT(`{"iss":"https://auth.example.com","aud":["api","admin"],"sub":"user-42","email":"jane@example.com"}`)
+	tests := []struct {
+		name          string
+		authorization string
+		expected      map[string]interface{}
+	}{
+		{`bearer JWT`, `Bearer ` + token, map[string]interface{}{
+			`jwt.iss`: `https://auth.example.com`,
+			`jwt.aud`: []interface{}{`api`, `admin`},
+		}},
+		{`lower case scheme`, `bearer ` + token, map[string]interface{}{
+			`jwt.iss`: `https://auth.example.com`,
+			`jwt.aud`: []interface{}{`api`, `admin`},
+		}},
+		{`missing claims`, `Bearer ` + testJWT(`{"sub":"user-42"}`), nil},
+		{`basic auth`, `Basic dXNlcjpwYXNz`, nil},
+		{`opaque token`, `Bearer opaque-token`, nil},
+		{`invalid payload`, `Bearer a.b!.c`, nil},
+		{`no header`, ``, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, `https://example.com`, nil)
+			if tt.authorization != `` {
+				req.Header.Set(`Authorization`, tt.authorization)
+			}
+			re := NewReportEvent(proxy.StageBodies, nil)
+			re.SetRequest(req)
+			re.SetResponse(&http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Request: req})
+			d := events.NewDispatcher().AddProviders(TopicReport,
+				JWTClaimsProvider{Claims: []string{`iss`, `aud`}},
+				SanitizationProvider{SensitiveKeys: []*regexp.Regexp{DefaultSensitiveKeys}},
+			)
+			if _, err := d.Dispatch(context.Background(), re); err != nil {
+				t.Fatalf("Dispatch() error = %v", err)
+			}
+
+			ll := All
+			rl := ll.Prepare(re)
+			if !reflect.DeepEqual(rl.CustomFields, tt.expected) {
+				t.Errorf("CustomFields = %v, want %v", rl.CustomFields, tt.expected)
+			}
+			if tt.authorization != `` && rl.RequestHeaders.Get(`Authorization`) != Filtered {
+				t.Errorf("Authorization header = %s, want %s", rl.RequestHeaders.Get(`Authorization`), Filtered)
+			}
+		})
+	}
+}
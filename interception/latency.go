@@ -0,0 +1,190 @@
+package interception
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/bearer/go-agent/events"
+)
+
+const (
+	// DefaultLatencyHosts is the default maximum number of hosts for which a
+	// LatencyProvider maintains distinct histograms.
+	DefaultLatencyHosts = 100
+
+	// OtherHosts is the key under which a LatencyProvider accumulates the
+	// latencies of the hosts beyond its maximum number of hosts.
+	OtherHosts = `(other)`
+
+	// latencyMin is the upper bound of the first histogram bucket.
+	latencyMin = 100 * time.Microsecond
+	// latencyGrowth is the ratio between the bounds of consecutive buckets,
+	// which is also the maximum relative error of the percentiles.
+	latencyGrowth = 1.1
+	// latencyBuckets covers latencies up to about 5 minutes, longer ones being
+	// counted in the last bucket.
+	latencyBuckets = 160
+)
+
+// LatencySummary summarizes the latencies of the API calls to a host.
+type LatencySummary struct {
+	Count         int
+	P50, P95, P99 time.Duration
+}
+
+// latencyHistogram is a histogram of latencies on exponential buckets.
+type latencyHistogram struct {
+	count   int
+	max     time.Duration
+	buckets [latencyBuckets]int
+}
+
+func latencyBucket(d time.Duration) int {
+	if d <= latencyMin {
+		return 0
+	}
+	i := int(math.Ceil(math.Log(float64(d)/float64(latencyMin)) / math.Log(latencyGrowth)))
+	if i >= latencyBuckets {
+		i = latencyBuckets - 1
+	}
+	return i
+}
+
+func latencyBucketBound(i int) time.Duration {
+	return time.Duration(float64(latencyMin) * math.Pow(latencyGrowth, float64(i)))
+}
+
+func (h *latencyHistogram) record(d time.Duration) {
+	h.count++
+	if d > h.max {
+		h.max = d
+	}
+	h.buckets[latencyBucket(d)]++
+}
+
+// percentile returns the upper bound of the bucket containing the q quantile,
+// capped to the maximum latency recorded.
+func (h *latencyHistogram) percentile(q float64) time.Duration {
+	rank := int(math.Ceil(q * float64(h.count)))
+	if rank < 1 {
+		rank = 1
+	}
+	seen := 0
+	for i, n := range h.buckets {
+		seen += n
+		if seen >= rank {
+			if bound := latencyBucketBound(i); bound < h.max {
+				return bound
+			}
+			break
+		}
+	}
+	return h.max
+}
+
+func (h *latencyHistogram) summary() LatencySummary {
+	return LatencySummary{
+		Count: h.count,
+		P50:   h.percentile(0.50),
+		P95:   h.percentile(0.95),
+		P99:   h.percentile(0.99),
+	}
+}
+
+// LatencyProvider is an events.ListenerProvider returning a listener which
+// maintains in-memory latency histograms of the API calls, per host, allowing
+// local latency statistics without a metrics backend.
+//
+// To bound its memory use, it only maintains distinct histograms for the first
+// MaxHosts hosts, later hosts being accumulated under OtherHosts, unless it
+// tracks hosts in a HostRegistry, which evicts the least recently used ones.
+//
+// Its listener needs to be placed before any listener stopping the dispatch of
+// reports, like the SamplingProvider, to record all calls.
+type LatencyProvider struct {
+	MaxHosts int
+	Hosts    *HostRegistry
+
+	mu    sync.Mutex
+	hosts map[string]*latencyHistogram
+}
+
+// NewLatencyProvider builds a LatencyProvider maintaining at most maxHosts
+// distinct histograms.
+func NewLatencyProvider(maxHosts int) *LatencyProvider {
+	return &LatencyProvider{
+		MaxHosts: maxHosts,
+		hosts:    make(map[string]*latencyHistogram),
+	}
+}
+
+// TrackHosts bounds the hosts with distinct histograms by a HostRegistry,
+// possibly shared with other features, instead of MaxHosts.
+func (p *LatencyProvider) TrackHosts(registry *HostRegistry) {
+	p.Hosts = registry
+	registry.OnEvict(p.evict)
+}
+
+// evict drops the histogram of a host evicted from the HostRegistry.
+func (p *LatencyProvider) evict(host string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.hosts, host)
+}
+
+// Record adds a latency for the host.
+func (p *LatencyProvider) Record(host string, d time.Duration) {
+	if p.Hosts != nil {
+		p.Hosts.Touch(host)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h, ok := p.hosts[host]
+	if !ok {
+		if p.Hosts == nil && len(p.hosts) >= p.MaxHosts {
+			host = OtherHosts
+		}
+		if h, ok = p.hosts[host]; !ok {
+			h = &latencyHistogram{}
+			p.hosts[host] = h
+		}
+	}
+	h.record(d)
+}
+
+// RecordLatency records the latency of the API call in a report, if it
+// received a response.
+func (p *LatencyProvider) RecordLatency(_ context.Context, e events.Event) error {
+	re, ok := e.(*ReportEvent)
+	if !ok {
+		return fmt.Errorf("topic %s used with event type %T", e.Topic(), e)
+	}
+	request := re.Request()
+	if request == nil || request.URL == nil || re.Response() == nil {
+		return nil
+	}
+	p.Record(request.URL.Hostname(), re.T1.Sub(re.T0))
+	return nil
+}
+
+// Stats returns the LatencySummary of each host.
+func (p *LatencyProvider) Stats() map[string]LatencySummary {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make(map[string]LatencySummary, len(p.hosts))
+	for host, h := range p.hosts {
+		stats[host] = h.summary()
+	}
+	return stats
+}
+
+// Listeners implements the events.ListenerProvider interface.
+func (p *LatencyProvider) Listeners(e events.Event) []events.Listener {
+	if e.Topic() != TopicReport {
+		return nil
+	}
+	return []events.Listener{p.RecordLatency}
+}
@@ -0,0 +1,87 @@
+package interception
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bearer/go-agent/proxy"
+)
+
+func TestLatencyProvider_Stats(t *testing.T) {
+	p := NewLatencyProvider(DefaultLatencyHosts)
+	// 1ms to 100ms, uniformly distributed.
+	for i := 1; i <= 100; i++ {
+		p.Record(`example.com`, time.Duration(i)*time.Millisecond)
+	}
+
+	stats := p.Stats()
+	actual, ok := stats[`example.com`]
+	if !ok {
+		t.Fatalf("no stats for host in %v", stats)
+	}
+	if actual.Count != 100 {
+		t.Errorf("Count = %d, expected 100", actual.Count)
+	}
+	tests := []struct {
+		name     string
+		actual   time.Duration
+		expected time.Duration
+	}{
+		{`p50`, actual.P50, 50 * time.Millisecond},
+		{`p95`, actual.P95, 95 * time.Millisecond},
+		{`p99`, actual.P99, 99 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Percentiles may be overestimated by the bucket growth ratio.
+			if tt.actual < tt.expected || float64(tt.actual) > float64(tt.expected)*latencyGrowth {
+				t.Errorf("%s = %v, expected about %v", tt.name, tt.actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLatencyProvider_MaxHosts(t *testing.T) {
+	p := NewLatencyProvider(2)
+	for _, host := range []string{`a.example.com`, `b.example.com`, `c.example.com`, `d.example.com`, `a.example.com`} {
+		p.Record(host, time.Millisecond)
+	}
+
+	stats := p.Stats()
+	expected := map[string]int{`a.example.com`: 2, `b.example.com`: 1, OtherHosts: 2}
+	if len(stats) != len(expected) {
+		t.Fatalf("got stats for %d hosts, expected %d: %v", len(stats), len(expected), stats)
+	}
+	for host, count := range expected {
+		if stats[host].Count != count {
+			t.Errorf("Count for %s = %d, expected %d", host, stats[host].Count, count)
+		}
+	}
+}
+
+func TestLatencyProvider_RecordLatency(t *testing.T) {
+	p := NewLatencyProvider(DefaultLatencyHosts)
+	request, _ := http.NewRequest(http.MethodGet, `https://example.com:8443/v1/items`, nil)
+
+	re := NewReportEvent(proxy.StageBodies, nil)
+	re.SetRequest(request).SetResponse(&http.Response{StatusCode: http.StatusOK})
+	re.T0 = time.Now()
+	re.T1 = re.T0.Add(20 * time.Millisecond)
+	if err := p.RecordLatency(context.Background(), re); err != nil {
+		t.Fatalf("RecordLatency() error = %v", err)
+	}
+
+	// Calls without a response are not recorded.
+	failed := NewReportEvent(proxy.StageConnect, nil)
+	failed.SetRequest(request)
+	if err := p.RecordLatency(context.Background(), failed); err != nil {
+		t.Fatalf("RecordLatency() error = %v", err)
+	}
+
+	actual := p.Stats()[`example.com`]
+	if actual.Count != 1 || actual.P50 != 20*time.Millisecond {
+		t.Errorf("stats = %+v, expected a single 20ms call", actual)
+	}
+}
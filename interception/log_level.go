@@ -8,7 +8,9 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/bearer/go-agent/filters"
 	"github.com/bearer/go-agent/proxy"
 )
 
@@ -16,17 +18,25 @@ import (
 type ContextKey string
 
 const (
-	// BodyTooLong is the replacement string for bodies beyond MaximumBodySize.
-	BodyTooLong = `(omitted due to size)`
+	// BodyTooLong is the replacement string for bodies beyond the maximum body
+	// size, which is MaximumBodySize unless configured lower.
+	BodyTooLong = filters.BodyTooLong
 
 	// BodyIsBinary is the replacement string for unparseable bodies.
-	BodyIsBinary = `(not showing binary data)`
+	BodyIsBinary = filters.BodyIsBinary
+
+	// BodyIsStream is the replacement string for the bodies of streaming
+	// responses, like Server-Sent Events, which are not buffered.
+	BodyIsStream = filters.BodyIsStream
 
 	// BodyUndecodable is the replacement string for bodies which were expected to be parsable but failed decoding.
-	BodyUndecodable = `(could not decode data)`
+	BodyUndecodable = filters.BodyUndecodable
 
 	// MaximumBodySize is the largest resBody size to store whole.
 	MaximumBodySize = 1 << 20
+
+	// MaxBodyPreview is the largest size of body previews.
+	MaxBodyPreview = 1 << 10
 )
 
 // ParsableContentType is a regexp defining the types to attempt to parse.
@@ -38,6 +48,11 @@ var StringContentType = regexp.MustCompile(`(?i)(text|xml)`)
 // JSONContentType is a regexp defining the content types to handle as JSON.
 var JSONContentType = regexp.MustCompile(`(?i)json`)
 
+// EventStreamContentType is a regexp defining the content types of streaming
+// responses, whose bodies are not buffered unless RoundTripper.BufferStreams
+// is set.
+var EventStreamContentType = regexp.MustCompile(`(?i)^\s*text/event-stream\b`)
+
 // FormContentType is a regexp definint the content types to handle as traditional web forms.
 var FormContentType = regexp.MustCompile(`(?i)x-www-form-urlencoded`)
 
@@ -103,26 +118,69 @@ func (ll *LogLevel) addDetectedInfo(rl *proxy.ReportLog, re *ReportEvent) {
 func (ll *LogLevel) addRestrictedInfo(rl *proxy.ReportLog, re *ReportEvent) {
 	request := re.Request()
 	response := re.Response()
-	triggeredRules := PrepareTriggeredRulesForReport(re.TriggeredDataCollectionRules())
+	config := re.Config()
+	triggered, omitted := re.TriggeredDataCollectionRules(), 0
+	if config != nil {
+		triggered, omitted = capTriggeredRules(triggered, config.MaxReportedRules, config.LogLevelRule)
+	}
+	triggeredRules := PrepareTriggeredRulesForReport(triggered)
 	u := request.URL
 
 	err := re.Error
 	var errorCode, errorMessage string
 	if err != nil {
-		errorCode = err.Error()
-		errorMessage = errorCode
+		errorMessage = err.Error()
+		errorCode = errorMessage
+		if re.ErrorCode != `` {
+			errorCode = re.ErrorCode
+		}
+		if re.CallerStack != `` {
+			errorMessage += "\n\ncalled from:\n" + re.CallerStack
+		}
 	}
 
 	rl.StartedAt = int(re.T0.UnixNano() / 1E6)
 	rl.EndedAt = int(re.T1.UnixNano() / 1E6)
 	rl.Stage = string(re.Stage)
 	rl.ActiveDataCollectionRules = &triggeredRules
+	rl.OmittedDataCollectionRules = omitted
+	if config != nil && config.LogLevelRule != nil {
+		rules := PrepareTriggeredRulesForReport([]*DataCollectionRule{config.LogLevelRule})
+		rl.LogLevelRule = &rules[0]
+	}
 	rl.Path = u.Path
 	rl.Method = request.Method
+	rl.MethodOverride = re.MethodOverride
 	rl.URL = u.String()
 	if response != nil {
 		rl.StatusCode = response.StatusCode
+		// Transports following redirects return the response to another request.
+		if final := response.Request; final != nil && final.URL != nil && final.URL.String() != rl.URL {
+			rl.FinalURL = final.URL.String()
+		}
+	}
+	rl.RetryCount = re.RetryCount
+	rl.FromCache = re.FromCache
+	rl.StreamID = int(re.StreamID)
+	rl.CustomFields = customFields(re)
+	if config := re.Config(); config != nil {
+		rl.Anomalies = config.Anomalies
 	}
+	rl.ResolvedAddresses = re.ResolvedAddresses
+	rl.ConnectionReuse = re.ConnectionReuse
+	rl.ProxyChain = proxyChain(request, response)
+	rl.RequestBodyBytesRead = int(re.RequestBodyBytesRead)
+	rl.RequestBodyPartial = re.RequestBodyPartial
+	rl.RequestChunked = re.RequestChunked
+	rl.RequestBodyLines = re.RequestBodyLines
+	rl.ResponseBodyLines = re.ResponseBodyLines
+	rl.RequestFormFields, rl.RequestFormSize = re.RequestFormFields, re.RequestFormSize
+	rl.ResponseFormFields, rl.ResponseFormSize = re.ResponseFormFields, re.ResponseFormSize
+	rl.ResponseChunked = re.ResponseChunked
+	rl.ResponseStreaming = re.ResponseStreaming
+	rl.RequestBodyContentType = re.RequestContentType
+	rl.RequestLengthMismatch = re.RequestLengthMismatch
+	rl.ResponseLengthMismatch = re.ResponseLengthMismatch
 	rl.ErrorCode = errorCode
 	rl.ErrorFullMessage = errorMessage
 
@@ -137,11 +195,18 @@ func (ll *LogLevel) addRestrictedInfo(rl *proxy.ReportLog, re *ReportEvent) {
 func (ll *LogLevel) addAllInfo(rl *proxy.ReportLog, re *ReportEvent) {
 	request, response := re.Request(), re.Response()
 
+	noBodies := re.Config() != nil && re.Config().NoBodies
+
 	rl.RequestHeaders = request.Header
-	rl.RequestBodyPayloadSHA = re.RequestSha
-	rl.RequestBody = serializeBody(rl.RequestHeaders, re.RequestBody)
-	if re.RequestBody != nil && rl.RequestBody == `` {
-		rl.RequestBody = `(no body)`
+	rl.RequestTrailers = re.RequestTrailers
+	rl.RequestBodySniffedContentType = re.RequestSniffedContentType
+	if !noBodies {
+		rl.RequestBodyPayloadSHA = re.RequestSha
+		rl.RequestBodyDigests = re.RequestDigests
+		rl.RequestBody = serializeBody(rl.RequestHeaders, re.RequestBody)
+		if re.RequestBody != nil && rl.RequestBody == `` {
+			rl.RequestBody = `(no body)`
+		}
 	}
 
 	if response == nil {
@@ -149,13 +214,45 @@ func (ll *LogLevel) addAllInfo(rl *proxy.ReportLog, re *ReportEvent) {
 	}
 
 	rl.ResponseHeaders = response.Header
-	rl.ResponseBodyPayloadSHA = re.ResponseSha
-	rl.ResponseBody = serializeBody(rl.ResponseHeaders, re.ResponseBody)
-	if re.ResponseBody != nil && rl.ResponseBody == `` {
-		rl.ResponseBody = `(no body)`
+	rl.ResponseBodyContentType = re.ResponseContentType
+	rl.ResponseBodySniffedContentType = re.ResponseSniffedContentType
+	if !noBodies {
+		rl.ResponseBodyPayloadSHA = re.ResponseSha
+		rl.ResponseBodyDigests = re.ResponseDigests
+		rl.ResponseBody = serializeBody(rl.ResponseHeaders, re.ResponseBody)
+		if re.ResponseBody != nil && rl.ResponseBody == `` {
+			rl.ResponseBody = `(no body)`
+		}
 	}
 }
 
+// addPreviewInfo adds to the report the body previews reported at the
+// "RESTRICTED" log level, if enabled.
+func (ll *LogLevel) addPreviewInfo(rl *proxy.ReportLog, re *ReportEvent) {
+	config := re.Config()
+	if config == nil || config.BodyPreview <= 0 || config.NoBodies {
+		return
+	}
+	rl.RequestBodyPreview = bodyPreview(re.Request().Header, re.RequestBody, config.BodyPreview)
+	if response := re.Response(); response != nil {
+		rl.ResponseBodyPreview = bodyPreview(response.Header, re.ResponseBody, config.BodyPreview)
+	}
+}
+
+// bodyPreview serializes the body, truncated to at most max bytes without
+// splitting UTF-8 sequences.
+func bodyPreview(headers http.Header, body interface{}, max int) string {
+	s := serializeBody(headers, body)
+	if len(s) <= max {
+		return s
+	}
+	end := max
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end]
+}
+
 // Prepare extract the ReportLog information from the API call, depending on the LogLevel.
 func (ll *LogLevel) Prepare(re *ReportEvent) proxy.ReportLog {
 	if request := re.Request(); request == nil {
@@ -170,6 +267,10 @@ func (ll *LogLevel) Prepare(re *ReportEvent) proxy.ReportLog {
 		ll.addRestrictedInfo(&rl, re)
 	}
 
+	if *ll == Restricted {
+		ll.addPreviewInfo(&rl, re)
+	}
+
 	if *ll >= All {
 		ll.addAllInfo(&rl, re)
 	}
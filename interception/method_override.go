@@ -0,0 +1,41 @@
+package interception
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/bearer/go-agent/filters"
+)
+
+// MethodOverrideHeaders are the headers used by clients to request a method
+// other than the HTTP one, usually on POST requests, for servers or proxies
+// not supporting it. They are checked in order.
+var MethodOverrideHeaders = []string{
+	`X-HTTP-Method-Override`,
+	`X-HTTP-Method`,
+	`X-Method-Override`,
+}
+
+// methodToken matches valid method names.
+var methodToken = regexp.MustCompile(filters.RFC7230_3_2_6Token)
+
+// methodOverride returns the method requested by the first MethodOverrideHeaders
+// header present on the request, upper-cased, or an empty string if there is
+// none, or if its value is not a valid method name.
+func methodOverride(request *http.Request) string {
+	if request == nil {
+		return ``
+	}
+	for _, header := range MethodOverrideHeaders {
+		value := strings.TrimSpace(request.Header.Get(header))
+		if value == `` {
+			continue
+		}
+		if !methodToken.MatchString(value) {
+			return ``
+		}
+		return strings.ToUpper(value)
+	}
+	return ``
+}
@@ -0,0 +1,74 @@
+package interception
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+)
+
+func Test_methodOverride(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   string
+	}{
+		{`no header`, nil, ``},
+		{`override`, http.Header{`X-Http-Method-Override`: {`DELETE`}}, `DELETE`},
+		{`lower case`, http.Header{`X-Http-Method-Override`: {` patch `}}, `PATCH`},
+		{`X-HTTP-Method`, http.Header{`X-Http-Method`: {`PUT`}}, `PUT`},
+		{`X-Method-Override`, http.Header{`X-Method-Override`: {`PUT`}}, `PUT`},
+		{`first header wins`, http.Header{`X-Http-Method-Override`: {`DELETE`}, `X-Method-Override`: {`PUT`}}, `DELETE`},
+		{`invalid value`, http.Header{`X-Http-Method-Override`: {`DE LETE`}}, ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, defaultTestURL, nil)
+			req.Header = tt.header
+			if got := methodOverride(req); got != tt.want {
+				t.Errorf("methodOverride() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundTripper_RoundTripMethodOverride(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	var re *ReportEvent
+	d := events.NewDispatcher()
+	d.AddProviders(TopicReport, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+		return []events.Listener{func(_ context.Context, e events.Event) error {
+			re = e.(*ReportEvent)
+			return nil
+		}}
+	}))
+	rt := &RoundTripper{
+		Dispatcher: d,
+		Underlying: ts.Client().Transport,
+	}
+	req, _ := http.NewRequest(http.MethodPost, ts.URL, nil)
+	req.Header.Set(`X-HTTP-Method-Override`, `DELETE`)
+	res, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	_ = res.Body.Close()
+	if re == nil {
+		t.Fatal("no report dispatched")
+	}
+
+	ll := Restricted
+	rl := ll.Prepare(re)
+	if rl.Method != http.MethodPost {
+		t.Errorf("Method = %s, want %s", rl.Method, http.MethodPost)
+	}
+	if rl.MethodOverride != http.MethodDelete {
+		t.Errorf("MethodOverride = %s, want %s", rl.MethodOverride, http.MethodDelete)
+	}
+}
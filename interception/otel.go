@@ -0,0 +1,109 @@
+package interception
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bearer/go-agent/events"
+)
+
+// OpenTelemetry semantic convention attribute keys used in spans.
+const (
+	SpanAttributeHost       = `net.peer.name`
+	SpanAttributeMethod     = `http.method`
+	SpanAttributeURL        = `http.url`
+	SpanAttributeStatusCode = `http.status_code`
+	SpanAttributeDuration   = `bearer.duration_ms`
+	SpanAttributeLogLevel   = `bearer.log_level`
+	SpanAttributeError      = `error`
+)
+
+// Span describes an API call as an OpenTelemetry client span.
+type Span struct {
+	Name       string
+	Start, End time.Time
+	Attributes map[string]interface{}
+
+	// Err is the error of failed API calls, to be recorded on the span with an
+	// error status.
+	Err error
+}
+
+// SpanTracer emits the spans built by an OTelProvider.
+//
+// It is the only part of an OpenTelemetry pipeline used by the Agent, which
+// therefore does not depend on the OpenTelemetry SDK: the Tracer in the
+// separate github.com/bearer/go-agent/otel module implements it around a
+// trace.Tracer.
+type SpanTracer interface {
+	EmitSpan(ctx context.Context, span Span)
+}
+
+// OTelProvider is an events.ListenerProvider returning a listener which converts
+// each sanitized report into a Span emitted by the Tracer, for API call
+// telemetry in an existing OpenTelemetry pipeline.
+//
+// Its listener needs to be placed after the SanitizationProvider, and before
+// any listener stopping the dispatch of reports, like the AggregationProvider,
+// to produce a span per call.
+type OTelProvider struct {
+	Tracer SpanTracer
+}
+
+// NewSpan builds the Span for the report in the event. Its attributes only
+// include the information reported at the LogLevel of the event, from the
+// ReportLog also sent by the ProxyProvider.
+func NewSpan(re *ReportEvent) Span {
+	ll := re.Config().LogLevel
+	rl := re.reportLog()
+
+	attributes := map[string]interface{}{
+		SpanAttributeHost:     rl.Hostname,
+		SpanAttributeLogLevel: rl.LogLevel,
+	}
+	name := `HTTP`
+	if ll >= Restricted {
+		name += ` ` + rl.Method
+		attributes[SpanAttributeMethod] = rl.Method
+		attributes[SpanAttributeURL] = rl.URL
+		attributes[SpanAttributeDuration] = re.T1.Sub(re.T0).Milliseconds()
+		attributes[SpanAttributeError] = re.Error != nil
+		if rl.StatusCode != 0 {
+			attributes[SpanAttributeStatusCode] = rl.StatusCode
+		}
+	}
+
+	span := Span{
+		Name:       name,
+		Start:      re.T0,
+		End:        re.T1,
+		Attributes: attributes,
+	}
+	if ll >= Restricted {
+		span.Err = re.Error
+	}
+	return span
+}
+
+// EmitSpan emits the Span for the report in the event.
+func (p OTelProvider) EmitSpan(ctx context.Context, e events.Event) error {
+	re, ok := e.(*ReportEvent)
+	if !ok {
+		return fmt.Errorf("topic %s used with event type %T", e.Topic(), e)
+	}
+	if p.Tracer == nil {
+		return nil
+	}
+	p.Tracer.EmitSpan(ctx, NewSpan(re))
+	return nil
+}
+
+// Listeners implements the events.ListenerProvider interface.
+func (p OTelProvider) Listeners(e events.Event) []events.Listener {
+	if e.Topic() != TopicReport {
+		return nil
+	}
+
+	return []events.Listener{p.EmitSpan}
+}
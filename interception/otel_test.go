@@ -0,0 +1,162 @@
+package interception
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/proxy"
+)
+
+// memorySpanExporter is an in-memory SpanTracer.
+type memorySpanExporter struct {
+	mu    sync.Mutex
+	spans []Span
+}
+
+func (x *memorySpanExporter) EmitSpan(_ context.Context, span Span) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.spans = append(x.spans, span)
+}
+
+func makeOTelTestTransport(underlying http.RoundTripper, exporter *memorySpanExporter) http.RoundTripper {
+	restricted := Restricted
+	dcrp := DCRProvider{DCRs: []*DataCollectionRule{{LogLevel: &restricted}}}
+	d := events.NewDispatcher()
+	d.AddProviders(TopicConnect, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+		return []events.Listener{RFCListener}
+	}), dcrp)
+	d.AddProviders(TopicRequest, dcrp)
+	d.AddProviders(TopicResponse, dcrp)
+	d.AddProviders(TopicBodies, BodyParsingProvider{}, dcrp)
+	d.AddProviders(TopicReport, dcrp, SanitizationProvider{
+		SensitiveKeys:    []*regexp.Regexp{DefaultSensitiveKeys},
+		SensitiveRegexps: []*regexp.Regexp{DefaultSensitiveData},
+	}, OTelProvider{Tracer: exporter})
+	return &RoundTripper{Dispatcher: d, Underlying: underlying}
+}
+
+func TestOTelProvider_EmitSpan(t *testing.T) {
+	const calls = 3
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	exporter := &memorySpanExporter{}
+	client := &http.Client{Transport: makeOTelTestTransport(ts.Client().Transport, exporter)}
+	for i := 0; i < calls; i++ {
+		res, err := client.Post(ts.URL+`/v1/items?password=hunter2`, `text/plain`, nil)
+		if err != nil {
+			t.Fatalf("Post() error = %v", err)
+		}
+		_, _ = ioutil.ReadAll(res.Body)
+		_ = res.Body.Close()
+	}
+
+	if len(exporter.spans) != calls {
+		t.Fatalf("%d spans emitted, expected %d", len(exporter.spans), calls)
+	}
+	for _, span := range exporter.spans {
+		if span.Name != `HTTP POST` {
+			t.Errorf("span name = %s, expected HTTP POST", span.Name)
+		}
+		if !span.End.After(span.Start) {
+			t.Errorf("span ends at %v, before its start at %v", span.End, span.Start)
+		}
+		if span.Err != nil {
+			t.Errorf("unexpected span error: %v", span.Err)
+		}
+		expected := map[string]interface{}{
+			SpanAttributeHost:       `127.0.0.1`,
+			SpanAttributeMethod:     http.MethodPost,
+			SpanAttributeStatusCode: http.StatusCreated,
+			SpanAttributeError:      false,
+		}
+		for key, value := range expected {
+			if span.Attributes[key] != value {
+				t.Errorf("span attribute %s = %v, expected %v", key, span.Attributes[key], value)
+			}
+		}
+		if _, ok := span.Attributes[SpanAttributeDuration].(int64); !ok {
+			t.Errorf("span attribute %s = %#v, expected an int64", SpanAttributeDuration, span.Attributes[SpanAttributeDuration])
+		}
+		if url, _ := span.Attributes[SpanAttributeURL].(string); strings.Contains(url, `hunter2`) {
+			t.Errorf("span URL not sanitized: %s", url)
+		}
+	}
+}
+
+func TestOTelProvider_EmitSpanError(t *testing.T) {
+	exporter := &memorySpanExporter{}
+	rt := makeOTelTestTransport(testErrorRoundTripper{}, exporter)
+	req, _ := http.NewRequest(http.MethodGet, defaultTestURL, nil)
+	if _, err := rt.RoundTrip(req); err == nil {
+		t.Fatal("RoundTrip() did not return the underlying error")
+	}
+
+	if len(exporter.spans) != 1 {
+		t.Fatalf("%d spans emitted, expected 1", len(exporter.spans))
+	}
+	span := exporter.spans[0]
+	if span.Err == nil || span.Attributes[SpanAttributeError] != true {
+		t.Errorf("span error not recorded: %v, attributes %v", span.Err, span.Attributes)
+	}
+	if _, ok := span.Attributes[SpanAttributeStatusCode]; ok {
+		t.Errorf("unexpected status code on failed call: %v", span.Attributes[SpanAttributeStatusCode])
+	}
+}
+
+func TestOTelProvider_EmitSpanDetected(t *testing.T) {
+	exporter := &memorySpanExporter{}
+	p := OTelProvider{Tracer: exporter}
+	req, _ := http.NewRequest(http.MethodGet, defaultTestURL+`/secret/path`, nil)
+	re := NewReportEvent(proxy.StageBodies, nil)
+	re.SetRequest(req)
+	re.SetConfig(&APIEventConfig{IsActive: true, LogLevel: Detected})
+	if err := p.EmitSpan(context.Background(), re); err != nil {
+		t.Fatalf("EmitSpan() error = %v", err)
+	}
+
+	span := exporter.spans[0]
+	if span.Name != `HTTP` {
+		t.Errorf("span name = %s, expected HTTP", span.Name)
+	}
+	if _, ok := span.Attributes[SpanAttributeURL]; ok {
+		t.Errorf("span includes the URL at the Detected log level: %v", span.Attributes)
+	}
+
+	if err := p.EmitSpan(context.Background(), events.NewEvent(`bad`)); err == nil {
+		t.Error(`expected error on non-ReportEvent`)
+	}
+}
+
+func TestOTelProvider_Listeners(t *testing.T) {
+	p := OTelProvider{}
+	if got := p.Listeners(NewReportEvent(proxy.StageBodies, nil)); len(got) != 1 {
+		t.Errorf("Listeners() on report returned %d listeners, expected 1", len(got))
+	}
+	if got := p.Listeners(&ResponseEvent{}); len(got) != 0 {
+		t.Errorf("Listeners() on response returned %d listeners, expected 0", len(got))
+	}
+}
+
+func TestNewSpan_SharesReportLog(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, defaultTestURL+`/first`, nil)
+	re := NewReportEvent(proxy.StageBodies, nil)
+	re.SetRequest(req)
+	re.SetConfig(&APIEventConfig{IsActive: true, LogLevel: Restricted})
+
+	span := NewSpan(re)
+	req.URL.Path = `/second`
+	if actual, expected := re.reportLog().URL, span.Attributes[SpanAttributeURL]; actual != expected {
+		t.Errorf("reported URL %s, expected the span URL %s", actual, expected)
+	}
+}
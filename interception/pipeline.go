@@ -0,0 +1,70 @@
+package interception
+
+import (
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/proxy"
+)
+
+// Pipeline lists the providers instrumenting API calls, to be wired on the
+// topics of a Dispatcher by Wire. It is shared by the Agent and
+// NewInstrumentedTransport, so that both handle API calls the same way.
+type Pipeline struct {
+	// DCR applies the data collection rules on every topic.
+	DCR DCRProvider
+
+	// Connect providers are placed before the DCR on TopicConnect, while
+	// Request and Response providers are placed after it on their topic.
+	Connect, Request, Response []events.ListenerProvider
+
+	// BodyParser parses the bodies, forced to parse them when the data
+	// collection rules filter on the parsed bodies.
+	BodyParser BodyParsingProvider
+
+	// Report providers complete the reports, between the DCR and the
+	// Sanitizer on TopicReport.
+	Report []events.ListenerProvider
+
+	Sanitizer SanitizationProvider
+
+	// Tracer, Aggregator and Sender are optional. When set, they are placed
+	// in that order after the Sanitizer.
+	Tracer     SpanTracer
+	Aggregator *AggregationProvider
+	Sender     *proxy.Sender
+}
+
+// Wire adds the pipeline providers to the Dispatcher.
+func (p Pipeline) Wire(d events.Dispatcher) {
+	connect := make([]events.ListenerProvider, 0, len(p.Connect)+1)
+	connect = append(connect, p.Connect...)
+	d.AddProviders(TopicConnect, append(connect, p.DCR)...)
+	d.AddProviders(TopicRequest, p.DCR)
+	if len(p.Request) > 0 {
+		d.AddProviders(TopicRequest, p.Request...)
+	}
+	d.AddProviders(TopicResponse, p.DCR)
+	if len(p.Response) > 0 {
+		d.AddProviders(TopicResponse, p.Response...)
+	}
+
+	bodyParser := p.BodyParser
+	if RulesUseParsedBodies(p.DCR.DCRs) {
+		bodyParser.ParseForFilters = true
+	}
+	d.AddProviders(TopicBodies, bodyParser, p.DCR)
+
+	report := make([]events.ListenerProvider, 0, len(p.Report)+5)
+	report = append(report, p.DCR)
+	report = append(report, p.Report...)
+	report = append(report, p.Sanitizer)
+	if p.Tracer != nil {
+		report = append(report, OTelProvider{Tracer: p.Tracer})
+	}
+	if p.Aggregator != nil {
+		report = append(report, p.Aggregator)
+	}
+	if p.Sender != nil {
+		report = append(report, ProxyProvider{Sender: p.Sender})
+	}
+	d.AddProviders(TopicReport, report...)
+}
@@ -0,0 +1,54 @@
+package interception
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bearer/go-agent/events"
+)
+
+func TestPipeline_Wire(t *testing.T) {
+	tests := []struct {
+		name     string
+		pipeline Pipeline
+		expected map[events.Topic]int
+	}{
+		{`minimal`, Pipeline{}, map[events.Topic]int{
+			TopicConnect:  1,
+			TopicRequest:  1,
+			TopicResponse: 1,
+			TopicBodies:   2,
+			TopicReport:   2,
+		}},
+		{`complete`, Pipeline{
+			Connect:    []events.ListenerProvider{CacheProvider{}},
+			Request:    []events.ListenerProvider{AnomalyProvider{}},
+			Response:   []events.ListenerProvider{StatusSuppressionProvider{}},
+			Report:     []events.ListenerProvider{CacheProvider{}, RetryCountProvider{}},
+			Tracer:     &memorySpanExporter{},
+			Aggregator: NewAggregationProvider(makeAggregationTestSender(1), time.Hour),
+			Sender:     makeAggregationTestSender(1),
+		}, map[events.Topic]int{
+			TopicConnect:  2,
+			TopicRequest:  2,
+			TopicResponse: 2,
+			TopicBodies:   2,
+			TopicReport:   7,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := events.NewDispatcher()
+			tt.pipeline.Wire(d)
+			inspector := d.(events.DispatcherInspector)
+			if topics := inspector.Topics(); len(topics) != len(tt.expected) {
+				t.Errorf("providers set for topics %v, expected %d topics", topics, len(tt.expected))
+			}
+			for topic, expected := range tt.expected {
+				if actual := inspector.ProviderCount(topic); actual != expected {
+					t.Errorf("ProviderCount(%s) = %d, expected %d", topic, actual, expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package interception
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/bearer/go-agent/proxy"
+)
+
+const (
+	// ForwardedForHeader lists the addresses of the client and the proxies
+	// which forwarded a request.
+	ForwardedForHeader = `X-Forwarded-For`
+
+	// ViaHeader lists the proxies which forwarded a request or response.
+	ViaHeader = `Via`
+)
+
+// splitHeaderList splits comma-separated header values into their trimmed
+// non-empty elements, ignoring the commas in parenthesized comments, which Via
+// entries may carry.
+func splitHeaderList(values []string) []string {
+	var elements []string
+	add := func(element string) {
+		if element = strings.TrimSpace(element); element != `` {
+			elements = append(elements, element)
+		}
+	}
+	for _, value := range values {
+		depth, start := 0, 0
+		for i := 0; i < len(value); i++ {
+			switch value[i] {
+			case '(':
+				depth++
+			case ')':
+				if depth > 0 {
+					depth--
+				}
+			case ',':
+				if depth == 0 {
+					add(value[start:i])
+					start = i + 1
+				}
+			}
+		}
+		add(value[start:])
+	}
+	return elements
+}
+
+// proxyChain builds the ProxyChain of a call from the X-Forwarded-For header
+// of its request and the Via headers of its request and response, or returns
+// nil if they are all missing.
+//
+// Since it uses the headers of the report event, their values are already
+// sanitized, so addresses matching the sensitive data are redacted.
+func proxyChain(request *http.Request, response *http.Response) *proxy.ProxyChain {
+	chain := proxy.ProxyChain{
+		ForwardedFor: splitHeaderList(request.Header.Values(ForwardedForHeader)),
+		Via:          splitHeaderList(request.Header.Values(ViaHeader)),
+	}
+	if response != nil {
+		chain.Via = append(chain.Via, splitHeaderList(response.Header.Values(ViaHeader))...)
+	}
+	if len(chain.ForwardedFor) == 0 && len(chain.Via) == 0 {
+		return nil
+	}
+	return &chain
+}
@@ -0,0 +1,86 @@
+package interception
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/bearer/go-agent/proxy"
+)
+
+func Test_splitHeaderList(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   []string
+	}{
+		{`none`, nil, nil},
+		{`single`, []string{`203.0.113.1`}, []string{`203.0.113.1`}},
+		{`multi-hop`, []string{`203.0.113.1, 198.51.100.2,10.0.0.3`}, []string{`203.0.113.1`, `198.51.100.2`, `10.0.0.3`}},
+		{`multiple headers`, []string{`203.0.113.1`, `10.0.0.3`}, []string{`203.0.113.1`, `10.0.0.3`}},
+		{`empty elements`, []string{` , 203.0.113.1,,`}, []string{`203.0.113.1`}},
+		{`comments`, []string{`1.0 fred, 1.1 p.example.net (Apache/1.1, internal)`}, []string{`1.0 fred`, `1.1 p.example.net (Apache/1.1, internal)`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitHeaderList(tt.values); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitHeaderList() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundTripper_RoundTripProxyChain(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(ViaHeader, `1.1 gateway.example.com`)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	restricted, detected := Restricted, Detected
+	tests := []struct {
+		name     string
+		level    *LogLevel
+		regexps  []*regexp.Regexp
+		expected *proxy.ProxyChain
+	}{
+		{`restricted`, &restricted, nil, &proxy.ProxyChain{
+			ForwardedFor: []string{`203.0.113.1`, `198.51.100.2`, `10.0.0.3`},
+			Via:          []string{`1.0 edge`, `1.1 gateway.example.com`},
+		}},
+		{`sanitized`, &restricted, []*regexp.Regexp{regexp.MustCompile(`\b10(\.\d{1,3}){3}\b`)}, &proxy.ProxyChain{
+			ForwardedFor: []string{`203.0.113.1`, `198.51.100.2`, Filtered},
+			Via:          []string{`1.0 edge`, `1.1 gateway.example.com`},
+		}},
+		{`detected`, &detected, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt, capture := NewTestRoundTripper(
+				DCRProvider{DCRs: []*DataCollectionRule{{LogLevel: tt.level}}},
+				SanitizationProvider{SensitiveRegexps: tt.regexps},
+			)
+			rt.Underlying = ts.Client().Transport
+
+			req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+			req.Header.Set(ForwardedForHeader, `203.0.113.1, 198.51.100.2`)
+			req.Header.Add(ForwardedForHeader, `10.0.0.3`)
+			req.Header.Set(ViaHeader, `1.0 edge`)
+			res, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			_ = res.Body.Close()
+
+			rl := capture.Last()
+			if rl == nil {
+				t.Fatal("no report captured")
+			}
+			if !reflect.DeepEqual(rl.ProxyChain, tt.expected) {
+				t.Errorf("ProxyChain = %+v, want %+v", rl.ProxyChain, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package interception
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+const (
+	// AuditPatternKey identifies redactions caused by SensitiveKeys.
+	AuditPatternKey = `key`
+
+	// AuditPatternData identifies redactions caused by SensitiveRegexps.
+	AuditPatternData = `data`
+)
+
+// RedactionAuditRecord describes a redaction, without the redacted value.
+type RedactionAuditRecord struct {
+	// Location is the dotted path of the redacted value, like
+	// "request.headers.Authorization", "request.query.password",
+	// "request.path", or "response.body.users.0.email".
+	Location string `json:"location"`
+
+	// Pattern is AuditPatternKey or AuditPatternData, and Index the position of
+	// the matching expression in SensitiveKeys or SensitiveRegexps.
+	Pattern string `json:"pattern"`
+	Index   int    `json:"index"`
+}
+
+// RedactionAuditor writes a RedactionAuditRecord per redaction, as a JSON line.
+// It is safe for concurrent use, and a nil RedactionAuditor records nothing.
+type RedactionAuditor struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewRedactionAuditor builds a RedactionAuditor writing to w.
+func NewRedactionAuditor(w io.Writer) *RedactionAuditor {
+	return &RedactionAuditor{enc: json.NewEncoder(w)}
+}
+
+// Record writes a RedactionAuditRecord. Write errors are ignored, as they must
+// not prevent reporting.
+func (a *RedactionAuditor) Record(location func() string, pattern string, index int) {
+	if a == nil {
+		return
+	}
+	record := RedactionAuditRecord{Location: location(), Pattern: pattern, Index: index}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_ = a.enc.Encode(record)
+}
+
+// auditLocation returns a function building the location of a value from a
+// prefix and the keys or indexes below it, only called when auditing.
+func auditLocation(prefix string, path ...interface{}) func() string {
+	return func() string {
+		b := strings.Builder{}
+		b.WriteString(prefix)
+		for _, k := range path {
+			b.WriteByte('.')
+			b.WriteString(fmt.Sprint(k))
+		}
+		return b.String()
+	}
+}
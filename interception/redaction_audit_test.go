@@ -0,0 +1,99 @@
+package interception
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bearer/go-agent/proxy"
+)
+
+func TestSanitizationProvider_Audit(t *testing.T) {
+	const (
+		secret = `s3cr3t`
+		mail   = `john.doe@example.com`
+		token  = `tok-123456`
+	)
+	// Removed values are audited like masked ones.
+	expected := []RedactionAuditRecord{
+		{`request.body.users.0.email`, AuditPatternData, 0},
+		{`request.body.users.0.password`, AuditPatternKey, 0},
+		{`request.headers.Authorization`, AuditPatternKey, 0},
+		{`request.headers.Cookie.session`, AuditPatternKey, 1},
+		{`request.headers.X-Token`, AuditPatternData, 1},
+		{`request.path`, AuditPatternData, 0},
+		{`request.query.password`, AuditPatternKey, 0},
+		{`response.body.token`, AuditPatternData, 1},
+	}
+	tests := []struct {
+		name     string
+		strategy RedactionStrategy
+	}{
+		{`mask`, RedactionMask},
+		{`remove`, RedactionRemove},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit := &bytes.Buffer{}
+			p := SanitizationProvider{
+				SensitiveKeys:    []*regexp.Regexp{DefaultSensitiveKeys, regexp.MustCompile(`^session$`)},
+				SensitiveRegexps: []*regexp.Regexp{DefaultSensitiveData, regexp.MustCompile(`tok-\d+`)},
+				Strategy:         tt.strategy,
+				Audit:            NewRedactionAuditor(audit),
+			}
+
+			req, _ := http.NewRequest(http.MethodPost, `https://example.com/users/`+mail+`?password=`+secret+`&page=2`, nil)
+			req.Header.Set(`Authorization`, secret)
+			req.Header.Set(`Cookie`, `session=`+secret+`; lang=en`)
+			req.Header.Set(`X-Token`, token)
+			req.Header.Set(`Accept`, `*/*`)
+			re := NewReportEvent(proxy.StageBodies, nil)
+			re.SetRequest(req)
+			re.SetResponse(&http.Response{Header: http.Header{}, Request: req})
+			re.RequestBody = map[string]interface{}{
+				`users`: []interface{}{map[string]interface{}{`email`: mail, `password`: secret, `id`: 1.0}},
+			}
+			re.ResponseBody = map[string]interface{}{`token`: token, `ok`: true}
+
+			for _, l := range p.Listeners(re) {
+				if err := l(context.Background(), re); err != nil {
+					t.Fatalf("sanitization error = %v", err)
+				}
+			}
+
+			output := audit.String()
+			for _, value := range []string{secret, mail, token} {
+				if strings.Contains(output, value) {
+					t.Errorf("audit leaked %q: %s", value, output)
+				}
+			}
+			var actual []RedactionAuditRecord
+			d := json.NewDecoder(audit)
+			for d.More() {
+				var record RedactionAuditRecord
+				if err := d.Decode(&record); err != nil {
+					t.Fatalf("decoding audit record: %v", err)
+				}
+				actual = append(actual, record)
+			}
+			sort.Slice(actual, func(i, j int) bool { return actual[i].Location < actual[j].Location })
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("audit records = %v, expected %v", actual, expected)
+			}
+		})
+	}
+}
+
+func TestRedactionAuditor_Nil(t *testing.T) {
+	var a *RedactionAuditor
+	a.Record(func() string {
+		t.Error("location computed without an auditor")
+		return ``
+	}, AuditPatternKey, 0)
+}
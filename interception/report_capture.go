@@ -0,0 +1,94 @@
+package interception
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/proxy"
+)
+
+// ReportCapture is an events.ListenerProvider capturing the ReportEvent of API
+// calls along with the proxy.ReportLog prepared for them, instead of sending
+// them to Bearer, for use in tests.
+type ReportCapture struct {
+	mu     sync.Mutex
+	events []*ReportEvent
+	logs   []proxy.ReportLog
+}
+
+// Capture records a ReportEvent and its proxy.ReportLog, prepared at the
+// event LogLevel.
+func (c *ReportCapture) Capture(_ context.Context, e events.Event) error {
+	re, ok := e.(*ReportEvent)
+	if !ok {
+		return fmt.Errorf("topic %s used with event type %T", e.Topic(), e)
+	}
+	ll := Detected
+	if config := re.Config(); config != nil {
+		ll = config.LogLevel
+	}
+	rl := ll.Prepare(re)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, re)
+	c.logs = append(c.logs, rl)
+	return nil
+}
+
+// Listeners implements the events.ListenerProvider interface.
+func (c *ReportCapture) Listeners(e events.Event) []events.Listener {
+	if e.Topic() != TopicReport {
+		return nil
+	}
+	return []events.Listener{c.Capture}
+}
+
+// Events returns the captured ReportEvent instances, in capture order.
+func (c *ReportCapture) Events() []*ReportEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*ReportEvent(nil), c.events...)
+}
+
+// Reports returns the captured proxy.ReportLog values, in capture order.
+func (c *ReportCapture) Reports() []proxy.ReportLog {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]proxy.ReportLog(nil), c.logs...)
+}
+
+// Last returns the last captured proxy.ReportLog, or nil if none was captured.
+func (c *ReportCapture) Last() *proxy.ReportLog {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.logs) == 0 {
+		return nil
+	}
+	rl := c.logs[len(c.logs)-1]
+	return &rl
+}
+
+// NewTestRoundTripper builds a RoundTripper over http.DefaultTransport, whose
+// dispatcher applies the given providers on all topics, then captures the
+// reports in the returned ReportCapture, allowing tests to issue API calls
+// through the full interception pipeline and assert the resulting reports.
+//
+// The providers are listed in dispatch order, and usually include a
+// DCRProvider to set the LogLevel, which is Detected otherwise, along with a
+// BodyParsingProvider and SanitizationProvider. Tests may replace the
+// Underlying transport, e.g. with the client transport of a TLS test server.
+func NewTestRoundTripper(providers ...events.ListenerProvider) (*RoundTripper, *ReportCapture) {
+	capture := &ReportCapture{}
+	d := events.NewDispatcher()
+	for _, topic := range []events.Topic{TopicConnect, TopicRequest, TopicResponse, TopicBodies, TopicReport} {
+		d.AddProviders(topic, providers...)
+	}
+	d.AddProviders(TopicReport, capture)
+	return &RoundTripper{
+		Dispatcher: d,
+		Underlying: http.DefaultTransport,
+	}, capture
+}
@@ -0,0 +1,71 @@
+package interception
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/bearer/go-agent/proxy"
+)
+
+func TestNewTestRoundTripper(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = ioutil.ReadAll(r.Body)
+		w.Header().Set(proxy.ContentTypeHeader, proxy.ContentTypeJSON)
+		_, _ = w.Write([]byte(`{"id":1,"token":"abc"}`))
+	}))
+	defer ts.Close()
+
+	all := All
+	rt, capture := NewTestRoundTripper(
+		DCRProvider{DCRs: []*DataCollectionRule{{LogLevel: &all}}},
+		BodyParsingProvider{},
+		SanitizationProvider{
+			SensitiveKeys:    []*regexp.Regexp{DefaultSensitiveKeys},
+			SensitiveRegexps: []*regexp.Regexp{DefaultSensitiveData},
+		},
+	)
+	if capture.Last() != nil {
+		t.Fatal("report captured before any call")
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+`/users`,
+		strings.NewReader(`{"name":"Jane","password":"hunter2"}`))
+	req.Header.Set(proxy.ContentTypeHeader, proxy.ContentTypeJSON)
+	req.Header.Set(`Authorization`, `Bearer secret`)
+	res, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	_, _ = ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+
+	if n := len(capture.Reports()); n != 1 {
+		t.Fatalf("captured %d reports, expected 1", n)
+	}
+	if n := len(capture.Events()); n != 1 {
+		t.Fatalf("captured %d events, expected 1", n)
+	}
+	rl := capture.Last()
+	if rl.LogLevel != `ALL` {
+		t.Errorf("LogLevel = %s, want ALL", rl.LogLevel)
+	}
+	if rl.Path != `/users` || rl.StatusCode != http.StatusOK {
+		t.Errorf("Path, StatusCode = %s, %d, want /users, 200", rl.Path, rl.StatusCode)
+	}
+	if actual := rl.RequestHeaders.Get(`Authorization`); actual != Filtered {
+		t.Errorf("Authorization header = %s, want %s", actual, Filtered)
+	}
+	if expected := `{"name":"Jane","password":"[FILTERED]"}`; rl.RequestBody != expected {
+		t.Errorf("RequestBody = %s, want %s", rl.RequestBody, expected)
+	}
+	if expected := ToSha(map[string]interface{}{`name`: ``, `password`: ``}); rl.RequestBodyPayloadSHA != expected {
+		t.Errorf("RequestBodyPayloadSHA = %s, want %s", rl.RequestBodyPayloadSHA, expected)
+	}
+	if expected := ToSha(map[string]interface{}{`id`: 0.0, `token`: ``}); rl.ResponseBodyPayloadSHA != expected {
+		t.Errorf("ResponseBodyPayloadSHA = %s, want %s", rl.ResponseBodyPayloadSHA, expected)
+	}
+}
@@ -0,0 +1,44 @@
+package interception
+
+import (
+	"context"
+	"net/http"
+)
+
+// reportFieldsKey is the context key used by WithReportFields.
+type reportFieldsKey struct{}
+
+// WithReportFields returns a context attaching custom fields, like an order ID
+// or a user tier, to the reports of the requests using it. Fields already
+// attached to ctx are kept, unless overridden by fields with the same name.
+//
+// The fields are added to the reports as provided, without sanitization, so
+// they should not contain sensitive data.
+func WithReportFields(ctx context.Context, fields map[string]interface{}) context.Context {
+	parent, _ := ctx.Value(reportFieldsKey{}).(map[string]interface{})
+	merged := make(map[string]interface{}, len(parent)+len(fields))
+	for k, v := range parent {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, reportFieldsKey{}, merged)
+}
+
+// reportFields returns a copy of the custom fields attached to a request
+// context by WithReportFields, or nil if there are none.
+func reportFields(request *http.Request) map[string]interface{} {
+	if request == nil {
+		return nil
+	}
+	fields, _ := request.Context().Value(reportFieldsKey{}).(map[string]interface{})
+	if len(fields) == 0 {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		copied[k] = v
+	}
+	return copied
+}
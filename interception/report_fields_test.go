@@ -0,0 +1,68 @@
+package interception
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/proxy"
+)
+
+func TestWithReportFields(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ts.Close()
+
+	base := WithReportFields(context.Background(), map[string]interface{}{`tier`: `free`, `orderId`: `o-1`})
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		expected map[string]interface{}
+	}{
+		{`no fields`, context.Background(), nil},
+		{`empty fields`, WithReportFields(context.Background(), nil), nil},
+		{`fields`, base, map[string]interface{}{`tier`: `free`, `orderId`: `o-1`}},
+		{`merged fields`, WithReportFields(base, map[string]interface{}{`tier`: `gold`, `items`: 3}),
+			map[string]interface{}{`tier`: `gold`, `orderId`: `o-1`, `items`: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rl proxy.ReportLog
+			d := events.NewDispatcher()
+			d.AddProviders(TopicConnect, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+				return []events.Listener{func(_ context.Context, e events.Event) error {
+					e.(APIEvent).Config().LogLevel = Restricted
+					return nil
+				}}
+			}))
+			d.AddProviders(TopicReport,
+				SanitizationProvider{SensitiveKeys: []*regexp.Regexp{DefaultSensitiveKeys}},
+				events.ListenerProviderFunc(func(events.Event) []events.Listener {
+					return []events.Listener{func(_ context.Context, e events.Event) error {
+						re := e.(*ReportEvent)
+						rl = re.Config().LogLevel.Prepare(re)
+						return nil
+					}}
+				}),
+			)
+			rt := &RoundTripper{
+				Dispatcher: d,
+				Underlying: ts.Client().Transport,
+			}
+
+			req, _ := http.NewRequestWithContext(tt.ctx, http.MethodGet, ts.URL, nil)
+			res, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			_ = res.Body.Close()
+
+			if !reflect.DeepEqual(rl.CustomFields, tt.expected) {
+				t.Errorf("reported CustomFields = %v, expected %v", rl.CustomFields, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package interception
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bearer/go-agent/events"
+)
+
+// RetryCountProvider is an events.ListenerProvider returning a listener which
+// reads the number of retries performed by a retrying transport from a response
+// header, for inclusion in the report.
+type RetryCountProvider struct {
+	// Header is the name of the response header carrying the retry count.
+	Header string
+}
+
+// ReadRetryCount sets the ReportEvent RetryCount from the configured response
+// header. Missing or non-numeric headers leave it unchanged.
+func (p RetryCountProvider) ReadRetryCount(_ context.Context, e events.Event) error {
+	re, ok := e.(*ReportEvent)
+	if !ok {
+		return fmt.Errorf(`topic ReportEvent, got %T`, e)
+	}
+	response := re.Response()
+	if response == nil || p.Header == `` {
+		return nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(response.Header.Get(p.Header)))
+	if err != nil || n < 0 {
+		return nil
+	}
+	re.RetryCount = n
+	return nil
+}
+
+// Listeners implements the events.ListenerProvider interface.
+func (p RetryCountProvider) Listeners(e events.Event) []events.Listener {
+	if e.Topic() != TopicReport {
+		return nil
+	}
+
+	return []events.Listener{p.ReadRetryCount}
+}
@@ -0,0 +1,66 @@
+package interception
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/proxy"
+)
+
+func TestRetryCountProvider_ReadRetryCount(t *testing.T) {
+	const header = `X-Retry-Count`
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		response bool
+		want     int
+	}{
+		{`happy`, header, `3`, true, 3},
+		{`spaces`, header, ` 2 `, true, 2},
+		{`no header configured`, ``, `3`, true, 0},
+		{`missing header`, header, ``, true, 0},
+		{`non-numeric`, header, `many`, true, 0},
+		{`negative`, header, `-1`, true, 0},
+		{`no response`, header, `3`, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, `https://example.com`, nil)
+			re := NewReportEvent(proxy.StageBodies, nil)
+			re.SetRequest(req)
+			if tt.response {
+				res := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Request: req}
+				if tt.value != `` {
+					res.Header.Set(header, tt.value)
+				}
+				re.SetResponse(res)
+			}
+			p := RetryCountProvider{Header: tt.header}
+			if err := p.ReadRetryCount(context.Background(), re); err != nil {
+				t.Fatalf(`unexpected error: %v`, err)
+			}
+
+			ll := Restricted
+			if got := ll.Prepare(re).RetryCount; got != tt.want {
+				t.Errorf("reported RetryCount = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if err := (RetryCountProvider{}).ReadRetryCount(context.Background(), events.NewEvent(`bad`)); err == nil {
+		t.Error(`expected error on non-ReportEvent`)
+	}
+}
+
+func TestRetryCountProvider_Listeners(t *testing.T) {
+	p := RetryCountProvider{Header: `X-Retry-Count`}
+	if got := p.Listeners(NewReportEvent(proxy.StageBodies, nil)); len(got) != 1 {
+		t.Errorf("Listeners() on report = %d, want 1", len(got))
+	}
+	if got := p.Listeners(&ResponseEvent{}); len(got) != 0 {
+		t.Errorf("Listeners() on response = %d, want 0", len(got))
+	}
+}
@@ -20,6 +20,54 @@ import (
 type RoundTripper struct {
 	events.Dispatcher
 	Underlying http.RoundTripper
+
+	// Paused, if not nil, is checked on each call: while it returns true, calls
+	// are passed to the Underlying transport without any instrumentation.
+	Paused func() bool
+
+	// IgnoredHosts are matched against the host name, without port, of each
+	// request: calls to matching hosts are passed to the Underlying transport
+	// without any instrumentation.
+	IgnoredHosts []*regexp.Regexp
+
+	// MaxInstrumentationLatency, if positive, bounds the total duration of the
+	// TopicConnect and TopicRequest stages, regardless of any per-topic timeout
+	// on the Dispatcher: once it is exceeded, the call is passed to the
+	// Underlying transport without any further instrumentation.
+	MaxInstrumentationLatency time.Duration
+
+	// Warn, if not nil, is invoked to report instrumentation anomalies, like
+	// exceeding the MaxInstrumentationLatency.
+	Warn func(msg string, fields map[string]interface{})
+
+	// CaptureCallerStack includes the stack of the code issuing failed calls in
+	// their reports, excluding the agent frames.
+	CaptureCallerStack bool
+
+	// MaxRequestBodySize and MaxResponseBodySize bound the size of the request
+	// and response bodies captured for parsing: longer bodies are reported as
+	// BodyTooLong. Zero means MaximumBodySize.
+	MaxRequestBodySize, MaxResponseBodySize int
+
+	// BufferStreams enables the buffering of the bodies of streaming responses,
+	// like Server-Sent Events, which are otherwise passed through unbuffered
+	// and reported as BodyIsStream, since peeking them blocks until enough
+	// events are received.
+	BufferStreams bool
+}
+
+// isStream checks whether a response is a stream whose body is not buffered.
+func (rt *RoundTripper) isStream(response *http.Response) bool {
+	return !rt.BufferStreams && response != nil &&
+		EventStreamContentType.MatchString(response.Header.Get(proxy.ContentTypeHeader))
+}
+
+// peekSize returns the BodyReadCloser peek size for a maximum body size.
+func peekSize(maxBodySize int) int {
+	if maxBodySize <= 0 || maxBodySize > MaximumBodySize {
+		maxBodySize = MaximumBodySize
+	}
+	return maxBodySize + 1
 }
 
 // schemeRegexp is the regular expression matching the RFC3986 grammar
@@ -94,12 +142,11 @@ func (rt *RoundTripper) stageConnect(ctx context.Context, url *url.URL) (APIEven
 	return e, nil
 }
 
-func (rt *RoundTripper) stageRequest(prevEvent APIEvent, request *http.Request) (APIEvent, error) {
+func (rt *RoundTripper) stageRequest(ctx context.Context, prevEvent APIEvent, request *http.Request) (APIEvent, error) {
 	if prevEvent == nil || !prevEvent.Config().IsActive {
 		return nil, nil
 	}
 
-	ctx := request.Context()
 	be := &RequestEvent{}
 	be.SetTopic(string(TopicRequest))
 	be.SetConfig(prevEvent.Config())
@@ -163,8 +210,60 @@ func (rt *RoundTripper) stageBodies(ctx context.Context, prevEvent APIEvent, req
 	return rev
 }
 
+// isIgnoredHost checks whether the URL host matches any of the IgnoredHosts.
+func (rt *RoundTripper) isIgnoredHost(u *url.URL) bool {
+	if u == nil || len(rt.IgnoredHosts) == 0 {
+		return false
+	}
+	host := u.Hostname()
+	for _, re := range rt.IgnoredHosts {
+		if re.MatchString(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// bypass passes a call to the Underlying transport without instrumentation,
+// once the MaxInstrumentationLatency is exceeded.
+func (rt *RoundTripper) bypass(request *http.Request) (*http.Response, error) {
+	if rt.Warn != nil {
+		rt.Warn(`instrumentation latency exceeded: API call not instrumented`, map[string]interface{}{
+			`host`:                      request.URL.Host,
+			`maxInstrumentationLatency`: rt.MaxInstrumentationLatency.String(),
+		})
+	}
+	return rt.Underlying.RoundTrip(request)
+}
+
+// listenerTimedOut checks whether a stage error is the expiry of a listener
+// timeout set on the Dispatcher, rather than of the context of the call.
+func listenerTimedOut(ctx context.Context, err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil
+}
+
+// bypassTimeout passes a call to the Underlying transport without
+// instrumentation, once a listener timeout set on the Dispatcher expired in a
+// pre-call stage, since listener timeouts do not fail the call.
+func (rt *RoundTripper) bypassTimeout(request *http.Request, topic events.Topic) (*http.Response, error) {
+	if rt.Warn != nil {
+		rt.Warn(`listener timeout exceeded: API call not instrumented`, map[string]interface{}{
+			`host`:  request.URL.Host,
+			`topic`: string(topic),
+		})
+	}
+	return rt.Underlying.RoundTrip(request)
+}
+
 // RoundTrip implements the http.RoundTripper interface.
 func (rt *RoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	if rt.Paused != nil && rt.Paused() {
+		return rt.Underlying.RoundTrip(request)
+	}
+	if rt.isIgnoredHost(request.URL) {
+		return rt.Underlying.RoundTrip(request)
+	}
+
 	var prevEvent APIEvent
 	var err error
 	var rev *ReportEvent
@@ -173,8 +272,27 @@ func (rt *RoundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 		t0 = time.Now()
 		t1 = t0
 	)
+	streaming := false
+	dns := &dnsRecorder{}
+	streams := &streamIDRecorder{}
+	conns := &connRecorder{}
 
 	ctx := request.Context()
+	// The pre-call stages share the MaxInstrumentationLatency, if any.
+	preCtx := ctx
+	if rt.MaxInstrumentationLatency > 0 {
+		var cancel context.CancelFunc
+		preCtx, cancel = context.WithTimeout(ctx, rt.MaxInstrumentationLatency)
+		defer cancel()
+	}
+	preExpired := func() bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		// The Dispatcher deadline may expire before the preCtx one is reported.
+		deadline, ok := preCtx.Deadline()
+		return preCtx.Err() != nil || ok && !time.Now().Before(deadline)
+	}
 
 	defer func() {
 		if rev == nil || !rev.Config().IsActive {
@@ -186,10 +304,29 @@ func (rt *RoundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 			t1 = time.Now()
 		}
 		rev.T1 = t1
+		rev.captureContentTypes()
+		rev.MethodOverride = methodOverride(rev.Request())
+		rev.captureTransferEncodings()
+		rev.captureRequestBodyTransmission()
+		rev.captureLengthMismatches()
+		rev.captureRequestTrailers()
+		rev.ResolvedAddresses = dns.Addresses()
+		rev.ConnectionReuse = conns.ConnectionReuse()
+		rev.ResponseStreaming = streaming
+		rev.StreamID = streams.StreamID(rev.Response())
+		if rt.CaptureCallerStack && rev.Error != nil {
+			rev.CallerStack = callerStack()
+		}
 		_, _ = rt.Dispatch(ctx, rev)
 	}()
 
-	if prevEvent, err = rt.stageConnect(ctx, request.URL); err != nil {
+	if prevEvent, err = rt.stageConnect(preCtx, request.URL); err != nil {
+		if preExpired() {
+			return rt.bypass(request)
+		}
+		if listenerTimedOut(preCtx, err) {
+			return rt.bypassTimeout(request, TopicConnect)
+		}
 		rev = NewReportEvent(proxy.StageConnect, err)
 		rev.SetRequest(request)
 		rev.SetConfig(prevEvent.Config())
@@ -197,7 +334,13 @@ func (rt *RoundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 		return nil, err
 	}
 
-	if prevEvent, err = rt.stageRequest(prevEvent, request); err != nil {
+	if prevEvent, err = rt.stageRequest(preCtx, prevEvent, request); err != nil {
+		if preExpired() {
+			return rt.bypass(request)
+		}
+		if listenerTimedOut(preCtx, err) {
+			return rt.bypassTimeout(request, TopicRequest)
+		}
 		rev = NewReportEvent(proxy.StageRequest, err)
 		rev.SetRequest(request)
 		rev.SetConfig(prevEvent.Config())
@@ -206,16 +349,17 @@ func (rt *RoundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 	}
 
 	if request.Body != nil {
-		request.Body = NewBodyReadCloser(request.Body, MaximumBodySize+1)
+		request.Body = NewBodyReadCloser(request.Body, peekSize(rt.MaxRequestBodySize))
 	}
 
 	// Perform and time the underlying API call, without resBody capture.
 	t0 = time.Now()
-	response, rtErr := rt.Underlying.RoundTrip(request)
+	response, rtErr := rt.Underlying.RoundTrip(streams.record(conns.trace(dns.trace(request))))
 	t1 = time.Now()
 
-	if response != nil && response.Body != nil {
-		response.Body = NewBodyReadCloser(response.Body, MaximumBodySize+1)
+	streaming = rt.isStream(response)
+	if response != nil && response.Body != nil && !streaming {
+		response.Body = NewBodyReadCloser(response.Body, peekSize(rt.MaxResponseBodySize))
 	}
 
 	if prevEvent, err = rt.stageResponse(ctx, prevEvent, request, response, rtErr); err != nil {
@@ -227,6 +371,10 @@ func (rt *RoundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 		rev.SetRequest(request).SetResponse(response)
 		rev.SetConfig(prevEvent.Config())
 		rev.SetTriggeredDataCollectionRules(prevEvent.TriggeredDataCollectionRules())
+		// Listener timeouts set on the Dispatcher are reported, but do not fail the call.
+		if rtErr == nil && listenerTimedOut(ctx, err) {
+			return rev.Response(), nil
+		}
 		return rev.Response(), err
 	}
 
@@ -234,5 +382,9 @@ func (rt *RoundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 	if rev == nil {
 		return response, rtErr
 	}
+	// Listener timeouts set on the Dispatcher are reported, but do not fail the call.
+	if listenerTimedOut(ctx, rev.Err()) {
+		return rev.Response(), nil
+	}
 	return rev.Response(), rev.Err()
 }
@@ -4,13 +4,20 @@ import (
 	"context"
 	"errors"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/filters"
+	"github.com/bearer/go-agent/proxy"
 )
 
 const defaultTestURL = `http://localhost:80`
@@ -106,3 +113,829 @@ func TestRoundTripper_RoundTrip(t *testing.T) {
 		})
 	}
 }
+
+func TestRoundTripper_RoundTripPaused(t *testing.T) {
+	paused := true
+	dispatched := 0
+	d := events.NewDispatcher()
+	d.AddProviders(TopicConnect, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+		dispatched++
+		return nil
+	}))
+	rt := &RoundTripper{
+		Dispatcher: d,
+		Underlying: testRoundTripper{},
+		Paused:     func() bool { return paused },
+	}
+	req, _ := http.NewRequest(http.MethodGet, defaultTestURL, nil)
+
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	if dispatched != 0 {
+		t.Errorf("RoundTrip() dispatched %d events while paused", dispatched)
+	}
+
+	paused = false
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	if dispatched == 0 {
+		t.Error("RoundTrip() dispatched no event after resuming")
+	}
+}
+
+func TestRoundTripper_RoundTripIgnoredHosts(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		wantDispatched bool
+	}{
+		{`ignored host`, `http://metrics.example.com:8080/push`, false},
+		{`other host`, `http://api.example.com/v1`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dispatched := 0
+			d := events.NewDispatcher()
+			d.AddProviders(TopicConnect, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+				dispatched++
+				return nil
+			}))
+			rt := &RoundTripper{
+				Dispatcher:   d,
+				Underlying:   testRoundTripper{},
+				IgnoredHosts: []*regexp.Regexp{regexp.MustCompile(`^metrics\.`)},
+			}
+			req, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+
+			if _, err := rt.RoundTrip(req); err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			if (dispatched > 0) != tt.wantDispatched {
+				t.Errorf("RoundTrip() dispatched %d events, wantDispatched %t", dispatched, tt.wantDispatched)
+			}
+		})
+	}
+}
+
+// zeroReader is an endless source of zero bytes.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestRoundTripper_RoundTripRequestBodyTransmission(t *testing.T) {
+	// Large enough for the server not to drain it, nor the sockets to buffer it.
+	const size = 16 << 20
+	tests := []struct {
+		name        string
+		readLimit   int64
+		wantPartial bool
+	}{
+		{`fully read`, size, false},
+		{`partially read`, 10, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.Copy(ioutil.Discard, io.LimitReader(r.Body, tt.readLimit))
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer ts.Close()
+
+			var re *ReportEvent
+			d := events.NewDispatcher()
+			d.AddProviders(TopicReport, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+				return []events.Listener{func(_ context.Context, e events.Event) error {
+					re = e.(*ReportEvent)
+					return nil
+				}}
+			}))
+			rt := &RoundTripper{
+				Dispatcher: d,
+				Underlying: ts.Client().Transport,
+			}
+			req, _ := http.NewRequest(http.MethodPost, ts.URL, io.LimitReader(zeroReader{}, size))
+			req.ContentLength = size
+
+			// Depending on timing, the transport may fail writing the partially
+			// read body, but the call is reported anyway.
+			res, err := rt.RoundTrip(req)
+			if err == nil {
+				_ = res.Body.Close()
+			} else if !tt.wantPartial {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			if re == nil {
+				t.Fatal("no report dispatched")
+			}
+			if re.RequestBodyPartial != tt.wantPartial {
+				t.Errorf("RequestBodyPartial = %t, wantPartial %t", re.RequestBodyPartial, tt.wantPartial)
+			}
+			if (re.RequestBodyBytesRead < size) != tt.wantPartial {
+				t.Errorf("RequestBodyBytesRead = %d for a %d bytes body, wantPartial %t", re.RequestBodyBytesRead, size, tt.wantPartial)
+			}
+			ll := Restricted
+			rl := ll.Prepare(re)
+			if rl.RequestBodyPartial != tt.wantPartial || int64(rl.RequestBodyBytesRead) != re.RequestBodyBytesRead {
+				t.Errorf("report body transmission = %d/%t, expected %d/%t",
+					rl.RequestBodyBytesRead, rl.RequestBodyPartial, re.RequestBodyBytesRead, tt.wantPartial)
+			}
+		})
+	}
+}
+
+func TestRoundTripper_RoundTripTransferEncoding(t *testing.T) {
+	const body = `{"id":1}`
+	tests := []struct {
+		name        string
+		chunked     bool
+		wantChunked bool
+	}{
+		{`chunked`, true, true},
+		{`content length`, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if !tt.chunked {
+					w.Header().Set(`Content-Length`, strconv.Itoa(len(body)))
+				}
+				_, _ = w.Write([]byte(body))
+				if tt.chunked {
+					// Flushing before the end of the handler forces chunking.
+					w.(http.Flusher).Flush()
+				}
+			}))
+			defer ts.Close()
+
+			var re *ReportEvent
+			d := events.NewDispatcher()
+			d.AddProviders(TopicReport, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+				return []events.Listener{func(_ context.Context, e events.Event) error {
+					re = e.(*ReportEvent)
+					return nil
+				}}
+			}))
+			rt := &RoundTripper{
+				Dispatcher: d,
+				Underlying: ts.Client().Transport,
+			}
+			req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+			res, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			_ = res.Body.Close()
+			if re == nil {
+				t.Fatal("no report dispatched")
+			}
+
+			ll := Restricted
+			rl := ll.Prepare(re)
+			if rl.ResponseChunked != tt.wantChunked {
+				t.Errorf("ResponseChunked = %t, want %t", rl.ResponseChunked, tt.wantChunked)
+			}
+			if rl.RequestChunked {
+				t.Error("RequestChunked = true for a request without body")
+			}
+		})
+	}
+}
+
+func TestRoundTripper_RoundTripLengthMismatch(t *testing.T) {
+	const body = `hello`
+	tests := []struct {
+		name                 string
+		method               string
+		requestLength        int64
+		response             string
+		wantRequestMismatch  bool
+		wantResponseMismatch bool
+	}{
+		{`matching`, http.MethodPost, 5, "Content-Length: 5\r\n\r\nhello", false, false},
+		{`long request`, http.MethodPost, 10, "Content-Length: 5\r\n\r\nhello", true, false},
+		{`short response`, http.MethodPost, 5, "Content-Length: 10\r\n\r\nhello", false, true},
+		{`chunked response`, http.MethodPost, 5, "Transfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n", false, false},
+		{`HEAD response`, http.MethodHead, 0, "Content-Length: 10\r\n\r\n", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Hijacking allows sending a Content-Length not matching the body.
+			// The handler may outlive the subtest when the request fails.
+			response := tt.response
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.Copy(ioutil.Discard, r.Body)
+				conn, buf, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				_, _ = buf.WriteString("HTTP/1.1 200 OK\r\nConnection: close\r\n" + response)
+				_ = buf.Flush()
+			}))
+			defer ts.Close()
+
+			var re *ReportEvent
+			d := events.NewDispatcher()
+			// Response bodies are only compared once peeked by the parser.
+			d.AddProviders(TopicBodies, BodyParsingProvider{})
+			d.AddProviders(TopicReport, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+				return []events.Listener{func(_ context.Context, e events.Event) error {
+					re = e.(*ReportEvent)
+					return nil
+				}}
+			}))
+			rt := &RoundTripper{
+				Dispatcher: d,
+				Underlying: ts.Client().Transport,
+			}
+			var reqBody io.Reader
+			if tt.method != http.MethodHead {
+				reqBody = strings.NewReader(body)
+			}
+			req, _ := http.NewRequest(tt.method, ts.URL, reqBody)
+			req.ContentLength = tt.requestLength
+
+			// The transport fails sending requests longer than their body.
+			if res, err := rt.RoundTrip(req); err == nil {
+				_ = res.Body.Close()
+			}
+			if re == nil {
+				t.Fatal("no report dispatched")
+			}
+
+			ll := Restricted
+			rl := ll.Prepare(re)
+			if rl.RequestLengthMismatch != tt.wantRequestMismatch {
+				t.Errorf("RequestLengthMismatch = %t, want %t", rl.RequestLengthMismatch, tt.wantRequestMismatch)
+			}
+			if rl.ResponseLengthMismatch != tt.wantResponseMismatch {
+				t.Errorf("ResponseLengthMismatch = %t, want %t", rl.ResponseLengthMismatch, tt.wantResponseMismatch)
+			}
+		})
+	}
+}
+
+func TestRoundTripper_RoundTripListenerTimeout(t *testing.T) {
+	const body = `{"id":1}`
+	const timeout = 20 * time.Millisecond
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	var re *ReportEvent
+	d := events.NewDispatcher()
+	d.AddProviders(TopicBodies, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+		return []events.Listener{func(ctx context.Context, _ events.Event) error {
+			// A listener slower than the topic timeout.
+			select {
+			case <-ctx.Done():
+			case <-time.After(10 * timeout):
+			}
+			return nil
+		}}
+	}))
+	d.AddProviders(TopicReport, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+		return []events.Listener{func(_ context.Context, e events.Event) error {
+			re = e.(*ReportEvent)
+			return nil
+		}}
+	}))
+	d.(events.TimeoutDispatcher).SetTimeout(TopicBodies, timeout)
+	rt := &RoundTripper{
+		Dispatcher: d,
+		Underlying: ts.Client().Transport,
+	}
+
+	t0 := time.Now()
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	res, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	actual, err := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil || string(actual) != body {
+		t.Errorf("response body = %q, %v, expected %q", actual, err, body)
+	}
+	if elapsed := time.Since(t0); elapsed >= 10*timeout {
+		t.Errorf("API call took %v, expected the listener to be aborted after %v", elapsed, timeout)
+	}
+	if re == nil {
+		t.Fatal("no report dispatched")
+	}
+	if !errors.Is(re.Error, context.DeadlineExceeded) {
+		t.Errorf("report error = %v, expected a DeadlineExceeded error", re.Error)
+	}
+	if req.Context().Err() != nil {
+		t.Errorf("request context error = %v, expected none", req.Context().Err())
+	}
+}
+
+func TestRoundTripper_RoundTripStageListenerTimeout(t *testing.T) {
+	const body = `{"id":1}`
+	const timeout = 20 * time.Millisecond
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		topic    events.Topic
+		reported bool
+	}{
+		{TopicConnect, false},
+		{TopicRequest, false},
+		{TopicResponse, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.topic), func(t *testing.T) {
+			var re *ReportEvent
+			var warnings []string
+			d := events.NewDispatcher()
+			d.AddProviders(tt.topic, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+				return []events.Listener{func(ctx context.Context, _ events.Event) error {
+					// A listener slower than the topic timeout.
+					select {
+					case <-ctx.Done():
+					case <-time.After(10 * timeout):
+					}
+					return nil
+				}}
+			}))
+			d.AddProviders(TopicReport, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+				return []events.Listener{func(_ context.Context, e events.Event) error {
+					re = e.(*ReportEvent)
+					return nil
+				}}
+			}))
+			d.(events.TimeoutDispatcher).SetTimeout(tt.topic, timeout)
+			rt := &RoundTripper{
+				Dispatcher: d,
+				Underlying: ts.Client().Transport,
+				Warn: func(msg string, _ map[string]interface{}) {
+					warnings = append(warnings, msg)
+				},
+			}
+
+			t0 := time.Now()
+			req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+			res, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			actual, err := ioutil.ReadAll(res.Body)
+			_ = res.Body.Close()
+			if err != nil || string(actual) != body {
+				t.Errorf("response body = %q, %v, expected %q", actual, err, body)
+			}
+			if elapsed := time.Since(t0); elapsed >= 10*timeout {
+				t.Errorf("API call took %v, expected the listener to be aborted after %v", elapsed, timeout)
+			}
+			if (re != nil) != tt.reported {
+				t.Fatalf("report dispatched: %t, expected %t", re != nil, tt.reported)
+			}
+			if tt.reported && !errors.Is(re.Error, context.DeadlineExceeded) {
+				t.Errorf("report error = %v, expected a DeadlineExceeded error", re.Error)
+			}
+			if !tt.reported && len(warnings) != 1 {
+				t.Errorf("warnings = %v, expected 1 warning", warnings)
+			}
+		})
+	}
+}
+
+func TestRoundTripper_RoundTripMaxInstrumentationLatency(t *testing.T) {
+	const (
+		body    = `{"id":1}`
+		latency = 20 * time.Millisecond
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	// Each listener is faster than the budget, but not all of them together.
+	slow := events.ListenerProviderFunc(func(events.Event) []events.Listener {
+		return []events.Listener{func(context.Context, events.Event) error {
+			time.Sleep(latency * 2 / 3)
+			return nil
+		}}
+	})
+	tests := []struct {
+		name         string
+		budget       time.Duration
+		instrumented bool
+	}{
+		{`unbounded`, 0, true},
+		{`within budget`, 10 * latency, true},
+		{`budget exhausted`, latency, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reported bool
+			var warnings []string
+			d := events.NewDispatcher()
+			d.AddProviders(TopicConnect, slow)
+			d.AddProviders(TopicRequest, slow, slow)
+			d.AddProviders(TopicReport, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+				return []events.Listener{func(context.Context, events.Event) error {
+					reported = true
+					return nil
+				}}
+			}))
+			rt := &RoundTripper{
+				Dispatcher:                d,
+				Underlying:                ts.Client().Transport,
+				MaxInstrumentationLatency: tt.budget,
+				Warn: func(msg string, _ map[string]interface{}) {
+					warnings = append(warnings, msg)
+				},
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+			res, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			actual, err := ioutil.ReadAll(res.Body)
+			_ = res.Body.Close()
+			if err != nil || string(actual) != body {
+				t.Errorf("response body = %q, %v, expected %q", actual, err, body)
+			}
+			if reported != tt.instrumented {
+				t.Errorf("reported = %t, expected %t", reported, tt.instrumented)
+			}
+			if (len(warnings) == 0) != tt.instrumented {
+				t.Errorf("warnings = %v, expected some: %t", warnings, !tt.instrumented)
+			}
+		})
+	}
+}
+
+func TestRoundTripper_RoundTripMaxInstrumentationLatencyListenerTimeout(t *testing.T) {
+	const latency = 20 * time.Millisecond
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	defer ts.Close()
+
+	var warnings []string
+	d := events.NewDispatcher()
+	d.AddProviders(TopicRequest, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+		return []events.Listener{func(ctx context.Context, _ events.Event) error {
+			select {
+			case <-ctx.Done():
+			case <-time.After(20 * latency):
+			}
+			return nil
+		}}
+	}))
+	// The topic timeout is longer than the latency budget, which still applies.
+	d.(events.TimeoutDispatcher).SetTimeout(TopicRequest, 10*latency)
+	rt := &RoundTripper{
+		Dispatcher:                d,
+		Underlying:                ts.Client().Transport,
+		MaxInstrumentationLatency: latency,
+		Warn: func(msg string, _ map[string]interface{}) {
+			warnings = append(warnings, msg)
+		},
+	}
+
+	t0 := time.Now()
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	res, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	_ = res.Body.Close()
+	if elapsed := time.Since(t0); elapsed >= 5*latency {
+		t.Errorf("API call took %v, expected the latency budget of %v to apply", elapsed, latency)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], `latency`) {
+		t.Errorf("warnings = %v, expected an instrumentation latency warning", warnings)
+	}
+}
+
+// trailerReader sets the request trailers once its body is read, like
+// uploads computing a checksum while streaming.
+type trailerReader struct {
+	io.Reader
+	trailer http.Header
+}
+
+func (r trailerReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		r.trailer.Set(`Checksum`, `abc`)
+		r.trailer.Set(`Api-Key`, `hunter2`)
+	}
+	return n, err
+}
+
+func TestRoundTripper_RoundTripRequestTrailers(t *testing.T) {
+	var received http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = ioutil.ReadAll(r.Body)
+		received = r.Trailer
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		level    LogLevel
+		expected http.Header
+	}{
+		{`all`, All, http.Header{`Checksum`: {`abc`}, `Api-Key`: {Filtered}}},
+		{`restricted`, Restricted, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rl proxy.ReportLog
+			d := events.NewDispatcher()
+			d.AddProviders(TopicConnect, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+				return []events.Listener{func(_ context.Context, e events.Event) error {
+					e.(APIEvent).Config().LogLevel = tt.level
+					return nil
+				}}
+			}))
+			d.AddProviders(TopicReport,
+				SanitizationProvider{SensitiveKeys: []*regexp.Regexp{DefaultSensitiveKeys}},
+				events.ListenerProviderFunc(func(events.Event) []events.Listener {
+					return []events.Listener{func(_ context.Context, e events.Event) error {
+						re := e.(*ReportEvent)
+						rl = re.Config().LogLevel.Prepare(re)
+						return nil
+					}}
+				}),
+			)
+			rt := &RoundTripper{
+				Dispatcher: d,
+				Underlying: ts.Client().Transport,
+			}
+
+			req, _ := http.NewRequest(http.MethodPost, ts.URL, nil)
+			// Trailers are declared before sending, and their values set later.
+			req.Trailer = http.Header{`Checksum`: nil, `Api-Key`: nil}
+			req.Body = ioutil.NopCloser(trailerReader{strings.NewReader(`data`), req.Trailer})
+			req.ContentLength = -1
+			res, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			_ = res.Body.Close()
+
+			if received.Get(`Checksum`) != `abc` {
+				t.Errorf("server received trailers %v, expected a Checksum", received)
+			}
+			if !reflect.DeepEqual(rl.RequestTrailers, tt.expected) {
+				t.Errorf("reported trailers = %v, expected %v", rl.RequestTrailers, tt.expected)
+			}
+			if req.Trailer.Get(`Api-Key`) != `hunter2` {
+				t.Errorf("request trailers modified by sanitization: %v", req.Trailer)
+			}
+		})
+	}
+}
+
+type testJSONRoundTripper struct{}
+
+func (testJSONRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	res := http.Response{
+		Header:  http.Header{proxy.ContentTypeHeader: {proxy.FullContentTypeJSON}},
+		Request: request,
+	}
+	return &res, nil
+}
+
+func TestRoundTripper_RoundTripContentTypes(t *testing.T) {
+	var rl proxy.ReportLog
+	d := events.NewDispatcher()
+	d.AddProviders(TopicConnect, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+		return []events.Listener{func(_ context.Context, e events.Event) error {
+			e.(APIEvent).Config().LogLevel = All
+			return nil
+		}}
+	}))
+	d.AddProviders(TopicReport,
+		SanitizationProvider{SensitiveKeys: []*regexp.Regexp{regexp.MustCompile(`(?i)^content-type$`)}},
+		events.ListenerProviderFunc(func(events.Event) []events.Listener {
+			return []events.Listener{func(_ context.Context, e events.Event) error {
+				re := e.(*ReportEvent)
+				rl = re.Config().LogLevel.Prepare(re)
+				return nil
+			}}
+		}),
+	)
+	rt := &RoundTripper{
+		Dispatcher: d,
+		Underlying: testJSONRoundTripper{},
+	}
+	req, _ := http.NewRequest(http.MethodPost, defaultTestURL, nil)
+	req.Header.Set(proxy.ContentTypeHeader, proxy.ContentTypeSimpleForm)
+
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	if actual := rl.RequestHeaders.Get(proxy.ContentTypeHeader); actual != Filtered {
+		t.Errorf("request Content-Type header = %s, expected it to be filtered", actual)
+	}
+	if actual := rl.ResponseHeaders.Get(proxy.ContentTypeHeader); actual != Filtered {
+		t.Errorf("response Content-Type header = %s, expected it to be filtered", actual)
+	}
+	if rl.RequestBodyContentType != proxy.ContentTypeSimpleForm {
+		t.Errorf("RequestBodyContentType = %s, want %s", rl.RequestBodyContentType, proxy.ContentTypeSimpleForm)
+	}
+	if rl.ResponseBodyContentType != proxy.FullContentTypeJSON {
+		t.Errorf("ResponseBodyContentType = %s, want %s", rl.ResponseBodyContentType, proxy.FullContentTypeJSON)
+	}
+}
+
+func TestRoundTripper_RoundTripRequestContentTypeFilter(t *testing.T) {
+	restricted := Restricted
+	jsonRule := &DataCollectionRule{
+		Filter: &filters.RequestContentTypeFilter{
+			RegexpMatcher: filters.NewRegexpMatcher(regexp.MustCompile(`(?i)^application/json\b`)),
+		},
+		LogLevel: &restricted,
+	}
+	tests := []struct {
+		name          string
+		contentType   string
+		expectedLevel string
+	}{
+		{`json`, proxy.FullContentTypeJSON, `RESTRICTED`},
+		{`form`, proxy.ContentTypeSimpleForm, `DETECTED`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt, capture := NewTestRoundTripper(DCRProvider{DCRs: []*DataCollectionRule{jsonRule}})
+			rt.Underlying = testJSONRoundTripper{}
+			req, _ := http.NewRequest(http.MethodPost, defaultTestURL, strings.NewReader(`{}`))
+			req.Header.Set(proxy.ContentTypeHeader, tt.contentType)
+			if _, err := rt.RoundTrip(req); err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+
+			rl := capture.Last()
+			if rl == nil {
+				t.Fatal("no report captured")
+			}
+			if rl.LogLevel != tt.expectedLevel {
+				t.Fatalf("LogLevel = %s, want %s", rl.LogLevel, tt.expectedLevel)
+			}
+			if tt.expectedLevel == `RESTRICTED` && rl.RequestBodyContentType != tt.contentType {
+				t.Errorf("RequestBodyContentType = %s, want %s", rl.RequestBodyContentType, tt.contentType)
+			}
+		})
+	}
+}
+
+// redirectingTransport is a http.RoundTripper following redirects.
+type redirectingTransport struct {
+	client *http.Client
+}
+
+func (t redirectingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	return t.client.Do(request)
+}
+
+func TestRoundTripper_RoundTripRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(`/start`, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, `/final?password=final-secret&page=2`, http.StatusFound)
+	})
+	mux.HandleFunc(`/final`, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	tests := []struct {
+		name          string
+		path          string
+		wantURL       string
+		wantFinalPath string
+	}{
+		{`redirected`, `/start?password=start-secret&page=1`,
+			`/start?page=1&password=%5BFILTERED%5D`, `/final?page=2&password=%5BFILTERED%5D`},
+		{`not redirected`, `/final?page=3`, `/final?page=3`, ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var re *ReportEvent
+			d := events.NewDispatcher()
+			d.AddProviders(TopicReport,
+				SanitizationProvider{
+					SensitiveKeys:    []*regexp.Regexp{DefaultSensitiveKeys},
+					SensitiveRegexps: []*regexp.Regexp{DefaultSensitiveData},
+				},
+				events.ListenerProviderFunc(func(events.Event) []events.Listener {
+					return []events.Listener{func(_ context.Context, e events.Event) error {
+						re = e.(*ReportEvent)
+						return nil
+					}}
+				}))
+			rt := &RoundTripper{
+				Dispatcher: d,
+				Underlying: redirectingTransport{client: ts.Client()},
+			}
+			req, _ := http.NewRequest(http.MethodGet, ts.URL+tt.path, nil)
+			res, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			_ = res.Body.Close()
+			if re == nil {
+				t.Fatal("no report dispatched")
+			}
+
+			ll := Restricted
+			rl := ll.Prepare(re)
+			if want := ts.URL + tt.wantURL; rl.URL != want {
+				t.Errorf("URL = %s, want %s", rl.URL, want)
+			}
+			wantFinal := ``
+			if tt.wantFinalPath != `` {
+				wantFinal = ts.URL + tt.wantFinalPath
+			}
+			if rl.FinalURL != wantFinal {
+				t.Errorf("FinalURL = %s, want %s", rl.FinalURL, wantFinal)
+			}
+		})
+	}
+}
+
+func TestRoundTripper_RoundTripMaxBodySizes(t *testing.T) {
+	const (
+		shortBody = `{"id":1}`
+		longBody  = `{"id":1,"name":"bearer"}`
+	)
+	tests := []struct {
+		name                      string
+		maxRequest, maxResponse   int
+		request, response         string
+		wantRequest, wantResponse interface{}
+	}{
+		{`default`, 0, 0, longBody, longBody,
+			map[string]interface{}{`id`: 1.0, `name`: `bearer`}, map[string]interface{}{`id`: 1.0, `name`: `bearer`}},
+		{`request truncated`, 16, 64, longBody, longBody,
+			BodyTooLong, map[string]interface{}{`id`: 1.0, `name`: `bearer`}},
+		{`response truncated`, 64, 16, longBody, longBody,
+			map[string]interface{}{`id`: 1.0, `name`: `bearer`}, BodyTooLong},
+		{`short bodies`, 16, 16, shortBody, shortBody,
+			map[string]interface{}{`id`: 1.0}, map[string]interface{}{`id`: 1.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := tt.response
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.Copy(ioutil.Discard, r.Body)
+				w.Header().Set(proxy.ContentTypeHeader, proxy.ContentTypeJSON)
+				_, _ = w.Write([]byte(response))
+			}))
+			defer ts.Close()
+
+			var re *ReportEvent
+			d := events.NewDispatcher()
+			d.AddProviders(TopicBodies, BodyParsingProvider{})
+			d.AddProviders(TopicReport, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+				return []events.Listener{func(_ context.Context, e events.Event) error {
+					re = e.(*ReportEvent)
+					return nil
+				}}
+			}))
+			rt := &RoundTripper{
+				Dispatcher:          d,
+				Underlying:          ts.Client().Transport,
+				MaxRequestBodySize:  tt.maxRequest,
+				MaxResponseBodySize: tt.maxResponse,
+			}
+			req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(tt.request))
+			req.Header.Set(proxy.ContentTypeHeader, proxy.ContentTypeJSON)
+			res, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			body, _ := ioutil.ReadAll(res.Body)
+			_ = res.Body.Close()
+			if string(body) != tt.response {
+				t.Errorf("response body = %s, want %s", body, tt.response)
+			}
+			if re == nil {
+				t.Fatal("no report dispatched")
+			}
+			if !reflect.DeepEqual(re.RequestBody, tt.wantRequest) {
+				t.Errorf("RequestBody = %#v, want %#v", re.RequestBody, tt.wantRequest)
+			}
+			if !reflect.DeepEqual(re.ResponseBody, tt.wantResponse) {
+				t.Errorf("ResponseBody = %#v, want %#v", re.ResponseBody, tt.wantResponse)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package interception
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/rand"
+	"net/http"
+
+	"github.com/bearer/go-agent/events"
+)
+
+// SamplingProvider is an events.ListenerProvider returning a listener which
+// only lets a sample of the API calls be reported.
+//
+// The sampling decision is only taken at the TopicReport stage, once the call
+// outcome is known, allowing failed calls to be sampled at a different rate
+// than successful ones.
+type SamplingProvider struct {
+	// SuccessRate is the ratio of successful calls to report, in [0, 1].
+	SuccessRate float64
+
+	// ErrorRate is the ratio of failed calls to report, in [0, 1]. Calls are
+	// considered to have failed if they returned an error, or a response with
+	// a status code indicating a client or server error.
+	ErrorRate float64
+
+	// Random returns pseudo-random numbers in [0, 1). If nil, rand.Float64 is used.
+	Random func() float64
+
+	// Key, if not nil, returns an identity for the request of a call, like a
+	// user or correlation ID, used instead of Random to take the sampling
+	// decision, so that calls with the same key are consistently sampled at a
+	// given rate. Calls for which it returns an empty string are sampled
+	// randomly.
+	Key func(*http.Request) string
+}
+
+// keyRatio deterministically maps a sampling key to a number in [0, 1).
+func keyRatio(key string) float64 {
+	sum := sha256.Sum256([]byte(key))
+	// Keep 53 bits, the float64 mantissa size, for an exact conversion.
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53)
+}
+
+// IsErrorCall checks whether the API call in an event failed, either on a
+// connection error, or with an HTTP error status.
+func IsErrorCall(e events.Event) bool {
+	if e.Err() != nil {
+		return true
+	}
+	response := e.Response()
+	return response != nil && response.StatusCode >= http.StatusBadRequest
+}
+
+// SampleReport stops the report dispatch for calls excluded from the sample.
+func (p SamplingProvider) SampleReport(_ context.Context, e events.Event) error {
+	rate := p.SuccessRate
+	if IsErrorCall(e) {
+		rate = p.ErrorRate
+	}
+	if rate >= 1 {
+		return nil
+	}
+
+	if rate <= 0 || p.ratio(e) >= rate {
+		return events.DispatchStopRequest
+	}
+	return nil
+}
+
+// ratio returns the number in [0, 1) compared to the sampling rate of a call.
+func (p SamplingProvider) ratio(e events.Event) float64 {
+	if p.Key != nil && e.Request() != nil {
+		if key := p.Key(e.Request()); key != `` {
+			return keyRatio(key)
+		}
+	}
+	if p.Random != nil {
+		return p.Random()
+	}
+	return rand.Float64()
+}
+
+// Listeners implements the events.ListenerProvider interface.
+func (p SamplingProvider) Listeners(e events.Event) []events.Listener {
+	if e.Topic() != TopicReport {
+		return nil
+	}
+
+	return []events.Listener{p.SampleReport}
+}
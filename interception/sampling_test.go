@@ -0,0 +1,137 @@
+package interception
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/proxy"
+)
+
+func TestIsErrorCall(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		response *http.Response
+		want     bool
+	}{
+		{`no response`, nil, nil, false},
+		{`success`, nil, &http.Response{StatusCode: http.StatusOK}, false},
+		{`redirect`, nil, &http.Response{StatusCode: http.StatusFound}, false},
+		{`client error`, nil, &http.Response{StatusCode: http.StatusNotFound}, true},
+		{`server error`, nil, &http.Response{StatusCode: http.StatusBadGateway}, true},
+		{`connection error`, errors.New(`oops`), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewReportEvent(proxy.StageBodies, tt.err)
+			e.SetResponse(tt.response)
+			if got := IsErrorCall(e); got != tt.want {
+				t.Errorf("IsErrorCall() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSamplingProvider_SampleReport(t *testing.T) {
+	const iterations = 10000
+	tests := []struct {
+		name               string
+		success, errorRate float64
+	}{
+		{`all`, 1, 1},
+		{`none`, 0, 0},
+		{`errors favored`, 0.1, 0.9},
+		{`successes favored`, 0.75, 0.25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reportedSuccesses, reportedErrors int
+			counter := events.ListenerProviderFunc(func(e events.Event) []events.Listener {
+				return []events.Listener{func(_ context.Context, e events.Event) error {
+					if IsErrorCall(e) {
+						reportedErrors++
+					} else {
+						reportedSuccesses++
+					}
+					return nil
+				}}
+			})
+			p := SamplingProvider{
+				SuccessRate: tt.success,
+				ErrorRate:   tt.errorRate,
+				Random:      rand.New(rand.NewSource(1)).Float64,
+			}
+			d := events.NewDispatcher().AddProviders(TopicReport, p, counter)
+
+			for i := 0; i < iterations; i++ {
+				status := http.StatusOK
+				if i%2 == 1 {
+					status = http.StatusInternalServerError
+				}
+				e := NewReportEvent(proxy.StageBodies, nil)
+				e.SetResponse(&http.Response{StatusCode: status})
+				if _, err := d.Dispatch(context.Background(), e); err != nil {
+					t.Fatalf(`unexpected dispatch error: %v`, err)
+				}
+			}
+
+			check := func(kind string, reported int, rate float64) {
+				actual := float64(reported) / (iterations / 2)
+				if math.Abs(actual-rate) > 0.03 {
+					t.Errorf(`%s reported at rate %.3f, expected %.3f`, kind, actual, rate)
+				}
+			}
+			check(`successes`, reportedSuccesses, tt.success)
+			check(`errors`, reportedErrors, tt.errorRate)
+		})
+	}
+}
+
+func TestSamplingProvider_SampleReportKey(t *testing.T) {
+	const (
+		rate  = 0.3
+		users = 1000
+	)
+	p := SamplingProvider{
+		SuccessRate: rate,
+		ErrorRate:   1,
+		Key: func(r *http.Request) string {
+			return r.Header.Get(`X-User-ID`)
+		},
+		Random: func() float64 {
+			t.Fatal("Random called for a keyed call")
+			return 0
+		},
+	}
+	sample := func(user string) bool {
+		req, _ := http.NewRequest(http.MethodGet, defaultTestURL, nil)
+		req.Header.Set(`X-User-ID`, user)
+		e := NewReportEvent(proxy.StageBodies, nil)
+		e.SetRequest(req)
+		e.SetResponse(&http.Response{StatusCode: http.StatusOK})
+		return p.SampleReport(context.Background(), e) == nil
+	}
+
+	sampled := 0
+	for i := 0; i < users; i++ {
+		user := strconv.Itoa(i)
+		first := sample(user)
+		for j := 0; j < 5; j++ {
+			if sample(user) != first {
+				t.Fatalf("inconsistent sampling decision for key %s", user)
+			}
+		}
+		if first {
+			sampled++
+		}
+	}
+	if actual := float64(sampled) / users; math.Abs(actual-rate) > 0.05 {
+		t.Errorf(`keys sampled at rate %.3f, expected %.3f`, actual, rate)
+	}
+}
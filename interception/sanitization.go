@@ -2,11 +2,18 @@ package interception
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
 	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/bearer/go-agent/events"
 )
@@ -14,17 +21,132 @@ import (
 // Filtered is a well-known string replacing filtered-out content.
 const Filtered = `[FILTERED]`
 
+// TruncatedQueryMarker is the name of the query parameter appended to reported
+// URLs whose query parameters were truncated, with the number of parameters
+// dropped as its value.
+const TruncatedQueryMarker = `__bearer_truncated`
+
 // DefaultSensitiveKeys is the expression used for sensitive keys if no other value is set.
 var DefaultSensitiveKeys = regexp.MustCompile(`(?i)^(authorization|password|secret|passwd|api.?key|access.?token|auth.?token|credentials|mysql_pwd|stripetoken|card.?number.?|secret|client.?id|client.?secret)$`)
 
 // DefaultSensitiveData is the expression used for sensitive data if no other value is set.
 var DefaultSensitiveData = regexp.MustCompile("(?i)[a-z0-9]{1}[a-z0-9.!#$%&’*+=?^_\"{|}~-]+@[a-z0-9-]+(?:\\.[a-z0-9-]+)*|(?:\\d[ -]*?){13,16}")
 
+// RedactionStrategy defines how sanitization replaces sensitive values.
+type RedactionStrategy int
+
+const (
+	// RedactionMask replaces sensitive values with Filtered. It is the default.
+	RedactionMask RedactionStrategy = iota
+
+	// RedactionHash replaces sensitive values with "[HASH:" followed by the
+	// first 16 hexadecimal digits of their HMAC-SHA256 and "]", so that equal
+	// values can be correlated across reports without being revealed.
+	RedactionHash
+
+	// RedactionRemove removes the headers, query parameters, cookies and body
+	// fields with sensitive keys, and the sensitive data in other values.
+	RedactionRemove
+)
+
+// IsValid checks whether a RedactionStrategy is one of the defined strategies.
+func (s RedactionStrategy) IsValid() bool {
+	return s >= RedactionMask && s <= RedactionRemove
+}
+
+// CoalesceRegexps combines regular expressions into a single alternation
+// matching whatever any of them matches, keeping their flags, so that values
+// are matched in one pass instead of one per expression. It returns a slice, to
+// be used as SanitizationProvider.SensitiveKeys or SensitiveRegexps, which is
+// unchanged if it holds less than 2 expressions.
+//
+// When matches of different expressions overlap, only the leftmost one is
+// found by the combined expression.
+func CoalesceRegexps(res []*regexp.Regexp) []*regexp.Regexp {
+	if len(res) < 2 {
+		return res
+	}
+	parts := make([]string, len(res))
+	for i, re := range res {
+		// Flags set in a group only apply within it.
+		parts[i] = `(?:` + re.String() + `)`
+	}
+	// Valid expressions combine into a valid expression.
+	return []*regexp.Regexp{regexp.MustCompile(strings.Join(parts, `|`))}
+}
+
 // SanitizationProvider is an events.Listener provider returning listeners based
 // on the sensitive keys and regexps.
 type SanitizationProvider struct {
 	SensitiveKeys    []*regexp.Regexp
 	SensitiveRegexps []*regexp.Regexp
+
+	// MaxBodyDepth is the maximum nesting depth of the bodies sanitized. Content
+	// nested deeper is replaced with DepthLimitExceeded. 0 means no limit.
+	MaxBodyDepth int
+
+	// MaxQueryParams is the maximum number of query parameters included in
+	// reported URLs. Extra parameters are dropped, and TruncatedQueryMarker is
+	// appended. 0 means no limit.
+	MaxQueryParams int
+
+	// ExcludedBodyFields are the dotted paths, like "data.image", of the fields
+	// removed from the bodies. Paths traverse arrays, applying to each element.
+	ExcludedBodyFields []string
+
+	// Strict makes sanitization fail safe: instead of returning an error, a
+	// listener failing to sanitize a URL or body replaces it entirely with
+	// Filtered, to avoid reporting unsanitized data.
+	Strict bool
+
+	// Strategy defines how sensitive values are replaced, and HashKey is the
+	// HMAC key used by the RedactionHash strategy.
+	Strategy RedactionStrategy
+	HashKey  []byte
+
+	// Audit, if not nil, records the location of each redaction and the
+	// expression causing it, without the redacted value.
+	Audit *RedactionAuditor
+}
+
+// redact returns the replacement of a sensitive value, depending on the Strategy.
+func (p SanitizationProvider) redact(value string) string {
+	switch p.Strategy {
+	case RedactionHash:
+		mac := hmac.New(sha256.New, p.HashKey)
+		_, _ = mac.Write([]byte(value))
+		return `[HASH:` + hex.EncodeToString(mac.Sum(nil))[:16] + `]`
+	case RedactionRemove:
+		return ``
+	default:
+		return Filtered
+	}
+}
+
+// redactValues returns the replacements of the values of a header or query
+// parameter with a sensitive name: a single Filtered value when masking, none
+// when removing.
+func (p SanitizationProvider) redactValues(values []string) []string {
+	switch p.Strategy {
+	case RedactionHash:
+		redacted := make([]string, len(values))
+		for i, value := range values {
+			redacted[i] = p.redact(value)
+		}
+		return redacted
+	case RedactionRemove:
+		return nil
+	default:
+		return []string{Filtered}
+	}
+}
+
+// redactData replaces the parts of a value matched by a sensitive regexp.
+func (p SanitizationProvider) redactData(re *regexp.Regexp, value string) string {
+	if p.Strategy == RedactionMask {
+		return re.ReplaceAllLiteralString(value, Filtered)
+	}
+	return re.ReplaceAllStringFunc(value, p.redact)
 }
 
 // Listeners implements the events.ListenerProvider interface.
@@ -46,73 +168,198 @@ func (p SanitizationProvider) Listeners(e events.Event) []events.Listener {
 // invoked have differing implementations.
 // To avoid overwriting original values, sanitizeURL returns a new URL.
 func (p SanitizationProvider) sanitizeURL(u *url.URL) (*url.URL, error) {
+	const location = `request`
 	sanU, err := url.ParseRequestURI(u.String())
 	if err != nil {
 		return nil, err
 	}
-	in := u.Query()
+	in, dropped := p.truncateQuery(u.Query())
 	out := make(url.Values, len(in))
 
 Name:
 	for name, values := range in {
 		// Filter on keys, erasing all values.
-		for _, sk := range p.SensitiveKeys {
-			if sk.MatchString(name) {
-				out.Set(name, Filtered)
-				continue Name
+		if i := p.sensitiveKeyIndex(name); i >= 0 {
+			p.Audit.Record(auditLocation(location, `query`, name), AuditPatternKey, i)
+			for _, value := range p.redactValues(values) {
+				out.Add(name, value)
 			}
+			continue Name
 		}
 
 		// If the key didn't match replace the matching values.
 		for _, value := range values {
-			for _, sr := range p.SensitiveRegexps {
-				if sr.MatchString(value) {
-					value = sr.ReplaceAllLiteralString(value, Filtered)
-				}
-			}
-			out.Add(name, value)
+			out.Add(name, p.sanitizeValue(value, auditLocation(location, `query`, name)))
 		}
 	}
 	sanU.RawQuery = out.Encode()
-
-	for _, r := range p.SensitiveRegexps {
-		if r.MatchString(sanU.Path) {
-			sanU.Path = r.ReplaceAllLiteralString(sanU.Path, Filtered)
+	if dropped > 0 {
+		if sanU.RawQuery != `` {
+			sanU.RawQuery += `&`
 		}
+		sanU.RawQuery += TruncatedQueryMarker + `=` + strconv.Itoa(dropped)
 	}
+
+	sanU.Path = p.sanitizeValue(sanU.Path, auditLocation(location, `path`))
 	return sanU, nil
 }
 
+// sanitizeURLOrFilter sanitizes a URL, replacing it with a filtered URL instead
+// of failing in Strict mode. The filtered URL only retains the scheme and host,
+// which are reported regardless of the log level, its path being Filtered.
+func (p SanitizationProvider) sanitizeURLOrFilter(u *url.URL) (*url.URL, error) {
+	sanU, err := p.sanitizeURL(u)
+	if err == nil || !p.Strict {
+		return sanU, err
+	}
+	return &url.URL{Scheme: u.Scheme, Host: u.Host, Path: `/` + Filtered}, nil
+}
+
+// truncateQuery keeps at most MaxQueryParams query parameters, in the order of
+// their names, returning the parameters kept and the number dropped.
+func (p SanitizationProvider) truncateQuery(in url.Values) (url.Values, int) {
+	if p.MaxQueryParams <= 0 {
+		return in, 0
+	}
+	names := make([]string, 0, len(in))
+	for name := range in {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := make(url.Values, len(in))
+	kept, dropped := 0, 0
+	for _, name := range names {
+		for _, value := range in[name] {
+			if kept >= p.MaxQueryParams {
+				dropped++
+				continue
+			}
+			out.Add(name, value)
+			kept++
+		}
+	}
+	return out, dropped
+}
+
 // sanitizeHeaders and sanitizeURL apply the same logical loop, but the methods
 // invoked have differing implementations.
 // To avoid overwriting original values, sanitizeHeaders returns a new URL.
-func (p SanitizationProvider) sanitizeHeaders(in http.Header) http.Header {
+// The location, like "request.headers", prefixes the audited locations.
+func (p SanitizationProvider) sanitizeHeaders(in http.Header, location string) http.Header {
 	out := make(http.Header, len(in))
 
 Name:
 	for name, values := range in {
 		// Filter on keys, erasing all values.
-		for _, sk := range p.SensitiveKeys {
-			if sk.MatchString(name) {
-				out.Set(name, Filtered)
-				continue Name
+		if i := p.sensitiveKeyIndex(name); i >= 0 {
+			p.Audit.Record(auditLocation(location, name), AuditPatternKey, i)
+			for _, value := range p.redactValues(values) {
+				out.Add(name, value)
 			}
+			continue Name
 		}
 
-		// If the key didn't match replace the matching values.
-		for _, value := range values {
-			for _, sr := range p.SensitiveRegexps {
-				if sr.MatchString(value) {
-					value = sr.ReplaceAllLiteralString(value, Filtered)
+		// Cookies are sanitized one by one, to preserve non-sensitive ones.
+		switch http.CanonicalHeaderKey(name) {
+		case cookieHeader:
+			for _, value := range values {
+				if value, ok := p.sanitizeCookies(value, location+`.`+name); ok {
+					out.Add(name, value)
 				}
 			}
-			out.Add(name, value)
+			continue Name
+		case setCookieHeader:
+			for _, value := range values {
+				if value, ok := p.sanitizeSetCookie(value, location+`.`+name); ok {
+					out.Add(name, value)
+				}
+			}
+			continue Name
+		}
+
+		// If the key didn't match replace the matching values.
+		for _, value := range values {
+			out.Add(name, p.sanitizeValue(value, auditLocation(location, name)))
 		}
 	}
 
 	return out
 }
 
+const (
+	cookieHeader    = `Cookie`
+	setCookieHeader = `Set-Cookie`
+)
+
+// sanitizeValue replaces the parts of a value matching SensitiveRegexps. The
+// location of the value is only computed when auditing a redaction.
+func (p SanitizationProvider) sanitizeValue(value string, location func() string) string {
+	for i, sr := range p.SensitiveRegexps {
+		if sr.MatchString(value) {
+			p.Audit.Record(location, AuditPatternData, i)
+			value = p.redactData(sr, value)
+		}
+	}
+	return value
+}
+
+// sensitiveKeyIndex returns the index of the first SensitiveKeys expression
+// matching a key, or -1 if none does.
+func (p SanitizationProvider) sensitiveKeyIndex(key string) int {
+	for i, sk := range p.SensitiveKeys {
+		if sk.MatchString(key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sanitizeCookie sanitizes a single "name=value" cookie pair, redacting its
+// value entirely if its name matches SensitiveKeys. It returns false if the
+// cookie is removed.
+func (p SanitizationProvider) sanitizeCookie(pair, location string) (string, bool) {
+	eq := strings.IndexByte(pair, '=')
+	if eq < 0 {
+		return p.sanitizeValue(pair, auditLocation(location)), true
+	}
+	name := strings.TrimSpace(pair[:eq])
+	if i := p.sensitiveKeyIndex(name); i >= 0 {
+		p.Audit.Record(auditLocation(location, name), AuditPatternKey, i)
+		return pair[:eq+1] + p.redact(pair[eq+1:]), p.Strategy != RedactionRemove
+	}
+	return pair[:eq+1] + p.sanitizeValue(pair[eq+1:], auditLocation(location, name)), true
+}
+
+// sanitizeCookies sanitizes the "; "-separated cookies in a Cookie header value.
+// It returns false if all cookies are removed.
+func (p SanitizationProvider) sanitizeCookies(value, location string) (string, bool) {
+	pairs := strings.Split(value, `;`)
+	kept := pairs[:0]
+	for _, pair := range pairs {
+		if pair, ok := p.sanitizeCookie(pair, location); ok {
+			kept = append(kept, pair)
+		}
+	}
+	if len(kept) == 0 {
+		return ``, false
+	}
+	// Removing the first cookie leaves a leading space on the next one.
+	kept[0] = strings.TrimLeft(kept[0], ` `)
+	return strings.Join(kept, `;`), true
+}
+
+// sanitizeSetCookie sanitizes the cookie in a Set-Cookie header value, leaving
+// its attributes unchanged. It returns false if the cookie is removed.
+func (p SanitizationProvider) sanitizeSetCookie(value, location string) (string, bool) {
+	pair, attributes := value, ``
+	if semi := strings.IndexByte(value, ';'); semi >= 0 {
+		pair, attributes = value[:semi], value[semi:]
+	}
+	pair, ok := p.sanitizeCookie(pair, location)
+	return pair + attributes, ok
+}
+
 // SanitizeQueryAndPaths sanitizes the URL query parameters and paths in both the
 // original request and the request present in the response, which may or may
 // not be the same.
@@ -120,7 +367,7 @@ func (p SanitizationProvider) SanitizeQueryAndPaths(_ context.Context, e events.
 	request := e.Request()
 	// To avoid overwriting original values, sanitizeRequestURL returns a new request.
 	req := request.Clone(request.Context())
-	u, err := p.sanitizeURL(req.URL)
+	u, err := p.sanitizeURLOrFilter(req.URL)
 	if err != nil {
 		return err
 	}
@@ -140,7 +387,7 @@ func (p SanitizationProvider) SanitizeQueryAndPaths(_ context.Context, e events.
 		return nil
 	}
 	req = response.Request.Clone(response.Request.Context())
-	u, err = p.sanitizeURL(req.URL)
+	u, err = p.sanitizeURLOrFilter(req.URL)
 	if err != nil {
 		return err
 	}
@@ -156,8 +403,11 @@ func (p SanitizationProvider) SanitizeQueryAndPaths(_ context.Context, e events.
 // SanitizeRequestHeaders sanitizes Request headers and trailers.
 func (p SanitizationProvider) SanitizeRequestHeaders(_ context.Context, e events.Event) error {
 	req := e.Request()
-	req.Header = p.sanitizeHeaders(req.Header)
+	req.Header = p.sanitizeHeaders(req.Header, `request.headers`)
 	e.SetRequest(req)
+	if re, ok := e.(*ReportEvent); ok && re.RequestTrailers != nil {
+		re.RequestTrailers = p.sanitizeHeaders(re.RequestTrailers, `request.trailers`)
+	}
 
 	res := e.Response()
 	if res == nil {
@@ -167,7 +417,7 @@ func (p SanitizationProvider) SanitizeRequestHeaders(_ context.Context, e events
 	if resReq == req {
 		return nil
 	}
-	resReq.Header = p.sanitizeHeaders(resReq.Header)
+	resReq.Header = p.sanitizeHeaders(resReq.Header, `request.headers`)
 	res.Request = resReq
 	e.SetResponse(res)
 	return nil
@@ -179,7 +429,7 @@ func (p SanitizationProvider) SanitizeResponseHeaders(_ context.Context, e event
 	if res == nil {
 		return nil
 	}
-	res.Header = p.sanitizeHeaders(res.Header)
+	res.Header = p.sanitizeHeaders(res.Header, `response.headers`)
 	e.SetResponse(res)
 	return nil
 }
@@ -190,13 +440,16 @@ func (p SanitizationProvider) SanitizeRequestBody(_ context.Context, e events.Ev
 	if !ok {
 		return fmt.Errorf(`topic ReportEvent, got %T`, e)
 	}
-	w := NewWalker(re.RequestBody)
-	var accu interface{}
-	err := w.Walk(&accu, p.BodySanitizer)
+	p.excludeBodyFields(re.RequestBody, `request.body`)
+	body, err := p.sanitizeBody(re.RequestBody, `request.body`)
 	if err != nil {
-		return err
+		if !p.Strict {
+			return err
+		}
+		re.RequestBody = Filtered
+		return nil
 	}
-	re.RequestBody = w.Value()
+	re.RequestBody = body
 	return nil
 }
 
@@ -206,38 +459,138 @@ func (p SanitizationProvider) SanitizeResponseBody(_ context.Context, e events.E
 	if !ok {
 		return fmt.Errorf(`topic ReportEvent, got %T`, e)
 	}
-	w := NewWalker(re.ResponseBody)
-	var accu interface{}
-	err := w.Walk(&accu, p.BodySanitizer)
+	p.excludeBodyFields(re.ResponseBody, `response.body`)
+	body, err := p.sanitizeBody(re.ResponseBody, `response.body`)
 	if err != nil {
-		return err
+		if !p.Strict {
+			return err
+		}
+		re.ResponseBody = Filtered
+		return nil
 	}
-	re.ResponseBody = w.Value()
+	re.ResponseBody = body
 	return nil
 }
 
-// BodySanitizer applies sanitization rules to data.
+// sanitizeBody walks a parsed body with the BodySanitizer logic, keeping track
+// of the location of the values for auditing, and returns the sanitized body.
+func (p SanitizationProvider) sanitizeBody(body interface{}, location string) (interface{}, error) {
+	w := NewDepthLimitedWalker(body, p.MaxBodyDepth).(PathWalker)
+	var accu interface{}
+	err := w.WalkPath(&accu, func(path []interface{}, v *interface{}, _ *interface{}) error {
+		if len(path) == 0 {
+			return nil
+		}
+		p.sanitizeBodyValue(path[len(path)-1], v, auditLocation(location, path...))
+		return nil
+	})
+	return w.Value(), err
+}
+
+// excludeBodyFields removes the ExcludedBodyFields from a parsed body, in place,
+// and the fields with sensitive keys when using the RedactionRemove strategy.
+func (p SanitizationProvider) excludeBodyFields(body interface{}, location string) {
+	for _, path := range p.ExcludedBodyFields {
+		excludeBodyField(body, strings.Split(path, `.`))
+	}
+	if p.Strategy == RedactionRemove {
+		p.removeSensitiveFields(body, nil, location)
+	}
+}
+
+// removeSensitiveFields removes the fields with sensitive keys below a value,
+// in place, down to the MaxBodyDepth sanitized. The path leads to the value.
+func (p SanitizationProvider) removeSensitiveFields(x interface{}, path []interface{}, location string) {
+	if p.MaxBodyDepth > 0 && len(path) >= p.MaxBodyDepth {
+		return
+	}
+	switch y := x.(type) {
+	case map[string]interface{}:
+		for k, v := range y {
+			if i := p.sensitiveKeyIndex(k); i >= 0 {
+				p.Audit.Record(auditLocation(location, append(path, k)...), AuditPatternKey, i)
+				delete(y, k)
+				continue
+			}
+			p.removeSensitiveFields(v, append(path, k), location)
+		}
+	case []interface{}:
+		for j, item := range y {
+			p.removeSensitiveFields(item, append(path, j), location)
+		}
+	case map[string][]string:
+		for k := range y {
+			if i := p.sensitiveKeyIndex(k); i >= 0 {
+				p.Audit.Record(auditLocation(location, append(path, k)...), AuditPatternKey, i)
+				delete(y, k)
+			}
+		}
+	}
+}
+
+// excludeBodyField removes the field at the path below the value, in place.
+func excludeBodyField(x interface{}, path []string) {
+	switch y := x.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			delete(y, path[0])
+			return
+		}
+		if child, ok := y[path[0]]; ok {
+			excludeBodyField(child, path[1:])
+		}
+	case []interface{}:
+		for _, item := range y {
+			excludeBodyField(item, path)
+		}
+	case map[string][]string:
+		// Form bodies have no nested fields.
+		if len(path) == 1 {
+			delete(y, path[0])
+		}
+	}
+}
+
+// BodySanitizer applies sanitization rules to data. Since it does not know the
+// path of the values, it audits them by key only.
 func (p SanitizationProvider) BodySanitizer(k interface{}, v *interface{}, accu *interface{}) error {
 	if k == nil {
 		return nil
 	}
+	p.sanitizeBodyValue(k, v, auditLocation(`body`, k))
+	return nil
+}
+
+// sanitizeBodyValue applies sanitization rules to a body value and its key.
+func (p SanitizationProvider) sanitizeBodyValue(k interface{}, v *interface{}, location func() string) {
 	if sk, ok := k.(string); ok {
-		for _, re := range p.SensitiveKeys {
-			if re.MatchString(sk) {
-				*v = Filtered
-				return nil
-			}
+		if i := p.sensitiveKeyIndex(sk); i >= 0 {
+			p.Audit.Record(location, AuditPatternKey, i)
+			*v = p.redactBodyValue(*v)
+			return
 		}
 	}
 
 	if reflect.ValueOf(*v).Kind() == reflect.String {
 		sv, _ := (*v).(string) // Cannot fail because of previous line.
-		for _, re := range p.SensitiveRegexps {
-			if re.MatchString(sv) {
-				sv = re.ReplaceAllLiteralString(sv, Filtered)
-			}
+		*v = p.sanitizeValue(sv, location)
+	}
+}
+
+// redactBodyValue returns the replacement of a body value with a sensitive key.
+// Only the RedactionHash strategy depends on the value: non-string values are
+// hashed in JSON.
+func (p SanitizationProvider) redactBodyValue(v interface{}) string {
+	if p.Strategy != RedactionHash {
+		return p.redact(``)
+	}
+	s, ok := v.(string)
+	if !ok {
+		b, err := json.Marshal(v)
+		if err != nil {
+			b = []byte(fmt.Sprint(v))
 		}
-		*v = sv
+		s = string(b)
 	}
-	return nil
+	return p.redact(s)
 }
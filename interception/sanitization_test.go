@@ -2,7 +2,13 @@ package interception_test
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
 	"reflect"
 	"regexp"
 	"testing"
@@ -21,7 +27,7 @@ const (
 func newSanitizationProvider() *interception.SanitizationProvider {
 	keysREs := []*regexp.Regexp{interception.DefaultSensitiveKeys}
 	valueREs := []*regexp.Regexp{interception.DefaultSensitiveData}
-	p := &interception.SanitizationProvider{keysREs, valueREs}
+	p := &interception.SanitizationProvider{SensitiveKeys: keysREs, SensitiveRegexps: valueREs}
 	return p
 }
 
@@ -89,6 +95,86 @@ func TestSanitizationProvider_SanitizeQueryAndPaths(t *testing.T) {
 	}
 }
 
+func TestSanitizationProvider_SanitizeQueryAndPathsMaxQueryParams(t *testing.T) {
+	tests := []struct {
+		name        string
+		max         int
+		requestURL  string
+		expectedURL string
+	}{
+		{`unlimited`, 0,
+			testURL + `/path?c=3&a=1&b=2&b=22`,
+			testURL + `/path?a=1&b=2&b=22&c=3`},
+		{`within limit`, 4,
+			testURL + `/path?c=3&a=1&b=2&b=22`,
+			testURL + `/path?a=1&b=2&b=22&c=3`},
+		{`over limit`, 2,
+			testURL + `/path?c=3&a=1&b=2&b=22&d=4`,
+			testURL + `/path?a=1&b=2&` + interception.TruncatedQueryMarker + `=3`},
+		{`over limit sanitized`, 1,
+			testURL + `/path?password=hunter2&secret=s`,
+			testURL + `/path?password=%5BFILTERED%5D&` + interception.TruncatedQueryMarker + `=1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newSanitizationProvider()
+			p.MaxQueryParams = tt.max
+			req, err := http.NewRequest(``, tt.requestURL, nil)
+			if err != nil {
+				t.Fatalf(`unexpected error building request: %v`, err)
+			}
+			e := events.NewEvent(topic).SetRequest(req)
+			if err = p.SanitizeQueryAndPaths(context.Background(), e); err != nil {
+				t.Fatalf(`sanitizeQueryAndPaths error = %v`, err)
+			}
+			if actual := e.Request().URL.String(); actual != tt.expectedURL {
+				t.Errorf(`sanitizeQueryAndPaths URL: got %s, expected %s`, actual, tt.expectedURL)
+			}
+		})
+	}
+}
+
+func TestSanitizationProvider_SanitizeQueryAndPathsStrict(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         *url.URL
+		strict      bool
+		expectedURL string
+		wantErr     bool
+	}{
+		{`lax`, &url.URL{Scheme: `https`, Host: `example.com:bad`, Path: `/john.doe@example.com`}, false, ``, true},
+		{`strict`, &url.URL{Scheme: `https`, Host: `example.com:bad`, Path: `/john.doe@example.com`}, true,
+			`https://example.com:bad/%5BFILTERED%5D`, false},
+		{`strict no host`, &url.URL{Path: `http//2020:609:241:98::80:10/authentication/login/`}, true,
+			`/%5BFILTERED%5D`, false},
+		{`strict valid`, &url.URL{Scheme: `https`, Host: `example.com`, Path: `/path`, RawQuery: `client_id=secret`}, true,
+			`https://example.com/path?client_id=%5BFILTERED%5D`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newSanitizationProvider()
+			p.Strict = tt.strict
+			req := &http.Request{Method: http.MethodGet, URL: tt.url, Header: http.Header{}}
+			res := &http.Response{Request: req}
+
+			e := events.NewEvent(topic).SetRequest(req).SetResponse(res)
+			err := p.SanitizeQueryAndPaths(context.Background(), e)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf(`SanitizeQueryAndPaths error = %v, wantErr %v`, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if actual := e.Request().URL.String(); actual != tt.expectedURL {
+				t.Errorf(`request URL = %s, expected %s`, actual, tt.expectedURL)
+			}
+			if actual := e.Response().Request.URL.String(); actual != tt.expectedURL {
+				t.Errorf(`response request URL = %s, expected %s`, actual, tt.expectedURL)
+			}
+		})
+	}
+}
+
 func TestSanitizationProvider_Listeners(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -132,6 +218,13 @@ func TestSanitizationProvider_SanitizeRequestHeaders(t *testing.T) {
 			[]string{`not a card`, `fake` + interception.Filtered + `card`, `nor that one`},
 			[]string{`not a card`, `fake` + interception.Filtered + `card`, `nor that one`},
 		},
+		{`cookies`, `Cookie`,
+			[]string{`theme=dark; api_key=s3cr3t; email=` + mail + `; card=` + card + `; lang=en`}, ``, nil,
+			[]string{`theme=dark; api_key=` + interception.Filtered + `; email=` + interception.Filtered +
+				`; card=fake` + interception.Filtered + `card; lang=en`},
+			[]string{`theme=dark; api_key=` + interception.Filtered + `; email=` + interception.Filtered +
+				`; card=fake` + interception.Filtered + `card; lang=en`},
+		},
 	}
 	p := newSanitizationProvider()
 	for _, tt := range tests {
@@ -183,6 +276,18 @@ func TestSanitizationProvider_SanitizeResponseHeaders(t *testing.T) {
 			[]string{`not a card`, `fake370057577167325card`, `nor that one`},
 			[]string{`not a card`, `fake` + interception.Filtered + `card`, `nor that one`},
 		},
+		{`set cookies`, `Set-Cookie`,
+			[]string{
+				`theme=dark; Path=/; HttpOnly`,
+				`password=hunter2; Path=/; Secure`,
+				`email=` + mail + `; Domain=example.com`,
+			},
+			[]string{
+				`theme=dark; Path=/; HttpOnly`,
+				`password=` + interception.Filtered + `; Path=/; Secure`,
+				`email=` + interception.Filtered + `; Domain=example.com`,
+			},
+		},
 	}
 	for _, tt := range tests {
 		p := newSanitizationProvider()
@@ -221,6 +326,14 @@ func TestSanitizationProvider_sanitize(t *testing.T) {
 		{`fully filtered map value`, map[string]interface{}{`foo`: mail}, map[string]interface{}{`foo`: interception.Filtered}, false},
 		{`partially filtered map value`, map[string]interface{}{`foo`: card}, map[string]interface{}{`foo`: `fake` + interception.Filtered + `card`}, false},
 		{`[]string, filtered`, []string{mail}, []string{interception.Filtered}, false},
+		{`[]map, filtered keys and values`,
+			[]interface{}{map[string]interface{}{`email`: mail, `name`: `a`}, map[string]interface{}{`password`: `bar`, `name`: `b`}},
+			[]interface{}{map[string]interface{}{`email`: interception.Filtered, `name`: `a`}, map[string]interface{}{`password`: interception.Filtered, `name`: `b`}},
+			false},
+		{`[]map with nulls`,
+			[]interface{}{nil, map[string]interface{}{`email`: mail, `phone`: nil}},
+			[]interface{}{nil, map[string]interface{}{`email`: interception.Filtered, `phone`: nil}},
+			false},
 	}
 	p := newSanitizationProvider()
 	for _, tt := range tests {
@@ -238,6 +351,39 @@ func TestSanitizationProvider_sanitize(t *testing.T) {
 	}
 }
 
+func TestSanitizationProvider_SanitizeResponseBodyExcludedFields(t *testing.T) {
+	const body = `{
+		"data": {"id": 1, "image": "iVBORw0KGgo", "meta": {"thumbnail": "R0lGOD", "width": 10}},
+		"items": [{"name": "a", "blob": "AAAA"}, {"name": "b", "blob": "BBBB"}, "scalar"],
+		"password": "hunter2"
+	}`
+	const expected = `{
+		"data": {"id": 1, "meta": {"width": 10}},
+		"items": [{"name": "a"}, {"name": "b"}, "scalar"],
+		"password": "[FILTERED]"
+	}`
+
+	p := newSanitizationProvider()
+	p.ExcludedBodyFields = []string{`data.image`, `data.meta.thumbnail`, `items.blob`, `missing.field`, `data.id.nested`}
+	var decoded, wanted interface{}
+	if err := json.Unmarshal([]byte(body), &decoded); err != nil {
+		t.Fatalf("failed decoding body: %v", err)
+	}
+	if err := json.Unmarshal([]byte(expected), &wanted); err != nil {
+		t.Fatalf("failed decoding expected body: %v", err)
+	}
+
+	e := &interception.ReportEvent{
+		BodiesEvent: &interception.BodiesEvent{ResponseBody: decoded},
+	}
+	if err := p.SanitizeResponseBody(context.Background(), e); err != nil {
+		t.Fatalf("SanitizeResponseBody() error = %v", err)
+	}
+	if !reflect.DeepEqual(e.ResponseBody, wanted) {
+		t.Errorf("SanitizeResponseBody() got %v, expected %v", e.ResponseBody, wanted)
+	}
+}
+
 func TestSanitizationProvider_SanitizeRequestBody(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -250,6 +396,14 @@ func TestSanitizationProvider_SanitizeRequestBody(t *testing.T) {
 		{`fully filtered map value`, map[string]interface{}{`foo`: mail}, map[string]interface{}{`foo`: interception.Filtered}, false},
 		{`partially filtered map value`, map[string]interface{}{`foo`: card}, map[string]interface{}{`foo`: `fake` + interception.Filtered + `card`}, false},
 		{`[]string, filtered`, []string{mail}, []string{interception.Filtered}, false},
+		{`[]map, filtered keys and values`,
+			[]interface{}{map[string]interface{}{`email`: mail, `name`: `a`}, map[string]interface{}{`password`: `bar`, `name`: `b`}},
+			[]interface{}{map[string]interface{}{`email`: interception.Filtered, `name`: `a`}, map[string]interface{}{`password`: interception.Filtered, `name`: `b`}},
+			false},
+		{`[]map with nulls`,
+			[]interface{}{nil, map[string]interface{}{`email`: mail, `phone`: nil}},
+			[]interface{}{nil, map[string]interface{}{`email`: interception.Filtered, `phone`: nil}},
+			false},
 	}
 	p := newSanitizationProvider()
 	for _, tt := range tests {
@@ -280,6 +434,14 @@ func TestSanitizationProvider_SanitizeResponseBody(t *testing.T) {
 		{`fully filtered map value`, map[string]interface{}{`foo`: mail}, map[string]interface{}{`foo`: interception.Filtered}, false},
 		{`partially filtered map value`, map[string]interface{}{`foo`: card}, map[string]interface{}{`foo`: `fake` + interception.Filtered + `card`}, false},
 		{`[]string, filtered`, []string{mail}, []string{interception.Filtered}, false},
+		{`[]map, filtered keys and values`,
+			[]interface{}{map[string]interface{}{`email`: mail, `name`: `a`}, map[string]interface{}{`password`: `bar`, `name`: `b`}},
+			[]interface{}{map[string]interface{}{`email`: interception.Filtered, `name`: `a`}, map[string]interface{}{`password`: interception.Filtered, `name`: `b`}},
+			false},
+		{`[]map with nulls`,
+			[]interface{}{nil, map[string]interface{}{`email`: mail, `phone`: nil}},
+			[]interface{}{nil, map[string]interface{}{`email`: interception.Filtered, `phone`: nil}},
+			false},
 	}
 	p := newSanitizationProvider()
 	for _, tt := range tests {
@@ -297,3 +459,213 @@ func TestSanitizationProvider_SanitizeResponseBody(t *testing.T) {
 		})
 	}
 }
+
+// coalescingTestPatterns returns sensitive keys and regexps with several
+// patterns each, using flags and anchors.
+func coalescingTestPatterns() (keys, values []*regexp.Regexp) {
+	keys = []*regexp.Regexp{
+		interception.DefaultSensitiveKeys,
+		regexp.MustCompile(`(?i)^x-internal-`),
+		regexp.MustCompile(`session`),
+	}
+	values = []*regexp.Regexp{
+		interception.DefaultSensitiveData,
+		regexp.MustCompile(`\bsk_live_\w+`),
+		regexp.MustCompile(`(?i)bearer [a-z0-9.]+`),
+	}
+	return keys, values
+}
+
+func TestCoalesceRegexps(t *testing.T) {
+	keys, values := coalescingTestPatterns()
+	if actual := interception.CoalesceRegexps(keys[:1]); len(actual) != 1 || actual[0] != keys[0] {
+		t.Errorf("CoalesceRegexps() = %v, expected a single expression to be unchanged", actual)
+	}
+	if actual := interception.CoalesceRegexps(nil); actual != nil {
+		t.Errorf("CoalesceRegexps(nil) = %v, expected nil", actual)
+	}
+
+	perPattern := interception.SanitizationProvider{SensitiveKeys: keys, SensitiveRegexps: values}
+	coalesced := interception.SanitizationProvider{
+		SensitiveKeys:    interception.CoalesceRegexps(keys),
+		SensitiveRegexps: interception.CoalesceRegexps(values),
+	}
+	if len(coalesced.SensitiveKeys) != 1 || len(coalesced.SensitiveRegexps) != 1 {
+		t.Fatalf("CoalesceRegexps() returned %d keys and %d regexps, expected 1 each",
+			len(coalesced.SensitiveKeys), len(coalesced.SensitiveRegexps))
+	}
+
+	sanitize := func(p interception.SanitizationProvider) *interception.ReportEvent {
+		req, _ := http.NewRequest(http.MethodPost,
+			`https://example.com/card/`+card+`?Session=1&api_key=2&token=sk_live_abc&q=`+mail+`&x=y`, nil)
+		req.Header.Set(`Authorization`, `Bearer abc.def`)
+		req.Header.Set(`X-Internal-Trace`, `t1`)
+		req.Header.Set(`X-Forwarded-For`, `bearer xyz from `+mail)
+		req.Header.Set(`Cookie`, `session=s1; theme=dark; sid=sk_live_123`)
+		res := &http.Response{Request: req, Header: http.Header{
+			`Set-Cookie`: {`session=s2; Path=/`},
+			`X-Card`:     {`card ` + card + ` and sk_live_456`},
+		}}
+		e := &interception.ReportEvent{
+			BodiesEvent: &interception.BodiesEvent{
+				RequestBody: map[string]interface{}{
+					`user_session`: `s3`,
+					`password`:     `hunter2`,
+					`note`:         `mail ` + mail + `, key sk_live_789`,
+					`items`:        []interface{}{map[string]interface{}{`x-internal-id`: 1, `auth`: `Bearer q.r`}},
+				},
+				ResponseBody: []interface{}{`plain`, card},
+			},
+		}
+		e.SetRequest(req).SetResponse(res)
+		for _, listener := range p.Listeners(e) {
+			if err := listener(context.Background(), e); err != nil {
+				t.Fatalf("sanitization error = %v", err)
+			}
+		}
+		return e
+	}
+
+	expected, actual := sanitize(perPattern), sanitize(coalesced)
+	if a, e := actual.Request().URL.String(), expected.Request().URL.String(); a != e {
+		t.Errorf("coalesced URL = %s, expected %s", a, e)
+	}
+	if a, e := actual.Request().Header, expected.Request().Header; !reflect.DeepEqual(a, e) {
+		t.Errorf("coalesced request headers = %v, expected %v", a, e)
+	}
+	if a, e := actual.Response().Header, expected.Response().Header; !reflect.DeepEqual(a, e) {
+		t.Errorf("coalesced response headers = %v, expected %v", a, e)
+	}
+	if !reflect.DeepEqual(actual.RequestBody, expected.RequestBody) {
+		t.Errorf("coalesced request body = %v, expected %v", actual.RequestBody, expected.RequestBody)
+	}
+	if !reflect.DeepEqual(actual.ResponseBody, expected.ResponseBody) {
+		t.Errorf("coalesced response body = %v, expected %v", actual.ResponseBody, expected.ResponseBody)
+	}
+}
+
+func BenchmarkCoalesceRegexps(b *testing.B) {
+	keys, values := coalescingTestPatterns()
+	for i := 0; i < 10; i++ {
+		keys = append(keys, regexp.MustCompile(fmt.Sprintf(`(?i)^x-private-%d$`, i)))
+		values = append(values, regexp.MustCompile(fmt.Sprintf(`\bsecret%d_\w+`, i)))
+	}
+	const key, value = `X-Header-12`, `some value 12 with a secret3_abc token`
+	benchmarks := []struct {
+		name     string
+		res      []*regexp.Regexp
+		sanitize func(re *regexp.Regexp)
+	}{
+		{`keys/per pattern`, keys, func(re *regexp.Regexp) { re.MatchString(key) }},
+		{`keys/coalesced`, interception.CoalesceRegexps(keys), func(re *regexp.Regexp) { re.MatchString(key) }},
+		{`values/per pattern`, values, func(re *regexp.Regexp) { re.ReplaceAllLiteralString(value, interception.Filtered) }},
+		{`values/coalesced`, interception.CoalesceRegexps(values), func(re *regexp.Regexp) { re.ReplaceAllLiteralString(value, interception.Filtered) }},
+	}
+	for _, bb := range benchmarks {
+		b.Run(bb.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, re := range bb.res {
+					bb.sanitize(re)
+				}
+			}
+		})
+	}
+}
+
+func TestSanitizationProvider_RedactionStrategy(t *testing.T) {
+	const secret = `s3cr3t`
+	key := []byte(`hash key`)
+	hash := func(value string) string {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(value))
+		return `[HASH:` + hex.EncodeToString(mac.Sum(nil))[:16] + `]`
+	}
+	tests := []struct {
+		name           string
+		strategy       interception.RedactionStrategy
+		expectedHeader http.Header
+		expectedQuery  string
+		expectedPath   string
+		expectedBody   interface{}
+	}{
+		{`mask`, interception.RedactionMask,
+			http.Header{
+				`Authorization`: {interception.Filtered},
+				`X-Card`:        {`fake` + interception.Filtered + `card`},
+				`Cookie`:        {`api_key=` + interception.Filtered + `; lang=en`},
+			},
+			`note=` + url.QueryEscape(interception.Filtered) + `&password=` + url.QueryEscape(interception.Filtered),
+			`/users/` + interception.Filtered,
+			map[string]interface{}{
+				`secret`: interception.Filtered,
+				`note`:   interception.Filtered,
+				`nested`: map[string]interface{}{`password`: interception.Filtered, `id`: 1.0},
+			},
+		},
+		{`hash`, interception.RedactionHash,
+			http.Header{
+				`Authorization`: {hash(secret)},
+				`X-Card`:        {`fake` + hash(`370057577167325`) + `card`},
+				`Cookie`:        {`api_key=` + hash(secret) + `; lang=en`},
+			},
+			`note=` + url.QueryEscape(hash(mail)) + `&password=` + url.QueryEscape(hash(secret)),
+			`/users/` + hash(mail),
+			map[string]interface{}{
+				`secret`: hash(secret),
+				`note`:   hash(mail),
+				`nested`: map[string]interface{}{`password`: hash(secret), `id`: 1.0},
+			},
+		},
+		{`remove`, interception.RedactionRemove,
+			http.Header{
+				`X-Card`: {`fakecard`},
+				`Cookie`: {`lang=en`},
+			},
+			`note=`,
+			`/users/`,
+			map[string]interface{}{
+				`note`:   ``,
+				`nested`: map[string]interface{}{`id`: 1.0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newSanitizationProvider()
+			p.Strategy = tt.strategy
+			p.HashKey = key
+
+			req, _ := http.NewRequest(http.MethodGet, testURL+`/users/`+mail+`?password=`+secret+`&note=`+mail, nil)
+			req.Header.Set(`Authorization`, secret)
+			req.Header.Set(`X-Card`, card)
+			req.Header.Set(`Cookie`, `api_key=`+secret+`; lang=en`)
+			e := &interception.ReportEvent{
+				BodiesEvent: &interception.BodiesEvent{RequestBody: map[string]interface{}{
+					`secret`: secret,
+					`note`:   mail,
+					`nested`: map[string]interface{}{`password`: secret, `id`: 1.0},
+				}},
+			}
+			e.SetRequest(req)
+
+			for _, sanitize := range []events.Listener{p.SanitizeQueryAndPaths, p.SanitizeRequestHeaders, p.SanitizeRequestBody} {
+				if err := sanitize(context.Background(), e); err != nil {
+					t.Fatalf("sanitization error = %v", err)
+				}
+			}
+			actual := e.Request()
+			if !reflect.DeepEqual(actual.Header, tt.expectedHeader) {
+				t.Errorf("headers = %v, expected %v", actual.Header, tt.expectedHeader)
+			}
+			if actual.URL.RawQuery != tt.expectedQuery {
+				t.Errorf("query = %s, expected %s", actual.URL.RawQuery, tt.expectedQuery)
+			}
+			if actual.URL.Path != tt.expectedPath {
+				t.Errorf("path = %s, expected %s", actual.URL.Path, tt.expectedPath)
+			}
+			if !reflect.DeepEqual(e.RequestBody, tt.expectedBody) {
+				t.Errorf("body = %v, expected %v", e.RequestBody, tt.expectedBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package interception
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/bearer/go-agent/events"
+)
+
+// DefaultShapeDiscoveryKeys is the default maximum number of host, path, and
+// shape combinations for which a ShapeDiscoveryProvider counts reports.
+const DefaultShapeDiscoveryKeys = 10000
+
+// shapeKey identifies the calls to an endpoint with given body shapes.
+type shapeKey struct {
+	host, path              string
+	requestSha, responseSha string
+}
+
+// ShapeDiscoveryProvider is an events.ListenerProvider returning a listener
+// which only lets the first Reports calls of each endpoint and body shapes be
+// reported, allowing shape discovery without an ongoing reporting overhead.
+//
+// To bound its memory use, it only counts reports for the first MaxKeys
+// combinations of host, path, and shapes. Calls with later combinations are
+// reported without being counted. If it tracks hosts in a HostRegistry, the
+// counts of the hosts it evicts are dropped as well.
+type ShapeDiscoveryProvider struct {
+	Reports int
+	MaxKeys int
+	Hosts   *HostRegistry
+
+	mu     sync.Mutex
+	counts map[shapeKey]int
+}
+
+// NewShapeDiscoveryProvider builds a ShapeDiscoveryProvider reporting at most
+// reports calls for each combination of host, path, and shapes.
+func NewShapeDiscoveryProvider(reports int) *ShapeDiscoveryProvider {
+	return &ShapeDiscoveryProvider{
+		Reports: reports,
+		MaxKeys: DefaultShapeDiscoveryKeys,
+		counts:  make(map[shapeKey]int),
+	}
+}
+
+// TrackHosts bounds the hosts with counted reports by a HostRegistry, possibly
+// shared with other features.
+func (p *ShapeDiscoveryProvider) TrackHosts(registry *HostRegistry) {
+	p.Hosts = registry
+	registry.OnEvict(p.evict)
+}
+
+// evict drops the counts of a host evicted from the HostRegistry.
+func (p *ShapeDiscoveryProvider) evict(host string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key := range p.counts {
+		if key.host == host {
+			delete(p.counts, key)
+		}
+	}
+}
+
+// count increments the number of reports for a key, returning false if the
+// key was already reported Reports times.
+func (p *ShapeDiscoveryProvider) count(key shapeKey) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n, ok := p.counts[key]
+	if !ok && len(p.counts) >= p.MaxKeys {
+		return true
+	}
+	if n >= p.Reports {
+		return false
+	}
+	p.counts[key] = n + 1
+	return true
+}
+
+// DiscoverShape marks the report inactive and stops its dispatch if its
+// endpoint and shapes were already reported Reports times.
+func (p *ShapeDiscoveryProvider) DiscoverShape(_ context.Context, e events.Event) error {
+	re, ok := e.(*ReportEvent)
+	if !ok {
+		return fmt.Errorf("topic %s used with event type %T", e.Topic(), e)
+	}
+	key := shapeKey{requestSha: re.RequestSha, responseSha: re.ResponseSha}
+	if request := re.Request(); request != nil && request.URL != nil {
+		key.host, key.path = request.URL.Hostname(), request.URL.Path
+	}
+	if p.Hosts != nil {
+		p.Hosts.Touch(key.host)
+	}
+	if p.count(key) {
+		return nil
+	}
+	if config := re.Config(); config != nil {
+		config.IsActive = false
+	}
+	return events.DispatchStopRequest
+}
+
+// Listeners implements the events.ListenerProvider interface.
+func (p *ShapeDiscoveryProvider) Listeners(e events.Event) []events.Listener {
+	if e.Topic() != TopicReport {
+		return nil
+	}
+	return []events.Listener{p.DiscoverShape}
+}
@@ -0,0 +1,84 @@
+package interception
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/proxy"
+)
+
+func TestShapeDiscoveryProvider_DiscoverShape(t *testing.T) {
+	const reports = 3
+	type call struct {
+		url, responseSha string
+	}
+	tests := []struct {
+		name     string
+		maxKeys  int
+		calls    []call
+		expected int
+	}{
+		{`same endpoint`, DefaultShapeDiscoveryKeys, []call{
+			{`https://example.com/a`, `s1`}, {`https://example.com/a`, `s1`}, {`https://example.com/a`, `s1`},
+			{`https://example.com/a`, `s1`}, {`https://example.com/a?q=1`, `s1`},
+		}, 3},
+		{`distinct shapes`, DefaultShapeDiscoveryKeys, []call{
+			{`https://example.com/a`, `s1`}, {`https://example.com/a`, `s1`}, {`https://example.com/a`, `s1`},
+			{`https://example.com/a`, `s2`}, {`https://example.com/a`, `s1`},
+		}, 4},
+		{`distinct endpoints`, DefaultShapeDiscoveryKeys, []call{
+			{`https://example.com/a`, `s1`}, {`https://example.com/a`, `s1`}, {`https://example.com/a`, `s1`},
+			{`https://example.com/b`, `s1`}, {`https://example.org/a`, `s1`}, {`https://example.com/a`, `s1`},
+		}, 5},
+		{`keys overflow`, 1, []call{
+			{`https://example.com/a`, `s1`}, {`https://example.com/a`, `s1`}, {`https://example.com/a`, `s1`},
+			{`https://example.com/a`, `s1`}, {`https://example.com/b`, `s1`}, {`https://example.com/b`, `s1`},
+			{`https://example.com/b`, `s1`}, {`https://example.com/b`, `s1`},
+		}, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reported := 0
+			counter := events.ListenerProviderFunc(func(e events.Event) []events.Listener {
+				return []events.Listener{func(context.Context, events.Event) error {
+					reported++
+					return nil
+				}}
+			})
+			p := NewShapeDiscoveryProvider(reports)
+			p.MaxKeys = tt.maxKeys
+			d := events.NewDispatcher()
+			d.AddProviders(TopicReport, p, counter)
+
+			for _, c := range tt.calls {
+				req, _ := http.NewRequest(http.MethodGet, c.url, nil)
+				re := NewReportEvent(proxy.StageBodies, nil)
+				re.SetRequest(req)
+				re.SetConfig(defaultAPIEventConfig())
+				re.ResponseSha = c.responseSha
+				if _, err := d.Dispatch(context.Background(), re); err != nil {
+					t.Fatalf("Dispatch() error = %v", err)
+				}
+			}
+			if reported != tt.expected {
+				t.Errorf("reported %d calls, expected %d", reported, tt.expected)
+			}
+		})
+	}
+}
+
+func TestShapeDiscoveryProvider_MarksInactive(t *testing.T) {
+	p := NewShapeDiscoveryProvider(1)
+	for i, expected := range []bool{true, false} {
+		req, _ := http.NewRequest(http.MethodGet, `https://example.com/a`, nil)
+		re := NewReportEvent(proxy.StageBodies, nil)
+		re.SetRequest(req)
+		re.SetConfig(defaultAPIEventConfig())
+		_ = p.DiscoverShape(context.Background(), re)
+		if active := re.Config().IsActive; active != expected {
+			t.Errorf("call %d IsActive = %t, expected %t", i, active, expected)
+		}
+	}
+}
@@ -8,6 +8,9 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 
 	mini "github.com/tdewolff/minify/v2"
 	miniJ "github.com/tdewolff/minify/v2/json"
@@ -18,6 +21,30 @@ import (
 
 var minifier *mini.M
 
+// ShapeWarner reports the first failure to marshal a ShapeDescriptor, after
+// which shapes are hashed from a fallback encoding.
+//
+// A nil ShapeWarner does not report failures.
+type ShapeWarner struct {
+	warn func(msg string, fields map[string]interface{})
+	once sync.Once
+}
+
+// NewShapeWarner builds a ShapeWarner reporting to the warn function.
+func NewShapeWarner(warn func(msg string, fields map[string]interface{})) *ShapeWarner {
+	return &ShapeWarner{warn: warn}
+}
+
+// marshalFailed reports a marshaling failure, if it is the first one.
+func (w *ShapeWarner) marshalFailed(err error) {
+	if w == nil || w.warn == nil {
+		return
+	}
+	w.once.Do(func() {
+		w.warn(`marshaling shape failed, using fallback encoding`, map[string]interface{}{`error`: err.Error()})
+	})
+}
+
 // NewShapeDescriptor builds a new ShapeDescriptor from its fields.
 func NewShapeDescriptor(typ ShapeDescriptor_PrimitiveType, fields []*FieldDescriptor, items []*ShapeDescriptor) *ShapeDescriptor {
 	if fields == nil {
@@ -104,6 +131,12 @@ func jsonToShapeHash(x interface{}) (*ShapeDescriptor, error) {
 
 // ToBytes builds a hex-encoded representation of the shape of its argument.
 func ToBytes(x interface{}) ([]byte, error) {
+	return toBytes(x, nil)
+}
+
+// toBytes is ToBytes, passing marshaling failures to the warn function if it
+// is not nil.
+func toBytes(x interface{}, warn func(error)) ([]byte, error) {
 	hashMessage, err := jsonToShapeHash(x)
 	if err != nil {
 		return nil, err
@@ -116,7 +149,12 @@ func ToBytes(x interface{}) ([]byte, error) {
 	}
 	j, err := mo.Marshal(hashMessage)
 	if err != nil {
-		return nil, err
+		// Protobuf rejects some descriptors, like ones with invalid UTF-8 keys,
+		// which still have a shape worth hashing.
+		if warn != nil {
+			warn(err)
+		}
+		return fallbackShapeBytes(hashMessage), nil
 	}
 	// This output is pseudo-random: Go goes out of its way to ensure the
 	// marshalling result changes from one build to the next, so we have to
@@ -129,6 +167,43 @@ func ToBytes(x interface{}) ([]byte, error) {
 	return j, err
 }
 
+// fallbackShapeBytes builds a deterministic encoding of a ShapeDescriptor, for
+// use when it cannot be marshaled to JSON. Keys and rules are quoted with Go
+// escapes, so they need not be valid UTF-8.
+func fallbackShapeBytes(d *ShapeDescriptor) []byte {
+	b := strings.Builder{}
+	writeFallbackShape(&b, d)
+	return []byte(b.String())
+}
+
+func writeFallbackShape(b *strings.Builder, d *ShapeDescriptor) {
+	b.WriteString(strconv.Itoa(int(d.GetType())))
+	b.WriteByte('{')
+	for i, f := range d.GetFields() {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Quote(f.GetKey()))
+		b.WriteByte(':')
+		writeFallbackShape(b, f.GetHash())
+	}
+	b.WriteString(`}[`)
+	for i, item := range d.GetItems() {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		writeFallbackShape(b, item)
+	}
+	b.WriteString(`](`)
+	for i, rule := range d.GetRules() {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Quote(rule))
+	}
+	b.WriteByte(')')
+}
+
 // ToHash builds a NewShapeDescriptor of its argument.
 func ToHash(j interface{}) string {
 	bytes, err := ToBytes(j)
@@ -140,7 +215,13 @@ func ToHash(j interface{}) string {
 
 // ToSha builds a SHA256 of the NewShapeDescriptor of its argument.
 func ToSha(j interface{}) string {
-	bytes, err := ToBytes(j)
+	return toSha(j, nil)
+}
+
+// toSha is ToSha, passing marshaling failures to the warn function if it is
+// not nil.
+func toSha(j interface{}, warn func(error)) string {
+	bytes, err := toBytes(j, warn)
 	if err != nil {
 		return `N/A`
 	}
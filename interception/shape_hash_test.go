@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
 var (
@@ -117,3 +119,50 @@ func TestToBytes(t *testing.T) {
 		})
 	}
 }
+
+func TestToSha_MarshalFallback(t *testing.T) {
+	// Protobuf rejects invalid UTF-8 strings.
+	invalid := map[string]interface{}{"\xff": `value`}
+	if _, err := (protojson.MarshalOptions{}).Marshal(&FieldDescriptor{Key: "\xff"}); err == nil {
+		t.Fatal(`expected protojson to reject invalid UTF-8`)
+	}
+
+	sha := ToSha(invalid)
+	if sha == `N/A` || sha == `` {
+		t.Fatalf("ToSha() = %q, expected a fallback hash", sha)
+	}
+	if again := ToSha(map[string]interface{}{"\xff": `other`}); again != sha {
+		t.Errorf("ToSha() on same shape = %s, expected %s", again, sha)
+	}
+	if other := ToSha(map[string]interface{}{"\xfe": 42}); other == sha {
+		t.Errorf("ToSha() on different shape = %s, expected a different hash", other)
+	}
+}
+
+func TestShapeWarner(t *testing.T) {
+	invalid := map[string]interface{}{"\xff": `value`}
+	var warnings [2]int
+	warners := [2]*ShapeWarner{
+		NewShapeWarner(func(string, map[string]interface{}) { warnings[0]++ }),
+		NewShapeWarner(func(string, map[string]interface{}) { warnings[1]++ }),
+	}
+
+	// Each warner reports its own first failure only.
+	for i := 0; i < 2; i++ {
+		if sha := toSha(invalid, warners[0].marshalFailed); sha != ToSha(invalid) {
+			t.Errorf("toSha() = %s, expected the ToSha fallback hash", sha)
+		}
+	}
+	if warnings != [2]int{1, 0} {
+		t.Errorf("got %v warnings, expected [1 0]", warnings)
+	}
+	toSha(invalid, warners[1].marshalFailed)
+	toSha(map[string]interface{}{`valid`: 1}, warners[1].marshalFailed)
+	if warnings != [2]int{1, 1} {
+		t.Errorf("got %v warnings, expected [1 1]", warnings)
+	}
+
+	// A nil warner does not report failures.
+	var nilWarner *ShapeWarner
+	toSha(invalid, nilWarner.marshalFailed)
+}
@@ -0,0 +1,49 @@
+package interception
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/filters"
+)
+
+// StatusSuppressionProvider is an events.ListenerProvider returning a listener
+// which prevents the report of API calls to some hosts when their response
+// status is within a given range, e.g. successful calls to health endpoints,
+// while still reporting their errors.
+type StatusSuppressionProvider struct {
+	// Hosts maps host names, without port, to the range of response status
+	// codes not reported for them. Host names are matched case-insensitively.
+	Hosts map[string]filters.RangeMatcher
+}
+
+// SuppressStatus marks the call inactive, preventing its report, if its host
+// and response status are in Hosts.
+func (p StatusSuppressionProvider) SuppressStatus(_ context.Context, e events.Event) error {
+	ae, ok := e.(APIEvent)
+	if !ok {
+		return fmt.Errorf("topic %s used with event type %T", e.Topic(), e)
+	}
+	request, response := e.Request(), e.Response()
+	if request == nil || request.URL == nil || response == nil {
+		return nil
+	}
+	statuses, ok := p.Hosts[strings.ToLower(request.URL.Hostname())]
+	if !ok || statuses == nil || !statuses.Contains(response.StatusCode) {
+		return nil
+	}
+	if config := ae.Config(); config != nil {
+		config.IsActive = false
+	}
+	return nil
+}
+
+// Listeners implements the events.ListenerProvider interface.
+func (p StatusSuppressionProvider) Listeners(e events.Event) []events.Listener {
+	if e.Topic() != TopicResponse || len(p.Hosts) == 0 {
+		return nil
+	}
+	return []events.Listener{p.SuppressStatus}
+}
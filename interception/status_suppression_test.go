@@ -0,0 +1,71 @@
+package interception
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/filters"
+)
+
+func TestStatusSuppressionProvider_SuppressStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status, _ := strconv.Atoi(r.URL.Query().Get(`status`))
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+
+	tests := []struct {
+		name         string
+		host         string
+		status       int
+		wantReported bool
+	}{
+		{`suppressed host success`, `127.0.0.1`, http.StatusOK, false},
+		{`suppressed host other success`, `127.0.0.1`, http.StatusNoContent, false},
+		{`suppressed host error`, `127.0.0.1`, http.StatusServiceUnavailable, true},
+		{`other host success`, `localhost`, http.StatusOK, true},
+		{`other host error`, `localhost`, http.StatusServiceUnavailable, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var re *ReportEvent
+			d := events.NewDispatcher()
+			d.AddProviders(TopicResponse, StatusSuppressionProvider{Hosts: map[string]filters.RangeMatcher{
+				`127.0.0.1`: filters.NewRangeMatcher().From(200).To(300).ExcludeTo(),
+			}})
+			d.AddProviders(TopicReport, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+				return []events.Listener{func(_ context.Context, e events.Event) error {
+					re = e.(*ReportEvent)
+					return nil
+				}}
+			}))
+			rt := &RoundTripper{
+				Dispatcher: d,
+				Underlying: ts.Client().Transport,
+			}
+			target := url.URL{
+				Scheme:   u.Scheme,
+				Host:     tt.host + `:` + u.Port(),
+				RawQuery: `status=` + strconv.Itoa(tt.status),
+			}
+			req, _ := http.NewRequest(http.MethodGet, target.String(), nil)
+			res, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			_ = res.Body.Close()
+			if res.StatusCode != tt.status {
+				t.Errorf("RoundTrip() status = %d, want %d", res.StatusCode, tt.status)
+			}
+			if reported := re != nil; reported != tt.wantReported {
+				t.Errorf("reported = %t, want %t", reported, tt.wantReported)
+			}
+		})
+	}
+}
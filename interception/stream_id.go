@@ -0,0 +1,42 @@
+package interception
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+)
+
+// streamIDKey is the context key of the streamIDRecorder of a request.
+type streamIDKey struct{}
+
+// streamIDRecorder captures the HTTP/2 stream ID reported by the underlying
+// transport for an API call. The standard library transports do not expose
+// stream IDs, so it stays unset unless a transport calls RecordStreamID.
+type streamIDRecorder struct {
+	id uint32
+}
+
+// RecordStreamID lets transports exposing the HTTP/2 stream ID of requests
+// report it, for inclusion in the API call report, using the context of the
+// request they received. It does nothing for requests not sent through a
+// RoundTripper, and 0 is not a valid stream ID.
+func RecordStreamID(ctx context.Context, id uint32) {
+	if r, ok := ctx.Value(streamIDKey{}).(*streamIDRecorder); ok {
+		atomic.StoreUint32(&r.id, id)
+	}
+}
+
+// StreamID returns the stream ID recorded for a response, if it was received
+// over HTTP/2, and 0 otherwise.
+func (r *streamIDRecorder) StreamID(response *http.Response) uint32 {
+	if response == nil || response.ProtoMajor != 2 {
+		return 0
+	}
+	return atomic.LoadUint32(&r.id)
+}
+
+// record returns a shallow copy of the request, with a context letting the
+// transport record its stream ID.
+func (r *streamIDRecorder) record(request *http.Request) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), streamIDKey{}, r))
+}
@@ -0,0 +1,86 @@
+package interception
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/proxy"
+)
+
+// streamIDTransport is a transport exposing stream IDs, like the typed
+// transports RecordStreamID is meant for.
+type streamIDTransport struct {
+	http.RoundTripper
+	id uint32
+}
+
+func (t streamIDTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	RecordStreamID(request.Context(), t.id)
+	return t.RoundTripper.RoundTrip(request)
+}
+
+func TestRoundTripper_RoundTripStreamID(t *testing.T) {
+	handler := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	h1 := httptest.NewServer(handler)
+	defer h1.Close()
+	h2 := httptest.NewUnstartedServer(handler)
+	h2.EnableHTTP2 = true
+	h2.StartTLS()
+	defer h2.Close()
+
+	tests := []struct {
+		name          string
+		server        *httptest.Server
+		exposed       bool
+		expectedProto int
+		expected      int
+	}{
+		{`HTTP/2 exposed`, h2, true, 2, 5},
+		{`HTTP/2 not exposed`, h2, false, 2, 0},
+		{`HTTP/1.1`, h1, true, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rl proxy.ReportLog
+			d := events.NewDispatcher()
+			d.AddProviders(TopicConnect, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+				return []events.Listener{func(_ context.Context, e events.Event) error {
+					e.(APIEvent).Config().LogLevel = Restricted
+					return nil
+				}}
+			}))
+			d.AddProviders(TopicReport, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+				return []events.Listener{func(_ context.Context, e events.Event) error {
+					re := e.(*ReportEvent)
+					rl = re.Config().LogLevel.Prepare(re)
+					return nil
+				}}
+			}))
+			underlying := tt.server.Client().Transport
+			if tt.exposed {
+				underlying = streamIDTransport{underlying, 5}
+			}
+			rt := &RoundTripper{Dispatcher: d, Underlying: underlying}
+
+			req, _ := http.NewRequest(http.MethodGet, tt.server.URL, nil)
+			res, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			_ = res.Body.Close()
+
+			if res.ProtoMajor != tt.expectedProto {
+				t.Fatalf("response protocol = %s, expected HTTP/%d", res.Proto, tt.expectedProto)
+			}
+			if rl.StreamID != tt.expected {
+				t.Errorf("reported StreamID = %d, expected %d", rl.StreamID, tt.expected)
+			}
+		})
+	}
+
+	// Outside of a RoundTripper, recording is a no-op.
+	RecordStreamID(context.Background(), 1)
+}
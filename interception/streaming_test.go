@@ -0,0 +1,114 @@
+package interception
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bearer/go-agent/proxy"
+)
+
+const eventStreamContentType = `text/event-stream`
+
+func TestRoundTripper_RoundTripEventStream(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(proxy.ContentTypeHeader, eventStreamContentType)
+		w.Header().Set(`X-Stream`, `events`)
+		_, _ = w.Write([]byte("data: first\n\n"))
+		w.(http.Flusher).Flush()
+		// Keep the stream open, as a peek would wait for more events.
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	all := All
+	rt, capture := NewTestRoundTripper(
+		DCRProvider{DCRs: []*DataCollectionRule{{LogLevel: &all}}},
+		BodyParsingProvider{},
+	)
+	rt.Underlying = ts.Client().Transport
+
+	type result struct {
+		res *http.Response
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+`/events`, nil)
+		res, err := rt.RoundTrip(req)
+		done <- result{res, err}
+	}()
+	var r result
+	select {
+	case r = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("RoundTrip() blocked on the open stream")
+	}
+	if r.err != nil {
+		t.Fatalf("RoundTrip() error = %v", r.err)
+	}
+	defer r.res.Body.Close()
+	if _, buffered := r.res.Body.(*BodyReadCloser); buffered {
+		t.Error("stream body was wrapped for buffering")
+	}
+	line, err := bufio.NewReader(r.res.Body).ReadString('\n')
+	if err != nil || line != "data: first\n" {
+		t.Errorf("first event = %q, %v, want the event unconsumed by the agent", line, err)
+	}
+
+	rl := capture.Last()
+	if rl == nil {
+		t.Fatal("no report captured")
+	}
+	if !rl.ResponseStreaming {
+		t.Error("ResponseStreaming = false, want true")
+	}
+	if rl.ResponseBody != BodyIsStream {
+		t.Errorf("ResponseBody = %q, want %q", rl.ResponseBody, BodyIsStream)
+	}
+	if rl.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %d, want %d", rl.StatusCode, http.StatusOK)
+	}
+	if actual := rl.ResponseHeaders.Get(`X-Stream`); actual != `events` {
+		t.Errorf("X-Stream header = %q, want events", actual)
+	}
+}
+
+func TestRoundTripper_RoundTripBufferStreams(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(proxy.ContentTypeHeader, eventStreamContentType)
+		_, _ = w.Write([]byte("data: only\n\n"))
+	}))
+	defer ts.Close()
+
+	all := All
+	rt, capture := NewTestRoundTripper(
+		DCRProvider{DCRs: []*DataCollectionRule{{LogLevel: &all}}},
+		BodyParsingProvider{},
+	)
+	rt.Underlying = ts.Client().Transport
+	rt.BufferStreams = true
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+`/events`, nil)
+	res, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	_ = res.Body.Close()
+
+	rl := capture.Last()
+	if rl == nil {
+		t.Fatal("no report captured")
+	}
+	if rl.ResponseStreaming {
+		t.Error("ResponseStreaming = true, want false with BufferStreams")
+	}
+	if !strings.Contains(rl.ResponseBody, `data: only`) {
+		t.Errorf("ResponseBody = %q, want the buffered events", rl.ResponseBody)
+	}
+}
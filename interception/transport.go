@@ -0,0 +1,143 @@
+package interception
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/proxy"
+)
+
+// TransportOption is a functional option configuring NewInstrumentedTransport.
+type TransportOption func(*transportConfig)
+
+type transportConfig struct {
+	sender           *proxy.Sender
+	dcrs             []*DataCollectionRule
+	maxLogLevel      *LogLevel
+	sensitiveKeys    []*regexp.Regexp
+	sensitiveRegexps []*regexp.Regexp
+}
+
+// WithTransportSender is a TransportOption configuring the Sender transmitting
+// the reports, which NewInstrumentedTransport requires. Its lifecycle remains
+// the responsibility of the caller, which needs to Start it and eventually
+// Stop it.
+func WithTransportSender(sender *proxy.Sender) TransportOption {
+	return func(c *transportConfig) {
+		c.sender = sender
+	}
+}
+
+// WithTransportDataCollectionRules is a TransportOption configuring the data
+// collection rules applied to API calls.
+func WithTransportDataCollectionRules(dcrs []*DataCollectionRule) TransportOption {
+	return func(c *transportConfig) {
+		c.dcrs = dcrs
+	}
+}
+
+// WithTransportMaxLogLevel is a TransportOption capping the LogLevel applied
+// by the data collection rules.
+func WithTransportMaxLogLevel(level LogLevel) TransportOption {
+	return func(c *transportConfig) {
+		c.maxLogLevel = &level
+	}
+}
+
+// WithTransportSensitiveData is a TransportOption configuring the sensitive
+// keys and regexps used for sanitization, instead of DefaultSensitiveKeys and
+// DefaultSensitiveData.
+func WithTransportSensitiveData(keys, res []*regexp.Regexp) TransportOption {
+	return func(c *transportConfig) {
+		c.sensitiveKeys = keys
+		c.sensitiveRegexps = res
+	}
+}
+
+// NewInstrumentedTransport wraps the underlying http.RoundTripper with Bearer
+// instrumentation, using its own Dispatcher wired with the standard providers.
+//
+// Unlike the Agent, it has no global side effects: it neither modifies the
+// http.DefaultTransport nor the http.DefaultClient, making it usable by
+// libraries instrumenting only their own clients.
+//
+// A nil underlying http.RoundTripper stands for the http.DefaultTransport,
+// without the instrumentation an Agent may have added to it, so that calls are
+// not reported twice.
+//
+// It returns an error if no Sender is configured with WithTransportSender,
+// since the calls would then not be reported.
+func NewInstrumentedTransport(underlying http.RoundTripper, opts ...TransportOption) (http.RoundTripper, error) {
+	c := transportConfig{
+		sensitiveKeys:    []*regexp.Regexp{DefaultSensitiveKeys},
+		sensitiveRegexps: []*regexp.Regexp{DefaultSensitiveData},
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.sender == nil {
+		return nil, errors.New(`instrumented transport without a report Sender: use WithTransportSender`)
+	}
+	if underlying == nil {
+		underlying = UnwrapTransport(http.DefaultTransport)
+	}
+
+	d := events.NewDispatcher()
+	Pipeline{
+		DCR: DCRProvider{
+			DCRs:        c.dcrs,
+			MaxLogLevel: c.maxLogLevel,
+		},
+		Connect: []events.ListenerProvider{events.ListenerProviderFunc(func(events.Event) []events.Listener {
+			return []events.Listener{RFCListener}
+		})},
+		Report: []events.ListenerProvider{CacheProvider{}},
+		Sanitizer: SanitizationProvider{
+			SensitiveKeys:    c.sensitiveKeys,
+			SensitiveRegexps: c.sensitiveRegexps,
+		},
+		Sender: c.sender,
+	}.Wire(d)
+
+	return &RoundTripper{
+		Dispatcher: d,
+		Underlying: underlying,
+	}, nil
+}
+
+// UnwrapTransport returns the http.RoundTripper instrumented by the Bearer
+// RoundTripper, if any, or the RoundTripper itself.
+//
+// If the instrumented RoundTripper is nil, it returns a Transport matching the
+// standard values of the http.DefaultTransport.
+func UnwrapTransport(rt http.RoundTripper) http.RoundTripper {
+	for {
+		if base, ok := rt.(*RoundTripper); ok {
+			rt = base.Underlying
+			continue
+		}
+		break
+	}
+	// If the underlying transport, some other package may modify it, so we cannot
+	// rely on it being correct afterwards, so provide a default Transport matching
+	// the standard values of the http.DefaultTransport.
+	if rt == nil {
+		rt = &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		}
+	}
+	return rt
+}
@@ -0,0 +1,100 @@
+package interception
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewInstrumentedTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(`Content-Type`, `application/json`)
+		_, _ = w.Write([]byte(`{"password":"hunter2"}`))
+	}))
+	defer ts.Close()
+
+	defaultTransport := http.DefaultTransport
+	sender := makeAggregationTestSender(1)
+	all := All
+	rt, err := NewInstrumentedTransport(ts.Client().Transport,
+		WithTransportSender(sender),
+		WithTransportDataCollectionRules([]*DataCollectionRule{{LogLevel: &all}}),
+	)
+	if err != nil {
+		t.Fatalf("NewInstrumentedTransport() error = %v", err)
+	}
+	client := &http.Client{Transport: rt}
+
+	res, err := client.Get(ts.URL + `/v1/items`)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	_, _ = ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+
+	if http.DefaultTransport != defaultTransport {
+		t.Error("http.DefaultTransport was modified")
+	}
+	if len(sender.FanIn) != 1 {
+		t.Fatalf("%d reports sent, expected 1", len(sender.FanIn))
+	}
+	rl := <-sender.FanIn
+	if rl.Path != `/v1/items` || rl.StatusCode != http.StatusOK {
+		t.Errorf("unexpected report for %s: status %d", rl.Path, rl.StatusCode)
+	}
+	if strings.Contains(rl.ResponseBody, `hunter2`) {
+		t.Errorf("response body not sanitized: %s", rl.ResponseBody)
+	}
+}
+
+func TestNewInstrumentedTransport_NoSender(t *testing.T) {
+	if rt, err := NewInstrumentedTransport(testJSONRoundTripper{}); err == nil {
+		t.Errorf("NewInstrumentedTransport() = %T, expected an error without a Sender", rt)
+	}
+}
+
+func TestNewInstrumentedTransport_InstrumentedDefault(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	defaultTransport := http.DefaultTransport
+	defer func() { http.DefaultTransport = defaultTransport }()
+	globalSender := makeAggregationTestSender(1)
+	http.DefaultTransport, _ = NewInstrumentedTransport(defaultTransport, WithTransportSender(globalSender))
+
+	sender := makeAggregationTestSender(1)
+	rt, err := NewInstrumentedTransport(nil, WithTransportSender(sender))
+	if err != nil {
+		t.Fatalf("NewInstrumentedTransport() error = %v", err)
+	}
+	client := &http.Client{Transport: rt}
+	res, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	_ = res.Body.Close()
+
+	if len(sender.FanIn) != 1 {
+		t.Errorf("%d reports sent, expected 1", len(sender.FanIn))
+	}
+	if len(globalSender.FanIn) != 0 {
+		t.Errorf("%d reports sent by the instrumented http.DefaultTransport, expected 0", len(globalSender.FanIn))
+	}
+}
+
+func TestUnwrapTransport(t *testing.T) {
+	underlying := testJSONRoundTripper{}
+	if actual := UnwrapTransport(&RoundTripper{Underlying: &RoundTripper{Underlying: underlying}}); actual != underlying {
+		t.Errorf("UnwrapTransport() = %T, expected the underlying %T", actual, underlying)
+	}
+	if actual := UnwrapTransport(underlying); actual != underlying {
+		t.Errorf("UnwrapTransport() = %T, expected %T unchanged", actual, underlying)
+	}
+	if _, ok := UnwrapTransport(&RoundTripper{}).(*http.Transport); !ok {
+		t.Error("UnwrapTransport() on a nil underlying transport did not return an http.Transport")
+	}
+}
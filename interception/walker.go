@@ -5,9 +5,18 @@ import (
 	"reflect"
 )
 
+// DepthLimitExceeded is the replacement value for content nested beyond the
+// maximum depth of a Walker.
+const DepthLimitExceeded = `(depth limit exceeded)`
+
 // WalkFn is the type for visitor functions used with a Walker.
 type WalkFn func(ik interface{}, iv *interface{}, accu *interface{}) error
 
+// PathWalkFn is the type for visitor functions used with PathWalker.WalkPath. The
+// path holds the keys and indexes leading from the root to the visited value,
+// and is only valid during the call.
+type PathWalkFn func(path []interface{}, iv *interface{}, accu *interface{}) error
+
 // Walker is able to walk a visitor WalkFn in preorder across the whole tree of
 // a value unmarshalled from JSON, which is far from being any type of Go data.
 type Walker interface {
@@ -16,6 +25,14 @@ type Walker interface {
 	Value() interface{}
 }
 
+// PathWalker is an optional interface for Walkers able to pass visitors the
+// full path to the visited values, like the ones built by NewWalker and
+// NewDepthLimitedWalker.
+type PathWalker interface {
+	Walker
+	WalkPath(accu *interface{}, visitor PathWalkFn) error
+}
+
 // NewWalker builds an initialized Walker.
 func NewWalker(x interface{}) Walker {
 	return walker{
@@ -23,8 +40,21 @@ func NewWalker(x interface{}) Walker {
 	}
 }
 
+// NewDepthLimitedWalker builds an initialized Walker which does not visit
+// values nested deeper than maxDepth levels below the root, replacing them with
+// DepthLimitExceeded instead, to bound the cost of walking untrusted data.
+//
+// A maxDepth of 0 or less means no limit.
+func NewDepthLimitedWalker(x interface{}, maxDepth int) Walker {
+	return walker{
+		root:     x,
+		maxDepth: maxDepth,
+	}
+}
+
 type walker struct {
-	root interface{}
+	root     interface{}
+	maxDepth int
 }
 
 func (w walker) String() string {
@@ -36,11 +66,26 @@ func (w walker) Value() interface{} {
 }
 
 func (w walker) Walk(accu *interface{}, visitor WalkFn) error {
+	return w.WalkPath(accu, func(path []interface{}, v *interface{}, accu *interface{}) error {
+		var k interface{}
+		if len(path) > 0 {
+			k = path[len(path)-1]
+		}
+		return visitor(k, v, accu)
+	})
+}
+
+func (w walker) WalkPath(accu *interface{}, visitor PathWalkFn) error {
 	return w.walkPreOrder(nil, &w.root, accu, visitor)
 }
 
-func (w walker) walkPreOrder(k interface{}, v *interface{}, accu *interface{}, visitor WalkFn) error {
-	if err := visitor(k, v, accu); err != nil {
+func (w walker) walkPreOrder(path []interface{}, v *interface{}, accu *interface{}, visitor PathWalkFn) error {
+	depth := len(path)
+	if w.maxDepth > 0 && depth > w.maxDepth {
+		*v = DepthLimitExceeded
+		return nil
+	}
+	if err := visitor(path, v, accu); err != nil {
 		return err
 	}
 
@@ -59,21 +104,21 @@ func (w walker) walkPreOrder(k interface{}, v *interface{}, accu *interface{}, v
 			k := iter.Key()
 			v := iter.Value()
 			vi := v.Interface()
-			err := w.walkPreOrder(k.Interface(), &vi, accu, visitor)
+			err := w.walkPreOrder(append(path, k.Interface()), &vi, accu, visitor)
 			if err != nil {
 				return err
 			}
-			value.SetMapIndex(k, reflect.ValueOf(vi))
+			value.SetMapIndex(k, elemValue(vi, value.Type().Elem()))
 		}
 	case reflect.Slice:
 		len := value.Len()
 		for i := 0; i < len; i++ {
 			v := value.Index(i)
 			vi := v.Interface()
-			if err := w.walkPreOrder(i, &vi, accu, visitor); err != nil {
+			if err := w.walkPreOrder(append(path, i), &vi, accu, visitor); err != nil {
 				return err
 			}
-			v.Set(reflect.ValueOf(vi))
+			v.Set(elemValue(vi, v.Type()))
 		}
 	default:
 		// Nothing to walk.
@@ -82,3 +127,12 @@ func (w walker) walkPreOrder(k interface{}, v *interface{}, accu *interface{}, v
 	return nil
 }
 
+// elemValue returns the reflect.Value of a walked map or slice element, using
+// the zero value of the element type for nil, like JSON null values. Unlike
+// reflect.ValueOf(nil), it does not delete map entries nor panic on slices.
+func elemValue(x interface{}, typ reflect.Type) reflect.Value {
+	if x == nil {
+		return reflect.Zero(typ)
+	}
+	return reflect.ValueOf(x)
+}
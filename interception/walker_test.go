@@ -64,3 +64,86 @@ func TestWalker_WalkPreOrder(t *testing.T) {
 		fmt.Println(w)
 	}
 }
+
+func TestWalker_DepthLimit(t *testing.T) {
+	// Build {"n":{"n":{"n":...{"n":"leaf"}}}} nested 10 levels deep.
+	var x interface{} = `leaf`
+	for i := 0; i < 10; i++ {
+		x = map[string]interface{}{`n`: x}
+	}
+	tests := []struct {
+		name       string
+		maxDepth   int
+		wantVisits int
+		wantMarker bool
+	}{
+		{`no limit`, 0, 11, false},
+		{`limit beyond depth`, 10, 11, false},
+		{`limit`, 3, 4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var root interface{}
+			_ = json.Unmarshal([]byte(mustMarshal(t, x)), &root)
+			w := interception.NewDepthLimitedWalker(root, tt.maxDepth)
+			visits := 0
+			var accu interface{}
+			err := w.Walk(&accu, func(_ interface{}, _ *interface{}, _ *interface{}) error {
+				visits++
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Walk() error: %v", err)
+			}
+			if visits != tt.wantVisits {
+				t.Errorf("Walk() visited %d values, want %d", visits, tt.wantVisits)
+			}
+
+			// Descend to the first non-map value.
+			v := w.Value()
+			depth := 0
+			for m, ok := v.(map[string]interface{}); ok; m, ok = v.(map[string]interface{}) {
+				v = m[`n`]
+				depth++
+			}
+			if tt.wantMarker {
+				if v != interception.DepthLimitExceeded || depth != tt.maxDepth+1 {
+					t.Errorf("got %v at depth %d, want marker at depth %d", v, depth, tt.maxDepth+1)
+				}
+			} else if v != `leaf` {
+				t.Errorf("got %v at depth %d, want leaf", v, depth)
+			}
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, x interface{}) string {
+	b, err := json.Marshal(x)
+	if err != nil {
+		t.Fatalf("marshalling test data: %v", err)
+	}
+	return string(b)
+}
+
+func TestWalker_WalkPath(t *testing.T) {
+	var x interface{}
+	_ = json.Unmarshal([]byte(`{"a":[{"b":"leaf"}]}`), &x)
+	w, ok := interception.NewWalker(x).(interception.PathWalker)
+	if !ok {
+		t.Fatal("NewWalker() does not implement PathWalker")
+	}
+	var leafPath string
+	var accu interface{}
+	err := w.WalkPath(&accu, func(path []interface{}, v *interface{}, _ *interface{}) error {
+		if *v == `leaf` {
+			leafPath = fmt.Sprint(path...)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkPath() error: %v", err)
+	}
+	if expected := fmt.Sprint(`a`, 0, `b`); leafPath != expected {
+		t.Errorf("WalkPath() visited the leaf at %q, want %q", leafPath, expected)
+	}
+}
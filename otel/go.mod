@@ -0,0 +1,12 @@
+module github.com/bearer/go-agent/otel
+
+go 1.15
+
+replace github.com/bearer/go-agent => ../
+
+require (
+	github.com/bearer/go-agent v0.0.0-00010101000000-000000000000
+	go.opentelemetry.io/otel v1.0.0
+	go.opentelemetry.io/otel/sdk v1.0.0
+	go.opentelemetry.io/otel/trace v1.0.0
+)
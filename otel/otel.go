@@ -0,0 +1,69 @@
+// Package otel adapts an OpenTelemetry trace.Tracer to the SpanTracer used by
+// the Agent OTelProvider.
+//
+// It is a separate module, so that the Agent itself does not depend on the
+// OpenTelemetry API.
+package otel
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/bearer/go-agent/interception"
+)
+
+// Tracer is an interception.SpanTracer starting each Span with an OpenTelemetry
+// trace.Tracer, as a client span.
+type Tracer struct {
+	trace.Tracer
+}
+
+// NewTracer builds a Tracer emitting its spans with the given trace.Tracer,
+// to be passed to the agent.WithSpanTracer option.
+func NewTracer(tracer trace.Tracer) *Tracer {
+	return &Tracer{Tracer: tracer}
+}
+
+// EmitSpan implements the interception.SpanTracer interface.
+func (t *Tracer) EmitSpan(ctx context.Context, span interception.Span) {
+	_, s := t.Start(ctx, span.Name,
+		trace.WithTimestamp(span.Start),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(Attributes(span.Attributes)...),
+	)
+	if span.Err != nil {
+		s.RecordError(span.Err, trace.WithTimestamp(span.End))
+		s.SetStatus(codes.Error, span.Err.Error())
+	}
+	s.End(trace.WithTimestamp(span.End))
+}
+
+// Attributes converts the attributes of an interception.Span to OpenTelemetry
+// attributes. Values of types without an attribute equivalent are formatted
+// as strings.
+func Attributes(attributes map[string]interface{}) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attributes))
+	for key, value := range attributes {
+		var kv attribute.KeyValue
+		switch v := value.(type) {
+		case string:
+			kv = attribute.String(key, v)
+		case bool:
+			kv = attribute.Bool(key, v)
+		case int:
+			kv = attribute.Int(key, v)
+		case int64:
+			kv = attribute.Int64(key, v)
+		case float64:
+			kv = attribute.Float64(key, v)
+		default:
+			kv = attribute.String(key, fmt.Sprint(v))
+		}
+		kvs = append(kvs, kv)
+	}
+	return kvs
+}
@@ -0,0 +1,152 @@
+package otel_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/bearer/go-agent/events"
+	"github.com/bearer/go-agent/interception"
+	"github.com/bearer/go-agent/otel"
+)
+
+type errorRoundTripper struct{}
+
+func (errorRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New(`connection refused`)
+}
+
+func makeTestTransport(underlying http.RoundTripper) (http.RoundTripper, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	restricted := interception.Restricted
+	dcrp := interception.DCRProvider{DCRs: []*interception.DataCollectionRule{{LogLevel: &restricted}}}
+	d := events.NewDispatcher()
+	d.AddProviders(interception.TopicConnect, events.ListenerProviderFunc(func(events.Event) []events.Listener {
+		return []events.Listener{interception.RFCListener}
+	}), dcrp)
+	d.AddProviders(interception.TopicRequest, dcrp)
+	d.AddProviders(interception.TopicResponse, dcrp)
+	d.AddProviders(interception.TopicBodies, interception.BodyParsingProvider{}, dcrp)
+	d.AddProviders(interception.TopicReport, dcrp, interception.SanitizationProvider{
+		SensitiveKeys:    []*regexp.Regexp{interception.DefaultSensitiveKeys},
+		SensitiveRegexps: []*regexp.Regexp{interception.DefaultSensitiveData},
+	}, interception.OTelProvider{Tracer: otel.NewTracer(tp.Tracer(`test`))})
+	return &interception.RoundTripper{Dispatcher: d, Underlying: underlying}, exporter
+}
+
+func attributeMap(kvs []attribute.KeyValue) map[string]interface{} {
+	m := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		m[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	return m
+}
+
+func TestTracer_EmitSpan(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	rt, exporter := makeTestTransport(ts.Client().Transport)
+	client := &http.Client{Transport: rt}
+	res, err := client.Post(ts.URL+`/v1/items?password=hunter2`, `text/plain`, nil)
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	_, _ = ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("%d spans exported, expected 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name != `HTTP POST` {
+		t.Errorf("span name = %s, expected HTTP POST", span.Name)
+	}
+	if span.SpanKind != trace.SpanKindClient {
+		t.Errorf("span kind = %v, expected %v", span.SpanKind, trace.SpanKindClient)
+	}
+	if !span.EndTime.After(span.StartTime) {
+		t.Errorf("span ends at %v, before its start at %v", span.EndTime, span.StartTime)
+	}
+	if span.Status.Code != codes.Unset {
+		t.Errorf("span status = %v, expected %v", span.Status.Code, codes.Unset)
+	}
+
+	attributes := attributeMap(span.Attributes)
+	expected := map[string]interface{}{
+		interception.SpanAttributeHost:       `127.0.0.1`,
+		interception.SpanAttributeMethod:     http.MethodPost,
+		interception.SpanAttributeStatusCode: int64(http.StatusCreated),
+		interception.SpanAttributeError:      false,
+	}
+	for key, value := range expected {
+		if attributes[key] != value {
+			t.Errorf("span attribute %s = %#v, expected %#v", key, attributes[key], value)
+		}
+	}
+	if _, ok := attributes[interception.SpanAttributeDuration].(int64); !ok {
+		t.Errorf("span attribute %s = %#v, expected an int64", interception.SpanAttributeDuration, attributes[interception.SpanAttributeDuration])
+	}
+	if url, _ := attributes[interception.SpanAttributeURL].(string); strings.Contains(url, `hunter2`) {
+		t.Errorf("span URL not sanitized: %s", url)
+	}
+}
+
+func TestTracer_EmitSpanError(t *testing.T) {
+	rt, exporter := makeTestTransport(errorRoundTripper{})
+	req, _ := http.NewRequest(http.MethodGet, `https://example.com/`, nil)
+	if _, err := rt.RoundTrip(req); err == nil {
+		t.Fatal("RoundTrip() did not return the underlying error")
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("%d spans exported, expected 1", len(spans))
+	}
+	span := spans[0]
+	if span.Status.Code != codes.Error {
+		t.Errorf("span status = %v, expected %v", span.Status.Code, codes.Error)
+	}
+	if len(span.Events) != 1 || span.Events[0].Name != `exception` {
+		t.Errorf("span events = %v, expected the recorded error", span.Events)
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	attributes := attributeMap(otel.Attributes(map[string]interface{}{
+		`string`: `value`,
+		`bool`:   true,
+		`int`:    42,
+		`int64`:  int64(42),
+		`float`:  1.5,
+		`other`:  []string{`a`},
+	}))
+	expected := map[string]interface{}{
+		`string`: `value`,
+		`bool`:   true,
+		`int`:    int64(42),
+		`int64`:  int64(42),
+		`float`:  1.5,
+		`other`:  `[a]`,
+	}
+	for key, value := range expected {
+		if attributes[key] != value {
+			t.Errorf("attribute %s = %#v, expected %#v", key, attributes[key], value)
+		}
+	}
+}
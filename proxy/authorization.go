@@ -0,0 +1,31 @@
+package proxy
+
+import "net/http"
+
+// Authorization describes how the secret key is transmitted to the Bearer
+// platform, in both report and configuration requests.
+//
+// Its zero value passes the bare secret key in the AuthorizationHeader.
+type Authorization struct {
+	// Header is the name of the header carrying the secret key. It defaults to
+	// AuthorizationHeader if empty.
+	Header string
+
+	// Scheme is an optional authentication scheme, like "Bearer", prefixed to
+	// the secret key with a separating space.
+	Scheme string
+}
+
+// Set adds the authorization header for the secret key to the headers, as
+// configured, replacing any existing value.
+func (a Authorization) Set(h http.Header, secretKey string) {
+	name := a.Header
+	if name == `` {
+		name = AuthorizationHeader
+	}
+	value := secretKey
+	if a.Scheme != `` {
+		value = a.Scheme + ` ` + secretKey
+	}
+	h.Set(name, value)
+}
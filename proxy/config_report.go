@@ -62,7 +62,9 @@ type ApplicationReport struct {
 }
 
 // LogReport is the information sent to the Bearer configuration and logs servers,
-// describing the current agent operating environment.
+// describing the current agent operating environment. It is the envelope
+// batching the individual ReportLog elements transmitted to the logs server;
+// configuration fetches send it without Logs.
 type LogReport struct {
 	SecretKey   string            `json:"secretKey,omitempty"`
 	Application ApplicationReport `json:"application"`
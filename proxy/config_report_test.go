@@ -2,6 +2,8 @@ package proxy_test
 
 import (
 	"encoding/base64"
+	"encoding/json"
+	"net/http"
 	"strings"
 	"testing"
 
@@ -37,3 +39,50 @@ func TestMakeConfigReport(t *testing.T) {
 		})
 	}
 }
+
+func TestLogReport_MarshalJSON(t *testing.T) {
+	lr := proxy.MakeConfigReport(`0.0.1`, `test`, agent.ExampleWellFormedInvalidKey)
+	lr.Logs = []proxy.ReportLog{
+		{LogLevel: `DETECTED`, Hostname: `a.example.com`},
+		{LogLevel: `RESTRICTED`, Hostname: `b.example.com`, Method: http.MethodGet},
+	}
+	b, err := json.Marshal(lr)
+	if err != nil {
+		t.Fatalf("failed marshaling LogReport: %v", err)
+	}
+	var actual map[string]interface{}
+	if err := json.Unmarshal(b, &actual); err != nil {
+		t.Fatalf("failed unmarshaling LogReport: %v", err)
+	}
+
+	for _, key := range []string{`secretKey`, `application`, `runtime`, `agent`, `logs`} {
+		if _, ok := actual[key]; !ok {
+			t.Errorf("missing %s in LogReport JSON: %s", key, b)
+		}
+	}
+	if actual[`secretKey`] != agent.ExampleWellFormedInvalidKey {
+		t.Errorf("secretKey = %v, expected %s", actual[`secretKey`], agent.ExampleWellFormedInvalidKey)
+	}
+	logs, ok := actual[`logs`].([]interface{})
+	if !ok || len(logs) != len(lr.Logs) {
+		t.Fatalf("logs = %v, expected %d elements", actual[`logs`], len(lr.Logs))
+	}
+	for i, expected := range lr.Logs {
+		log, ok := logs[i].(map[string]interface{})
+		if !ok {
+			t.Fatalf("logs[%d] = %T, expected an object", i, logs[i])
+		}
+		if log[`logLevel`] != expected.LogLevel || log[`hostname`] != expected.Hostname {
+			t.Errorf("logs[%d] = %v, expected %s %s", i, log, expected.LogLevel, expected.Hostname)
+		}
+	}
+
+	// Configuration fetches send the envelope without logs.
+	lr.Logs = nil
+	b, _ = json.Marshal(lr)
+	actual = nil
+	_ = json.Unmarshal(b, &actual)
+	if _, ok := actual[`logs`]; ok {
+		t.Errorf("unexpected logs in LogReport JSON without logs: %s", b)
+	}
+}
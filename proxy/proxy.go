@@ -6,10 +6,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -25,6 +28,12 @@ const (
 	FanInBacklog = 100
 	// DrainingTimeout is how long to wait for draining before giving up
 	DrainingTimeout = 20 * time.Second
+	// DefaultStopGracePeriod is the default maximum duration Stop waits for
+	// Send calls in progress to complete before finishing.
+	DefaultStopGracePeriod = 100 * time.Millisecond
+	// MaxRetryAfter is the maximum duration transmissions are paused for when
+	// the Bearer platform requests it with a Retry-After header.
+	MaxRetryAfter = 10 * time.Minute
 
 	// End is the ReportLog Type for successful API calls.
 	End = `REQUEST_END`
@@ -36,6 +45,9 @@ const (
 	// AuthorizationHeader is the canonical Authorization header name.
 	AuthorizationHeader = `Authorization`
 
+	// RetryAfterHeader is the canonical Retry-After header name.
+	RetryAfterHeader = `Retry-After`
+
 	// AcceptHeader is the canonical Accept header name.
 	AcceptHeader = `Accept`
 
@@ -53,6 +65,9 @@ const (
 
 	// FullContentTypeJSON is the content type for JSON when emitting it.
 	FullContentTypeJSON = `application/json; charset=utf-8`
+
+	// ContentTypeProtobuf is the content type for protobuf-encoded reports.
+	ContentTypeProtobuf = `application/x-protobuf`
 )
 
 // MustParseURL builds a URL instance from a known-good URL string, panicking it
@@ -95,6 +110,11 @@ type Sender struct {
 	// LogReport elements actually transmitted.
 	Acks chan uint
 
+	// Retries receives the ReportLog elements rejected by the Bearer platform
+	// with a Retry-After header, to be transmitted again once the delay elapses.
+	// Each element is also an acknowledgment of its previous transmission.
+	Retries chan ReportLog
+
 	// InFlight is the number of ReportLog elements awaiting delivery to the
 	// Bearer platform.
 	InFlight uint
@@ -106,6 +126,9 @@ type Sender struct {
 	// Counter is the total number of records handled.
 	Counter uint
 
+	// counterMutex protects Counter, which is updated by WriteLog goroutines.
+	counterMutex sync.Mutex
+
 	// Configuration fields below.
 
 	// InflightLimit is the maximum value of Inflight before bandwidth reduction
@@ -114,15 +137,80 @@ type Sender struct {
 	// of the client process and network.
 	InFlightLimit uint
 
+	// RateLimit is the maximum number of ReportLog elements transmitted per
+	// second, loss reports included. Reports beyond that rate are queued until
+	// transmission is allowed again, the queue being bounded by InFlightLimit
+	// like in-flight reports. Zero means no limit.
+	RateLimit float64
+
+	// limiter enforces RateLimit in the background sending loop.
+	limiter *tokenBucket
+
+	// pending holds the reports delayed by the RateLimit or a Retry-After delay.
+	pending []ReportLog
+
+	// backoffUntil is the UnixNano time until which transmissions are paused,
+	// as requested by the Bearer platform. Access it atomically.
+	backoffUntil int64
+
+	// queueLatency and maxQueueLatency are the durations, in nanoseconds, the
+	// last transmitted report and the slowest one spent queued before their
+	// transmission started. Access them atomically.
+	queueLatency, maxQueueLatency int64
+
+	// MeasureSendDelay enables the measurement of the time Send calls wait
+	// before FanIn accepts their report, which grows when FanIn is full or the
+	// goroutines calling Send are starved.
+	MeasureSendDelay bool
+
+	// sendDelay and maxSendDelay are the durations, in nanoseconds, the last
+	// report and the slowest one waited in Send before entering FanIn, when
+	// MeasureSendDelay is set. Access them atomically.
+	sendDelay, maxSendDelay int64
+
+	// paused is non-zero while reporting is paused. Access it atomically.
+	paused int32
+
+	// stopping is non-zero once Stop was called, and sending is the number of
+	// Send calls in progress. Access them atomically.
+	stopping int32
+	sending  int32
+
+	// StopGracePeriod is the maximum duration Stop waits for Send calls in
+	// progress to complete, before switching to the Finishing mode.
+	StopGracePeriod time.Duration
+
 	// LogEndpoint is the URL of the Bearer host receiving the logs.
 	LogEndpoint string
 
+	// MirrorEndpoint, if not empty, is the URL of a secondary collector also
+	// receiving the reports, without the SecretKey. Its transmissions are made
+	// in the background, independently of those to the LogEndpoint: they are
+	// neither retried nor acknowledged, and failures are only logged.
+	MirrorEndpoint string
+
+	// mirroring is the number of transmissions to the MirrorEndpoint in
+	// progress, bounded by InFlightLimit, and mirrorDropped the number of
+	// reports not mirrored because of that bound. Access them atomically.
+	mirroring     int32
+	mirrorDropped uint64
+
+	// mirrors tracks the transmissions to the MirrorEndpoint, for Stop to wait
+	// for them.
+	mirrors sync.WaitGroup
+
 	// EnvironmentType is the runtime environment type, e.g. staging or production.
 	EnvironmentType string
 
 	// SecretKey is the account secret key.
 	SecretKey string
 
+	// Format is the encoding used for reports sent to the Bearer platform.
+	Format ReportFormat
+
+	// Authorization defines how the SecretKey is passed to the Bearer platform.
+	Authorization Authorization
+
 	// Version is the agent version.
 	Version string
 
@@ -133,7 +221,15 @@ type Sender struct {
 // Stop notifies the background sending loop that the application is shutting
 // down. It will then block waiting for any remaining reports to be sent. If
 // the DrainingTimeout is reached then it will stop sending any further logs.
+//
+// Send calls made after Stop are dropped, and those in progress are given the
+// StopGracePeriod to complete.
 func (s *Sender) Stop() {
+	atomic.StoreInt32(&s.stopping, 1)
+	deadline := time.Now().Add(s.StopGracePeriod)
+	for atomic.LoadInt32(&s.sending) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
 	close(s.Finish)
 	select {
 	case <-s.Done:
@@ -141,6 +237,17 @@ func (s *Sender) Stop() {
 		close(s.ForceFinish)
 	}
 	<-s.Done
+
+	mirrored := make(chan struct{})
+	go func() {
+		s.mirrors.Wait()
+		close(mirrored)
+	}()
+	select {
+	case <-mirrored:
+	case <-time.After(DrainingTimeout):
+		s.Warn().Msg(`mirroring did not complete in time`)
+	}
 }
 
 // NewSender builds a ready-to-user
@@ -153,6 +260,7 @@ func NewSender(
 		Done:            make(chan struct{}),
 		FanIn:           make(chan ReportLog, FanInBacklog),
 		Acks:            make(chan uint, AckBacklog),
+		Retries:         make(chan ReportLog, AckBacklog),
 		Draining:        make(chan struct{}),
 		ForceFinish:     make(chan struct{}),
 		InFlightLimit:   limit,
@@ -162,72 +270,167 @@ func NewSender(
 		Version:         version,
 		Client:          http.Client{Transport: transport},
 		Logger:          logger,
+		StopGracePeriod: DefaultStopGracePeriod,
 	}
 	return &s
 }
 
 // Send sends a ReportLog element to the FanIn channel for transmission.
-// It should not be called after Stop.
+// Reports sent while the Sender is paused, draining, or after Stop are dropped.
 func (s *Sender) Send(log ReportLog) {
+	var calledAt time.Time
+	if s.MeasureSendDelay {
+		calledAt = time.Now()
+	}
+	// Count the call before checking stopping, for Stop to wait for it.
+	atomic.AddInt32(&s.sending, 1)
+	defer atomic.AddInt32(&s.sending, -1)
+	if atomic.LoadInt32(&s.stopping) != 0 {
+		s.Warn().Msg(`sending attempted after Stop: ignored`)
+		return
+	}
+	if s.IsPaused() {
+		s.Trace().Msg(`sending attempted while paused: dropped`)
+		return
+	}
 	select {
 	case <-s.Draining:
-		s.Warn().Msg(`sending attempted after Stop: ignored`)
+		s.Warn().Msg(`sending attempted while draining: dropped`)
 		return
 	default:
+		log.queuedAt = time.Now()
 		s.FanIn <- log
+		if s.MeasureSendDelay {
+			s.recordSendDelay(time.Since(calledAt))
+		}
 	}
 }
 
+// recordSendDelay records the time a Send call waited before FanIn accepted
+// its report. Unlike queue latencies, it is updated by concurrent Send calls.
+func (s *Sender) recordSendDelay(delay time.Duration) {
+	atomic.StoreInt64(&s.sendDelay, int64(delay))
+	for {
+		current := atomic.LoadInt64(&s.maxSendDelay)
+		if int64(delay) <= current || atomic.CompareAndSwapInt64(&s.maxSendDelay, current, int64(delay)) {
+			return
+		}
+	}
+}
+
+// Pause stops the Sender from accepting new reports until Resume is called.
+// Reports already accepted are still transmitted.
+func (s *Sender) Pause() {
+	atomic.StoreInt32(&s.paused, 1)
+}
+
+// Resume lets the Sender accept new reports again after Pause.
+func (s *Sender) Resume() {
+	atomic.StoreInt32(&s.paused, 0)
+}
+
+// IsPaused checks whether the Sender is paused.
+func (s *Sender) IsPaused() bool {
+	return atomic.LoadInt32(&s.paused) != 0
+}
+
+// SenderStats is a snapshot of the Sender activity, for diagnostics.
+type SenderStats struct {
+	// Handled is the number of reports for which transmission was attempted.
+	Handled uint `json:"handled"`
+	// Queued is the number of reports waiting to be picked by the sending loop.
+	Queued int `json:"queued"`
+	// Paused is true while reporting is paused.
+	Paused bool `json:"paused"`
+	// BackoffUntil is the time until which transmissions are paused at the
+	// request of the Bearer platform, if any.
+	BackoffUntil *time.Time `json:"backoffUntil,omitempty"`
+	// QueueLatency is the time the last transmitted report spent queued before
+	// its transmission started, and MaxQueueLatency the longest such time since
+	// the Sender started. Growing values mean the Sender is falling behind.
+	QueueLatency    time.Duration `json:"queueLatency"`
+	MaxQueueLatency time.Duration `json:"maxQueueLatency"`
+	// SendDelay is the time the last report waited in Send before entering the
+	// queue, and MaxSendDelay the longest such time since the Sender started.
+	// They are only measured with MeasureSendDelay. Growing values mean the
+	// queue is full or the goroutines reporting calls are starved.
+	SendDelay    time.Duration `json:"sendDelay,omitempty"`
+	MaxSendDelay time.Duration `json:"maxSendDelay,omitempty"`
+	// MirrorDropped is the number of reports not sent to the MirrorEndpoint
+	// because too many transmissions to it were in progress.
+	MirrorDropped uint64 `json:"mirrorDropped,omitempty"`
+}
+
+// Stats returns a snapshot of the Sender activity. It is safe to call while
+// the sending loop runs.
+func (s *Sender) Stats() SenderStats {
+	stats := SenderStats{
+		Handled: s.count(),
+		Queued:  len(s.FanIn),
+		Paused:  s.IsPaused(),
+
+		QueueLatency:    time.Duration(atomic.LoadInt64(&s.queueLatency)),
+		MaxQueueLatency: time.Duration(atomic.LoadInt64(&s.maxQueueLatency)),
+		SendDelay:       time.Duration(atomic.LoadInt64(&s.sendDelay)),
+		MaxSendDelay:    time.Duration(atomic.LoadInt64(&s.maxSendDelay)),
+		MirrorDropped:   atomic.LoadUint64(&s.mirrorDropped),
+	}
+	if until := s.BackoffUntil(); until.After(time.Now()) {
+		stats.BackoffUntil = &until
+	}
+	return stats
+}
+
 // Start configures and starts the background sending loop.
 func (s *Sender) Start() {
 	defer func() {
 		close(s.Done)
 	}()
 
+	s.limiter = newTokenBucket(s.RateLimit, time.Now())
+
 	// Normal operation.
 Normal:
 	for {
 		select {
 		// Finish received: switch to Finishing mode.
 		case <-s.Finish:
-			s.Logger.Trace().Msgf("Sender switching to Finishing mode at counter %d.", s.Counter)
+			s.Logger.Trace().Msgf("Sender switching to Finishing mode at counter %d.", s.count())
 			break Normal
 
 		// ReportLog to write.
 		case rl, ok := <-s.FanIn:
 			if !ok {
-				s.Logger.Trace().Msgf("Sender switching to Finishing mode on FanIn close, at counter %d.", s.Counter)
+				s.Logger.Trace().Msgf("Sender switching to Finishing mode on FanIn close, at counter %d.", s.count())
 				break Normal
 			}
 			s.Logger.Trace().Msg("Sender received log to send.")
-			if s.InFlight >= s.InFlightLimit {
-				s.Lost++
-				continue
-			}
-			s.InFlight++
-			go s.WriteLog(rl)
+			s.enqueue(rl)
+			s.flush()
+
+		// ReportLog rejected with a Retry-After delay.
+		case rl := <-s.Retries:
+			s.Logger.Trace().Msg("Sender received log to retry.")
+			s.retry(rl)
 
 		// Acknowledgment of ReportLog written.
 		case n := <-s.Acks:
 			s.Logger.Trace().Msg("Sender received ack.")
 			if n == 0 {
-				s.Error().Msgf("received an acknowledgment for 0 report at counter %d", s.Counter)
+				s.Error().Msgf("received an acknowledgment for 0 report at counter %d", s.count())
 				continue
 			}
 			if n > s.InFlight {
 				// This should never happen, except for bugs.
 				s.Error().Msgf(`%d reports acknowledged at counter %d, but only %d were in flight`,
-					n, s.Counter, s.InFlight)
+					n, s.count(), s.InFlight)
 				n = s.InFlight
 			}
 			// First window of opportunity to transmit a loss report.
 			s.InFlight -= n
-			if s.Lost > 0 {
-				s.InFlight++
-				go s.WriteLog(NewReportLossReport(s.Lost))
-				s.Lost = 0
-			}
+			s.reportLoss()
 		default:
+			s.flush()
 			// Go tight loops may be sub-microsecond, so if nothing is going on,
 			// avoid a tight loop to save energy.
 			if len(s.FanIn) == 0 && len(s.Acks) == 0 {
@@ -240,22 +443,30 @@ Normal:
 
 	// Finishing.
 	for {
-		if len(s.FanIn) == 0 && s.InFlight == 0 {
+		if len(s.FanIn) == 0 && len(s.pending) == 0 && s.InFlight == 0 {
 			return
 		}
+		// Wake up periodically while reports are held back by the rate limit.
+		var retry <-chan time.Time
+		if len(s.pending) > 0 {
+			retry = time.After(QuietLoopPause)
+		}
 		select {
 		case <-s.ForceFinish:
-			s.Logger.Warn().Msgf("did not complete in time, dropping %d remaining reports", len(s.FanIn))
+			s.Logger.Warn().Msgf("did not complete in time, dropping %d remaining reports", len(s.FanIn)+len(s.pending))
 			return
 		// ReportLog to write. Same as normal operation.
 		case rl := <-s.FanIn:
 			s.Logger.Trace().Msg("Finishing sender received log.")
-			if s.InFlight >= s.InFlightLimit {
-				s.Lost++
-				continue
-			}
-			s.InFlight++
-			go s.WriteLog(rl)
+			s.enqueue(rl)
+			s.flush()
+
+		case <-retry:
+			s.flush()
+
+		case rl := <-s.Retries:
+			s.Logger.Trace().Msg("Finishing sender received log to retry.")
+			s.retry(rl)
 
 		case n := <-s.Acks:
 			s.Logger.Trace().Msg("Finishing sender received ack.")
@@ -269,67 +480,275 @@ Normal:
 				n = s.InFlight
 			}
 			s.InFlight -= n
-			if s.Lost > 0 {
-				s.InFlight++
-				go s.WriteLog(NewReportLossReport(s.Lost))
-				s.Lost = 0
-			}
+			s.reportLoss()
+		}
+	}
+}
+
+// count returns the Counter value, which WriteLog goroutines may be updating.
+func (s *Sender) count() uint {
+	s.counterMutex.Lock()
+	defer s.counterMutex.Unlock()
+	return s.Counter
+}
+
+// enqueue adds a ReportLog to the pending reports, unless the number of reports
+// in flight or pending exceeds InFlightLimit, in which case it is counted as lost.
+func (s *Sender) enqueue(rl ReportLog) {
+	if s.InFlight+uint(len(s.pending)) >= s.InFlightLimit {
+		s.Lost++
+		return
+	}
+	s.pending = append(s.pending, rl)
+}
+
+// reportLoss queues a loss report for the reports lost so far, if any. Unlike
+// other reports, it is queued regardless of the InFlightLimit, so that losses
+// are always reported, but it is still transmitted within the RateLimit.
+func (s *Sender) reportLoss() {
+	if s.Lost == 0 {
+		return
+	}
+	s.pending = append(s.pending, NewReportLossReport(s.Lost))
+	s.Lost = 0
+	s.flush()
+}
+
+// retry puts a ReportLog rejected with a Retry-After delay back in front of the
+// pending reports. It is no longer in flight, but not lost either.
+func (s *Sender) retry(rl ReportLog) {
+	if s.InFlight > 0 {
+		s.InFlight--
+	}
+	s.pending = append([]ReportLog{rl}, s.pending...)
+}
+
+// BackoffUntil returns the time until which transmissions are paused at the
+// request of the Bearer platform. It is in the past if they are not paused.
+func (s *Sender) BackoffUntil() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.backoffUntil))
+}
+
+// backOff pauses transmissions until the given time, unless they are already
+// paused for longer.
+func (s *Sender) backOff(until time.Time) {
+	next := until.UnixNano()
+	for {
+		current := atomic.LoadInt64(&s.backoffUntil)
+		if current >= next || atomic.CompareAndSwapInt64(&s.backoffUntil, current, next) {
+			return
+		}
+	}
+}
+
+// retryAfter returns the delay requested by the Retry-After header of a 429 or
+// 503 response, either as a number of seconds or as an HTTP date, capped to
+// MaxRetryAfter. It returns false if no valid delay is requested.
+func retryAfter(res *http.Response, now time.Time) (time.Duration, bool) {
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	value := res.Header.Get(RetryAfterHeader)
+	if value == `` {
+		return 0, false
+	}
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
 		}
+		delay = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		delay = at.Sub(now)
+	} else {
+		return 0, false
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > MaxRetryAfter {
+		delay = MaxRetryAfter
+	}
+	return delay, true
+}
+
+// recordQueueLatency records the time a ReportLog spent queued since Send
+// accepted it. Reports not accepted by Send, like loss reports, are ignored.
+func (s *Sender) recordQueueLatency(rl ReportLog, now time.Time) {
+	if rl.queuedAt.IsZero() {
+		return
+	}
+	// Only the sending loop records latencies, so there is no concurrent update.
+	latency := int64(now.Sub(rl.queuedAt))
+	atomic.StoreInt64(&s.queueLatency, latency)
+	if latency > atomic.LoadInt64(&s.maxQueueLatency) {
+		atomic.StoreInt64(&s.maxQueueLatency, latency)
+	}
+}
+
+// flush starts transmission of the pending reports, in order, as long as the
+// RateLimit and any Retry-After delay allow it.
+func (s *Sender) flush() {
+	if time.Now().Before(s.BackoffUntil()) {
+		return
+	}
+	for now := time.Now(); len(s.pending) > 0 && s.limiter.Allow(now); now = time.Now() {
+		rl := s.pending[0]
+		s.pending = s.pending[1:]
+		s.recordQueueLatency(rl, now)
+		s.InFlight++
+		go s.WriteLog(rl)
 	}
 }
 
 // WriteLog attempts to transmit a ReportLog to the Bearer platform, and acknowleges
-// it finished its attempt, whether it succeeded or not.
+// it finished its attempt, whether it succeeded or not. Invalid ReportLog
+// elements are dropped without being transmitted.
+//
+// If the platform rejects it with a 429 or 503 response carrying a Retry-After
+// header, transmissions are paused for the requested delay, and the ReportLog
+// is handed back for retry instead of being acknowledged.
+//
+// The ReportLog is mirrored once the attempt is over, so that retries do not
+// mirror it again.
 func (s *Sender) WriteLog(rl ReportLog) {
+	retrying := false
+	var mirrored *LogReport
 	defer func() {
+		if retrying {
+			s.Retries <- rl
+			return
+		}
+		if mirrored != nil {
+			s.mirror(*mirrored)
+		}
 		var n uint = 1
+		// Count the report before acknowledging it, so that the Counter is up
+		// to date once Stop returns.
+		s.counterMutex.Lock()
+		s.Counter += n
+		s.counterMutex.Unlock()
 		// The attempt was made, the request is no longer outstanding even if it failed.
 		s.Acks <- n
-		s.Counter += n
 	}()
 
+	if err := rl.Validate(); err != nil {
+		s.Warn().Err(err).Msg(`dropping invalid log report`)
+		return
+	}
+
 	lr := MakeConfigReport(s.Version, s.EnvironmentType, s.SecretKey)
-	lr.SecretKey = s.SecretKey
 	lr.Logs = []ReportLog{rl}
+	if s.MirrorEndpoint != `` {
+		ml := lr
+		mirrored = &ml
+	}
+	lr.SecretKey = s.SecretKey
 
-	// Cannot fail: the LogReport is made of basic JSON types.
-	body, _ := json.Marshal(lr)
+	body, err := s.Format.Marshal(lr)
+	if err != nil {
+		s.Warn().Err(err).Msg(`error encoding the log report`)
+		return
+	}
+	// Logs always show the report in JSON for readability.
+	logged := body
+	if s.Format != FormatJSON {
+		// Cannot fail: the LogReport is made of basic JSON types.
+		logged, _ = json.Marshal(lr)
+	}
 
 	req, err := http.NewRequest(http.MethodPost, s.LogEndpoint, bytes.NewReader(body))
 	if err != nil {
 		s.Warn().Err(err).Msg(`error building the log request`)
 		return
 	}
-	req.Header.Add(AuthorizationHeader, s.SecretKey)
+	s.Authorization.Set(req.Header, s.SecretKey)
 	req.Header.Add(AcceptHeader, ContentTypeJSON)
-	req.Header.Set(ContentTypeHeader, FullContentTypeJSON)
+	req.Header.Set(ContentTypeHeader, s.Format.ContentType())
 	res, err := s.Client.Do(req)
 
 	if err != nil {
-		s.Warn().Err(err).Msgf(`transmitting log %d to the report server.`, s.Counter)
+		s.Warn().Err(err).Msgf(`transmitting log %d to the report server.`, s.count())
 	} else {
+		// Drain the body on every path, so that the connection can be reused.
+		defer func() {
+			_, _ = io.Copy(ioutil.Discard, res.Body)
+			_ = res.Body.Close()
+		}()
 		if res.StatusCode < http.StatusContinue || res.StatusCode >= http.StatusBadRequest {
+			if delay, ok := retryAfter(res, time.Now()); ok {
+				s.backOff(time.Now().Add(delay))
+				retrying = true
+				s.Warn().Msgf(`got response %d %s transmitting log %d to the report server: retrying after %v.`,
+					res.StatusCode, res.Status, s.count(), delay)
+				return
+			}
 			logsBody, err := ioutil.ReadAll(res.Body)
 			if len(logsBody) == 0 {
 				logsBody = []byte(`[]`)
 			}
 			s.Warn().
-				RawJSON("report", body).
+				RawJSON("report", logged).
 				Err(err).
 				RawJSON("logs body", logsBody).
-				Msgf(`got response %d %s transmitting log %d to the report server.`, res.StatusCode, res.Status, s.Counter)
+				Msgf(`got response %d %s transmitting log %d to the report server.`, res.StatusCode, res.Status, s.count())
 			return
 		}
 		resBody, _ := ioutil.ReadAll(res.Body)
 		s.Trace().
-			Uint("reportId", s.Counter).
+			Uint("reportId", s.count()).
 			Str("status", res.Status).
-			RawJSON("report", body).
+			RawJSON("report", logged).
 			Bytes("response", resBody).
 			Send()
 	}
 }
 
+// mirror starts transmitting a LogReport to the MirrorEndpoint in the
+// background, unless InFlightLimit transmissions to it are in progress, in
+// which case it is dropped.
+func (s *Sender) mirror(lr LogReport) {
+	if atomic.AddInt32(&s.mirroring, 1) > int32(s.InFlightLimit) {
+		atomic.AddInt32(&s.mirroring, -1)
+		atomic.AddUint64(&s.mirrorDropped, 1)
+		return
+	}
+	s.mirrors.Add(1)
+	go func() {
+		defer s.mirrors.Done()
+		defer atomic.AddInt32(&s.mirroring, -1)
+		s.writeMirror(lr)
+	}()
+}
+
+// writeMirror transmits a LogReport to the MirrorEndpoint, logging failures.
+// The SecretKey is neither included in the report nor in the request headers.
+func (s *Sender) writeMirror(lr LogReport) {
+	lr.SecretKey = ``
+	body, err := s.Format.Marshal(lr)
+	if err != nil {
+		s.Warn().Err(err).Msg(`error encoding the mirrored log report`)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, s.MirrorEndpoint, bytes.NewReader(body))
+	if err != nil {
+		s.Warn().Err(err).Msg(`error building the mirrored log request`)
+		return
+	}
+	req.Header.Add(AcceptHeader, ContentTypeJSON)
+	req.Header.Set(ContentTypeHeader, s.Format.ContentType())
+	res, err := s.Client.Do(req)
+	if err != nil {
+		s.Warn().Err(err).Msg(`transmitting log to the mirror server.`)
+		return
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	if res.StatusCode < http.StatusContinue || res.StatusCode >= http.StatusBadRequest {
+		s.Warn().Msgf(`got response %d %s transmitting log to the mirror server.`, res.StatusCode, res.Status)
+	}
+}
+
 // NewReportLossReport creates an off-API ReportLog for lost records.
 func NewReportLossReport(n uint) ReportLog {
 	return ReportLog{
@@ -351,35 +770,109 @@ type ReportLog struct {
 	Type                      string                      `json:"type,omitempty"`      // REQUEST_END on success, REQUEST_ERROR on connection errors
 	Stage                     string                      `json:"stageType,omitempty"`
 	ActiveDataCollectionRules *[]ReportDataCollectionRule `json:"activeDataCollectionRules,omitempty"` // More compact than sending the complete rule.
+	LogLevelRule              *ReportDataCollectionRule   `json:"logLevelRule,omitempty"`              // The active rule which determined the LogLevel.
+	// OmittedDataCollectionRules is the number of triggered rules left out of
+	// ActiveDataCollectionRules to bound the report size.
+	OmittedDataCollectionRules int `json:"omittedDataCollectionRules,omitempty"`
 
 	// filters.StageConnect
 
 	Port     uint16 `json:"port"`
 	Protocol string `json:"protocol"` // Scheme: http[s]
 	Hostname string `json:"hostname"`
+	// ResolvedAddresses are the addresses the Hostname resolved to, if a
+	// lookup was performed for the call, which is not the case on connection reuse.
+	ResolvedAddresses []string `json:"resolvedAddresses,omitempty"`
+	// ConnectionReuse is "new" or "reused" when the transport traced whether
+	// the call connection was reused from its pool.
+	ConnectionReuse string `json:"connectionReuse,omitempty"`
 
 	// filters.StageRequest
 
 	Path           string      `json:"path,omitempty"`
 	Method         string      `json:"method,omitempty"`
+	MethodOverride string      `json:"methodOverride,omitempty"` // From a method override header.
 	URL            string      `json:"url,omitempty"`
+	FinalURL       string      `json:"finalUrl,omitempty"` // After redirects followed by the transport.
 	RequestHeaders http.Header `json:"requestHeaders"`
+	Anomalies      []string    `json:"anomalies,omitempty"` // Suspicious request characteristics.
+	// Request body transmission: bytes read by the transport, and whether it
+	// stopped before the end of the body.
+	RequestBodyBytesRead int  `json:"requestBodyBytesRead,omitempty"`
+	RequestBodyPartial   bool `json:"requestBodyPartial,omitempty"`
+	RequestChunked       bool `json:"requestChunked,omitempty"` // Chunked transfer encoding.
+	// Request trailers, sent after the request body.
+	RequestTrailers http.Header `json:"requestTrailers,omitempty"`
+	// ProxyChain lists the intermediaries described by the X-Forwarded-For and
+	// Via headers, if any.
+	ProxyChain *ProxyChain `json:"proxyChain,omitempty"`
 
 	// filters.StageResponse
 
 	ResponseHeaders http.Header `json:"responseHeaders"`
 	StatusCode      int         `json:"statusCode,omitempty"`
+	RetryCount      int         `json:"retryCount,omitempty"`
+	FromCache       bool        `json:"fromCache,omitempty"`       // Served from a cache.
+	StreamID        int         `json:"streamId,omitempty"`        // HTTP/2 stream ID, if known.
+	ResponseChunked bool        `json:"responseChunked,omitempty"` // Chunked transfer encoding.
+	// ResponseStreaming is true for streams, like Server-Sent Events, whose
+	// body is not captured.
+	ResponseStreaming bool `json:"responseStreaming,omitempty"`
 
 	// filters.StageBodies. Note that these 4 may very well NOT be valid strings.
 	RequestBody  string `json:"requestBody,omitempty"`
 	ResponseBody string `json:"responseBody,omitempty"`
+	// Content types as received, even if the headers are filtered. Unlike the
+	// bodies, the request content type is reported from the Restricted level.
+	RequestBodyContentType  string `json:"requestBodyContentType,omitempty"`
+	ResponseBodyContentType string `json:"responseBodyContentType,omitempty"`
+	// Content types sniffed from bodies without a Content-Type header.
+	RequestBodySniffedContentType  string `json:"requestBodySniffedContentType,omitempty"`
+	ResponseBodySniffedContentType string `json:"responseBodySniffedContentType,omitempty"`
 	// Payload SHAs
 	RequestBodyPayloadSHA  string `json:"requestBodyPayloadSha,omitempty"`
 	ResponseBodyPayloadSHA string `json:"responseBodyPayloadSha,omitempty"`
+	// Raw body digests, by algorithm name.
+	RequestBodyDigests  map[string]string `json:"requestBodyDigests,omitempty"`
+	ResponseBodyDigests map[string]string `json:"responseBodyDigests,omitempty"`
+	// Line counts of the text bodies, reported without the bodies.
+	RequestBodyLines  int `json:"requestBodyLines,omitempty"`
+	ResponseBodyLines int `json:"responseBodyLines,omitempty"`
+	// Form field counts and total sizes, reported without the form values.
+	RequestFormFields  int `json:"requestFormFields,omitempty"`
+	RequestFormSize    int `json:"requestFormSize,omitempty"`
+	ResponseFormFields int `json:"responseFormFields,omitempty"`
+	ResponseFormSize   int `json:"responseFormSize,omitempty"`
+	// Whether the body sizes differ from their declared Content-Length.
+	RequestLengthMismatch  bool `json:"requestLengthMismatch,omitempty"`
+	ResponseLengthMismatch bool `json:"responseLengthMismatch,omitempty"`
+	// Sanitized body previews, only at the RESTRICTED level.
+	RequestBodyPreview  string `json:"requestBodyPreview,omitempty"`
+	ResponseBodyPreview string `json:"responseBodyPreview,omitempty"`
 
 	// Error
 	ErrorCode        string `json:"errorCode,omitempty"`
 	ErrorFullMessage string `json:"errorFullMessage,omitempty"`
+
+	// Count is the number of identical calls aggregated in the report, if any.
+	Count int `json:"count,omitempty"`
+
+	// CustomFields are the fields attached by the application to the request
+	// context, e.g. an order ID. They are not sanitized.
+	CustomFields map[string]interface{} `json:"customFields,omitempty"`
+
+	// queuedAt is the time Send accepted the report, to measure its queue latency.
+	queuedAt time.Time
+}
+
+// ProxyChain describes the proxies traversed by an API call.
+type ProxyChain struct {
+	// ForwardedFor are the addresses in the request X-Forwarded-For headers,
+	// from the original client to the last proxy.
+	ForwardedFor []string `json:"forwardedFor,omitempty"`
+	// Via are the entries of the request then response Via headers, like
+	// "1.1 proxy.example.com".
+	Via []string `json:"via,omitempty"`
 }
 
 // ReportDataCollectionRule is a subset of a DataCollectionRule used to report
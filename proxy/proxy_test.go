@@ -4,12 +4,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -45,6 +49,20 @@ func makeTestSender() (*proxy.Sender, *ConcurrentBuilder) {
 	return sender, sb
 }
 
+// makeTestReportLog builds a valid RESTRICTED ReportLog for the method.
+func makeTestReportLog(method string) proxy.ReportLog {
+	return proxy.ReportLog{
+		LogLevel:  proxy.LogLevelRestricted,
+		StartedAt: 1590000000000,
+		EndedAt:   1590000000100,
+		Type:      proxy.End,
+		Stage:     string(proxy.StageBodies),
+		Hostname:  `api.example.com`,
+		Method:    method,
+		URL:       `https://api.example.com/`,
+	}
+}
+
 func TestMustParseURL(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -96,7 +114,8 @@ func TestSender_Send(t *testing.T) {
 
 	select {
 	case sentLog := <-sender.FanIn:
-		if !reflect.DeepEqual(sentLog, log) {
+		// Send stamps the log with its queuing time, so it is not DeepEqual.
+		if sentLog.LogLevel != log.LogLevel {
 			t.Error(`received log is not the one that was sent`)
 		}
 	default:
@@ -107,8 +126,8 @@ func TestSender_Send(t *testing.T) {
 
 	close(sender.Draining)
 	sender.Send(log)
-	if len(builder.String()) == 0 {
-		t.Errorf(`expected warning but got none`)
+	if !strings.Contains(builder.String(), `draining`) {
+		t.Errorf(`expected draining warning, got: %s`, builder.String())
 	}
 
 	select {
@@ -118,6 +137,28 @@ func TestSender_Send(t *testing.T) {
 	}
 }
 
+func TestSender_SendPaused(t *testing.T) {
+	sender, _ := makeTestSender()
+
+	sender.Pause()
+	if !sender.IsPaused() {
+		t.Fatal(`sender not paused after Pause()`)
+	}
+	sender.Send(proxy.ReportLog{})
+	if len(sender.FanIn) != 0 {
+		t.Error(`log was sent but should have been dropped while paused`)
+	}
+
+	sender.Resume()
+	if sender.IsPaused() {
+		t.Fatal(`sender still paused after Resume()`)
+	}
+	sender.Send(proxy.ReportLog{})
+	if len(sender.FanIn) != 1 {
+		t.Error(`log was not sent after Resume()`)
+	}
+}
+
 func TestSender_StartHappyAck(t *testing.T) {
 	sender, builder := makeTestSender()
 	sender.InFlight = 1
@@ -139,6 +180,47 @@ func TestSender_StartHappyAck(t *testing.T) {
 	}
 }
 
+func TestSender_SendDuringStop(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	z := zerolog.New(ioutil.Discard)
+	sender := proxy.NewSender(config.DefaultReportOutstanding, ts.URL,
+		agent.Version, agent.ExampleWellFormedInvalidKey, `test`, ts.Client().Transport, &z)
+	go sender.Start()
+
+	const senders, sends = 50, 20
+	var wg sync.WaitGroup
+	wg.Add(senders)
+	for i := 0; i < senders; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < sends; j++ {
+				sender.Send(proxy.ReportLog{LogLevel: `DETECTED`})
+			}
+		}()
+	}
+	sender.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal(`Send calls blocked after Stop`)
+	}
+
+	// Sending after Stop is a no-op.
+	sender.Send(proxy.ReportLog{})
+	if n := len(sender.FanIn); n != 0 {
+		t.Errorf("%d reports left in FanIn after Stop", n)
+	}
+}
+
 func TestNewReportLossReport(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -169,7 +251,7 @@ func TestSender_WriteLog(t *testing.T) {
 		method      string
 		wantErr     bool
 	}{
-		{`happy`, ``, ``, false},
+		{`happy`, ``, http.MethodGet, false},
 		{`sad bad endpoint`, `_://`, ``, true},
 		{`sad mute endpoint`, `http://example.invalid`, ``, true},
 		{`sad rejected response`, ``, http.MethodConnect, true},
@@ -200,7 +282,7 @@ func TestSender_WriteLog(t *testing.T) {
 				s.LogEndpoint = ts.URL
 			}
 
-			s.WriteLog(proxy.ReportLog{Method: tt.method})
+			s.WriteLog(makeTestReportLog(tt.method))
 			log := struct {
 				Level    string
 				ReportId int
@@ -220,3 +302,496 @@ func TestSender_WriteLog(t *testing.T) {
 		})
 	}
 }
+
+func TestSender_WriteLogInvalid(t *testing.T) {
+	var m sync.Mutex
+	var methods []string
+	ts := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+		lr := proxy.LogReport{}
+		_ = json.Unmarshal(body, &lr)
+		m.Lock()
+		defer m.Unlock()
+		methods = append(methods, lr.Logs[0].Method)
+	}))
+	defer ts.Close()
+
+	s, cb := makeTestSender()
+	s.Client = *ts.Client()
+	s.LogEndpoint = ts.URL
+
+	invalid := makeTestReportLog(http.MethodPost)
+	invalid.URL = ``
+	s.WriteLog(invalid)
+	s.WriteLog(makeTestReportLog(http.MethodGet))
+
+	m.Lock()
+	defer m.Unlock()
+	if expected := []string{http.MethodGet}; !reflect.DeepEqual(methods, expected) {
+		t.Errorf(`received reports %v, expected %v`, methods, expected)
+	}
+	if !strings.Contains(cb.String(), proxy.ErrInvalidReportLog.Error()) {
+		t.Errorf(`invalid report not logged: %s`, cb.String())
+	}
+	if n := len(s.Acks); n != 2 {
+		t.Errorf(`%d reports acknowledged, expected 2`, n)
+	}
+}
+
+func TestSender_StartRateLimit(t *testing.T) {
+	const (
+		rate  = 20
+		count = 30
+	)
+	var m sync.Mutex
+	var received []time.Time
+	ts := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		m.Lock()
+		defer m.Unlock()
+		received = append(received, time.Now())
+	}))
+	defer ts.Close()
+
+	sender, _ := makeTestSender()
+	sender.Client = *ts.Client()
+	sender.LogEndpoint = ts.URL
+	sender.RateLimit = rate
+	go sender.Start()
+
+	t0 := time.Now()
+	for i := 0; i < count; i++ {
+		sender.Send(makeTestReportLog(http.MethodGet))
+	}
+	sender.Stop()
+
+	m.Lock()
+	defer m.Unlock()
+	if len(received) != count {
+		t.Fatalf(`received %d reports, expected %d`, len(received), count)
+	}
+	sort.Slice(received, func(i, j int) bool { return received[i].Before(received[j]) })
+	// The first second worth of reports may be sent as a burst, the rest must
+	// be spread according to the rate.
+	minDuration := time.Duration(float64(count-rate) / rate * float64(time.Second))
+	if elapsed := received[count-1].Sub(t0); elapsed < minDuration*9/10 {
+		t.Errorf(`%d reports sent in %v, expected at least %v at %d/sec`, count, elapsed, minDuration, rate)
+	}
+	// Over any one-second window, no more than the burst plus the rate may be sent.
+	for i, start := range received {
+		n := 0
+		for _, at := range received[i:] {
+			if at.Sub(start) < time.Second {
+				n++
+			}
+		}
+		if n > 2*rate {
+			t.Fatalf(`%d reports sent within a second, exceeding the %d/sec ceiling`, n, rate)
+		}
+	}
+}
+
+func TestSender_WriteLogAuthorization(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization proxy.Authorization
+		wantHeader    string
+		wantValue     string
+	}{
+		{`default`, proxy.Authorization{}, proxy.AuthorizationHeader, agent.ExampleWellFormedInvalidKey},
+		{`scheme`, proxy.Authorization{Scheme: `Bearer`}, proxy.AuthorizationHeader, `Bearer ` + agent.ExampleWellFormedInvalidKey},
+		{`header and scheme`, proxy.Authorization{Header: `X-Api-Key`, Scheme: `Token`}, `X-Api-Key`, `Token ` + agent.ExampleWellFormedInvalidKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var actual http.Header
+			ts := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				actual = request.Header
+			}))
+			defer ts.Close()
+
+			s, _ := makeTestSender()
+			s.Client = *ts.Client()
+			s.LogEndpoint = ts.URL
+			s.Authorization = tt.authorization
+			s.WriteLog(makeTestReportLog(http.MethodGet))
+
+			if got := actual.Get(tt.wantHeader); got != tt.wantValue {
+				t.Errorf(`header %s = %q, want %q`, tt.wantHeader, got, tt.wantValue)
+			}
+			if tt.wantHeader != proxy.AuthorizationHeader && actual.Get(proxy.AuthorizationHeader) != `` {
+				t.Errorf(`unexpected %s header with custom header name`, proxy.AuthorizationHeader)
+			}
+		})
+	}
+}
+
+func TestSender_StartRetryAfter(t *testing.T) {
+	const retryAfter = 1 // Second.
+	var m sync.Mutex
+	var received []time.Time
+	var methods []string
+	ts := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+		lr := proxy.LogReport{}
+		_ = json.Unmarshal(body, &lr)
+		m.Lock()
+		defer m.Unlock()
+		received = append(received, time.Now())
+		methods = append(methods, lr.Logs[0].Method)
+		if len(received) == 1 {
+			writer.Header().Set(proxy.RetryAfterHeader, strconv.Itoa(retryAfter))
+			writer.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer ts.Close()
+
+	sender, _ := makeTestSender()
+	sender.Client = *ts.Client()
+	sender.LogEndpoint = ts.URL
+	go sender.Start()
+
+	sender.Send(makeTestReportLog(http.MethodGet))
+	sender.Send(makeTestReportLog(http.MethodPost))
+	sender.Stop()
+
+	m.Lock()
+	defer m.Unlock()
+	if len(methods) != 3 {
+		t.Fatalf(`received reports %v, expected 3 with the rejected one retried`, methods)
+	}
+	// Both reports may be transmitted concurrently before the rejection, but
+	// the rejected one must only be retried after the requested delay.
+	rejected, retried := methods[0], -1
+	for i := 1; i < len(methods); i++ {
+		if methods[i] == rejected {
+			retried = i
+		}
+	}
+	if retried < 0 {
+		t.Fatalf(`rejected report %s not retried: received %v`, rejected, methods)
+	}
+	if elapsed := received[retried].Sub(received[0]); elapsed < retryAfter*time.Second {
+		t.Errorf(`report retried after %v, expected at least %v`, elapsed, retryAfter*time.Second)
+	}
+}
+
+func TestSender_StatsQueueLatency(t *testing.T) {
+	const (
+		rate  = 20
+		count = 25
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ts.Close()
+
+	sender, _ := makeTestSender()
+	sender.Client = *ts.Client()
+	sender.LogEndpoint = ts.URL
+	sender.RateLimit = rate
+	go sender.Start()
+
+	// Beyond the initial burst, reports wait for the rate limit in the queue.
+	for i := 0; i < count; i++ {
+		sender.Send(makeTestReportLog(http.MethodGet))
+	}
+	sender.Stop()
+
+	stats := sender.Stats()
+	if stats.QueueLatency <= 0 {
+		t.Errorf(`QueueLatency = %v, expected it to be positive`, stats.QueueLatency)
+	}
+	// The last reports wait for (count - rate) / rate seconds.
+	minLatency := time.Duration(float64(count-rate) / rate * float64(time.Second))
+	if stats.MaxQueueLatency < minLatency*9/10 {
+		t.Errorf(`MaxQueueLatency = %v, expected at least %v`, stats.MaxQueueLatency, minLatency)
+	}
+	if stats.MaxQueueLatency < stats.QueueLatency {
+		t.Errorf(`MaxQueueLatency = %v, expected at least QueueLatency %v`, stats.MaxQueueLatency, stats.QueueLatency)
+	}
+}
+
+func TestSender_StatsSendDelay(t *testing.T) {
+	const (
+		senders = 50
+		period  = 2 * time.Millisecond
+	)
+	for _, measured := range []bool{false, true} {
+		t.Run(strconv.FormatBool(measured), func(t *testing.T) {
+			sender, _ := makeTestSender()
+			sender.MeasureSendDelay = measured
+			// Simulate a saturated sending loop: a short queue drained slowly.
+			sender.FanIn = make(chan proxy.ReportLog, 1)
+			drained := make(chan struct{})
+			go func() {
+				defer close(drained)
+				for i := 0; i < senders; i++ {
+					<-sender.FanIn
+					time.Sleep(period)
+				}
+			}()
+
+			wg := sync.WaitGroup{}
+			wg.Add(senders)
+			for i := 0; i < senders; i++ {
+				go func() {
+					defer wg.Done()
+					sender.Send(makeTestReportLog(http.MethodGet))
+				}()
+			}
+			wg.Wait()
+			<-drained
+
+			stats := sender.Stats()
+			if !measured {
+				if stats.SendDelay != 0 || stats.MaxSendDelay != 0 {
+					t.Errorf(`SendDelay = %v, MaxSendDelay = %v, expected them unmeasured`, stats.SendDelay, stats.MaxSendDelay)
+				}
+				return
+			}
+			if stats.SendDelay <= 0 {
+				t.Errorf(`SendDelay = %v, expected it to be positive`, stats.SendDelay)
+			}
+			// The last senders wait for most of the others to be drained.
+			if minDelay := senders / 2 * period; stats.MaxSendDelay < minDelay {
+				t.Errorf(`MaxSendDelay = %v, expected at least %v`, stats.MaxSendDelay, minDelay)
+			}
+			if stats.MaxSendDelay < stats.SendDelay {
+				t.Errorf(`MaxSendDelay = %v, expected at least SendDelay %v`, stats.MaxSendDelay, stats.SendDelay)
+			}
+		})
+	}
+}
+
+// reportSink is a test report server recording the methods of the reports it
+// receives.
+type reportSink struct {
+	sync.Mutex
+	*httptest.Server
+	methods []string
+	headers []http.Header
+	keys    []string
+}
+
+func newReportSink(t *testing.T, handle func(http.ResponseWriter)) *reportSink {
+	s := &reportSink{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var lr proxy.LogReport
+		if err := json.NewDecoder(r.Body).Decode(&lr); err != nil {
+			t.Errorf(`decoding report: %v`, err)
+		}
+		s.Lock()
+		for _, rl := range lr.Logs {
+			s.methods = append(s.methods, rl.Method)
+		}
+		s.headers = append(s.headers, r.Header)
+		s.keys = append(s.keys, lr.SecretKey)
+		s.Unlock()
+		handle(w)
+	}))
+	return s
+}
+
+func (s *reportSink) received() []string {
+	s.Lock()
+	defer s.Unlock()
+	methods := append([]string(nil), s.methods...)
+	sort.Strings(methods)
+	return methods
+}
+
+func TestSender_Mirror(t *testing.T) {
+	methods := []string{http.MethodDelete, http.MethodGet, http.MethodPost}
+	ok := func(http.ResponseWriter) {}
+	failing := func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) }
+	// Both sinks receive all reports, whatever happens to the other one.
+	tests := []struct {
+		name            string
+		primary, mirror func(http.ResponseWriter)
+		blockMirror     bool
+	}{
+		{`both up`, ok, ok, false},
+		{`mirror failing`, ok, failing, false},
+		{`primary failing`, failing, ok, false},
+		{`mirror blocked`, ok, ok, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A blocked mirror is only released once the primary sink received
+			// all reports, which would never happen if it delayed them.
+			primaryDone := make(chan struct{})
+			var count int
+			var countMutex sync.Mutex
+			primary := newReportSink(t, func(w http.ResponseWriter) {
+				countMutex.Lock()
+				count++
+				if count == len(methods) {
+					close(primaryDone)
+				}
+				countMutex.Unlock()
+				tt.primary(w)
+			})
+			defer primary.Close()
+			block := tt.blockMirror
+			mirror := newReportSink(t, func(w http.ResponseWriter) {
+				if block {
+					select {
+					case <-primaryDone:
+					case <-time.After(5 * time.Second):
+						t.Error(`the blocked mirror delayed the primary reports`)
+					}
+				}
+				tt.mirror(w)
+			})
+			defer mirror.Close()
+
+			sender, _ := makeTestSender()
+			sender.LogEndpoint = primary.URL
+			sender.MirrorEndpoint = mirror.URL
+			go sender.Start()
+			for _, method := range methods {
+				sender.Send(makeTestReportLog(method))
+			}
+			sender.Stop()
+
+			if actual := primary.received(); !reflect.DeepEqual(actual, methods) {
+				t.Errorf(`primary received %v, expected %v`, actual, methods)
+			}
+			if actual := mirror.received(); !reflect.DeepEqual(actual, methods) {
+				t.Errorf(`mirror received %v, expected %v`, actual, methods)
+			}
+			for i, h := range mirror.headers {
+				if h.Get(proxy.AuthorizationHeader) != `` || mirror.keys[i] != `` {
+					t.Error(`secret key sent to the mirror`)
+				}
+			}
+			if primary.keys[0] != agent.ExampleWellFormedInvalidKey {
+				t.Errorf(`primary secret key = %q, expected %q`, primary.keys[0], agent.ExampleWellFormedInvalidKey)
+			}
+		})
+	}
+}
+
+func TestSender_MirrorRetryAfter(t *testing.T) {
+	var rejected int32
+	primary := newReportSink(t, func(w http.ResponseWriter) {
+		if atomic.AddInt32(&rejected, 1) == 1 {
+			w.Header().Set(proxy.RetryAfterHeader, `1`)
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	})
+	defer primary.Close()
+	mirror := newReportSink(t, func(http.ResponseWriter) {})
+	defer mirror.Close()
+
+	sender, _ := makeTestSender()
+	sender.LogEndpoint = primary.URL
+	sender.MirrorEndpoint = mirror.URL
+	go sender.Start()
+	sender.Send(makeTestReportLog(http.MethodGet))
+	sender.Stop()
+
+	if actual, expected := primary.received(), []string{http.MethodGet, http.MethodGet}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf(`primary received %v, expected %v`, actual, expected)
+	}
+	if actual, expected := mirror.received(), []string{http.MethodGet}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf(`mirror received %v, expected %v`, actual, expected)
+	}
+}
+
+func TestSender_RetryAfterReusesConnection(t *testing.T) {
+	const rejections = 3
+	var rejected, connections int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(&rejected, 1) <= rejections {
+			w.Header().Set(proxy.RetryAfterHeader, `0`)
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`slow down`))
+		}
+	}))
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	sender, _ := makeTestSender()
+	sender.LogEndpoint = ts.URL
+	go sender.Start()
+	sender.Send(makeTestReportLog(http.MethodGet))
+	sender.Stop()
+
+	if actual, expected := atomic.LoadInt32(&rejected), int32(rejections+1); actual != expected {
+		t.Errorf("%d transmissions, expected %d", actual, expected)
+	}
+	if actual := atomic.LoadInt32(&connections); actual != 1 {
+		t.Errorf("%d connections opened, expected 1 reused across retries", actual)
+	}
+}
+
+func TestSender_StartRateLimitLossReports(t *testing.T) {
+	var m sync.Mutex
+	var received []time.Time
+	var losses int
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		var lr proxy.LogReport
+		_ = json.NewDecoder(r.Body).Decode(&lr)
+		m.Lock()
+		defer m.Unlock()
+		received = append(received, time.Now())
+		for _, rl := range lr.Logs {
+			if rl.Type == proxy.Loss {
+				losses++
+			}
+		}
+	}))
+	defer ts.Close()
+
+	sender, _ := makeTestSender()
+	sender.Client = *ts.Client()
+	sender.LogEndpoint = ts.URL
+	sender.InFlightLimit = 1
+	sender.RateLimit = 1
+	go sender.Start()
+
+	// Beyond the first report, most are lost, and reported as such.
+	for i := 0; i < 5; i++ {
+		sender.Send(makeTestReportLog(http.MethodGet))
+	}
+	sender.Stop()
+
+	m.Lock()
+	defer m.Unlock()
+	if losses == 0 {
+		t.Fatalf(`no loss report received among %d reports`, len(received))
+	}
+	// Loss reports are transmitted within the rate limit, like other reports.
+	for i := 1; i < len(received); i++ {
+		if gap := received[i].Sub(received[i-1]); gap < time.Second*9/10 {
+			t.Errorf(`report %d sent %v after the previous one, expected at least 1s at 1/sec`, i, gap)
+		}
+	}
+}
+
+func TestSender_StopCounter(t *testing.T) {
+	const count = 20
+	ts := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ts.Close()
+
+	for i := 0; i < 10; i++ {
+		sender, _ := makeTestSender()
+		sender.Client = *ts.Client()
+		sender.LogEndpoint = ts.URL
+		go sender.Start()
+		for j := 0; j < count; j++ {
+			sender.Send(makeTestReportLog(http.MethodGet))
+		}
+		sender.Stop()
+
+		// All reports are counted once Stop returns.
+		if sender.Counter != count {
+			t.Fatalf(`Counter = %d after Stop, expected %d`, sender.Counter, count)
+		}
+	}
+}
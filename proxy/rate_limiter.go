@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"math"
+	"time"
+)
+
+// tokenBucket is a minimal token-bucket rate limiter. It is not goroutine-safe,
+// as it is only meant to be used from the Sender background loop.
+//
+// A nil *tokenBucket applies no limit.
+type tokenBucket struct {
+	rate     float64 // Tokens added per second.
+	capacity float64 // Maximum number of tokens accumulated while idle.
+	tokens   float64
+	last     time.Time
+}
+
+// newTokenBucket builds a full token bucket allowing perSecond tokens per
+// second, with a burst capacity of one second worth of tokens, but at least one.
+// It returns nil, meaning no limit, for non-positive rates.
+func newTokenBucket(perSecond float64, now time.Time) *tokenBucket {
+	if perSecond <= 0 {
+		return nil
+	}
+	capacity := math.Max(1, math.Floor(perSecond))
+	return &tokenBucket{
+		rate:     perSecond,
+		capacity: capacity,
+		tokens:   capacity,
+		last:     now,
+	}
+}
+
+// Allow consumes a token and returns true if one is available at the given time.
+func (b *tokenBucket) Allow(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.capacity, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket_Allow(t *testing.T) {
+	t0 := time.Now()
+	tests := []struct {
+		name      string
+		perSecond float64
+		at        time.Duration
+		attempts  int
+		want      int
+	}{
+		{`unlimited`, 0, 0, 100, 100},
+		{`burst`, 5, 0, 10, 5},
+		{`fractional rate`, 0.5, 0, 3, 1},
+		// At 5 per second, 400ms only refill 2 tokens.
+		{`refill`, 5, 400 * time.Millisecond, 10, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTokenBucket(tt.perSecond, t0)
+			if tt.at > 0 {
+				// Drain the bucket, then let it refill.
+				for b.Allow(t0) {
+				}
+			}
+			got := 0
+			for i := 0; i < tt.attempts; i++ {
+				if b.Allow(t0.Add(tt.at)) {
+					got++
+				}
+			}
+			if got != tt.want {
+				t.Errorf("Allow() accepted %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
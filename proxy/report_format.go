@@ -0,0 +1,338 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/protobuf/proto"
+)
+
+//go:generate protoc -I=$PWD/proxy --go_out=$PWD/proxy $PWD/proxy/report.proto
+
+// ReportFormat is the encoding used to transmit LogReport elements.
+type ReportFormat int
+
+const (
+	// FormatJSON encodes reports as JSON. This is the default.
+	FormatJSON ReportFormat = iota
+	// FormatProtobuf encodes reports as a ReportMessage in protobuf wire format.
+	FormatProtobuf
+)
+
+// ContentType returns the content type header value for the format.
+func (f ReportFormat) ContentType() string {
+	if f == FormatProtobuf {
+		return ContentTypeProtobuf
+	}
+	return FullContentTypeJSON
+}
+
+// Marshal encodes a LogReport in the format.
+func (f ReportFormat) Marshal(lr LogReport) ([]byte, error) {
+	switch f {
+	case FormatJSON:
+		return json.Marshal(lr)
+	case FormatProtobuf:
+		return proto.Marshal(lr.ToProto())
+	default:
+		return nil, fmt.Errorf(`unknown report format %d`, f)
+	}
+}
+
+// UnmarshalProtobufReport decodes a LogReport from its protobuf wire format.
+func UnmarshalProtobufReport(b []byte) (LogReport, error) {
+	var m ReportMessage
+	if err := proto.Unmarshal(b, &m); err != nil {
+		return LogReport{}, err
+	}
+	return LogReportFromProto(&m)
+}
+
+// ToProto converts the LogReport to its protobuf form.
+func (lr LogReport) ToProto() *ReportMessage {
+	m := &ReportMessage{
+		SecretKey:   lr.SecretKey,
+		Application: &ApplicationMessage{Environment: lr.Application.Environment},
+		Runtime: &RuntimeMessage{
+			Version:  lr.Runtime.Version,
+			Arch:     lr.Runtime.Arch,
+			Platform: lr.Runtime.Platform,
+			Type:     lr.Runtime.Type,
+			Hostname: lr.Runtime.Hostname,
+		},
+		Agent: &AgentMessage{Type: lr.Agent.Type, Version: lr.Agent.Version},
+	}
+	for _, rl := range lr.Logs {
+		m.Logs = append(m.Logs, rl.ToProto())
+	}
+	return m
+}
+
+// LogReportFromProto converts a ReportMessage back to a LogReport.
+func LogReportFromProto(m *ReportMessage) (LogReport, error) {
+	lr := LogReport{
+		SecretKey:   m.GetSecretKey(),
+		Application: ApplicationReport{Environment: m.GetApplication().GetEnvironment()},
+		Runtime: RuntimeReport{
+			Version:  m.GetRuntime().GetVersion(),
+			Arch:     m.GetRuntime().GetArch(),
+			Platform: m.GetRuntime().GetPlatform(),
+			Type:     m.GetRuntime().GetType(),
+			Hostname: m.GetRuntime().GetHostname(),
+		},
+		Agent: AgentReport{Type: m.GetAgent().GetType(), Version: m.GetAgent().GetVersion()},
+	}
+	for i, rlm := range m.GetLogs() {
+		rl, err := ReportLogFromProto(rlm)
+		if err != nil {
+			return LogReport{}, fmt.Errorf(`decoding log %d: %w`, i, err)
+		}
+		lr.Logs = append(lr.Logs, rl)
+	}
+	return lr, nil
+}
+
+// ToProto converts the ReportLog to its protobuf form.
+//
+// DCR params which cannot be encoded to JSON are dropped.
+func (rl ReportLog) ToProto() *ReportLogMessage {
+	m := &ReportLogMessage{
+		LogLevel:                       rl.LogLevel,
+		StartedAt:                      int64(rl.StartedAt),
+		EndedAt:                        int64(rl.EndedAt),
+		Type:                           rl.Type,
+		StageType:                      rl.Stage,
+		Port:                           uint32(rl.Port),
+		Protocol:                       rl.Protocol,
+		Hostname:                       rl.Hostname,
+		Path:                           rl.Path,
+		Method:                         rl.Method,
+		Url:                            rl.URL,
+		RequestHeaders:                 headerToProto(rl.RequestHeaders),
+		ResponseHeaders:                headerToProto(rl.ResponseHeaders),
+		RequestTrailers:                headerToProto(rl.RequestTrailers),
+		StatusCode:                     int64(rl.StatusCode),
+		RetryCount:                     int64(rl.RetryCount),
+		RequestBody:                    []byte(rl.RequestBody),
+		ResponseBody:                   []byte(rl.ResponseBody),
+		RequestBodyPayloadSha:          rl.RequestBodyPayloadSHA,
+		ResponseBodyPayloadSha:         rl.ResponseBodyPayloadSHA,
+		ErrorCode:                      rl.ErrorCode,
+		ErrorFullMessage:               rl.ErrorFullMessage,
+		Count:                          int64(rl.Count),
+		Anomalies:                      rl.Anomalies,
+		ResolvedAddresses:              rl.ResolvedAddresses,
+		FromCache:                      rl.FromCache,
+		StreamId:                       int64(rl.StreamID),
+		OmittedDataCollectionRules:     int64(rl.OmittedDataCollectionRules),
+		RequestBodyContentType:         rl.RequestBodyContentType,
+		ResponseBodyContentType:        rl.ResponseBodyContentType,
+		RequestBodyBytesRead:           int64(rl.RequestBodyBytesRead),
+		RequestBodyPartial:             rl.RequestBodyPartial,
+		RequestChunked:                 rl.RequestChunked,
+		ResponseChunked:                rl.ResponseChunked,
+		RequestBodyDigests:             rl.RequestBodyDigests,
+		ResponseBodyDigests:            rl.ResponseBodyDigests,
+		RequestBodyLines:               int64(rl.RequestBodyLines),
+		ResponseBodyLines:              int64(rl.ResponseBodyLines),
+		RequestFormFields:              int64(rl.RequestFormFields),
+		RequestFormSize:                int64(rl.RequestFormSize),
+		ResponseFormFields:             int64(rl.ResponseFormFields),
+		ResponseFormSize:               int64(rl.ResponseFormSize),
+		RequestLengthMismatch:          rl.RequestLengthMismatch,
+		ResponseLengthMismatch:         rl.ResponseLengthMismatch,
+		MethodOverride:                 rl.MethodOverride,
+		FinalUrl:                       rl.FinalURL,
+		ConnectionReuse:                rl.ConnectionReuse,
+		ResponseStreaming:              rl.ResponseStreaming,
+		RequestBodySniffedContentType:  rl.RequestBodySniffedContentType,
+		ResponseBodySniffedContentType: rl.ResponseBodySniffedContentType,
+		RequestBodyPreview:             rl.RequestBodyPreview,
+		ResponseBodyPreview:            rl.ResponseBodyPreview,
+	}
+	if rl.ActiveDataCollectionRules != nil {
+		m.HasActiveDataCollectionRules = true
+		for _, dcr := range *rl.ActiveDataCollectionRules {
+			m.ActiveDataCollectionRules = append(m.ActiveDataCollectionRules, dcr.toProto())
+		}
+	}
+	if rl.LogLevelRule != nil {
+		m.LogLevelRule = rl.LogLevelRule.toProto()
+	}
+	if rl.ProxyChain != nil {
+		m.ProxyForwardedFor = rl.ProxyChain.ForwardedFor
+		m.ProxyVia = rl.ProxyChain.Via
+	}
+	m.CustomFields = customFieldsToProto(rl.CustomFields)
+	return m
+}
+
+// toProto converts the ReportDataCollectionRule to its protobuf form, dropping
+// params which cannot be encoded to JSON.
+func (dcr ReportDataCollectionRule) toProto() *DataCollectionRuleMessage {
+	var params []byte
+	if len(dcr.Params) > 0 {
+		params, _ = json.Marshal(dcr.Params)
+	}
+	return &DataCollectionRuleMessage{
+		FilterHash: dcr.FilterHash,
+		Params:     params,
+		Signature:  dcr.Signature,
+	}
+}
+
+// reportDataCollectionRuleFromProto converts a DataCollectionRuleMessage back
+// to a ReportDataCollectionRule.
+func reportDataCollectionRuleFromProto(m *DataCollectionRuleMessage) (ReportDataCollectionRule, error) {
+	dcr := ReportDataCollectionRule{
+		FilterHash: m.GetFilterHash(),
+		Signature:  m.GetSignature(),
+	}
+	if len(m.GetParams()) > 0 {
+		if err := json.Unmarshal(m.GetParams(), &dcr.Params); err != nil {
+			return ReportDataCollectionRule{}, fmt.Errorf(`decoding params for filter %s: %w`, dcr.FilterHash, err)
+		}
+	}
+	return dcr, nil
+}
+
+// ReportLogFromProto converts a ReportLogMessage back to a ReportLog.
+//
+// Since protobuf does not distinguish empty maps from missing ones, empty
+// headers are decoded as nil.
+func ReportLogFromProto(m *ReportLogMessage) (ReportLog, error) {
+	rl := ReportLog{
+		LogLevel:                       m.GetLogLevel(),
+		StartedAt:                      int(m.GetStartedAt()),
+		EndedAt:                        int(m.GetEndedAt()),
+		Type:                           m.GetType(),
+		Stage:                          m.GetStageType(),
+		Port:                           uint16(m.GetPort()),
+		Protocol:                       m.GetProtocol(),
+		Hostname:                       m.GetHostname(),
+		Path:                           m.GetPath(),
+		Method:                         m.GetMethod(),
+		URL:                            m.GetUrl(),
+		RequestHeaders:                 headerFromProto(m.GetRequestHeaders()),
+		ResponseHeaders:                headerFromProto(m.GetResponseHeaders()),
+		RequestTrailers:                headerFromProto(m.GetRequestTrailers()),
+		StatusCode:                     int(m.GetStatusCode()),
+		RetryCount:                     int(m.GetRetryCount()),
+		RequestBody:                    string(m.GetRequestBody()),
+		ResponseBody:                   string(m.GetResponseBody()),
+		RequestBodyPayloadSHA:          m.GetRequestBodyPayloadSha(),
+		ResponseBodyPayloadSHA:         m.GetResponseBodyPayloadSha(),
+		ErrorCode:                      m.GetErrorCode(),
+		ErrorFullMessage:               m.GetErrorFullMessage(),
+		Count:                          int(m.GetCount()),
+		Anomalies:                      m.GetAnomalies(),
+		ResolvedAddresses:              m.GetResolvedAddresses(),
+		FromCache:                      m.GetFromCache(),
+		StreamID:                       int(m.GetStreamId()),
+		OmittedDataCollectionRules:     int(m.GetOmittedDataCollectionRules()),
+		RequestBodyContentType:         m.GetRequestBodyContentType(),
+		ResponseBodyContentType:        m.GetResponseBodyContentType(),
+		RequestBodyBytesRead:           int(m.GetRequestBodyBytesRead()),
+		RequestBodyPartial:             m.GetRequestBodyPartial(),
+		RequestChunked:                 m.GetRequestChunked(),
+		ResponseChunked:                m.GetResponseChunked(),
+		RequestBodyDigests:             m.GetRequestBodyDigests(),
+		ResponseBodyDigests:            m.GetResponseBodyDigests(),
+		RequestBodyLines:               int(m.GetRequestBodyLines()),
+		ResponseBodyLines:              int(m.GetResponseBodyLines()),
+		RequestFormFields:              int(m.GetRequestFormFields()),
+		RequestFormSize:                int(m.GetRequestFormSize()),
+		ResponseFormFields:             int(m.GetResponseFormFields()),
+		ResponseFormSize:               int(m.GetResponseFormSize()),
+		RequestLengthMismatch:          m.GetRequestLengthMismatch(),
+		ResponseLengthMismatch:         m.GetResponseLengthMismatch(),
+		MethodOverride:                 m.GetMethodOverride(),
+		FinalURL:                       m.GetFinalUrl(),
+		ConnectionReuse:                m.GetConnectionReuse(),
+		ResponseStreaming:              m.GetResponseStreaming(),
+		RequestBodySniffedContentType:  m.GetRequestBodySniffedContentType(),
+		ResponseBodySniffedContentType: m.GetResponseBodySniffedContentType(),
+		RequestBodyPreview:             m.GetRequestBodyPreview(),
+		ResponseBodyPreview:            m.GetResponseBodyPreview(),
+	}
+	if m.GetHasActiveDataCollectionRules() {
+		dcrs := make([]ReportDataCollectionRule, 0, len(m.GetActiveDataCollectionRules()))
+		for _, dcrm := range m.GetActiveDataCollectionRules() {
+			dcr, err := reportDataCollectionRuleFromProto(dcrm)
+			if err != nil {
+				return ReportLog{}, err
+			}
+			dcrs = append(dcrs, dcr)
+		}
+		rl.ActiveDataCollectionRules = &dcrs
+	}
+	if m.GetLogLevelRule() != nil {
+		dcr, err := reportDataCollectionRuleFromProto(m.GetLogLevelRule())
+		if err != nil {
+			return ReportLog{}, err
+		}
+		rl.LogLevelRule = &dcr
+	}
+	if len(m.GetProxyForwardedFor()) > 0 || len(m.GetProxyVia()) > 0 {
+		rl.ProxyChain = &ProxyChain{ForwardedFor: m.GetProxyForwardedFor(), Via: m.GetProxyVia()}
+	}
+	customFields, err := customFieldsFromProto(m.GetCustomFields())
+	if err != nil {
+		return ReportLog{}, err
+	}
+	rl.CustomFields = customFields
+	return rl, nil
+}
+
+// customFieldsToProto encodes the custom fields values to JSON, dropping those
+// which cannot be encoded.
+func customFieldsToProto(fields map[string]interface{}) map[string]string {
+	if len(fields) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(fields))
+	for k, v := range fields {
+		if b, err := json.Marshal(v); err == nil {
+			m[k] = string(b)
+		}
+	}
+	return m
+}
+
+func customFieldsFromProto(m map[string]string) (map[string]interface{}, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	fields := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		var value interface{}
+		if err := json.Unmarshal([]byte(v), &value); err != nil {
+			return nil, fmt.Errorf(`decoding custom field %s: %w`, k, err)
+		}
+		fields[k] = value
+	}
+	return fields, nil
+}
+
+func headerToProto(h http.Header) map[string]*HeaderValues {
+	if len(h) == 0 {
+		return nil
+	}
+	m := make(map[string]*HeaderValues, len(h))
+	for k, v := range h {
+		m[k] = &HeaderValues{Values: v}
+	}
+	return m
+}
+
+func headerFromProto(m map[string]*HeaderValues) http.Header {
+	if len(m) == 0 {
+		return nil
+	}
+	h := make(http.Header, len(m))
+	for k, v := range m {
+		h[k] = v.GetValues()
+	}
+	return h
+}
@@ -0,0 +1,159 @@
+package proxy_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bearer/go-agent"
+	"github.com/bearer/go-agent/proxy"
+)
+
+func makeTestLogReport() proxy.LogReport {
+	dcrs := []proxy.ReportDataCollectionRule{{
+		FilterHash: `hash`,
+		Params:     map[string]interface{}{`answer`: 42.0, `name`: `value`},
+		Signature:  `signature`,
+	}}
+	lr := proxy.MakeConfigReport(agent.Version, `test`, agent.ExampleWellFormedInvalidKey)
+	lr.Logs = []proxy.ReportLog{
+		{
+			LogLevel:                  `ALL`,
+			StartedAt:                 1590000000000,
+			EndedAt:                   1590000000100,
+			Type:                      proxy.End,
+			Stage:                     `ClientRequest`,
+			ActiveDataCollectionRules: &dcrs,
+			LogLevelRule:              &dcrs[0],
+			Port:                      443,
+			Protocol:                  `https`,
+			Hostname:                  `api.example.com`,
+			Path:                      `/v1/payments`,
+			Method:                    http.MethodPost,
+			URL:                       `https://api.example.com/v1/payments?id=1`,
+			RequestHeaders:            http.Header{`Accept`: {`application/json`, `text/plain`}},
+			ResponseHeaders:           http.Header{`Content-Type`: {`application/json`}},
+			RequestTrailers:           http.Header{`Checksum`: {`abc`}},
+			ProxyChain: &proxy.ProxyChain{
+				ForwardedFor: []string{`203.0.113.1`, `10.0.0.2`},
+				Via:          []string{`1.1 proxy.example.com`},
+			},
+			StatusCode:                     http.StatusCreated,
+			RetryCount:                     2,
+			Anomalies:                      []string{`anomaly`},
+			ResolvedAddresses:              []string{`127.0.0.1`, `::1`},
+			FromCache:                      true,
+			StreamID:                       3,
+			OmittedDataCollectionRules:     3,
+			RequestBodyLines:               2,
+			ResponseBodyLines:              5,
+			RequestFormFields:              3,
+			RequestFormSize:                24,
+			ResponseFormFields:             1,
+			ResponseFormSize:               7,
+			RequestLengthMismatch:          true,
+			ResponseLengthMismatch:         true,
+			MethodOverride:                 `DELETE`,
+			FinalURL:                       `https://example.com/final`,
+			ConnectionReuse:                `reused`,
+			ResponseStreaming:              true,
+			RequestBodySniffedContentType:  `text/plain; charset=utf-8`,
+			ResponseBodySniffedContentType: `application/json`,
+			CustomFields:                   map[string]interface{}{`orderId`: `o-1`, `tier`: 2.0},
+			RequestBodyBytesRead:           4096,
+			RequestBodyPartial:             true,
+			RequestChunked:                 true,
+			ResponseChunked:                true,
+			RequestBodyDigests:             map[string]string{`md5`: `5eb63bbbe01eeed093cb22bb8f5acdc3`},
+			ResponseBodyDigests:            map[string]string{`sha1`: `2aae6c35c94fcfb415dbe95f408b9ce91ee846ed`},
+			RequestBodyPreview:             `{"name":"[FILTERED]"}`,
+			ResponseBodyPreview:            `{"id":1}`,
+			RequestBody:                    "\xff\xfenot UTF-8",
+			ResponseBody:                   `{"id":1}`,
+			RequestBodyContentType:         `text/plain`,
+			ResponseBodyContentType:        proxy.ContentTypeJSON,
+			RequestBodyPayloadSHA:          `req-sha`,
+			ResponseBodyPayloadSHA:         `res-sha`,
+			Count:                          5,
+		},
+		proxy.NewReportLossReport(3),
+	}
+	return lr
+}
+
+func TestReportFormat_MarshalProtobuf(t *testing.T) {
+	expected := makeTestLogReport()
+	b, err := proxy.FormatProtobuf.Marshal(expected)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	actual, err := proxy.UnmarshalProtobufReport(b)
+	if err != nil {
+		t.Fatalf("UnmarshalProtobufReport() error = %v", err)
+	}
+
+	expectedJSON, _ := json.Marshal(expected)
+	actualJSON, _ := json.Marshal(actual)
+	if string(actualJSON) != string(expectedJSON) {
+		t.Errorf("protobuf round trip JSON = %s, want %s", actualJSON, expectedJSON)
+	}
+	if actual.Logs[0].RequestBody != expected.Logs[0].RequestBody {
+		t.Errorf("non-UTF-8 request body not preserved: got %q", actual.Logs[0].RequestBody)
+	}
+	if actual.Logs[1].ActiveDataCollectionRules != nil {
+		t.Error("missing DCR list decoded as non-nil")
+	}
+}
+
+func TestReportFormat_Marshal(t *testing.T) {
+	tests := []struct {
+		name            string
+		format          proxy.ReportFormat
+		wantContentType string
+		wantErr         bool
+	}{
+		{`json`, proxy.FormatJSON, proxy.FullContentTypeJSON, false},
+		{`protobuf`, proxy.FormatProtobuf, proxy.ContentTypeProtobuf, false},
+		{`sad unknown`, proxy.ReportFormat(42), proxy.FullContentTypeJSON, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := tt.format.ContentType(); actual != tt.wantContentType {
+				t.Errorf("ContentType() = %s, want %s", actual, tt.wantContentType)
+			}
+			if _, err := tt.format.Marshal(makeTestLogReport()); (err != nil) != tt.wantErr {
+				t.Errorf("Marshal() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSender_WriteLogProtobuf(t *testing.T) {
+	var contentType string
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		contentType = request.Header.Get(proxy.ContentTypeHeader)
+		body, _ = ioutil.ReadAll(request.Body)
+	}))
+	defer ts.Close()
+
+	s, _ := makeTestSender()
+	s.Client = *ts.Client()
+	s.LogEndpoint = ts.URL
+	s.Format = proxy.FormatProtobuf
+	rl := makeTestLogReport().Logs[0]
+	s.WriteLog(rl)
+
+	if contentType != proxy.ContentTypeProtobuf {
+		t.Errorf("content type = %s, want %s", contentType, proxy.ContentTypeProtobuf)
+	}
+	lr, err := proxy.UnmarshalProtobufReport(body)
+	if err != nil {
+		t.Fatalf("UnmarshalProtobufReport() error = %v", err)
+	}
+	if len(lr.Logs) != 1 || lr.Logs[0].URL != rl.URL {
+		t.Errorf("unexpected decoded logs: %v", lr.Logs)
+	}
+}
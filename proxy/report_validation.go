@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The ReportLog LogLevel values.
+const (
+	LogLevelDetected   = `DETECTED`
+	LogLevelRestricted = `RESTRICTED`
+	LogLevelAll        = `ALL`
+)
+
+// ErrInvalidReportLog is the error wrapped by ReportLog.Validate errors.
+var ErrInvalidReportLog = errors.New(`invalid report log`)
+
+// Validate checks the invariants of a ReportLog expected by the Bearer platform:
+//   - timestamps are ordered, and not negative
+//   - fields required by the LogLevel are present
+//   - the status code, if any, is a valid HTTP status code.
+//
+// Loss reports are not attached to any API call, and therefore always valid.
+func (rl ReportLog) Validate() error {
+	if rl.Type == Loss {
+		return nil
+	}
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidReportLog, fmt.Sprintf(format, args...))
+	}
+
+	var detailed bool
+	switch strings.ToUpper(rl.LogLevel) {
+	case LogLevelDetected:
+	case LogLevelRestricted, LogLevelAll:
+		detailed = true
+	default:
+		return invalid("unknown log level %q", rl.LogLevel)
+	}
+	if rl.Hostname == `` {
+		return invalid(`missing hostname`)
+	}
+	if rl.StartedAt < 0 || rl.EndedAt < 0 {
+		return invalid("negative timestamps %d-%d", rl.StartedAt, rl.EndedAt)
+	}
+	if rl.EndedAt < rl.StartedAt {
+		return invalid("ended at %d before starting at %d", rl.EndedAt, rl.StartedAt)
+	}
+	if rl.StatusCode != 0 && (rl.StatusCode < http.StatusContinue || rl.StatusCode > 599) {
+		return invalid("status code %d out of range", rl.StatusCode)
+	}
+	if !detailed {
+		return nil
+	}
+
+	if rl.StartedAt == 0 {
+		return invalid(`missing start time`)
+	}
+	if rl.Type != End && rl.Type != Error {
+		return invalid("unknown type %q", rl.Type)
+	}
+	for name, value := range map[string]string{
+		`stage`:  rl.Stage,
+		`method`: rl.Method,
+		`URL`:    rl.URL,
+	} {
+		if value == `` {
+			return invalid("missing %s", name)
+		}
+	}
+	return nil
+}
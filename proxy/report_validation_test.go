@@ -0,0 +1,50 @@
+package proxy_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/bearer/go-agent/proxy"
+)
+
+func TestReportLog_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(rl *proxy.ReportLog)
+		wantErr bool
+	}{
+		{`happy restricted`, func(*proxy.ReportLog) {}, false},
+		{`happy all lowercase`, func(rl *proxy.ReportLog) { rl.LogLevel = `all` }, false},
+		{`happy detected`, func(rl *proxy.ReportLog) {
+			*rl = proxy.ReportLog{LogLevel: proxy.LogLevelDetected, Hostname: `api.example.com`}
+		}, false},
+		{`happy error`, func(rl *proxy.ReportLog) { rl.Type = proxy.Error; rl.StatusCode = 0 }, false},
+		{`happy loss`, func(rl *proxy.ReportLog) { *rl = proxy.NewReportLossReport(3) }, false},
+		{`sad log level`, func(rl *proxy.ReportLog) { rl.LogLevel = `foo` }, true},
+		{`sad hostname`, func(rl *proxy.ReportLog) { rl.Hostname = `` }, true},
+		{`sad negative timestamp`, func(rl *proxy.ReportLog) { rl.StartedAt = -1 }, true},
+		{`sad unordered timestamps`, func(rl *proxy.ReportLog) { rl.EndedAt = rl.StartedAt - 1 }, true},
+		{`sad missing start`, func(rl *proxy.ReportLog) { rl.StartedAt, rl.EndedAt = 0, 0 }, true},
+		{`sad status code low`, func(rl *proxy.ReportLog) { rl.StatusCode = 99 }, true},
+		{`sad status code high`, func(rl *proxy.ReportLog) { rl.StatusCode = 600 }, true},
+		{`sad type`, func(rl *proxy.ReportLog) { rl.Type = `` }, true},
+		{`sad stage`, func(rl *proxy.ReportLog) { rl.Stage = `` }, true},
+		{`sad method`, func(rl *proxy.ReportLog) { rl.Method = `` }, true},
+		{`sad URL`, func(rl *proxy.ReportLog) { rl.URL = `` }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := makeTestReportLog(http.MethodGet)
+			rl.StatusCode = http.StatusOK
+			tt.modify(&rl)
+			err := rl.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, proxy.ErrInvalidReportLog) {
+				t.Errorf("Validate() error = %v, expected to wrap ErrInvalidReportLog", err)
+			}
+		})
+	}
+}
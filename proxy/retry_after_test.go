@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name       string
+		statusCode int
+		value      string
+		want       time.Duration
+		wantOK     bool
+	}{
+		{`seconds`, http.StatusTooManyRequests, `3`, 3 * time.Second, true},
+		{`date`, http.StatusServiceUnavailable, now.Add(time.Minute).Format(http.TimeFormat), time.Minute, true},
+		{`past date`, http.StatusServiceUnavailable, now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{`capped`, http.StatusTooManyRequests, `86400`, MaxRetryAfter, true},
+		{`missing`, http.StatusTooManyRequests, ``, 0, false},
+		{`negative`, http.StatusTooManyRequests, `-1`, 0, false},
+		{`malformed`, http.StatusTooManyRequests, `soon`, 0, false},
+		{`other status`, http.StatusInternalServerError, `3`, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &http.Response{StatusCode: tt.statusCode, Header: http.Header{}}
+			if tt.value != `` {
+				res.Header.Set(RetryAfterHeader, tt.value)
+			}
+			got, ok := retryAfter(res, now)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf(`retryAfter() = %v, %t, want %v, %t`, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// SelfStats describes the resources used by the process running the agent, as
+// sampled by the self-monitoring enabled with WithSelfMonitoring.
+type SelfStats struct {
+	// At is the time of the sample. It is zero until the first sample.
+	At time.Time `json:"at"`
+	// Goroutines is the number of goroutines in the process.
+	Goroutines int `json:"goroutines"`
+	// HeapAlloc and HeapSys are the bytes of allocated heap objects, and of
+	// heap memory obtained from the OS, as in runtime.MemStats.
+	HeapAlloc uint64 `json:"heapAlloc"`
+	HeapSys   uint64 `json:"heapSys"`
+	// ReportsPerSecond is the rate of reports handled by the sender since the
+	// previous sample.
+	ReportsPerSecond float64 `json:"reportsPerSecond"`
+}
+
+// selfMonitor periodically samples the SelfStats.
+type selfMonitor struct {
+	interval time.Duration
+	handled  func() uint
+
+	mu          sync.Mutex
+	stats       SelfStats
+	lastHandled uint
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newSelfMonitor builds a selfMonitor sampling every interval, using handled
+// to count the reports handled by the sender.
+func newSelfMonitor(interval time.Duration, handled func() uint) *selfMonitor {
+	return &selfMonitor{
+		interval: interval,
+		handled:  handled,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// sample updates the stats.
+func (m *selfMonitor) sample(now time.Time) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	handled := m.handled()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rate := 0.0
+	if elapsed := now.Sub(m.stats.At); !m.stats.At.IsZero() && elapsed > 0 && handled >= m.lastHandled {
+		rate = float64(handled-m.lastHandled) / elapsed.Seconds()
+	}
+	m.stats = SelfStats{
+		At:               now,
+		Goroutines:       runtime.NumGoroutine(),
+		HeapAlloc:        mem.HeapAlloc,
+		HeapSys:          mem.HeapSys,
+		ReportsPerSecond: rate,
+	}
+	m.lastHandled = handled
+}
+
+// Stats returns the last sampled stats.
+func (m *selfMonitor) Stats() SelfStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
+
+// Start samples the stats immediately, then every interval, until Stop.
+func (m *selfMonitor) Start() {
+	defer close(m.done)
+	m.sample(time.Now())
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			m.sample(now)
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// Stop ends the sampling loop and waits for it to exit.
+func (m *selfMonitor) Stop() {
+	close(m.stop)
+	<-m.done
+}
+
+// SelfStats returns the last sample of the resources used by the process
+// running the agent, when enabled with WithSelfMonitoring. It returns a zero
+// SelfStats otherwise.
+func (a *Agent) SelfStats() SelfStats {
+	if a.monitor == nil {
+		return SelfStats{}
+	}
+	return a.monitor.Stats()
+}
@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSelfMonitor_sample(t *testing.T) {
+	var handled uint
+	m := newSelfMonitor(time.Minute, func() uint { return handled })
+	if stats := m.Stats(); !stats.At.IsZero() {
+		t.Fatalf("Stats() before sampling = %v, expected zero", stats)
+	}
+
+	t0 := time.Now()
+	handled = 4
+	m.sample(t0)
+	stats := m.Stats()
+	if !stats.At.Equal(t0) {
+		t.Errorf("At = %v, expected %v", stats.At, t0)
+	}
+	if stats.Goroutines <= 0 || stats.HeapAlloc == 0 || stats.HeapSys == 0 {
+		t.Errorf("Stats() = %+v, expected goroutines and heap sizes", stats)
+	}
+	if stats.ReportsPerSecond != 0 {
+		t.Errorf("ReportsPerSecond on first sample = %f, expected 0", stats.ReportsPerSecond)
+	}
+
+	handled = 14
+	m.sample(t0.Add(2 * time.Second))
+	if actual := m.Stats().ReportsPerSecond; actual != 5 {
+		t.Errorf("ReportsPerSecond = %f, expected 5", actual)
+	}
+}
+
+func TestAgent_SelfStats(t *testing.T) {
+	a := Agent{}
+	if stats := a.SelfStats(); !stats.At.IsZero() {
+		t.Errorf("SelfStats() without self-monitoring = %v, expected zero", stats)
+	}
+
+	// A local configuration server keeps the agent enabled.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	const interval = 10 * time.Millisecond
+	b := New(ExampleWellFormedInvalidKey, WithEndpoints(ts.URL, ts.URL), WithSelfMonitoring(interval))
+	defer b.Close()
+	if b.Error() != nil {
+		t.Fatalf("New() error = %v", b.Error())
+	}
+
+	var first time.Time
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		stats := b.SelfStats()
+		switch {
+		case stats.At.IsZero():
+		case first.IsZero():
+			first = stats.At
+			if stats.Goroutines <= 0 || stats.HeapAlloc == 0 {
+				t.Fatalf("SelfStats() = %+v, expected goroutines and heap size", stats)
+			}
+		case stats.At.After(first):
+			return
+		}
+		time.Sleep(interval / 2)
+	}
+	t.Errorf("SelfStats() not updated within a second: first sample at %v", first)
+}